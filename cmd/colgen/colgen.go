@@ -12,60 +12,206 @@
 // Flags:
 // -list: use List suffix for collection, default false.
 // -imports: use custom imports: e.g pkg/db, pkg/domain.
+// -force: overwrite generated files that were edited after generation (detected by `// colgen:sha256:` header).
+// -strict-imports: fail if custom imports from -imports are not used by generated code.
+// -allow-unexported: allow custom rules exposing unexported fields via exported methods, e.g. Index(secret).
+// -rename-field-methods: add List suffix to collection methods named as entity fields, e.g. NamesList() for Names field.
+// -emit-sql-scan: generate sql.Scanner and driver.Valuer (JSON) for collections, e.g. for PostgreSQL jsonb columns.
+// -verbose: print verbose messages, e.g. skipped optional rules and vet findings.
+// -tags: comma-separated build tags for package loading. Directives of files excluded by build constraints are skipped.
+// -ai-system-prompt-file: use system prompt from file for all assistant modes.
+// -stats, -stats-json: print run summary as table or JSON.
+// -jobs: number of files generated concurrently in multi-file run `colgen -jobs N <file.go>...`, default GOMAXPROCS.
+// -errors-json: print errors as JSON to stderr. Exit codes: 1 generic, 2 parse, 3 package/type, 4 assistant error.
+// -test-directives: process directives of _test.go files. Directives of test and generated files are skipped by default.
+// -no-ai: disable AI features, assistant directives fail. Also COLGEN_NO_AI=1, DisableAI in ~/.colgen or colgen_noai build tag.
+// -report-names: print collection names of entities with reason before generation: News => NewsList, Tag => Tags.
+// Flags of `//go:generate colgen ...` line of the file are applied if they are not set on the command line.
 //
 // Base Generators (by default) will be created for `//colgen:<struct>,<struct>,...`.
 // - Collection type `type <structs> []<struct>` and methods for this type:
 // - IDs() []<id type>: if ID field exists. Returns all IDs in slice.
 // - Index() map[<id type>]<struct>: if ID filed exists. Returns all structs as map[ID]struct.
 //
+// Output file of directives is <file>_colgen.go, `//colgen[heavy_colgen.go,!tinygo]:Analytics,Event` routes directives
+// to another generated file in the same dir with optional build constraint as its first line.
+//
 // Custom generators
 // - `Index` can accept another field for creating index. By default, it is ID.
+// - `IndexExact(Name)` is equivalent to `Index(Name)`: one element per key.
+// - `ByField(Field)`: same as `Index(Field)`, but method is named By<Field>. Preferred in new code.
 // - `Group` can accept field for group by operation.
+// - `Index(ParentID)`, `Group(ParentID,zeronil)`: pointer fields are keyed by value, nil is skipped or keyed by zero value.
+// - `IndexMultiPtr` can accept field for group by operation with pointers to the original slice elements.
+// - `Append(Field)`, `IDsAppend`: same as <Field>, but appends values to a caller-provided slice.
 // - <Field>: collect all values from field.
+// - `Field(Author.Company.Name)`: AuthorCompanyNames() through nested fields, elements with nil Author or Company are skipped.
+// - `FieldOr(Author.Company.Name,"unknown")`: AuthorCompanyNamesOr() with "unknown" for elements with nil pointers of path.
+// - `Exclude(0,999)`, `ExcludeDeleted(0,999)`: returns collection without elements with ID in static list of values.
+// - `Len`: generates Len() int and IsEmpty() bool methods.
+// - `Apply(processor.Enrich)`: generates Apply(fn func(*T)) and Enrich() methods, package import is added automatically.
+// - `First`, `Last`: return first/last element or ErrEmptyCollection, which is declared in generated file.
+// - `Sortable(Title)`: implements sort.Interface (Len, Swap, Less) by field.
+// - `Missing(TagIDs,Tag)`: MissingTagIDs(tags Tags) []int with values of field not present in tags by ID.
+// - `Attach(Tags,TagIDs,Tags)`: AttachTags(tags Tags) fills Tags of elements in place from tags by TagIDs.
+// - `Merge`: Merge(other) returns new collection, elements of other replace same-ID elements, new IDs are appended.
+// - `Sort(-CreatedAt,ID)`: returns stably sorted copy by keys, minus for descending order, e.g. SortByCreatedAtDescID().
+// - `Delta(Quantity)`: returns difference of numeric field between ll and previous snapshot by ID.
+// - `Avg(Price)`: returns arithmetic mean of integer or float field as float64, 0 for empty collection.
+// - `StdDev(Price)`: returns population standard deviation of integer or float field, 0 for less than two elements.
+// - `Accumulate(Price)`: returns running totals of numeric field, e.g. AccumulatedPrices().
+// - `Partition(Active)`: splits collection by bool field into matched and the rest.
+// - `Rule?`: optional rule, e.g. `Index(Slug)?`, is skipped if field is missing. Use -verbose to see skipped rules.
 // - Unique<Field>: collect unique values from field.
+// - `Distinct(Title)`: collect unique values from field in order of first occurrence.
+// - `Sparse(AuthorID)`: index elements with non-zero field only.
+// - `UniqueSorted(TagIDs)`: collect unique values from field sorted in ascending order.
+// - `GroupSorted(CategoryID)`: group elements by field with sorted group keys.
+// - `CacheKey`: generate deterministic cache key of entity from exported fields.
+// - `Hash`: generate FNV hash of entity from exported fields.
+// - `Paginate`: return page of collection with PaginationMeta.
+// - `IndexInto`, `GroupInto(CategoryID)`: fill existing map with index or groups for reuse between calls.
+// - `Shuffle`: return a new collection with elements in random order, ShuffleWith(rnd) of seeded *rand.Rand.
+// - `Synced`: SyncedNewsList wrapper with sync.RWMutex for concurrent use: Add, All, Get by ID and Len.
+// - `TakeWhile(Published)`, `DropWhile(Published)`: return prefix with field equal to value or the rest after it.
+// - `FlattenSections`: collect values of slice field ([]T or [][]T) of all elements.
+// - `FlattenSelf(SubCategories)`: collect values of recursive slice field ([]T of T) of all elements at any depth.
+// - `Unique(Name,fold,trim)`: unique string values of field compared case-insensitively with trimmed spaces in order of first occurrence.
+// - `Associate(URL,Title)`: map of key field to value field of elements.
+// - `Keys(Metadata)`, `Values(Metadata)`: keys or values of map field of all elements.
+// - `Pairs(ID,Title)`: pairs of two fields as []NewsIDTitlePair in order of elements.
+// - `Pivot(Month,Amount)`: values of value field grouped by key field.
+// - `WithTitle`: copy of entity with Title replaced.
+// - `Index(Slug())`: Index, Unique and Group accept method with no params and one result instead of field.
 // - MapP: `func NewUsers(in []<arg>) <structs> { return <func pkg>MapP(in, New<struct>) }`
 // - Map: same as MapP. Map or MapP can accept package or struct as arg. Can be lower for private constructors.
+// - `MapP(db.Row)`: interface input is used as is, constructor of the package must accept it: NewNews(r *db.Row) *News.
+//
+// AI mode via //go:generate
+// //colgen@ai:<readme|review|tests>(<deepseek|claude>)
+// //colgen@ai:readme(claude,temp=0.4,preset=concise): temperature and system prompt preset from ~/.colgen Presets.
+// //colgen@ai:commitmsg(claude): prints commit message for generated changes, same as -commitmsg flag.
+// //colgen@ai:upgrade(claude,format=file|apply): modernization to current Go idioms, apply replaces file after confirmation.
+//
+// One-off assistant run without directive: `colgen -ai-run=review -ai-provider=claude [-ai-file news.go]`, file defaults to $GOFILE.
+//
+// Fallback = ["claude", "deepseek"] in ~/.colgen: the same prompt is sent to these assistants in order if assistant fails.
+//
+// Health check of assistants with configured keys: `colgen ai ping [assistant]`.
+//
+// Likely mistakes in directives are reported by `colgen vet [<file.go>...]` and with -verbose, e.g. CG001 for entity
+// without ID field. Findings don't fail generation and are suppressed by `//colgen:nolint:CG001`.
+//
+// Directives upgrade to the current syntax: `colgen migrate [-dry-run] <file|dir|./...>`.
+// Spaces between rules are removed, rule names and Map/MapP casing are canonicalized, e.g. index(ID) => Index(ID).
 //
 // Inline mode via //go:generate
 // //colgen@NewCall(db)
 // //colgen@newUserSummary(newsportal.User,full,json)
+// //colgen@newUserSummary(newsportal.User,full,order=alpha): fields sorted by name instead of source order.
+// //colgen@NewUser(db,full,opts): NewUser(in *db.User, opts ...UserOption) applies options after field mapping.
 package main
 
 import (
 	"bufio"
 	"bytes"
+	"cmp"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/vmkteam/colgen/pkg/colgen"
 
 	"github.com/BurntSushi/toml"
+	"golang.org/x/tools/go/packages"
 )
 
 var (
 	flList      = flag.Bool("list", false, "use List suffix for collection")
 	flImports   = flag.String("imports", "", "use custom imports: e.g pkg/db, pkg/domain")
+	flForce     = flag.Bool("force", false, "overwrite generated files with manual edits")
+	flStrict    = flag.Bool("strict-imports", false, "fail if custom imports from -imports are not used by generated code")
+	flUnexport  = flag.Bool("allow-unexported", false, "allow custom rules exposing unexported fields via exported methods")
+	flRenameMth = flag.Bool("rename-field-methods", false, "add List suffix to collection methods named as entity fields")
 	flFuncPkg   = flag.String("funcpkg", "", "use funcpkg for Map & MapP functions")
+	flSQLScan   = flag.Bool("emit-sql-scan", false, "generate sql.Scanner and driver.Valuer (JSON) for collections")
 	flWriteKey  = flag.String("write-key", "", "write assistant key to ~/.colgen file")
-	flAssistant = flag.String("ai", "", "use it to redefining assistant while writing a key to ~/.colgen file")
+	flDeleteKey = flag.Bool("delete-key", false, "delete assistant key (chosen by -ai) from ~/.colgen file")
+	flAssistant = flag.String("ai", "", "use it to redefining assistant while writing a key to ~/.colgen file or with -commitmsg")
+	flCommitMsg = flag.Bool("commitmsg", false, "print commit message for generated changes using assistant from -ai flag")
 	flVersion   = flag.Bool("v", false, "print version and exit")
+	flVerbose   = flag.Bool("verbose", false, "print verbose messages, e.g. skipped optional rules")
+	flTags      = flag.String("tags", "", "comma-separated build tags for package loading and build constraints of files, e.g. integration")
+
+	flStats     = flag.Bool("stats", false, "print run summary: entities, methods, bytes written, load time and tokens")
+	flStatsJSON = flag.Bool("stats-json", false, "print run summary as JSON")
+
+	flJobs        = flag.Int("jobs", runtime.GOMAXPROCS(0), "number of files generated concurrently in multi-file run: colgen -jobs N <file.go>...")
+	flErrorsJSON  = flag.Bool("errors-json", false, "print errors as JSON to stderr: kind, file, line and message")
+	flTestDirs    = flag.Bool("test-directives", false, "process colgen directives of _test.go files, they are skipped by default")
+	flReportNames = flag.Bool("report-names", false, "print collection names of entities before generation, e.g. News => NewsList")
+
+	flSystemPromptFile = flag.String("ai-system-prompt-file", "", "path to file containing custom system prompt for all assistant modes")
+
+	flAIRun      = flag.String("ai-run", "", "run assistant mode on file without //colgen@ai: directive: review, readme, tests, upgrade, e.g. -ai-run=upgrade(apply)")
+	flAIProvider = flag.String("ai-provider", "", "assistant for -ai-run, default is -ai or deepseek")
+	flAIFile     = flag.String("ai-file", "", "file for -ai-run, default is $GOFILE")
+	flNoAI       = flag.Bool("no-ai", false, "disable AI features: assistant directives fail, same as COLGEN_NO_AI=1 or DisableAI in ~/.colgen")
 )
 
 const (
 	configFile = ".colgen"
 )
 
+// ErrInvalidAIPrompt is returned by extractAIPrompts for malformed assistant directives.
+var ErrInvalidAIPrompt = errors.New("invalid AI prompt")
+
+// ErrAIDisabled is returned for assistant directives and commands if AI features are disabled by policy.
+var ErrAIDisabled = errors.New("AI features are disabled")
+
+// noAIEnv disables AI features if set to any value except false, e.g. COLGEN_NO_AI=1.
+const noAIEnv = "COLGEN_NO_AI"
+
 // Config represents the configuration for colgen tool including API keys for different assistants.
 type Config struct {
 	DeepSeekKey string
 	ClaudeKey   string
+
+	// Keys are API keys of other registered assistants by name, e.g. `Keys = { openai = "..." }`.
+	Keys map[string]string
+
+	// MaxPromptBytes overrides prompt budget by assistant name, e.g. `MaxPromptBytes = { claude = 400000 }`.
+	MaxPromptBytes map[string]int
+
+	// Presets are named system prompt variants for assistant directives, e.g. `Presets = { concise = "..." }`
+	// used by `//colgen@ai:readme(claude,preset=concise)`.
+	Presets map[string]string
+
+	// AIFrontMatter prefixes assistant markdown outputs (review, readme, upgrade) with YAML front matter:
+	// source file, package, provider, model, mode, colgen version and timestamp. Tests never have it.
+	AIFrontMatter bool
+
+	// Fallback is an ordered list of assistants called with the same prompt if assistant of directive fails,
+	// e.g. `Fallback = ["claude", "deepseek"]`. Assistant of directive and assistants without keys are skipped.
+	Fallback []string
+
+	// DisableAI disables AI features: assistant directives and commands fail, so no code is sent to assistants.
+	DisableAI bool
 }
 
 // fillByName sets the API key for the specified assistant name.
@@ -81,12 +227,69 @@ func (cfg *Config) fillByName(name colgen.AssistantName, key string) error {
 	case colgen.AssistantClaude:
 		cfg.ClaudeKey = key
 	default:
-		return fmt.Errorf("unknown assistant name=%s", name)
+		if !slices.Contains(colgen.AssistantNames(), name) {
+			return fmt.Errorf("unknown assistant name=%s", name)
+		}
+		if cfg.Keys == nil {
+			cfg.Keys = make(map[string]string)
+		}
+		cfg.Keys[string(name)] = key
+	}
+
+	return nil
+}
+
+// assistants returns names of assistants with non-empty keys.
+func (cfg *Config) assistants() []colgen.AssistantName {
+	var names []colgen.AssistantName
+	for _, an := range colgen.AssistantNames() {
+		if cfg.keyByName(an) != "" {
+			names = append(names, an)
+		}
 	}
 
+	return names
+}
+
+// fallbacks returns assistants of Fallback for primary assistant in order. Primary assistant is skipped,
+// assistants without keys are returned too: they are skipped by Assistant. Unknown names are returned as error.
+func (cfg *Config) fallbacks(primary colgen.AssistantName) ([]*colgen.Assistant, error) {
+	var r []*colgen.Assistant
+	for _, name := range cfg.Fallback {
+		an := colgen.AssistantName(name)
+		if an == primary {
+			continue
+		}
+
+		aa, err := colgen.NewAssistant(an, cfg.keyByName(an))
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %w", err)
+		}
+
+		r = append(r, aa)
+	}
+
+	return r, nil
+}
+
+// setFallbacks sets fallback assistants from config to assistant aa of primary assistant.
+func setFallbacks(aa *colgen.Assistant, cfg Config, primary colgen.AssistantName) error {
+	fbs, err := cfg.fallbacks(primary)
+	if err != nil {
+		return err
+	}
+
+	aa.SetFallbacks(fbs...)
 	return nil
 }
 
+// logProvider logs assistant that produced the answer if it is a fallback of primary assistant.
+func logProvider(aa *colgen.Assistant, primary colgen.AssistantName) {
+	if p := aa.Provider(); p != primary {
+		log.Printf("%s failed, answer is produced by fallback %s", primary, p)
+	}
+}
+
 // keyByName returns the API key for the specified assistant name.
 // Returns empty string if assistant name is unknown.
 func (cfg *Config) keyByName(name colgen.AssistantName) string {
@@ -97,76 +300,353 @@ func (cfg *Config) keyByName(name colgen.AssistantName) string {
 		return cfg.ClaudeKey
 	}
 
-	return ""
+	return cfg.Keys[string(name)]
 }
 
-// exitOnErr logs the error and exits the program if error is not nil.
-func exitOnErr(err error) {
-	if err != nil {
-		log.Fatal("generation failed: ", err)
+// printStats prints run summary if -stats or -stats-json flag is set.
+func printStats(st *runStats) {
+	if !*flStats && !*flStatsJSON {
+		return
 	}
+
+	exitOnErr(st.write(os.Stdout, *flStatsJSON))
 }
 
 func main() {
 	log.SetFlags(log.Lshortfile)
+	flag.Usage = func() { printUsage(flag.CommandLine.Output()) }
 	flag.Parse()
 
 	switch {
 	case *flVersion:
-		fmt.Printf("colgen version: %v\n", appVersion())
+		exitOnErr(printVersion(os.Stdout, appVersion(), false))
 		return // quit
 	case *flWriteKey != "":
 		err := writeConfig(*flWriteKey, colgen.AssistantName(*flAssistant))
 		exitOnErr(err)
 		return // quits
+	case *flDeleteKey:
+		err := deleteKey(colgen.AssistantName(*flAssistant))
+		exitOnErr(err)
+		return // quits
+	}
+
+	// version subcommand: colgen version [--json]
+	if flag.Arg(0) == "version" {
+		asJSON := slices.Contains(flag.Args()[1:], "--json") || slices.Contains(flag.Args()[1:], "-json")
+		exitOnErr(printVersion(os.Stdout, appVersion(), asJSON))
+		return
+	}
+
+	// doctor reads config itself and reports its errors: colgen doctor [-online]
+	if flag.Arg(0) == "doctor" {
+		online := slices.Contains(flag.Args()[1:], "--online") || slices.Contains(flag.Args()[1:], "-online")
+		exitOnErr(runDoctor(os.Stdout, ".", online))
+		return
+	}
+
+	// migrate doesn't need config: colgen migrate [-dry-run] <file|dir|./...>
+	if flag.Arg(0) == "migrate" {
+		exitOnErr(runMigrate(os.Stdout, flag.Args()[1:]))
+		return
+	}
+
+	// vet doesn't need config: colgen vet [<file.go>...]
+	if flag.Arg(0) == "vet" {
+		exitOnErr(runVet(os.Stdout, flag.Args()[1:]))
+		return
 	}
 
 	// read config
 	cfg, err := readConfig()
 	exitOnErr(err)
 
+	// AI features can be disabled by build, flag, env or config
+	noAI := aiDisabledBy(cfg)
+
+	// subcommands
+	if flag.Arg(0) == "ai" {
+		exitOnErr(aiDisabledErr(noAI))
+		exitOnErr(runAI(cfg, flag.Args()[1:], os.Stdout))
+		return
+	}
+
+	// assistant mode from flags bypasses directives: colgen -ai-run=review -ai-provider=claude [-ai-file news.go]
+	if *flAIRun != "" {
+		exitOnErr(aiDisabledErr(noAI))
+		am, an, opts, filename, err := aiRunFlags(*flAIRun, cmp.Or(*flAIProvider, *flAssistant), *flAIFile)
+		exitOnErr(err)
+
+		var st runStats
+		defer printStats(&st)
+		now := time.Now()
+		log.Println("assisting: ", *flAIRun)
+		assistFile(newRunContext(cfg, flag.CommandLine, filename, colgenLines{}, &st), am, an, opts)
+		log.Println("assisting done", time.Since(now))
+		return
+	}
+
 	// set filename from go:generate
 	filename := os.Getenv("GOFILE")
+
+	// multi-file run: colgen -jobs 4 a/a.go b/b.go
+	if filename == "" && flag.NArg() > 0 {
+		var st runStats
+		defer printStats(&st)
+		for _, r := range generateFiles(log.Writer(), flag.Args(), *flJobs, cfg) {
+			exitOnErr(r.err)
+			st.addGenerator(r.stats)
+		}
+		return
+	}
+
 	if filename == "" {
-		log.Fatal("GOFILE environment variable is not set. Run via `go generate`")
+		exitOnErr(errors.New("GOFILE environment variable is not set. Run via `go generate`"))
 	}
 
 	// get colgen lines from file
 	cl, err := readFile(filename)
 	exitOnErr(err)
+	if cl.excluded {
+		if *flVerbose || cl.skipped == skipTest {
+			log.Println(excludedMessage(filename, cl.skipped))
+		}
+		return
+	}
+
+	var st runStats
+	defer printStats(&st)
+	rc := newRunContext(cfg, flag.CommandLine, filename, cl, &st)
+
+	// flags of go:generate line are applied, if colgen is run without them, e.g. GOFILE=news.go colgen
+	applied, err := applyGenerateArgs(rc.flags, cl.generateArgs)
+	exitOnErr(withFile(err, filename, cl))
+	if *flVerbose && len(applied) > 0 {
+		log.Printf("flags of go:generate line are applied: %s", strings.Join(applied, ", "))
+	}
+
+	// assistant directives fail before generation if AI features are disabled, -no-ai may be set by go:generate line
+	noAI = aiDisabledBy(cfg)
+	exitOnErr(checkAssistantLines(cl, filename, noAI))
+	if *flCommitMsg {
+		exitOnErr(aiDisabledErr(noAI))
+	}
 
 	// if assistant was found, process only one instruction
+	commitMsg, commitAssistant := *flCommitMsg, colgen.AssistantName(*flAssistant)
+	var commitOpts aiOptions
 	if len(cl.assistant) > 0 {
-		now := time.Now()
-		log.Println("assisting: ", cl.assistant[0])
-		assistFile(cfg, cl.assistant[0], filename)
-		log.Println("assisting done", time.Since(now))
-		return
+		am, an, opts, err := extractAIPrompts(cl.assistant[0])
+		exitOnErr(withFile(err, filename, cl))
+
+		if am != colgen.ModeCommitMsg {
+			now := time.Now()
+			log.Println("assisting: ", cl.assistant[0])
+			assistFile(rc, am, an, opts)
+			log.Println("assisting done", time.Since(now))
+			return
+		}
+
+		// generate commit message after generation
+		commitMsg, commitAssistant, commitOpts = true, an, opts
 	}
 
+	var changes []colgen.FileDiff
 	if len(cl.injection) > 0 {
 		log.Println("replacing injections")
-		replaceFile(cl, filename)
+		changes = append(changes, replaceFile(rc))
 	}
 
-	if len(cl.lines) == 0 {
+	switch {
+	case len(cl.lines) > 0:
+		changes = append(changes, generateFile(rc, cl))
+	case len(cl.routes) == 0:
 		log.Println("no colgen lines found")
+	}
+
+	for _, rl := range cl.routes {
+		changes = append(changes, generateFile(rc, rl))
+	}
+
+	if commitMsg {
+		printCommitMsg(cfg, commitAssistant, commitOpts, changes, &st)
+	}
+}
+
+// usageExamples is a runtime help for common workflows. Keep it in sync with generators.
+const usageExamples = `Usage: colgen [flags]
+       colgen [-jobs N] <file.go>...
+       colgen ai ping [assistant]
+       colgen doctor [-online]
+       colgen migrate [-dry-run] <file|dir|./...>
+       colgen vet [<file.go>...]
+       colgen version [--json]
+
+colgen is run via go generate and processes $GOFILE.
+Files from arguments are generated concurrently when run outside of go generate.
+Flags of //go:generate colgen line of the file are applied if they are not set.
+
+Basic collections:
+	//go:generate colgen
+	//colgen:News,Tag
+	  => type NewsList []News
+	     func (ll NewsList) IDs() []int
+	     func (ll NewsList) Index() map[int]News
+
+Custom rules:
+	//colgen:News:TagIDs,UniqueTagIDs,Index(CategoryID),Group(CategoryID),MapP(db)
+	  => func (ll NewsList) TagIDs() [][]int
+	     func (ll NewsList) UniqueTagIDs() []int
+	     func (ll NewsList) IndexByCategoryID() map[int]News
+	     func (ll NewsList) GroupByCategoryID() map[int]NewsList
+	     func NewNewsList(in []db.News) NewsList
+	//colgen:News:Field(Author.Company.Name),FieldOr(Author.Company.Name,"unknown")
+	  => func (ll NewsList) AuthorCompanyNames() []string
+	     func (ll NewsList) AuthorCompanyNamesOr() []string
+
+Output file with build constraint:
+	//colgen[heavy_colgen.go,!tinygo]:Analytics,Event
+	  => heavy_colgen.go with //go:build !tinygo
+
+Inline replacement:
+	//colgen@NewUser(db)
+	  => type User struct { db.User }
+	     func NewUser(in *db.User) *User
+	//colgen@newUserSummary(db.User,full,json)
+	  => type UserSummary struct { <all exported fields with json tags> }
+	//colgen@NewUser(db,full,opts)
+	  => type UserOption func(*User)
+	     func NewUser(in *db.User, opts ...UserOption) *User
+
+AI assistant (deepseek by default, claude):
+	colgen -write-key=<key> -ai=claude
+	//colgen@ai:review(claude)    => <file>.go.md
+	//colgen@ai:readme            => <file>.go.md
+	//colgen@ai:tests(deepseek)   => <file>_test.go
+	//colgen@ai:commitmsg(claude) => commit message for generated changes to stdout
+	//colgen@ai:upgrade(claude)   => <file>.go.upgrade.md with diff to current Go idioms
+	//colgen@ai:upgrade(claude,apply) => replaces <file>.go after confirmation
+	colgen -ai-run=review -ai-provider=claude -ai-file=news.go => news.go.md without directive
+
+Flags:
+`
+
+// printUsage prints usage examples and flags.
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, usageExamples)
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+}
+
+// runAI runs `colgen ai <command>` subcommands.
+func runAI(cfg Config, args []string, w io.Writer) error {
+	if len(args) == 0 || args[0] != "ping" {
+		return errors.New("usage: colgen ai ping [assistant]")
+	}
+
+	names := cfg.assistants()
+	if len(args) > 1 {
+		names = []colgen.AssistantName{colgen.AssistantName(args[1])}
+	}
+
+	if len(names) == 0 {
+		return errors.New("no assistant keys found, use `colgen -write-key=<key> -ai=<assistant>`")
+	}
+
+	return pingAssistants(cfg, names, w)
+}
+
+// pingAssistants performs health check for each assistant and returns error if any of them has failed.
+func pingAssistants(cfg Config, names []colgen.AssistantName, w io.Writer) error {
+	var failed []string
+	for _, an := range names {
+		aa, err := colgen.NewAssistant(an, cfg.keyByName(an))
+		if err != nil {
+			return err
+		}
+
+		r := aa.Ping()
+		fmt.Fprintf(w, "%s: %s model=%s latency=%v\n", an, r.Status, r.Model, r.Latency.Round(time.Millisecond))
+		if r.Status != colgen.PingOK {
+			fmt.Fprintf(w, "  %s\n", r.Diagnosis)
+			failed = append(failed, string(an))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("ping failed: %s", strings.Join(failed, ","))
+	}
+
+	return nil
+}
+
+// printCommitMsg prints commit message for changed files to stdout.
+func printCommitMsg(cfg Config, an colgen.AssistantName, opts aiOptions, changes []colgen.FileDiff, st *runStats) {
+	prompt := colgen.UserPromptForCommitMsg(changes)
+	if prompt == "" {
+		log.Println("no changes for commit message")
 		return
 	}
-	generateFile(cl, filename)
+
+	if an == "" {
+		an = colgen.AssistantDeepSeek
+	}
+
+	aa, err := colgen.NewAssistant(an, cfg.keyByName(an))
+	exitOnErr(err)
+	exitOnErr(setSystemPrompt(aa, *flSystemPromptFile))
+	exitOnErr(applyAIOptions(aa, cfg, opts))
+	exitOnErr(setFallbacks(aa, cfg, an))
+
+	r, err := aa.Generate(colgen.ModeCommitMsg, prompt)
+	exitOnErr(err)
+	st.addUsage(aa.Usage())
+	logProvider(aa, an)
+
+	fmt.Println(r)
 }
 
-func assistFile(cfg Config, assistPrompt, filename string) {
-	am, an, err := extractAIPrompts(assistPrompt)
+// setSystemPrompt overrides assistant system prompt with content of file, if filename is set.
+// Filename is relative to current working directory.
+func setSystemPrompt(aa *colgen.Assistant, filename string) error {
+	if filename == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		exitOnErr(err)
+		return fmt.Errorf("read system prompt file: %w", err)
 	}
 
+	if strings.TrimSpace(string(data)) == "" {
+		return fmt.Errorf("system prompt file %s is empty", filename)
+	}
+
+	aa.SetSystemPrompt(string(data))
+	return nil
+}
+
+// withGeneratedMode is a tests mode suffix to include generated files, e.g. tests+generated(claude).
+const withGeneratedMode = "+generated"
+
+func assistFile(rc *runContext, am colgen.AssistMode, an colgen.AssistantName, opts aiOptions) {
+	cfg, filename, st := rc.cfg, rc.filename, rc.st
 	aa, err := colgen.NewAssistant(an, cfg.keyByName(an))
 	if err != nil {
 		exitOnErr(err)
 	}
 
+	if n := cfg.MaxPromptBytes[string(an)]; n > 0 {
+		aa.SetMaxPromptBytes(n)
+	}
+	exitOnErr(setSystemPrompt(aa, *flSystemPromptFile))
+	exitOnErr(applyAIOptions(aa, cfg, opts))
+	exitOnErr(setFallbacks(aa, cfg, an))
+	defer func() { st.addUsage(aa.Usage()); logProvider(aa, an) }()
+
+	// tests+generated allows tests for generated files
+	mode, withGenerated := strings.CutSuffix(string(am), withGeneratedMode)
+	am = colgen.AssistMode(mode)
 	if err = aa.IsValidMode(am); err != nil {
 		exitOnErr(err)
 	}
@@ -174,15 +654,35 @@ func assistFile(cfg Config, assistPrompt, filename string) {
 	content, err := os.ReadFile(filename)
 	exitOnErr(err)
 
+	// add generated methods to prompt as context
+	if am != colgen.ModeCommitMsg {
+		rc.setGeneratedMethods(aa)
+	}
+
+	// markdown outputs have front matter if enabled
+	fm := newFrontMatter(cfg, aa, an, am, filename, content)
+
+	// upgrade writes suggestions or replaces file after confirmation
+	if am == colgen.ModeUpgrade {
+		exitOnErr(upgradeFile(aa, opts, fm, filename, content, os.Stdin, os.Stderr))
+		return
+	}
+
 	// normal cases
 	if am != colgen.ModeTests {
 		r, err := aa.Generate(am, string(content))
 		exitOnErr(err)
 
 		// write file
-		err = os.WriteFile(filename+".md", []byte(r), os.ModePerm)
+		err = writeMarkdown(filename+".md", []byte(r), fm)
 		exitOnErr(err)
 	} else { // tests
+		ok, err := colgen.IsTestContextFile(filename, content, withGenerated)
+		exitOnErr(err)
+		if !ok {
+			exitOnErr(fmt.Errorf("%w: %s is generated or excluded by build tags, use tests%s to override", colgen.ErrSkippedTestFile, filename, withGeneratedMode))
+		}
+
 		tp, err := colgen.UserPromptForTests(content, filename)
 		exitOnErr(err)
 
@@ -190,121 +690,570 @@ func assistFile(cfg Config, assistPrompt, filename string) {
 		exitOnErr(err)
 
 		if tp.AppendToFile {
-			file, er := os.OpenFile(tp.TestFilename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-			exitOnErr(er)
-			defer file.Close()
-
-			_, er = file.WriteString(r)
-			exitOnErr(er)
+			exitOnErr(appendTests(tp.TestFilename, []byte(r)))
 			return
 		}
 
 		// full
-		err = os.WriteFile(tp.TestFilename, []byte(r), os.ModePerm)
+		err = os.WriteFile(tp.TestFilename, []byte(r), 0644)
 		exitOnErr(err)
 	}
 }
 
-// extractAIPrompts Extracts AI mode and name if specified.
-// name is specified in parentheses like a function argument. Uses "deepseek" by default.
+// aiDisabledBy returns source of policy disabling AI features: colgen_noai build, -no-ai flag, COLGEN_NO_AI env
+// or DisableAI of config. AI features are allowed if it is empty.
+func aiDisabledBy(cfg Config) string {
+	switch {
+	case colgen.NoAI:
+		return "colgen_noai build"
+	case *flNoAI:
+		return "-no-ai flag"
+	case isSetEnv(os.Getenv(noAIEnv)):
+		return noAIEnv + " environment variable"
+	case cfg.DisableAI:
+		return "DisableAI in ~/.colgen"
+	}
+
+	return ""
+}
+
+// isSetEnv checks that env value is set to any value except false, e.g. 1, true or yes.
+func isSetEnv(v string) bool {
+	if v == "" {
+		return false
+	}
+
+	b, err := strconv.ParseBool(v)
+	return err != nil || b
+}
+
+// aiDisabledErr returns ErrAIDisabled with policy source or nil if AI features are allowed.
+func aiDisabledErr(by string) error {
+	if by == "" {
+		return nil
+	}
+
+	return fmt.Errorf("%w by %s: code must not leave the machine, assistant directives and commands are not allowed", ErrAIDisabled, by)
+}
+
+// checkAssistantLines returns ErrAIDisabled for assistant directives of file if AI features are disabled by policy.
+func checkAssistantLines(cl colgenLines, filename, noAI string) error {
+	if len(cl.assistant) == 0 {
+		return nil
+	}
+
+	if err := aiDisabledErr(noAI); err != nil {
+		return withFile(fmt.Errorf("%w, remove %s%s", err, colgen.AssistantPrefix, cl.assistant[0]), filename, cl)
+	}
+
+	return nil
+}
+
+// aiRunFlags returns assistant mode, name, options and file of -ai-run flags. Run is a mode with optional options
+// as in directive, e.g. upgrade(apply), provider is added as assistant name. Filename defaults to $GOFILE.
+func aiRunFlags(run, provider, filename string) (colgen.AssistMode, colgen.AssistantName, aiOptions, string, error) {
+	prompt := run
+	if provider != "" {
+		if idx := strings.Index(run, "("); idx == -1 {
+			prompt = run + "(" + provider + ")"
+		} else {
+			prompt = run[:idx+1] + provider + "," + run[idx+1:]
+		}
+	}
+
+	am, an, opts, err := extractAIPrompts(prompt)
+	if err != nil {
+		return "", "", nil, "", err
+	}
+
+	// commit message needs generated changes
+	mode, _ := strings.CutSuffix(string(am), withGeneratedMode)
+	switch colgen.AssistMode(mode) {
+	case colgen.ModeReview, colgen.ModeReadme, colgen.ModeTests, colgen.ModeUpgrade:
+	case colgen.ModeCommitMsg:
+		return "", "", nil, "", fmt.Errorf("%w: %s with -ai-run, use -commitmsg", colgen.ErrUnsupportedAssistMode, mode)
+	default:
+		return "", "", nil, "", fmt.Errorf("%w: %s", colgen.ErrUnsupportedAssistMode, mode)
+	}
+
+	filename = cmp.Or(filename, os.Getenv("GOFILE"))
+	if filename == "" {
+		return "", "", nil, "", errors.New("no file for -ai-run, use -ai-file or run via `go generate`")
+	}
+
+	fi, err := os.Stat(filename)
+	switch {
+	case err != nil:
+		return "", "", nil, "", fmt.Errorf("ai-run: %w", err)
+	case fi.IsDir():
+		return "", "", nil, "", fmt.Errorf("ai-run: %s is a directory", filename)
+	}
+
+	return am, an, opts, filename, nil
+}
+
+// appendTests appends tests from assistant to existing test file: package clause is removed and imports are merged.
+func appendTests(filename string, tests []byte) error {
+	existing, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	r, err := colgen.AppendTests(existing, tests)
+	if err != nil {
+		return fmt.Errorf("append tests to %s: %w", filename, err)
+	}
+
+	return os.WriteFile(filename, r, 0644)
+}
+
+// upgradeSuffix is a suffix of file with upgrade suggestions, e.g. news.go.upgrade.md.
+const upgradeSuffix = ".upgrade.md"
+
+// upgradeFile writes modernization of file by assistant to <file>.upgrade.md: unified diff by default or full file
+// with format=file. With apply option upgraded file is validated, gofmt-ed and replaces the source after confirmation,
+// declined upgrade is written to <file>.upgrade.md. Front matter fm is added to <file>.upgrade.md if not nil.
+func upgradeFile(aa *colgen.Assistant, opts aiOptions, fm *frontMatter, filename string, content []byte, in io.Reader, out io.Writer) error {
+	_, apply := opts[aiOptApply]
+	if !apply && opts[aiOptFormat] != upgradeFormatFile {
+		r, err := aa.Upgrade(string(content))
+		if err != nil {
+			return err
+		}
+
+		return writeMarkdown(filename+upgradeSuffix, []byte(r), fm)
+	}
+
+	r, err := aa.UpgradeFile(string(content))
+	if err != nil {
+		return err
+	}
+
+	if !apply {
+		return writeMarkdown(filename+upgradeSuffix, []byte("```go\n"+strings.TrimSpace(r)+"\n```\n"), fm)
+	}
+
+	upgraded, err := colgen.FormatUpgrade(content, []byte(r))
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	diff := colgen.UnifiedDiff(filename, content, upgraded)
+	if diff == "" {
+		log.Println("upgrade: no changes for", filename)
+		return nil
+	}
+
+	fmt.Fprint(out, diff)
+	if !confirm(in, out, fmt.Sprintf("overwrite %s?", filename)) {
+		log.Println("upgrade is not applied, see", filename+upgradeSuffix)
+		return writeMarkdown(filename+upgradeSuffix, []byte("```diff\n"+diff+"```\n"), fm)
+	}
+
+	return os.WriteFile(filename, upgraded, 0644)
+}
+
+// confirm asks question and reads answer from in. Only y or yes is a confirmation, e.g. non-interactive run
+// with closed stdin is declined.
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+
+	return false
+}
+
+// Assistant directive options, e.g. //colgen@ai:readme(claude,temp=0.4,preset=concise).
+const (
+	aiOptTemp   = "temp"   // temperature of assistant calls: range depends on assistant, e.g. 0..1 for claude
+	aiOptPreset = "preset" // system prompt preset from config
+	aiOptFormat = "format" // upgrade result: diff (default) or file
+	aiOptApply  = "apply"  // upgrade replaces source file after confirmation, it is set without value
+
+	upgradeFormatDiff = "diff"
+	upgradeFormatFile = "file"
+)
+
+// aiOptions are key=value arguments of assistant directive.
+type aiOptions map[string]string
+
+// extractAIPrompts Extracts AI mode, name and options if specified.
+// name and key=value options are specified in parentheses like function arguments. Uses "deepseek" by default.
+// Known options are temp (temperature in range of assistant) and preset (system prompt preset from config).
+// Upgrade mode also accepts format (diff or file) and apply without value.
 // example:
 //
-//	"readme(deepseek)" -> "readme", "deepseek", nil
-//	review(claude)     -> "review", "claude",   nil
-//	tests()            -> "tests",  "deepseek", nil
-//	readme             -> "readme", "deepseek", nil
-//	invalid            -> "",       "deepseek", nil
+//	"readme(deepseek)"              -> "readme", "deepseek", nil, nil
+//	review(claude)                  -> "review", "claude",   nil, nil
+//	tests()                         -> "tests",  "deepseek", nil, nil
+//	readme                          -> "readme", "deepseek", nil, nil
+//	invalid                         -> "",       "deepseek", nil, nil
+//	readme(claude,temp=0.4)         -> "readme", "claude",   {temp: 0.4}, nil
+//	readme(preset=concise)          -> "readme", "deepseek", {preset: concise}, nil
 //
-//	readme(invalid)    -> "", "", error
-//	readme)(invalid)   -> "", "", error
-//	readme)(invalid    -> "", "", error
-//	readme(invalid     -> "", "", error
-func extractAIPrompts(aiPrompt string) (mode colgen.AssistMode, name colgen.AssistantName, err error) {
+//	readme(invalid)                 -> "", "", nil, error
+//	readme)(invalid)                -> "", "", nil, error
+//	readme)(invalid                 -> "", "", nil, error
+//	readme(invalid                  -> "", "", nil, error
+//	upgrade(claude,apply)           -> "upgrade", "claude", {apply: true}, nil
+//	readme(claude,top=1)            -> "", "", nil, error
+//	readme(claude,apply)            -> "", "", nil, error
+func extractAIPrompts(aiPrompt string) (mode colgen.AssistMode, name colgen.AssistantName, opts aiOptions, err error) {
 	name = colgen.AssistantDeepSeek
 
-	aiPrompt = strings.ReplaceAll(aiPrompt, " ", "")
+	aiPrompt = strings.ReplaceAll(strings.TrimSpace(aiPrompt), " ", "")
 	// No parenthesis found — return mode and default assistant
 	idx := strings.Index(aiPrompt, "(")
 	if idx == -1 {
-		return colgen.AssistMode(aiPrompt), name, nil
+		return colgen.AssistMode(aiPrompt), name, nil, nil
 	}
 
 	// If it contains, rewrite mode
 	mode = colgen.AssistMode(aiPrompt[:idx])
 	// Try to find closing parenthesis
 	endIdx := strings.Index(aiPrompt, ")")
-	if endIdx == -1 || endIdx < idx {
-		return "", "", errors.New("invalid AI prompt, \")\" is not found or has invalid position")
+	switch {
+	case endIdx == -1:
+		return "", "", nil, fmt.Errorf("closing parenthesis not found: %w", ErrInvalidAIPrompt)
+	case endIdx < idx:
+		return "", "", nil, fmt.Errorf("closing parenthesis before opening: %w", ErrInvalidAIPrompt)
+	case endIdx != len(aiPrompt)-1:
+		return "", "", nil, fmt.Errorf("unexpected %q after closing parenthesis: %w", aiPrompt[endIdx+1:], ErrInvalidAIPrompt)
+	}
+
+	// Extract name and options between parentheses
+	hasName := false
+	for _, arg := range strings.Split(aiPrompt[idx+1:endIdx], ",") {
+		arg = strings.TrimSpace(arg)
+		key, value, isOpt := strings.Cut(arg, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case arg == "":
+			continue
+		case arg == aiOptApply:
+			key, value = aiOptApply, "true"
+		case !isOpt && hasName:
+			return "", "", nil, fmt.Errorf("duplicate assistant name %q: %w", arg, ErrInvalidAIPrompt)
+		case !isOpt:
+			name, hasName = colgen.AssistantName(arg), true
+			continue
+		}
+
+		if err = validateAIOption(mode, key, value); err != nil {
+			return "", "", nil, err
+		}
+
+		if _, ok := opts[key]; ok {
+			return "", "", nil, fmt.Errorf("duplicate option %q: %w", key, ErrInvalidAIPrompt)
+		}
+
+		if opts == nil {
+			opts = make(aiOptions)
+		}
+		opts[key] = value
+	}
+
+	if v, ok := opts[aiOptTemp]; ok {
+		if err = validateTemperature(name, v); err != nil {
+			return "", "", nil, err
+		}
+	}
+
+	return mode, name, opts, nil
+}
+
+// validateAIOption checks that option key is known for mode and its value is valid.
+func validateAIOption(mode colgen.AssistMode, key, value string) error {
+	if (key == aiOptFormat || key == aiOptApply) && mode != colgen.ModeUpgrade {
+		return fmt.Errorf("option %s is supported only by %s mode: %w", key, colgen.ModeUpgrade, ErrInvalidAIPrompt)
+	}
+
+	switch key {
+	case aiOptTemp: // range depends on assistant, see validateTemperature
+	case aiOptPreset:
+		if value == "" {
+			return fmt.Errorf("option %s is empty: %w", key, ErrInvalidAIPrompt)
+		}
+	case aiOptFormat:
+		if value != upgradeFormatDiff && value != upgradeFormatFile {
+			return fmt.Errorf("option %s=%q must be %s or %s: %w", key, value, upgradeFormatDiff, upgradeFormatFile, ErrInvalidAIPrompt)
+		}
+	case aiOptApply:
+	default:
+		return fmt.Errorf("unknown option %q, expected %s, %s, %s or %s: %w", key, aiOptTemp, aiOptPreset, aiOptFormat, aiOptApply, ErrInvalidAIPrompt)
+	}
+
+	return nil
+}
+
+// validateTemperature checks that temperature is a number in range of assistant from registry.
+// Unknown assistant is not checked here, it is reported on assistant creation.
+func validateTemperature(name colgen.AssistantName, value string) error {
+	maxTemp, err := colgen.MaxTemperature(name)
+	if err != nil {
+		maxTemp = colgen.DefaultMaxTemperature
+	}
+
+	t, err := strconv.ParseFloat(value, 64)
+	if err != nil || t < 0 || t > maxTemp {
+		return fmt.Errorf("option %s=%q must be a number in 0..%v for %s: %w", aiOptTemp, value, maxTemp, name, ErrInvalidAIPrompt)
+	}
+
+	return nil
+}
+
+// applyAIOptions sets temperature and system prompt preset of assistant from directive options.
+// Preset must be defined in config, it overrides system prompt from -ai-system-prompt-file.
+func applyAIOptions(aa *colgen.Assistant, cfg Config, opts aiOptions) error {
+	if v, ok := opts[aiOptTemp]; ok {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("option %s=%q: %w", aiOptTemp, v, ErrInvalidAIPrompt)
+		}
+		aa.SetTemperature(t)
 	}
 
-	// Extract name between parentheses
-	sName := aiPrompt[idx+1 : endIdx]
-	if sName != "" {
-		name = colgen.AssistantName(sName)
+	if v, ok := opts[aiOptPreset]; ok {
+		prompt, ok := cfg.Presets[v]
+		if !ok || strings.TrimSpace(prompt) == "" {
+			return fmt.Errorf("preset %q is not found in ~/%s: %w", v, configFile, ErrInvalidAIPrompt)
+		}
+		aa.SetSystemPrompt(prompt)
 	}
 
-	return
+	return nil
 }
 
-func replaceFile(cl colgenLines, filename string) {
+// replaceFile replaces injections in file and returns its contents before and after replacement.
+// Package of run is loaded again on next use if file was changed.
+func replaceFile(rc *runContext) colgen.FileDiff {
+	cl, filename, st := rc.cl, rc.filename, rc.st
 	r := colgen.NewReplacer()
 	// load go packages
-	err := r.UsePackageDir(filepath.Dir(filename))
-	exitOnErr(err)
+	pkg, err := rc.loadPackage()
+	exitOnErr(withFile(err, filename, cl))
+	log.Println("loaded", r.UsePackage(pkg))
 
 	rr, err := r.Generate(cl.injection)
-	exitOnErr(err)
+	exitOnErr(withFile(err, filename, cl))
 
 	// read file
 	content, err := os.ReadFile(filename)
 	exitOnErr(err)
+	fd := colgen.FileDiff{Filename: filename, Before: bytes.Clone(content)}
 
 	// replace
-	for _, r := range rr {
-		content = bytes.ReplaceAll(content, []byte(r.Find), []byte(r.Replace))
-	}
+	content, err = r.Apply(content, rr)
+	exitOnErr(withFile(err, filename, cl))
 
 	// write file
-	err = os.WriteFile(filename, content, os.ModePerm)
+	err = os.WriteFile(filename, content, 0644)
 	exitOnErr(err)
+	st.Injections += len(rr)
+	st.BytesWritten += len(content)
+	if !bytes.Equal(fd.Before, content) {
+		rc.resetPackage()
+	}
+
+	fd.After = content
+	return fd
+}
+
+// generateFile generates colgen file of lines with package of run and returns its contents before and after generation.
+func generateFile(rc *runContext, cl colgenLines) colgen.FileDiff {
+	r := generate(cl, rc.filename, rc.loadPackage, log.Printf)
+	exitOnErr(r.err)
+
+	rc.st.addGenerator(r.stats)
+	return r.fd
+}
+
+// genResult is a result of generation for a single file.
+type genResult struct {
+	fd    colgen.FileDiff
+	stats colgen.Stats
+	err   error
 }
 
-func generateFile(cl colgenLines, filename string) {
+// generate generates colgen file of lines next to filename and returns its contents before and after generation.
+// Routed lines are generated to their output file with build constraint. It uses own Generator, so it can be called concurrently for different files. Messages are written with logf.
+// Package is taken from load if not nil, otherwise it is loaded by generator.
+func generate(cl colgenLines, filename string, load func() (*packages.Package, error), logf func(format string, args ...any)) (r genResult) {
+	defer func() { r.err = withFile(r.err, filename, cl) }()
+
 	// init generator and rules
-	g := colgen.NewGenerator(cl.pkgName, *flImports, *flFuncPkg, appVersion())
+	g := colgen.NewGenerator(cl.pkgName, *flImports, *flFuncPkg, appVersion().String())
+	g.SetSQLScan(*flSQLScan)
+	g.SetStrictImports(*flStrict)
+	g.SetAllowUnexported(*flUnexport)
+	g.SetRenameFieldMethods(*flRenameMth)
+	g.SetBuildTags(buildTags())
+	g.SetOutputFile(cl.outputFile(filename))
+	g.SetBuildConstraint(cl.output.Constraint)
+	if *flVerbose {
+		g.SetVerbose(logf)
+	}
 	rules, err := colgen.ParseRules(cl.lines, *flList)
-	exitOnErr(err)
+	if err != nil {
+		return genResult{err: err}
+	}
+
+	// collection names are reported before generation to review naming, e.g. News => NewsList
+	if *flReportNames || *flVerbose {
+		for _, en := range colgen.EntityNames(rules) {
+			logf("name: %s: %s", filename, en)
+		}
+	}
 
 	// load go packages
-	err = g.UsePackageDir(filepath.Dir(filename))
-	exitOnErr(err)
+	if load == nil {
+		err = g.UsePackageDir(filepath.Dir(filename))
+	} else {
+		var pkg *packages.Package
+		if pkg, err = load(); err == nil {
+			g.UsePackage(pkg)
+		}
+	}
+	if err != nil {
+		return genResult{err: err}
+	}
 
-	// generate code
-	data, err := g.Generate(rules)
-	exitOnErr(err)
+	// save previous contents for diff
+	fd := colgen.FileDiff{Filename: filepath.Join(filepath.Dir(filename), cl.outputFile(filename))}
+	if prev, err := os.ReadFile(fd.Filename); err == nil {
+		fd.Before = prev
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return genResult{err: err}
+	}
+
+	// refuse to overwrite manual edits
+	if err = colgen.VerifyHash(fd.Before); err != nil && !*flForce {
+		return genResult{err: fmt.Errorf("%s: %w, use -force to overwrite", fd.Filename, err)}
+	}
 
-	// try to save formatted file
-	formatted, err := g.Format()
+	// generate formatted code directly to file
+	var after bytes.Buffer
+	err = writeFileAtomic(fd.Filename, 0644, func(w io.Writer) error {
+		err := g.GenerateTo(rules, io.MultiWriter(w, &after))
+		if errors.Is(err, colgen.ErrFormat) {
+			logf("failed to format: %v", err)
+			logf("saving anyway")
+			return nil
+		}
+		return err
+	})
 	if err != nil {
-		log.Println("failed to format:", err)
-		log.Println("saving anyway")
-	} else {
-		data = formatted
+		return genResult{err: err}
 	}
 
-	// save file to FS
-	err = os.WriteFile(baseName(filename)+"_colgen.go", data, os.ModePerm)
-	exitOnErr(err)
+	fd.After = after.Bytes()
+	for _, imp := range g.UnusedImports() {
+		logf("warning: import %q is not used by generated code, use -strict-imports to fail", imp)
+	}
+
+	if *flVerbose {
+		logFindings(g, rules, cl, filename, logf)
+	}
+
+	return genResult{fd: fd, stats: g.Stats()}
+}
+
+// writeFileAtomic writes file via temporary file in the same dir and rename,
+// so existing file is never left partially written.
+func writeFileAtomic(filename string, perm os.FileMode, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
 }
 
 type colgenLines struct {
 	lines     []string
+	lineNums  []int // line numbers of lines in file
 	injection []string
 	assistant []string
 	pkgName   string
+	warnings  []string // advisory messages, e.g. duplicate go:generate lines
+	excluded  bool     // directives of file are skipped: file is excluded by build constraints, generated or test file
+	skipped   string   // reason of excluded file, empty for build constraints
+
+	output colgen.Output // generated file of routed lines, empty for default <file>_colgen.go
+	routes []colgenLines // lines routed to other generated files by //colgen[<file>,<constraint>]:
+
+	generateArgs []string // colgen args of `//go:generate colgen` line: the first one or the line of $GOLINE
+}
+
+// outputFile returns name of generated file of lines.
+func (cl colgenLines) outputFile(filename string) string {
+	if cl.output.Filename != "" {
+		return cl.output.Filename
+	}
+
+	return baseName(filename) + generatedSuffix
+}
+
+// addRoute adds routed line to lines of its output. Constraints of the same output must match.
+func (cl *colgenLines) addRoute(filename, line string, lineNum int) error {
+	out, rest, err := colgen.CutRoute(line)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%s:%d: %w", filename, lineNum, err)
+	case out.Filename == baseName(filename)+generatedSuffix:
+		return fmt.Errorf("%s:%d: %w: %s is default output, use %s", filename, lineNum, colgen.ErrInvalidArg, out.Filename, colgen.ColgenPrefix)
+	}
+
+	for i := range cl.routes {
+		r := &cl.routes[i]
+		if r.output.Filename != out.Filename {
+			continue
+		}
+
+		switch {
+		case r.output.Constraint == "":
+			r.output.Constraint = out.Constraint
+		case out.Constraint != "" && out.Constraint != r.output.Constraint:
+			return fmt.Errorf("%s:%d: %w: %s has build constraints %q and %q", filename, lineNum, colgen.ErrInvalidArg, out.Filename, r.output.Constraint, out.Constraint)
+		}
+
+		r.lines = append(r.lines, rest)
+		r.lineNums = append(r.lineNums, lineNum)
+		return nil
+	}
+
+	cl.routes = append(cl.routes, colgenLines{output: out, lines: []string{rest}, lineNums: []int{lineNum}})
+	return nil
 }
 
+const (
+	goGeneratePrefix = "//go:generate "
+	generatedSuffix  = "_colgen.go"
+)
+
 // readFile parses file line by line and returns all colgen lines without prefix.
+// Warnings about duplicate `//go:generate colgen` lines and circular generation are logged and returned in result.
+// Directives of files excluded by build constraints for current platform and -tags are skipped, as well as
+// directives of generated files and _test.go files (unless -test-directives is set): they are docs or examples there.
+// Routed lines `//colgen[heavy_colgen.go,!tinygo]:Analytics` are returned in routes by output file.
 func readFile(filename string) (result colgenLines, err error) {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -312,15 +1261,47 @@ func readFile(filename string) (result colgenLines, err error) {
 	}
 	defer f.Close()
 
+	ok, err := colgen.MatchBuildTags(filename, buildTags())
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		result.excluded = true
+		return result, nil
+	}
+
+	if result.skipped = skipDirectives(filename); result.skipped != "" {
+		result.excluded = true
+		return result, nil
+	}
+
+	// detect circular generation: colgen should not be run on its own output
+	if strings.HasSuffix(filename, generatedSuffix) {
+		result.warnings = append(result.warnings, fmt.Sprintf("%s looks like colgen output, generation may be circular", filename))
+	}
+
+	generateLines, lineNum := 0, 0
 	s := bufio.NewScanner(f)
 	for s.Scan() {
 		line := s.Text()
+		lineNum++
 		// is it possible to get package from gopackages, but we will do it in simple way.
 		if strings.HasPrefix(line, "package ") {
 			result.pkgName = strings.TrimPrefix(line, "package ")
 		}
 
 		switch {
+		// count go:generate lines and keep args of running one
+		case strings.HasPrefix(line, goGeneratePrefix) && strings.Contains(line, "colgen"):
+			generateLines++
+			if generateLines == 1 || isGoLine(filename, lineNum) {
+				if result.generateArgs, err = generateArgs(line); err != nil {
+					result.warnings = append(result.warnings, fmt.Sprintf("%s:%d: go:generate args are skipped: %v", filename, lineNum, err))
+				}
+			}
+		// detect imports of generated files
+		case strings.Contains(line, generatedSuffix) && strings.Contains(line, `"`):
+			result.warnings = append(result.warnings, fmt.Sprintf("%s references %q, generation may be circular", filename, strings.TrimSpace(line)))
 		// find assistant lines
 		case strings.HasPrefix(line, colgen.AssistantPrefix):
 			if l, ok := strings.CutPrefix(line, colgen.AssistantPrefix); ok {
@@ -329,74 +1310,305 @@ func readFile(filename string) (result colgenLines, err error) {
 		// find injection lines
 		case strings.HasPrefix(line, colgen.InjectionPrefix):
 			result.injection = append(result.injection, line)
+		// find routed lines
+		case strings.HasPrefix(line, colgen.RoutePrefix):
+			if err = result.addRoute(filename, line, lineNum); err != nil {
+				return result, err
+			}
 		// find normal lines
 		case strings.HasPrefix(line, colgen.ColgenPrefix):
 			if l, ok := strings.CutPrefix(line, colgen.ColgenPrefix); ok {
 				result.lines = append(result.lines, l)
+				result.lineNums = append(result.lineNums, lineNum)
 			}
 		}
 	}
 
+	for i := range result.routes {
+		result.routes[i].pkgName = result.pkgName
+	}
+
+	if generateLines > 1 {
+		result.warnings = append(result.warnings, fmt.Sprintf("%s has %d `//go:generate colgen` lines, colgen will run %d times", filename, generateLines, generateLines))
+	}
+
+	for _, w := range result.warnings {
+		log.Println("warning:", w)
+	}
+
 	return result, s.Err()
 }
 
+// isGoLine checks that line of file is the //go:generate line run by go generate: $GOFILE and $GOLINE.
+func isGoLine(filename string, lineNum int) bool {
+	return os.Getenv("GOFILE") == filepath.Base(filename) && os.Getenv("GOLINE") == strconv.Itoa(lineNum)
+}
+
+// generateArgs returns args of colgen command of `//go:generate` line, words are split and expanded as go generate
+// does: `//go:generate go run ../cmd/colgen -list -funcpkg util` => [-list -funcpkg util].
+// Line without colgen command, e.g. `//go:generate sh -c "colgen"`, has no args.
+func generateArgs(line string) ([]string, error) {
+	var words []string
+	for s := strings.TrimSpace(strings.TrimPrefix(line, goGeneratePrefix)); s != ""; s = strings.TrimSpace(s) {
+		if s[0] != '"' {
+			i := strings.IndexAny(s, " \t")
+			if i < 0 {
+				i = len(s)
+			}
+			words, s = append(words, os.ExpandEnv(s[:i])), s[i:]
+			continue
+		}
+
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", s)
+		}
+
+		w, _ := strconv.Unquote(q)
+		words, s = append(words, os.ExpandEnv(w)), s[len(q):]
+	}
+
+	for i, w := range words {
+		// colgen, ../cmd/colgen or github.com/vmkteam/colgen/cmd/colgen@latest
+		if cmd, _, _ := strings.Cut(w, "@"); path.Base(cmd) == "colgen" {
+			return words[i+1:], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// applyGenerateArgs sets flags of fs from args of `//go:generate` line, flags set on command line are kept.
+// It returns names of applied flags. Args after the first non-flag arg are skipped.
+func applyGenerateArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var applied []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		f := fs.Lookup(name)
+		if f == nil {
+			return applied, fmt.Errorf("unknown flag %s of go:generate line", arg)
+		}
+
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() && !hasValue {
+			value = "true"
+		} else if !hasValue {
+			if i++; i == len(args) {
+				return applied, fmt.Errorf("flag %s of go:generate line needs a value", arg)
+			}
+			value = args[i]
+		}
+
+		if set[name] {
+			continue
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return applied, fmt.Errorf("invalid value %q of flag %s of go:generate line: %w", value, arg, err)
+		}
+		applied = append(applied, name)
+	}
+
+	return applied, nil
+}
+
+// buildTags returns build tags from -tags flag.
+func buildTags() []string {
+	var tags []string
+	for _, t := range strings.Split(*flTags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// excludedMessage returns verbose message about file skipped by build constraints or reason of skipped directives.
+func excludedMessage(filename, reason string) string {
+	return fmt.Sprintf("skipping %s: %s", filename, cmp.Or(reason, "excluded by build constraints, use -tags to enable it"))
+}
+
+// Reasons of skipped directives of file, see skipDirectives.
+const (
+	skipGenerated = "generated file, its directives are not processed"
+	skipTest      = "test file, use -test-directives to process its directives"
+)
+
+// skipDirectives returns reason to skip directives of file or empty string: directives in generated files (with
+// `// Code generated ... DO NOT EDIT.` header) and _test.go files are not live, e.g. provenance comments of
+// generated code or examples in docs. Directives of test files are processed with -test-directives.
+func skipDirectives(filename string) string {
+	if strings.HasSuffix(filename, "_test.go") && !*flTestDirs {
+		return skipTest
+	}
+
+	// files without package clause are reported by generation
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err == nil && ast.IsGenerated(f) {
+		return skipGenerated
+	}
+
+	return ""
+}
+
 // baseName returns baseName from path without extension.
 func baseName(path string) string {
 	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
 }
 
-// appVersion returns app version from VCS info.
-func appVersion() string {
-	result := "devel"
+// VersionInfo contains colgen build info.
+type VersionInfo struct {
+	Version string `json:"version"`
+	Go      string `json:"go"`
+	Arch    string `json:"arch"`
+	OS      string `json:"os"`
+	Built   string `json:"built,omitempty"` // VCS commit time
+}
+
+// String returns version for logs and generated code.
+func (vi VersionInfo) String() string {
+	return vi.Version
+}
+
+// appVersion returns app version info from build and VCS info.
+func appVersion() VersionInfo {
+	vi := VersionInfo{Version: "devel", Go: runtime.Version(), Arch: runtime.GOARCH, OS: runtime.GOOS}
 	info, ok := debug.ReadBuildInfo()
 	if !ok {
-		return result
-	}
-
-	if info.Main.Version != "" {
-		return info.Main.Version
+		return vi
 	}
 
+	vi.Go = info.GoVersion
+	revision := ""
 	for _, v := range info.Settings {
-		if v.Key == "vcs.revision" {
-			result = v.Value
+		switch v.Key {
+		case "vcs.revision":
+			revision = v.Value
+		case "vcs.time":
+			vi.Built = v.Value
+		case "GOARCH":
+			vi.Arch = v.Value
+		case "GOOS":
+			vi.OS = v.Value
 		}
 	}
 
-	if len(result) > 8 {
-		result = result[:8]
+	switch {
+	// (devel) is set for go run and go build in module
+	case info.Main.Version != "" && info.Main.Version != "(devel)":
+		vi.Version = info.Main.Version
+	case len(revision) > 8:
+		vi.Version = revision[:8]
+	case revision != "":
+		vi.Version = revision
+	}
+
+	return vi
+}
+
+// printVersion prints version as text or as JSON for `colgen version [--json]`.
+func printVersion(w io.Writer, vi VersionInfo, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintf(w, "colgen version: %v\n", vi)
+		return err
 	}
 
-	return result
+	return json.NewEncoder(w).Encode(vi)
 }
 
-// writeConfig creates config in home dir.
+// writeConfig sets assistant key in config in home dir. Other keys and custom fields are preserved.
 func writeConfig(key string, name colgen.AssistantName) error {
-	cp, err := configPath()
+	field, err := keyField(name)
 	if err != nil {
 		return err
 	}
 
-	// Open existing config file not to erase existing keys
-	cfg, err := readConfig()
+	return updateConfig(func(raw map[string]any) {
+		if field != keysField {
+			raw[field] = key
+			return
+		}
+
+		keys, _ := raw[keysField].(map[string]any)
+		if keys == nil {
+			keys = make(map[string]any)
+		}
+		keys[string(name)] = key
+		raw[keysField] = keys
+	})
+}
+
+// deleteKey removes assistant key from config in home dir. Other keys and custom fields are preserved.
+func deleteKey(name colgen.AssistantName) error {
+	field, err := keyField(name)
 	if err != nil {
-		return fmt.Errorf("reading config: %w", err)
+		return err
 	}
 
-	// Set default deepseek assistant
-	if name == "" {
-		name = colgen.AssistantDeepSeek
+	return updateConfig(func(raw map[string]any) {
+		if field != keysField {
+			delete(raw, field)
+			return
+		}
+
+		if keys, ok := raw[keysField].(map[string]any); ok {
+			delete(keys, string(name))
+		}
+	})
+}
+
+// keysField is config field for keys of registered assistants without own field.
+const keysField = "Keys"
+
+// keyField returns config field name for assistant key. Uses deepseek by default.
+// Other registered assistants are stored in Keys.
+func keyField(name colgen.AssistantName) (string, error) {
+	switch name {
+	case colgen.AssistantDeepSeek, "":
+		return "DeepSeekKey", nil
+	case colgen.AssistantClaude:
+		return "ClaudeKey", nil
 	}
 
-	// Set needed key by assistant name
-	if err = cfg.fillByName(name, key); err != nil {
+	if slices.Contains(colgen.AssistantNames(), name) {
+		return keysField, nil
+	}
+
+	return "", fmt.Errorf("unknown assistant name=%s", name)
+}
+
+// updateConfig decodes config as raw map, applies update and writes it back with 0600 permissions.
+// Unknown fields are preserved, comments are not.
+func updateConfig(update func(raw map[string]any)) error {
+	cp, err := configPath()
+	if err != nil {
 		return err
 	}
 
+	// Open existing config file not to erase existing keys
+	raw := make(map[string]any)
+	if _, err = os.Stat(cp); err == nil {
+		if _, err = toml.DecodeFile(cp, &raw); err != nil {
+			return fmt.Errorf("reading config: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading config: %w", err)
+	}
+
+	update(raw)
+
 	// Write result
 	var buf bytes.Buffer
 	enc := toml.NewEncoder(&buf)
-	if err := enc.Encode(cfg); err != nil {
+	if err := enc.Encode(raw); err != nil {
 		return fmt.Errorf("create config failed: %w", err)
 	}
 
@@ -404,10 +1616,15 @@ func writeConfig(key string, name colgen.AssistantName) error {
 		return fmt.Errorf("write config to %s failed: %w", cp, err)
 	}
 
+	// WriteFile keeps permissions of existing file
+	if err := os.Chmod(cp, 0600); err != nil {
+		return fmt.Errorf("chmod config %s failed: %w", cp, err)
+	}
+
 	return nil
 }
 
-// configPath gets config path.
+// configPath gets config path. Home dir is $HOME on Unix and %USERPROFILE% on Windows.
 func configPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
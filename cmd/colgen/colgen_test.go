@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/vmkteam/colgen/pkg/colgen"
 
+	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,6 +24,7 @@ func TestExtractAIPrompts(t *testing.T) {
 		input       string
 		wantMode    colgen.AssistMode
 		wantName    colgen.AssistantName
+		wantOpts    aiOptions
 		wantErr     bool
 		expectedErr string
 	}{
@@ -54,7 +62,7 @@ func TestExtractAIPrompts(t *testing.T) {
 			wantMode:    "",
 			wantName:    "",
 			wantErr:     true,
-			expectedErr: "invalid AI prompt, \")\" is not found or has invalid position",
+			expectedErr: "closing parenthesis not found: invalid AI prompt",
 		},
 		{
 			name:        "invalid parentheses order",
@@ -62,7 +70,7 @@ func TestExtractAIPrompts(t *testing.T) {
 			wantMode:    "",
 			wantName:    "",
 			wantErr:     true,
-			expectedErr: "invalid AI prompt, \")\" is not found or has invalid position",
+			expectedErr: "closing parenthesis before opening: invalid AI prompt",
 		},
 		{
 			name:     "empty input",
@@ -85,14 +93,164 @@ func TestExtractAIPrompts(t *testing.T) {
 			wantName: colgen.AssistantClaude,
 			wantErr:  false,
 		},
+		{
+			name:     "assistant with temperature",
+			input:    "readme(claude,temp=0.4)",
+			wantMode: "readme",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"temp": "0.4"},
+		},
+		{
+			name:     "assistant with temperature and preset",
+			input:    "readme(claude,temp=0.4,preset=concise)",
+			wantMode: "readme",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"temp": "0.4", "preset": "concise"},
+		},
+		{
+			name:     "options without assistant",
+			input:    "review(temp=0)",
+			wantMode: "review",
+			wantName: colgen.AssistantDeepSeek,
+			wantOpts: aiOptions{"temp": "0"},
+		},
+		{
+			name:     "options before assistant",
+			input:    "review(preset=strict,claude)",
+			wantMode: "review",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"preset": "strict"},
+		},
+		{
+			name:     "options with spaces",
+			input:    "readme( claude, temp = 0.4 )",
+			wantMode: "readme",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"temp": "0.4"},
+		},
+		{
+			name:     "options with tabs",
+			input:    "readme(claude,\ttemp=0.4\t)\r",
+			wantMode: "readme",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"temp": "0.4"},
+		},
+		{
+			name:     "deepseek temperature above 1",
+			input:    "readme(deepseek,temp=1.5)",
+			wantMode: "readme",
+			wantName: colgen.AssistantDeepSeek,
+			wantOpts: aiOptions{"temp": "1.5"},
+		},
+		{
+			name:     "empty arguments are skipped",
+			input:    "tests(,claude,,temp=1,)",
+			wantMode: "tests",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"temp": "1"},
+		},
+		{
+			name:     "tests with generated and options",
+			input:    "tests+generated(claude,preset=table)",
+			wantMode: "tests+generated",
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"preset": "table"},
+		},
+		{
+			name:        "unknown option",
+			input:       "readme(claude,top_p=0.9)",
+			wantErr:     true,
+			expectedErr: `unknown option "top_p", expected temp, preset, format or apply: invalid AI prompt`,
+		},
+		{
+			name:        "invalid temperature",
+			input:       "readme(claude,temp=hot)",
+			wantErr:     true,
+			expectedErr: `option temp="hot" must be a number in 0..1 for claude: invalid AI prompt`,
+		},
+		{
+			name:        "negative temperature",
+			input:       "readme(temp=-1)",
+			wantErr:     true,
+			expectedErr: `option temp="-1" must be a number in 0..2 for deepseek: invalid AI prompt`,
+		},
+		{
+			name:        "temperature out of range",
+			input:       "readme(temp=2.5)",
+			wantErr:     true,
+			expectedErr: `option temp="2.5" must be a number in 0..2 for deepseek: invalid AI prompt`,
+		},
+		{
+			name:        "claude temperature out of range",
+			input:       "readme(temp=1.5,claude)",
+			wantErr:     true,
+			expectedErr: `option temp="1.5" must be a number in 0..1 for claude: invalid AI prompt`,
+		},
+		{
+			name:        "empty preset",
+			input:       "readme(claude,preset=)",
+			wantErr:     true,
+			expectedErr: "option preset is empty: invalid AI prompt",
+		},
+		{
+			name:        "duplicate option",
+			input:       "readme(temp=0.1,temp=0.2)",
+			wantErr:     true,
+			expectedErr: `duplicate option "temp": invalid AI prompt`,
+		},
+		{
+			name:     "upgrade with apply",
+			input:    "upgrade(claude,apply)",
+			wantMode: colgen.ModeUpgrade,
+			wantName: colgen.AssistantClaude,
+			wantOpts: aiOptions{"apply": "true"},
+		},
+		{
+			name:     "upgrade with format",
+			input:    "upgrade(format=file,temp=0)",
+			wantMode: colgen.ModeUpgrade,
+			wantName: colgen.AssistantDeepSeek,
+			wantOpts: aiOptions{"format": "file", "temp": "0"},
+		},
+		{
+			name:        "invalid upgrade format",
+			input:       "upgrade(claude,format=patch)",
+			wantErr:     true,
+			expectedErr: `option format="patch" must be diff or file: invalid AI prompt`,
+		},
+		{
+			name:        "apply for other modes",
+			input:       "review(claude,apply)",
+			wantErr:     true,
+			expectedErr: "option apply is supported only by upgrade mode: invalid AI prompt",
+		},
+		{
+			name:        "duplicate apply",
+			input:       "upgrade(apply,apply)",
+			wantErr:     true,
+			expectedErr: `duplicate option "apply": invalid AI prompt`,
+		},
+		{
+			name:        "duplicate assistant",
+			input:       "readme(claude,deepseek)",
+			wantErr:     true,
+			expectedErr: `duplicate assistant name "deepseek": invalid AI prompt`,
+		},
+		{
+			name:        "text after closing parenthesis",
+			input:       "readme(claude)temp=0.4",
+			wantErr:     true,
+			expectedErr: `unexpected "temp=0.4" after closing parenthesis: invalid AI prompt`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotMode, gotName, err := extractAIPrompts(tt.input)
+			gotMode, gotName, gotOpts, err := extractAIPrompts(tt.input)
 
 			if tt.wantErr {
-				assert.Error(t, err)
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrInvalidAIPrompt))
 				if tt.expectedErr != "" {
 					assert.Equal(t, tt.expectedErr, err.Error())
 				}
@@ -102,6 +260,110 @@ func TestExtractAIPrompts(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, tt.wantMode, gotMode)
 			assert.Equal(t, tt.wantName, gotName)
+			assert.Equal(t, tt.wantOpts, gotOpts)
+		})
+	}
+}
+
+func TestApplyAIOptions(t *testing.T) {
+	cfg := Config{Presets: map[string]string{"concise": "Be concise."}}
+
+	aa, err := colgen.NewAssistant(colgen.AssistantDeepSeek, "")
+	require.NoError(t, err)
+	require.NoError(t, applyAIOptions(aa, cfg, nil))
+	require.NoError(t, applyAIOptions(aa, cfg, aiOptions{aiOptTemp: "0.4", aiOptPreset: "concise"}))
+
+	err = applyAIOptions(aa, cfg, aiOptions{aiOptPreset: "verbose"})
+	require.ErrorIs(t, err, ErrInvalidAIPrompt)
+	assert.Contains(t, err.Error(), `preset "verbose" is not found`)
+}
+
+func TestConfirm(t *testing.T) {
+	var out bytes.Buffer
+	assert.True(t, confirm(strings.NewReader("y\n"), &out, "overwrite news.go?"))
+	assert.Equal(t, "overwrite news.go? [y/N]: ", out.String())
+	assert.True(t, confirm(strings.NewReader(" YES "), io.Discard, "?"))
+	assert.False(t, confirm(strings.NewReader("\n"), io.Discard, "?"))
+	assert.False(t, confirm(strings.NewReader("no\n"), io.Discard, "?"))
+	assert.False(t, confirm(strings.NewReader(""), io.Discard, "?"), "closed stdin")
+}
+
+// fakeCaller returns predefined answer of assistant.
+type fakeCaller string
+
+func (f fakeCaller) Call(colgen.Code) (string, error) {
+	return string(f), nil
+}
+
+func TestUpgradeFile(t *testing.T) {
+	const (
+		source   = "package news\n\nfunc Sum(a []interface{}) int { return len(a) }\n"
+		upgraded = "```go\npackage news\n\nfunc Sum(a []any) int {\nreturn len(a)\n}\n```"
+		want     = "package news\n\nfunc Sum(a []any) int {\n\treturn len(a)\n}\n"
+	)
+
+	newAssistant := func(answer string) *colgen.Assistant {
+		r := colgen.NewAssistantRegistry()
+		r.Register("fake", func(string) colgen.Caller { return fakeCaller(answer) })
+		aa, err := r.New("fake", "")
+		require.NoError(t, err)
+		return aa
+	}
+
+	setup := func(t *testing.T) string {
+		filename := filepath.Join(t.TempDir(), "news.go")
+		require.NoError(t, os.WriteFile(filename, []byte(source), 0644))
+		return filename
+	}
+
+	t.Run("diff by default", func(t *testing.T) {
+		filename := setup(t)
+		require.NoError(t, upgradeFile(newAssistant("use any"), nil, nil, filename, []byte(source), nil, io.Discard))
+
+		md, err := os.ReadFile(filename + upgradeSuffix)
+		require.NoError(t, err)
+		assert.Equal(t, "use any", string(md))
+	})
+
+	t.Run("apply confirmed", func(t *testing.T) {
+		filename := setup(t)
+		var out bytes.Buffer
+		err := upgradeFile(newAssistant(upgraded), aiOptions{aiOptApply: "true"}, nil, filename, []byte(source), strings.NewReader("y\n"), &out)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "+func Sum(a []any) int {")
+
+		content, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(content), "formatted with gofmt")
+		assert.NoFileExists(t, filename+upgradeSuffix)
+	})
+
+	t.Run("apply declined", func(t *testing.T) {
+		filename := setup(t)
+		err := upgradeFile(newAssistant(upgraded), aiOptions{aiOptApply: "true"}, nil, filename, []byte(source), strings.NewReader(""), io.Discard)
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, source, string(content))
+
+		md, err := os.ReadFile(filename + upgradeSuffix)
+		require.NoError(t, err)
+		assert.Contains(t, string(md), "+func Sum(a []any) int {")
+	})
+
+	for name, answer := range map[string]string{
+		"invalid go code": "package news\n\nfunc Sum(a []any) int {",
+		"another package": "package main\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := setup(t)
+			err := upgradeFile(newAssistant(answer), aiOptions{aiOptApply: "true"}, nil, filename, []byte(source), strings.NewReader("y\n"), io.Discard)
+			require.ErrorIs(t, err, colgen.ErrInvalidUpgrade)
+
+			content, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			assert.Equal(t, source, string(content), "source is not changed")
 		})
 	}
 }
@@ -145,6 +407,22 @@ func TestBaseName(t *testing.T) {
 func TestAppVersion(t *testing.T) {
 	version := appVersion()
 	assert.NotEmpty(t, version)
+	assert.NotEmpty(t, version.Version)
+	assert.NotEmpty(t, version.Go)
+	assert.NotEmpty(t, version.OS)
+	assert.NotEmpty(t, version.Arch)
+}
+
+func TestPrintVersion(t *testing.T) {
+	vi := VersionInfo{Version: "v1.2.3", Go: "go1.22.0", Arch: "amd64", OS: "linux", Built: "2024-01-15T10:00:00Z"}
+
+	var buf bytes.Buffer
+	require.NoError(t, printVersion(&buf, vi, false))
+	assert.Equal(t, "colgen version: v1.2.3\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, printVersion(&buf, vi, true))
+	assert.JSONEq(t, `{"version": "v1.2.3", "go": "go1.22.0", "arch": "amd64", "os": "linux", "built": "2024-01-15T10:00:00Z"}`, buf.String())
 }
 
 func TestConfigFillByAssistName(t *testing.T) {
@@ -285,3 +563,619 @@ func main() {
 	assert.Equal(t, []string{"tests(claude)"}, cl.assistant)
 	assert.Equal(t, []string{"//colgen@replace:something"}, cl.injection)
 }
+
+func TestReadFileWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     []string
+	}{
+		{
+			name:     "single go:generate",
+			filename: "single.go",
+			content:  "package testpkg\n\n//go:generate colgen\n//colgen:User\n",
+		},
+		{
+			name:     "duplicate go:generate",
+			filename: "duplicate.go",
+			content:  "package testpkg\n\n//go:generate colgen\n//go:generate colgen -list\n//colgen:User\n",
+			want:     []string{"has 2 `//go:generate colgen` lines"},
+		},
+		{
+			name:     "generated file",
+			filename: "user_colgen.go",
+			content:  "package testpkg\n\n//go:generate colgen\n",
+			want:     []string{"looks like colgen output"},
+		},
+		{
+			name:     "generated file reference",
+			filename: "embed.go",
+			content:  "package testpkg\n\n//go:generate colgen\n//go:embed \"user_colgen.go\"\n",
+			want:     []string{"generation may be circular"},
+		},
+		{
+			name:     "CRLF line endings",
+			filename: "crlf.go",
+			content:  "package testpkg\r\n\r\n//go:generate colgen\r\n//go:generate colgen\r\n//colgen:User\r\n",
+			want:     []string{"has 2 `//go:generate colgen` lines"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tt.filename)
+			require.NoError(t, os.WriteFile(filename, []byte(tt.content), 0600))
+
+			cl, err := readFile(filename)
+			require.NoError(t, err)
+			require.Len(t, cl.warnings, len(tt.want))
+			for _, l := range cl.lines {
+				assert.NotContains(t, l, "\r")
+			}
+			for i, w := range tt.want {
+				assert.Contains(t, cl.warnings[i], w)
+			}
+		})
+	}
+}
+
+func TestReadFileBuildTags(t *testing.T) {
+	const dir = "../../pkg/colgen/testdata/buildtags"
+
+	cl, err := readFile(filepath.Join(dir, "models.go"))
+	require.NoError(t, err)
+	assert.False(t, cl.excluded)
+	assert.Equal(t, []string{"Common"}, cl.lines)
+
+	cl, err = readFile(filepath.Join(dir, "models_integration.go"))
+	require.NoError(t, err)
+	assert.True(t, cl.excluded)
+	assert.Empty(t, cl.lines)
+
+	tags := *flTags
+	t.Cleanup(func() { *flTags = tags })
+	*flTags = " integration, "
+	assert.Equal(t, []string{"integration"}, buildTags())
+
+	cl, err = readFile(filepath.Join(dir, "models_integration.go"))
+	require.NoError(t, err)
+	assert.False(t, cl.excluded)
+	assert.Equal(t, []string{"Fixture"}, cl.lines)
+}
+
+func TestReadFileSelfReferential(t *testing.T) {
+	const dir = "../../pkg/colgen/testdata/selfref"
+
+	cl, err := readFile(filepath.Join(dir, "models.go"))
+	require.NoError(t, err)
+	assert.False(t, cl.excluded)
+	assert.Equal(t, []string{"Item"}, cl.lines)
+
+	// directive-like lines of generated and test files produce no rules
+	for name, reason := range map[string]string{"mocks.go": skipGenerated, "models_colgen.go": skipGenerated, "models_test.go": skipTest} {
+		cl, err = readFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.True(t, cl.excluded, name)
+		assert.Equal(t, reason, cl.skipped, name)
+		assert.Empty(t, cl.lines, name)
+		assert.Empty(t, cl.routes, name)
+
+		rules, err := colgen.ParseRules(cl.lines, false)
+		require.NoError(t, err)
+		assert.Empty(t, rules, name)
+	}
+
+	// test files opt in
+	testDirs := *flTestDirs
+	t.Cleanup(func() { *flTestDirs = testDirs })
+	*flTestDirs = true
+
+	cl, err = readFile(filepath.Join(dir, "models_test.go"))
+	require.NoError(t, err)
+	assert.False(t, cl.excluded)
+	assert.Equal(t, []string{"Item:Index(Title)"}, cl.lines)
+
+	cl, err = readFile(filepath.Join(dir, "mocks.go"))
+	require.NoError(t, err)
+	assert.True(t, cl.excluded, "generated files are skipped with -test-directives")
+
+	assert.Equal(t, "skipping mocks.go: "+skipGenerated, excludedMessage("mocks.go", skipGenerated))
+	assert.Contains(t, excludedMessage("models_plan9.go", ""), "use -tags to enable it")
+}
+
+func TestGenerateArgs(t *testing.T) {
+	t.Setenv("COLGEN_PKG", "util")
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "//go:generate colgen", want: []string{}},
+		{line: "//go:generate colgen -list -funcpkg util", want: []string{"-list", "-funcpkg", "util"}},
+		{line: "//go:generate go run ../cmd/colgen -imports=pkg/db", want: []string{"-imports=pkg/db"}},
+		{line: "//go:generate go run github.com/vmkteam/colgen/cmd/colgen@latest -list", want: []string{"-list"}},
+		{line: `//go:generate colgen -imports "pkg/db, pkg/domain" -funcpkg $COLGEN_PKG`, want: []string{"-imports", "pkg/db, pkg/domain", "-funcpkg", "util"}},
+		{line: `//go:generate sh -c "colgen -list"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := generateArgs(tt.line)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := generateArgs(`//go:generate colgen -imports "pkg/db`)
+	assert.Error(t, err)
+}
+
+func TestApplyGenerateArgs(t *testing.T) {
+	newFlagSet := func(args ...string) (*flag.FlagSet, *bool, *string) {
+		fs := flag.NewFlagSet("colgen", flag.ContinueOnError)
+		list, funcPkg := fs.Bool("list", false, ""), fs.String("funcpkg", "", "")
+		require.NoError(t, fs.Parse(args))
+		return fs, list, funcPkg
+	}
+
+	fs, list, funcPkg := newFlagSet()
+	applied, err := applyGenerateArgs(fs, []string{"-list", "--funcpkg", "util", "vet", "-list=false"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list", "funcpkg"}, applied)
+	assert.True(t, *list)
+	assert.Equal(t, "util", *funcPkg)
+
+	// command line flags are kept
+	fs, list, funcPkg = newFlagSet("-funcpkg", "lo")
+	applied, err = applyGenerateArgs(fs, []string{"-list=true", "-funcpkg=util"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list"}, applied)
+	assert.True(t, *list)
+	assert.Equal(t, "lo", *funcPkg)
+
+	for _, args := range [][]string{{"-unknown"}, {"-funcpkg"}, {"-list=maybe"}} {
+		fs, _, _ = newFlagSet()
+		_, err = applyGenerateArgs(fs, args)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestReadFileGenerateArgs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "news.go")
+	content := "package testpkg\n\n//go:generate colgen -list\n//go:generate colgen -funcpkg util\n//colgen:News\n"
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+
+	cl, err := readFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-list"}, cl.generateArgs, "the first line without go generate")
+
+	// line run by go generate
+	t.Setenv("GOFILE", "news.go")
+	t.Setenv("GOLINE", "4")
+	cl, err = readFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-funcpkg", "util"}, cl.generateArgs)
+
+	// file without go:generate line
+	require.NoError(t, os.WriteFile(filename, []byte("package testpkg\n\n//colgen:News\n"), 0600))
+	cl, err = readFile(filename)
+	require.NoError(t, err)
+	assert.Nil(t, cl.generateArgs)
+}
+
+func TestReadFileRoutes(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/routes\n\ngo 1.21\n"), 0644))
+	filename := filepath.Join(dir, "models.go")
+	content := `package routes
+
+//colgen:User
+//colgen[heavy_colgen.go,!tinygo]:Analytics,Event
+//colgen[heavy_colgen.go]:Event:Index(Name)
+
+type User struct{ ID int }
+
+type Analytics struct{ ID int }
+
+type Event struct {
+	ID   int
+	Name string
+}
+`
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+
+	cl, err := readFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"User"}, cl.lines)
+	require.Len(t, cl.routes, 1)
+	assert.Equal(t, colgen.Output{Filename: "heavy_colgen.go", Constraint: "!tinygo"}, cl.routes[0].output)
+	assert.Equal(t, []string{"Analytics,Event", "Event:Index(Name)"}, cl.routes[0].lines)
+	assert.Equal(t, []int{4, 5}, cl.routes[0].lineNums)
+	assert.Equal(t, "routes", cl.routes[0].pkgName)
+
+	r := generate(cl.routes[0], filename, nil, t.Logf)
+	require.NoError(t, r.err)
+	assert.Equal(t, filepath.Join(dir, "heavy_colgen.go"), r.fd.Filename)
+	assert.True(t, strings.HasPrefix(string(r.fd.After), "//go:build !tinygo\n\n// Code generated by colgen"))
+	assert.Contains(t, string(r.fd.After), "func (ll Events) IndexByName() map[string]Event")
+	assert.NotContains(t, string(r.fd.After), "type Users")
+
+	ok, err := colgen.MatchBuildTags(r.fd.Filename, []string{"tinygo"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// regeneration keeps constraint and passes hash check
+	r = generate(cl.routes[0], filename, nil, t.Logf)
+	require.NoError(t, r.err)
+	assert.Equal(t, r.fd.Before, r.fd.After)
+
+	// routed outputs are generated in multi-file run
+	results := generateFiles(&bytes.Buffer{}, []string{filename}, 2, Config{})
+	require.Len(t, results, 2)
+	assert.Equal(t, filepath.Join(dir, "models_colgen.go"), results[0].fd.Filename)
+	assert.Equal(t, filepath.Join(dir, "heavy_colgen.go"), results[1].fd.Filename)
+
+	for _, bad := range []string{
+		"//colgen[models_colgen.go]:User",
+		"//colgen[heavy_colgen.go,linux]:User\n//colgen[heavy_colgen.go,!tinygo]:Event",
+		"//colgen[heavy.go]:User",
+	} {
+		require.NoError(t, os.WriteFile(filename, []byte("package routes\n\n"+bad+"\n"), 0644))
+		_, err = readFile(filename)
+		require.ErrorIs(t, err, colgen.ErrInvalidArg, bad)
+	}
+}
+
+func TestReadConfigMaxPromptBytes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cp, err := configPath()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cp, []byte("ClaudeKey = \"key\"\nMaxPromptBytes = { claude = 400000 }\n"), 0600))
+
+	cfg, err := readConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "key", cfg.ClaudeKey)
+	assert.Equal(t, map[string]int{"claude": 400000}, cfg.MaxPromptBytes)
+}
+
+func TestRunAI(t *testing.T) {
+	t.Run("unknown command", func(t *testing.T) {
+		require.Error(t, runAI(Config{}, []string{"pong"}, io.Discard))
+		require.Error(t, runAI(Config{}, nil, io.Discard))
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		err := runAI(Config{}, []string{"ping"}, io.Discard)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "-write-key")
+	})
+
+	t.Run("unknown assistant", func(t *testing.T) {
+		err := runAI(Config{}, []string{"ping", "unknown"}, io.Discard)
+		assert.ErrorIs(t, err, colgen.ErrUnsupportedAssistName)
+	})
+}
+
+// assistantTest is a registered third-party assistant without own config field.
+const assistantTest colgen.AssistantName = "zztest"
+
+// assistants of fallback tests: zzdown always fails, zzfallback answers.
+const (
+	assistantDown     colgen.AssistantName = "zzdown"
+	assistantFallback colgen.AssistantName = "zzfallback"
+)
+
+// downCaller is an assistant that is down.
+type downCaller struct{}
+
+func (downCaller) Call(colgen.Code) (string, error) {
+	return "", errors.New("overloaded; http_status_code=529")
+}
+
+func init() {
+	colgen.RegisterAssistant(assistantTest, func(string) colgen.Caller { return nil })
+	colgen.RegisterAssistant(assistantDown, func(string) colgen.Caller { return downCaller{} })
+	colgen.RegisterAssistant(assistantFallback, func(string) colgen.Caller { return fakeCaller("# Review") })
+}
+
+func TestConfigFallbacks(t *testing.T) {
+	cfg := Config{
+		Keys:          map[string]string{string(assistantDown): "key", string(assistantFallback): "key"},
+		Fallback:      []string{string(assistantDown), string(assistantFallback)},
+		AIFrontMatter: true,
+	}
+
+	fbs, err := cfg.fallbacks(assistantDown)
+	require.NoError(t, err)
+	require.Len(t, fbs, 1, "primary is skipped")
+	assert.Equal(t, assistantFallback, fbs[0].Provider())
+
+	// primary failure is answered by fallback and noted in front matter
+	aa, err := colgen.NewAssistant(assistantDown, cfg.keyByName(assistantDown))
+	require.NoError(t, err)
+	require.NoError(t, setFallbacks(aa, cfg, assistantDown))
+	fm := newFrontMatter(cfg, aa, assistantDown, colgen.ModeReview, "news.go", []byte("package news\n"))
+
+	r, err := aa.Generate(colgen.ModeReview, "package news")
+	require.NoError(t, err)
+	assert.Equal(t, "# Review", r)
+
+	filename := filepath.Join(t.TempDir(), "news.go.md")
+	require.NoError(t, writeMarkdown(filename, []byte(r), fm))
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `provider: "zzfallback"`)
+
+	// fallback without key is skipped: error of primary is returned
+	delete(cfg.Keys, string(assistantFallback))
+	aa, err = colgen.NewAssistant(assistantDown, cfg.keyByName(assistantDown))
+	require.NoError(t, err)
+	require.NoError(t, setFallbacks(aa, cfg, assistantDown))
+	_, err = aa.Generate(colgen.ModeReview, "package news")
+	require.ErrorIs(t, err, colgen.ErrProvider)
+	assert.Equal(t, assistantDown, aa.Provider())
+
+	_, err = (&Config{Fallback: []string{"unknown"}}).fallbacks(assistantDown)
+	require.ErrorIs(t, err, colgen.ErrUnsupportedAssistName)
+}
+
+func TestReadConfigFallback(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cp, err := configPath()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cp, []byte("Fallback = [\"claude\", \"deepseek\"]\n"), 0600))
+
+	cfg, err := readConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"claude", "deepseek"}, cfg.Fallback)
+}
+
+func TestConfigAssistants(t *testing.T) {
+	assert.Empty(t, (&Config{}).assistants())
+	assert.Equal(t, []colgen.AssistantName{colgen.AssistantClaude}, (&Config{ClaudeKey: "key"}).assistants())
+	assert.Equal(t, []colgen.AssistantName{colgen.AssistantClaude, colgen.AssistantDeepSeek}, (&Config{ClaudeKey: "key", DeepSeekKey: "key"}).assistants())
+
+	t.Run("registered assistant", func(t *testing.T) {
+		var cfg Config
+		require.NoError(t, cfg.fillByName(assistantTest, "key"))
+		assert.Equal(t, "key", cfg.keyByName(assistantTest))
+		assert.Equal(t, []colgen.AssistantName{assistantTest}, cfg.assistants())
+		require.Error(t, cfg.fillByName("unknown", "key"))
+	})
+}
+
+func TestWriteConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cp, err := configPath()
+	require.NoError(t, err)
+
+	// existing config with custom fields and wrong permissions
+	const existing = `# my keys
+DeepSeekKey = "deepseek-key"
+Custom = "value"
+
+[Section]
+Number = 1
+`
+	require.NoError(t, os.WriteFile(cp, []byte(existing), 0644))
+	require.NoError(t, os.Chmod(cp, 0644))
+
+	t.Run("write key preserves custom fields", func(t *testing.T) {
+		require.NoError(t, writeConfig("claude-key", colgen.AssistantClaude))
+
+		cfg, err := readConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "deepseek-key", cfg.DeepSeekKey)
+		assert.Equal(t, "claude-key", cfg.ClaudeKey)
+
+		var raw map[string]any
+		_, err = toml.DecodeFile(cp, &raw)
+		require.NoError(t, err)
+		assert.Equal(t, "value", raw["Custom"])
+		assert.Equal(t, map[string]any{"Number": int64(1)}, raw["Section"])
+
+		fi, err := os.Stat(cp)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	})
+
+	t.Run("delete key", func(t *testing.T) {
+		require.NoError(t, deleteKey(colgen.AssistantDeepSeek))
+
+		cfg, err := readConfig()
+		require.NoError(t, err)
+		assert.Empty(t, cfg.DeepSeekKey)
+		assert.Equal(t, "claude-key", cfg.ClaudeKey)
+
+		b, err := os.ReadFile(cp)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), "DeepSeekKey")
+		assert.Contains(t, string(b), "Custom")
+	})
+
+	t.Run("unknown assistant", func(t *testing.T) {
+		require.Error(t, writeConfig("key", "unknown"))
+		require.Error(t, deleteKey("unknown"))
+	})
+
+	t.Run("registered assistant", func(t *testing.T) {
+		require.NoError(t, writeConfig("test-key", assistantTest))
+		cfg, err := readConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "test-key", cfg.keyByName(assistantTest))
+		assert.Equal(t, "claude-key", cfg.ClaudeKey)
+
+		require.NoError(t, deleteKey(assistantTest))
+		cfg, err = readConfig()
+		require.NoError(t, err)
+		assert.Empty(t, cfg.keyByName(assistantTest))
+	})
+
+	t.Run("new config", func(t *testing.T) {
+		require.NoError(t, os.Remove(cp))
+		require.NoError(t, writeConfig("key", ""))
+
+		cfg, err := readConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "key", cfg.DeepSeekKey)
+	})
+}
+
+func TestPrintUsage(t *testing.T) {
+	defer flag.CommandLine.SetOutput(nil)
+
+	var buf bytes.Buffer
+	printUsage(&buf)
+
+	out := buf.String()
+	for _, want := range []string{
+		"//go:generate colgen",
+		"//colgen:News,Tag",
+		"func (ll NewsList) IDs() []int",
+		"//colgen@NewUser(db)",
+		"//colgen@ai:review(claude)",
+		"-write-key",
+		"-list",
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+func TestSetSystemPrompt(t *testing.T) {
+	aa, err := colgen.NewAssistant(colgen.AssistantDeepSeek, "key")
+	require.NoError(t, err)
+
+	require.NoError(t, setSystemPrompt(aa, ""))
+
+	err = setSystemPrompt(aa, filepath.Join(t.TempDir(), "missing.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	require.NoError(t, os.WriteFile(empty, []byte(" \n"), 0600))
+	require.Error(t, setSystemPrompt(aa, empty))
+
+	custom := filepath.Join(t.TempDir(), "prompt.txt")
+	require.NoError(t, os.WriteFile(custom, []byte("Use our coding standards."), 0600))
+	require.NoError(t, setSystemPrompt(aa, custom))
+}
+
+func TestAIRunFlags(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "news.go")
+	require.NoError(t, os.WriteFile(filename, []byte("package news\n"), 0600))
+	t.Setenv("GOFILE", filename)
+
+	am, an, opts, f, err := aiRunFlags("review", "claude", "")
+	require.NoError(t, err)
+	assert.Equal(t, colgen.ModeReview, am)
+	assert.Equal(t, colgen.AssistantClaude, an)
+	assert.Nil(t, opts)
+	assert.Equal(t, filename, f)
+
+	// options and default assistant
+	am, an, opts, _, err = aiRunFlags("upgrade(apply)", "", filename)
+	require.NoError(t, err)
+	assert.Equal(t, colgen.ModeUpgrade, am)
+	assert.Equal(t, colgen.AssistantDeepSeek, an)
+	assert.Equal(t, aiOptions{aiOptApply: "true"}, opts)
+
+	am, an, _, _, err = aiRunFlags("tests+generated", "claude", filename)
+	require.NoError(t, err)
+	assert.Equal(t, colgen.AssistMode("tests+generated"), am)
+	assert.Equal(t, colgen.AssistantClaude, an)
+
+	tests := []struct {
+		name, run, file string
+		wantErr         error
+	}{
+		{name: "unknown mode", run: "summary", file: filename, wantErr: colgen.ErrUnsupportedAssistMode},
+		{name: "commit message", run: "commitmsg", file: filename, wantErr: colgen.ErrUnsupportedAssistMode},
+		{name: "invalid options", run: "readme(apply)", file: filename, wantErr: ErrInvalidAIPrompt},
+		{name: "missing file", run: "review", file: filepath.Join(dir, "missing.go"), wantErr: os.ErrNotExist},
+		{name: "directory", run: "review", file: dir},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, _, err := aiRunFlags(tt.run, "claude", tt.file)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+
+	// no file
+	t.Setenv("GOFILE", "")
+	_, _, _, _, err = aiRunFlags("review", "", "")
+	assert.Error(t, err)
+}
+
+func TestAIDisabledBy(t *testing.T) {
+	noAI := *flNoAI
+	t.Cleanup(func() { *flNoAI = noAI })
+	*flNoAI = false
+	t.Setenv(noAIEnv, "")
+
+	if colgen.NoAI {
+		assert.Equal(t, "colgen_noai build", aiDisabledBy(Config{}))
+		return
+	}
+
+	assert.Empty(t, aiDisabledBy(Config{}))
+	require.NoError(t, aiDisabledErr(""))
+
+	assert.Equal(t, "DisableAI in ~/.colgen", aiDisabledBy(Config{DisableAI: true}))
+
+	for v, want := range map[string]bool{"1": true, "true": true, "yes": true, "0": false, "false": false} {
+		t.Setenv(noAIEnv, v)
+		assert.Equal(t, want, aiDisabledBy(Config{}) == "COLGEN_NO_AI environment variable", v)
+	}
+
+	*flNoAI = true
+	assert.Equal(t, "-no-ai flag", aiDisabledBy(Config{}))
+	assert.ErrorIs(t, aiDisabledErr(aiDisabledBy(Config{})), ErrAIDisabled)
+}
+
+func TestCheckAssistantLines(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "news.go")
+	require.NoError(t, os.WriteFile(filename, []byte("package news\n\n//go:generate colgen\n//colgen@ai:review(claude)\n//colgen:News\n"), 0600))
+
+	cl, err := readFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, checkAssistantLines(cl, filename, ""))
+
+	err = checkAssistantLines(cl, filename, "-no-ai flag")
+	require.ErrorIs(t, err, ErrAIDisabled)
+	assert.Contains(t, err.Error(), "//colgen@ai:review(claude)")
+
+	var re *runError
+	require.ErrorAs(t, err, &re)
+	assert.Equal(t, kindAssistant, re.Kind)
+	assert.Equal(t, filename, re.File)
+
+	// files without assistant directives are allowed
+	require.NoError(t, checkAssistantLines(colgenLines{lines: []string{"News"}}, filename, "-no-ai flag"))
+
+	// multi-file run
+	results := generateFiles(&bytes.Buffer{}, []string{filename}, 1, Config{DisableAI: true})
+	require.Len(t, results, 1)
+	require.ErrorIs(t, results[0].err, ErrAIDisabled)
+}
+
+func TestNoAIBuildDeps(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", "-tags", "colgen_noai", ".").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	// restricted binary has no assistant providers
+	for _, pkg := range strings.Fields(string(out)) {
+		assert.NotContains(t, pkg, "anthropic-sdk-go")
+		assert.NotContains(t, pkg, "go-deepseek")
+	}
+	assert.Contains(t, string(out), "github.com/vmkteam/colgen/pkg/colgen")
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/version"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+// minGoVersion is a minimal supported Go version for generated code and go/packages.
+const minGoVersion = "go1.21"
+
+// requiredBinaries must be present in PATH: go/packages runs `go list` under the hood.
+var requiredBinaries = []string{"go"}
+
+type checkStatus string
+
+const (
+	checkOK   checkStatus = "OK"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+// checkResult is a result of single doctor check.
+type checkResult struct {
+	Name    string
+	Status  checkStatus
+	Message string
+}
+
+// runDoctor runs all checks from `colgen doctor`, prints results and returns error if any check has failed.
+// Assistants with configured keys are pinged only if online is set.
+func runDoctor(w io.Writer, dir string, online bool) error {
+	cfgCheck, cfg := checkConfig()
+	results := []checkResult{
+		checkBinaries(requiredBinaries),
+		checkGoVersion(goEnv("GOVERSION")),
+		checkModules(goEnv("GO111MODULE"), goEnv("GOMOD")),
+		checkGOPATH(os.Getenv("GOPATH")),
+		checkPackage(dir),
+		cfgCheck,
+		checkKeys(cfg),
+	}
+
+	if online {
+		for _, an := range cfg.assistants() {
+			aa, err := colgen.NewAssistant(an, cfg.keyByName(an))
+			if err != nil {
+				results = append(results, checkResult{Name: "ai " + string(an), Status: checkFail, Message: err.Error()})
+				continue
+			}
+
+			results = append(results, checkProvider(an, aa.Ping()))
+		}
+	}
+
+	return printChecks(w, append(results, checkDirectives(dir)))
+}
+
+// printChecks prints check results and returns error with failed check names.
+func printChecks(w io.Writer, results []checkResult) error {
+	var failed []string
+	for _, r := range results {
+		fmt.Fprintf(w, "[%s] %s: %s\n", r.Status, r.Name, r.Message)
+		if r.Status == checkFail {
+			failed = append(failed, r.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("doctor failed: %s", strings.Join(failed, ","))
+	}
+
+	return nil
+}
+
+// goEnv returns value of `go env <key>` or empty string on error.
+func goEnv(key string) string {
+	out, err := exec.Command("go", "env", key).Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// checkBinaries checks that all binaries are in PATH.
+func checkBinaries(names []string) checkResult {
+	r := checkResult{Name: "binaries", Status: checkOK}
+
+	var missing []string
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err != nil {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		r.Status, r.Message = checkFail, "not found in PATH: "+strings.Join(missing, ", ")
+		return r
+	}
+
+	r.Message = "found " + strings.Join(names, ", ")
+	return r
+}
+
+// checkGoVersion checks that Go toolchain version (e.g. go1.23.4) is at least minGoVersion.
+func checkGoVersion(goVersion string) checkResult {
+	r := checkResult{Name: "go", Status: checkOK, Message: goVersion}
+	switch {
+	case goVersion == "" || !version.IsValid(goVersion):
+		r.Status, r.Message = checkFail, "unable to detect Go version, is Go installed?"
+	case version.Compare(goVersion, minGoVersion) < 0:
+		r.Status, r.Message = checkFail, fmt.Sprintf("%s is too old, %s or newer is required", goVersion, minGoVersion)
+	}
+
+	return r
+}
+
+// checkModules checks that Go modules are enabled and dir is inside a module: gomod is a path of go.mod from `go env GOMOD`.
+func checkModules(go111module, gomod string) checkResult {
+	r := checkResult{Name: "modules", Status: checkOK, Message: gomod}
+	switch {
+	case go111module == "off":
+		r.Status, r.Message = checkFail, "GO111MODULE=off, colgen requires modules: run `go env -w GO111MODULE=on`"
+	case gomod == "" || gomod == os.DevNull:
+		r.Status, r.Message = checkFail, "current directory is not inside a Go module, run `go mod init <module>`"
+	}
+
+	return r
+}
+
+// checkPackage checks that go/packages can load Go package from dir.
+func checkPackage(dir string) checkResult {
+	r := checkResult{Name: "package", Status: checkOK}
+	g := colgen.NewGenerator("", "", "", appVersion().String())
+	if err := g.UsePackageDir(dir); err != nil {
+		r.Status, r.Message = checkFail, fmt.Sprintf("%v, check the package with `go build`", err)
+		return r
+	}
+
+	r.Message = "loaded " + dir
+	return r
+}
+
+// checkGOPATH checks that GOPATH env is set.
+func checkGOPATH(gopath string) checkResult {
+	if gopath == "" {
+		return checkResult{Name: "GOPATH", Status: checkWarn, Message: "GOPATH is not set, go uses default ~/go"}
+	}
+
+	return checkResult{Name: "GOPATH", Status: checkOK, Message: gopath}
+}
+
+// checkConfig checks that ~/.colgen exists and is a valid TOML file. Returns parsed config.
+func checkConfig() (checkResult, Config) {
+	r := checkResult{Name: "config", Status: checkOK}
+	cp, err := configPath()
+	if err != nil {
+		r.Status, r.Message = checkFail, err.Error()
+		return r, Config{}
+	}
+
+	if _, err = os.Stat(cp); errors.Is(err, os.ErrNotExist) {
+		r.Status, r.Message = checkWarn, cp+" not found, use `colgen -write-key=<key>` to create it"
+		return r, Config{}
+	}
+
+	cfg, err := readConfig()
+	if err != nil {
+		r.Status, r.Message = checkFail, fmt.Sprintf("invalid %s: %v", cp, err)
+		return r, Config{}
+	}
+
+	// config contains API keys
+	if fi, err := os.Stat(cp); err == nil && fi.Mode().Perm()&0o077 != 0 {
+		r.Status, r.Message = checkWarn, fmt.Sprintf("%s is accessible by other users (%v), run `chmod 600 %s`", cp, fi.Mode().Perm(), cp)
+		return r, cfg
+	}
+
+	r.Message = cp
+	return r, cfg
+}
+
+// checkKeys checks that at least one assistant key is set.
+func checkKeys(cfg Config) checkResult {
+	r := checkResult{Name: "ai keys", Status: checkOK}
+	names := cfg.assistants()
+	if len(names) == 0 {
+		r.Status, r.Message = checkWarn, "no assistant keys found, //colgen@ai directives will not work"
+		return r
+	}
+
+	ss := make([]string, 0, len(names))
+	for _, n := range names {
+		ss = append(ss, string(n))
+	}
+	r.Message = strings.Join(ss, ", ")
+
+	return r
+}
+
+// checkProvider returns check result of assistant ping.
+func checkProvider(an colgen.AssistantName, p colgen.PingResult) checkResult {
+	r := checkResult{Name: "ai " + string(an), Status: checkOK, Message: fmt.Sprintf("model=%s latency=%v", p.Model, p.Latency.Round(time.Millisecond))}
+	if p.Status != colgen.PingOK {
+		r.Status, r.Message = checkFail, fmt.Sprintf("%s: %s", p.Status, p.Diagnosis)
+	}
+
+	return r
+}
+
+// checkDirectives checks that dir or its subdirectories contain .go files with colgen directives.
+// Hidden, vendor and testdata directories are skipped, as well as directives of generated and test files.
+func checkDirectives(dir string) checkResult {
+	r := checkResult{Name: "directives", Status: checkOK}
+
+	var files, found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() && path != dir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || d.Name() == "testdata"):
+			return filepath.SkipDir
+		case d.IsDir() || filepath.Ext(path) != ".go":
+			return nil
+		}
+
+		files = append(files, path)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if strings.Contains(string(content), "//colgen") && skipDirectives(path) == "" {
+			rel, _ := filepath.Rel(dir, path)
+			found = append(found, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		r.Status, r.Message = checkFail, err.Error()
+		return r
+	}
+
+	if len(files) == 0 {
+		r.Status, r.Message = checkWarn, "no .go files in current directory"
+		return r
+	}
+
+	if len(found) == 0 {
+		r.Status, r.Message = checkWarn, "no //colgen directives found in .go files"
+		return r
+	}
+
+	r.Message = "found in " + strings.Join(found, ", ")
+	return r
+}
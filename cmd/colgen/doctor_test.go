@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGoVersion(t *testing.T) {
+	assert.Equal(t, checkOK, checkGoVersion("go1.23.4").Status)
+	assert.Equal(t, checkOK, checkGoVersion("go1.21").Status)
+	assert.Equal(t, checkFail, checkGoVersion("go1.20.5").Status)
+	assert.Equal(t, checkFail, checkGoVersion("").Status)
+}
+
+func TestCheckModules(t *testing.T) {
+	assert.Equal(t, checkOK, checkModules("", "/src/app/go.mod").Status)
+	assert.Equal(t, checkOK, checkModules("on", "/src/app/go.mod").Status)
+	assert.Equal(t, checkFail, checkModules("off", "").Status)
+	assert.Equal(t, checkFail, checkModules("", os.DevNull).Status)
+	assert.Equal(t, checkFail, checkModules("", "").Status)
+}
+
+func TestCheckPackage(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/doctor\n\ngo 1.21\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0600))
+	assert.Equal(t, checkOK, checkPackage(dir).Status)
+
+	r := checkPackage(filepath.Join(dir, "not-exists"))
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "go build")
+}
+
+func TestCheckProvider(t *testing.T) {
+	assert.Equal(t, checkOK, checkProvider(colgen.AssistantClaude, colgen.PingResult{Status: colgen.PingOK, Model: "m"}).Status)
+
+	r := checkProvider(colgen.AssistantClaude, colgen.PingResult{Status: colgen.PingAuthFailed, Diagnosis: "check API key"})
+	assert.Equal(t, checkFail, r.Status)
+	assert.Equal(t, "ai claude", r.Name)
+	assert.Contains(t, r.Message, "check API key")
+}
+
+func TestCheckConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	r, _ := checkConfig()
+	assert.Equal(t, checkWarn, r.Status)
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, configFile), []byte("ClaudeKey = \"key\"\n"), 0600))
+	r, cfg := checkConfig()
+	assert.Equal(t, checkOK, r.Status)
+	assert.Equal(t, checkOK, checkKeys(cfg).Status)
+
+	// keys are readable by other users
+	require.NoError(t, os.Chmod(filepath.Join(home, configFile), 0644))
+	r, cfg = checkConfig()
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "chmod 600")
+	assert.Equal(t, checkOK, checkKeys(cfg).Status)
+
+	require.NoError(t, os.WriteFile(filepath.Join(home, configFile), []byte("ClaudeKey = "), 0600))
+	r, cfg = checkConfig()
+	assert.Equal(t, checkFail, r.Status)
+	assert.Equal(t, checkWarn, checkKeys(cfg).Status)
+}
+
+func TestCheckDirectives(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, checkWarn, checkDirectives(dir).Status)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0600))
+	assert.Equal(t, checkWarn, checkDirectives(dir).Status)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.go"), []byte("package a\n\n//colgen:News\n"), 0600))
+	r := checkDirectives(dir)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "b.go")
+
+	// subdirectories are checked, testdata is skipped
+	for _, sub := range []string{"news", "testdata"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, sub), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, sub, sub+".go"), []byte("package a\n\n//colgen:News\n"), 0600))
+	}
+	r = checkDirectives(dir)
+	assert.Contains(t, r.Message, filepath.Join("news", "news.go"))
+	assert.NotContains(t, r.Message, "testdata")
+
+	// directives of generated and test files are not counted
+	r = checkDirectives("../../pkg/colgen/testdata/selfref")
+	assert.Equal(t, "found in models.go", r.Message)
+}
+
+func TestPrintChecks(t *testing.T) {
+	var buf bytes.Buffer
+	err := printChecks(&buf, []checkResult{
+		{Name: "go", Status: checkOK, Message: "go1.23.4"},
+		{Name: "config", Status: checkFail, Message: "invalid"},
+	})
+	require.EqualError(t, err, "doctor failed: config")
+	assert.Equal(t, "[OK] go: go1.23.4\n[FAIL] config: invalid\n", buf.String())
+}
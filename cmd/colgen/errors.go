@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+// errorKind is a class of colgen error, it defines exit code.
+type errorKind string
+
+const (
+	kindGeneric   errorKind = "generic"   // I/O, config and other errors
+	kindParse     errorKind = "parse"     // malformed directives
+	kindPackage   errorKind = "package"   // package loading and type errors
+	kindAssistant errorKind = "assistant" // assistant modes and provider errors
+)
+
+// exitCodes are process exit codes by error kind.
+var exitCodes = map[errorKind]int{
+	kindGeneric:   1,
+	kindParse:     2,
+	kindPackage:   3,
+	kindAssistant: 4,
+}
+
+// runError is a classified colgen error with optional location of directive.
+type runError struct {
+	Kind    errorKind `json:"kind"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Message string    `json:"message"`
+
+	err error
+}
+
+func (e *runError) Error() string {
+	if e.File == "" {
+		return e.Message
+	}
+
+	if e.Line == 0 {
+		return e.File + ": " + e.Message
+	}
+
+	return e.File + ":" + strconv.Itoa(e.Line) + ": " + e.Message
+}
+
+func (e *runError) Unwrap() error {
+	return e.err
+}
+
+// withFile adds filename to err. Line of directive is detected by quoted directive in err, e.g. `unknown line: "News Tag"`.
+// Directive is quoted after the invalid token, e.g. `invalid entity name: duplicate "News": "News,News"`, so the last
+// quoted directive wins.
+func withFile(err error, filename string, cl colgenLines) error {
+	if err == nil {
+		return nil
+	}
+
+	re := classifyError(err)
+	re.File = filename
+	pos := -1
+	for i, l := range cl.lines {
+		if i >= len(cl.lineNums) {
+			break
+		}
+
+		if p := strings.LastIndex(re.Message, strconv.Quote(strings.TrimSpace(l))); p > pos {
+			pos, re.Line = p, cl.lineNums[i]
+		}
+	}
+
+	return re
+}
+
+// classifyError returns runError with kind detected by colgen errors. Already classified errors are returned as is.
+func classifyError(err error) *runError {
+	var re *runError
+	if errors.As(err, &re) {
+		return re
+	}
+
+	return &runError{Kind: errorKindOf(err), Message: err.Error(), err: err}
+}
+
+// errorKindOf returns error kind by colgen sentinel errors.
+func errorKindOf(err error) errorKind {
+	switch {
+	case isAny(err, colgen.ErrUnknownLine, colgen.ErrMissingArg, colgen.ErrInvalidArg, colgen.ErrMissingEntity, colgen.ErrInvalidEntity,
+		colgen.ErrDuplicateRule, colgen.ErrOptionalRule, ErrInvalidAIPrompt):
+		return kindParse
+	case isAny(err, colgen.ErrLoadPackage, colgen.ErrNotInWorkspace, colgen.ErrMissingType, colgen.ErrIllTyped, colgen.ErrGeneratedType, colgen.ErrMissingField,
+		colgen.ErrFieldType, colgen.ErrUnexported, colgen.ErrFieldMethod, colgen.ErrUnusedImport, colgen.ErrMapFunc, colgen.ErrFormat):
+		return kindPackage
+	case isAny(err, colgen.ErrProvider, colgen.ErrInvalidUpgrade, colgen.ErrInvalidTests, colgen.ErrUnsupportedAssistMode, colgen.ErrUnsupportedAssistName, colgen.ErrSkippedTestFile, ErrAIDisabled):
+		return kindAssistant
+	}
+
+	return kindGeneric
+}
+
+// isAny checks that err matches any of targets.
+func isAny(err error, targets ...error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeError prints error as JSON line or as log message.
+func writeError(w io.Writer, re *runError, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(re)
+	}
+
+	_, err := io.WriteString(w, "generation failed: "+re.Error()+"\n")
+	return err
+}
+
+// exitOnErr prints the classified error and exits the program with exit code by error kind if error is not nil.
+func exitOnErr(err error) {
+	if err == nil {
+		return
+	}
+
+	re := classifyError(err)
+	if werr := writeError(os.Stderr, re, *flErrorsJSON); werr != nil {
+		log.Println(werr)
+	}
+
+	os.Exit(exitCodes[re.Kind])
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	_, parseErr := colgen.ParseRules([]string{"News Tag"}, false)
+	_, _, _, aiErr := extractAIPrompts("review(")
+	_, nameErr := colgen.NewAssistant("unknown", "")
+	_, readErr := os.ReadFile(filepath.Join(t.TempDir(), "not-exists.go"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pkgErr := colgen.NewGenerator("main", "", "", "devel").UsePackageDirWithContext(ctx, t.TempDir())
+
+	tests := []struct {
+		name     string
+		err      error
+		wantKind errorKind
+		wantCode int
+	}{
+		{name: "parse", err: parseErr, wantKind: kindParse, wantCode: 2},
+		{name: "invalid ai prompt", err: aiErr, wantKind: kindParse, wantCode: 2},
+		{name: "package", err: pkgErr, wantKind: kindPackage, wantCode: 3},
+		{name: "type", err: colgen.ErrMissingField, wantKind: kindPackage, wantCode: 3},
+		{name: "assistant", err: nameErr, wantKind: kindAssistant, wantCode: 4},
+		{name: "provider", err: errors.Join(colgen.ErrProvider, errors.New("401")), wantKind: kindAssistant, wantCode: 4},
+		{name: "generic", err: readErr, wantKind: kindGeneric, wantCode: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.err)
+			re := classifyError(tt.err)
+			assert.Equal(t, tt.wantKind, re.Kind)
+			assert.Equal(t, tt.wantCode, exitCodes[re.Kind])
+			assert.ErrorIs(t, re, tt.err)
+		})
+	}
+}
+
+func TestWithFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "news.go")
+	require.NoError(t, os.WriteFile(filename, []byte("package main\n\n//colgen:News\n//colgen:News:ByField(ID),Index\n"), 0644))
+
+	cl, err := readFile(filename)
+	require.NoError(t, err)
+
+	_, err = colgen.ParseRules(cl.lines, false)
+	require.Error(t, err)
+
+	err = withFile(err, filename, cl)
+	var re *runError
+	require.ErrorAs(t, err, &re)
+	assert.Equal(t, kindParse, re.Kind)
+	assert.Equal(t, filename, re.File)
+	assert.Equal(t, 4, re.Line)
+	assert.Contains(t, re.Error(), filename+":4: ")
+
+	require.NoError(t, withFile(nil, filename, cl))
+
+	// invalid token equals to previous directive
+	require.NoError(t, os.WriteFile(filename, []byte("package main\n\n//colgen:News\n//colgen:Tag,News,News\n"), 0644))
+	cl, err = readFile(filename)
+	require.NoError(t, err)
+
+	_, err = colgen.ParseRules(cl.lines, false)
+	require.ErrorIs(t, err, colgen.ErrInvalidEntity)
+	require.ErrorAs(t, withFile(err, filename, cl), &re)
+	assert.Equal(t, 4, re.Line)
+}
+
+func TestWriteError(t *testing.T) {
+	re := &runError{Kind: kindParse, File: "news.go", Line: 4, Message: "unknown line"}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeError(&buf, re, true))
+	assert.JSONEq(t, `{"kind":"parse","file":"news.go","line":4,"message":"unknown line"}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeError(&buf, re, false))
+	assert.Equal(t, "generation failed: news.go:4: unknown line\n", buf.String())
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+// frontMatter is a metadata of assistant markdown output written as YAML front matter, see AIFrontMatter in config.
+type frontMatter struct {
+	Source   string // source file
+	Package  string // package name of source file
+	Provider colgen.AssistantName
+	Model    string
+	Mode     colgen.AssistMode
+	Version  string // colgen version
+	Time     time.Time
+
+	aa *colgen.Assistant // provider and model are taken from assistant on writing: answer might be produced by fallback
+}
+
+// newFrontMatter returns front matter for source file processed by assistant or nil if it is disabled by config.
+// Tests mode never has front matter: its output is Go code. Package is empty if file doesn't parse.
+func newFrontMatter(cfg Config, aa *colgen.Assistant, an colgen.AssistantName, am colgen.AssistMode, filename string, content []byte) *frontMatter {
+	if !cfg.AIFrontMatter || am == colgen.ModeTests {
+		return nil
+	}
+
+	fm := &frontMatter{
+		Source:   filename,
+		Provider: an,
+		Model:    aa.Model(),
+		Mode:     am,
+		Version:  appVersion().String(),
+		Time:     time.Now().UTC(),
+		aa:       aa,
+	}
+
+	if f, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.PackageClauseOnly); err == nil {
+		fm.Package = f.Name.Name
+	}
+
+	return fm
+}
+
+// String renders front matter as YAML document with stable keys order. Values are double-quoted strings.
+func (fm frontMatter) String() string {
+	kv := [][2]string{
+		{"source", fm.Source},
+		{"package", fm.Package},
+		{"provider", string(fm.Provider)},
+		{"model", fm.Model},
+		{"mode", string(fm.Mode)},
+		{"colgen", fm.Version},
+		{"timestamp", fm.Time.Format(time.RFC3339)},
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	for _, v := range kv {
+		sb.WriteString(v[0] + ": " + strconv.Quote(v[1]) + "\n")
+	}
+	sb.WriteString("---\n\n")
+
+	return sb.String()
+}
+
+// writeMarkdown writes assistant markdown to file, front matter is prepended if fm is not nil.
+func writeMarkdown(filename string, data []byte, fm *frontMatter) error {
+	if fm != nil {
+		if fm.aa != nil {
+			fm.Provider, fm.Model = fm.aa.Provider(), fm.aa.Model()
+		}
+		data = append([]byte(fm.String()), data...)
+	}
+
+	return os.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+func TestFrontMatter_String(t *testing.T) {
+	fm := frontMatter{
+		Source:   "news/news.go",
+		Package:  "news",
+		Provider: colgen.AssistantClaude,
+		Model:    "model",
+		Mode:     colgen.ModeReadme,
+		Version:  `v1.0.0 "dirty"`,
+		Time:     time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+	}
+
+	s := fm.String()
+	require.True(t, strings.HasPrefix(s, "---\n"))
+	require.True(t, strings.HasSuffix(s, "\n---\n\n"))
+
+	// every line is `key: "value"`, values are valid YAML double-quoted strings
+	var keys []string
+	values := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(s, "---\n"), "\n---\n\n"), "\n") {
+		k, v, ok := strings.Cut(line, ": ")
+		require.True(t, ok, line)
+
+		uv, err := strconv.Unquote(v)
+		require.NoError(t, err, line)
+
+		keys = append(keys, k)
+		values[k] = uv
+	}
+
+	assert.Equal(t, []string{"source", "package", "provider", "model", "mode", "colgen", "timestamp"}, keys)
+	assert.Equal(t, `v1.0.0 "dirty"`, values["colgen"])
+	assert.Equal(t, "2026-10-16T12:00:00Z", values["timestamp"])
+	assert.Equal(t, s, fm.String(), "stable output")
+}
+
+func TestNewFrontMatter(t *testing.T) {
+	aa, err := colgen.NewAssistant(colgen.AssistantClaude, "key")
+	require.NoError(t, err)
+
+	cfg := Config{AIFrontMatter: true}
+	content := []byte("package news\n")
+
+	fm := newFrontMatter(cfg, aa, colgen.AssistantClaude, colgen.ModeReview, "news.go", content)
+	require.NotNil(t, fm)
+	assert.Equal(t, "news", fm.Package)
+	assert.Equal(t, aa.Model(), fm.Model)
+
+	assert.Nil(t, newFrontMatter(cfg, aa, colgen.AssistantClaude, colgen.ModeTests, "news.go", content), "tests never have front matter")
+	assert.Nil(t, newFrontMatter(Config{}, aa, colgen.AssistantClaude, colgen.ModeReview, "news.go", content), "disabled by config")
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "news.go.md")
+	fm := &frontMatter{Source: "news.go", Mode: colgen.ModeReview}
+
+	require.NoError(t, writeMarkdown(filename, []byte("# Review\n"), fm))
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, fm.String()+"# Review\n", string(data))
+
+	require.NoError(t, writeMarkdown(filename, []byte("# Review\n"), nil))
+	data, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "# Review\n", string(data))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// fileJob is a generation of a single file in multi-file run.
+type fileJob struct {
+	filename string
+	cl       colgenLines
+	logs     bytes.Buffer // buffered messages of job, printed after all jobs are done
+	result   genResult
+}
+
+// logf writes message to job logs.
+func (j *fileJob) logf(format string, args ...any) {
+	fmt.Fprintf(&j.logs, format+"\n", args...)
+}
+
+// generateFiles generates colgen files for files using up to jobs workers: package loading and generation
+// for independent files proceed concurrently. Logs are written to w sequentially in order of files.
+// Injections and assistant directives are not processed in multi-file run. Flags of go:generate lines are applied
+// before generation if all files have the same ones.
+// Assistant directives fail with ErrAIDisabled if AI features are disabled by policy, e.g. DisableAI of cfg.
+// Results are in order of files, results of routed outputs follow result of their file.
+func generateFiles(w io.Writer, files []string, jobs int, cfg Config) []genResult {
+	jj := make([]*fileJob, 0, len(files))
+	for _, filename := range files {
+		j := &fileJob{filename: filename}
+		j.cl, j.result.err = readFile(filename)
+		if j.cl.excluded && *flVerbose {
+			j.logf("%s", excludedMessage(filename, j.cl.skipped))
+		}
+		if j.result.err == nil && (len(j.cl.injection) > 0 || len(j.cl.assistant) > 0) {
+			j.logf("warning: %s: injections and assistant directives are skipped, run colgen via go generate", filename)
+		}
+		jj = append(jj, j)
+
+		// routed outputs are independent jobs
+		for _, rl := range j.cl.routes {
+			jj = append(jj, &fileJob{filename: filename, cl: rl})
+		}
+	}
+
+	applyFilesGenerateArgs(w, jj)
+
+	// -no-ai may be set by go:generate lines
+	if noAI := aiDisabledBy(cfg); noAI != "" {
+		for _, j := range jj {
+			if j.result.err == nil {
+				j.result.err = checkAssistantLines(j.cl, j.filename, noAI)
+			}
+		}
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, max(jobs, 1))
+	)
+
+	for _, j := range jj {
+		if j.result.err != nil || len(j.cl.lines) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			j.result = generate(j.cl, j.filename, nil, j.logf)
+		}()
+	}
+	wg.Wait()
+
+	results := make([]genResult, len(jj))
+	for i, j := range jj {
+		_, _ = w.Write(j.logs.Bytes())
+		results[i] = j.result
+	}
+
+	return results
+}
+
+// applyFilesGenerateArgs applies flags of go:generate lines of files, flags set on command line are kept.
+// Flags are shared by all files of multi-file run: they are skipped with warning if files have different ones.
+// Files without go:generate colgen line don't affect flags.
+func applyFilesGenerateArgs(w io.Writer, jj []*fileJob) {
+	var first *fileJob
+	for _, j := range jj {
+		switch {
+		case j.cl.generateArgs == nil:
+			continue
+		case first == nil:
+			first = j
+		case !slices.Equal(first.cl.generateArgs, j.cl.generateArgs):
+			fmt.Fprintf(w, "warning: %s and %s have different go:generate flags, they are skipped\n", first.filename, j.filename)
+			return
+		}
+	}
+
+	if first == nil {
+		return
+	}
+
+	if _, err := applyGenerateArgs(flag.CommandLine, first.cl.generateArgs); err != nil {
+		fmt.Fprintf(w, "warning: %s: %v\n", first.filename, err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFixtureTree writes module with several packages with colgen directives to dir and returns go files.
+func writeFixtureTree(t *testing.T, dir string) []string {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/tree\n\ngo 1.21\n"), 0644))
+
+	var files []string
+	for _, name := range []string{"news", "tags", "users", "events"} {
+		content := fmt.Sprintf("package %s\n\n//colgen:Item\n//colgen:Item:Index(Name),Len\n\ntype Item struct {\n\tID   int\n\tName string\n}\n", name)
+		filename := filepath.Join(dir, name, name+".go")
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+		files = append(files, filename)
+	}
+
+	// file without directives is skipped
+	filename := filepath.Join(dir, "empty", "empty.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+	require.NoError(t, os.WriteFile(filename, []byte("package empty\n"), 0644))
+
+	return append(files, filename)
+}
+
+func TestGenerateFiles(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	generated := func(jobs int) [][]byte {
+		files := writeFixtureTree(t, t.TempDir())
+
+		var logs bytes.Buffer
+		results := generateFiles(&logs, files, jobs, Config{})
+		require.Len(t, results, len(files))
+
+		var r [][]byte
+		for i, res := range results {
+			require.NoError(t, res.err)
+			if i == len(files)-1 {
+				assert.Empty(t, res.fd.Filename, "file without directives")
+				continue
+			}
+
+			content, err := os.ReadFile(res.fd.Filename)
+			require.NoError(t, err)
+			assert.Equal(t, content, res.fd.After)
+			assert.Equal(t, filepath.Dir(files[i]), filepath.Dir(res.fd.Filename))
+			assert.Equal(t, 1, res.stats.Entities)
+			r = append(r, content)
+		}
+
+		return r
+	}
+
+	serial := generated(1)
+	assert.Equal(t, serial, generated(4))
+	assert.Contains(t, string(serial[0]), "func (ll Items) IndexByName() map[string]Item")
+}
+
+func TestGenerateFiles_Errors(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	files := writeFixtureTree(t, dir)
+	bad := filepath.Join(dir, "bad", "bad.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(bad), 0755))
+	require.NoError(t, os.WriteFile(bad, []byte("package bad\n\n//colgen:Item\n//colgen:Item:Delta(Name)\n\ntype Item struct {\n\tID   int\n\tName string\n}\n"), 0644))
+
+	results := generateFiles(&bytes.Buffer{}, append(files, bad, filepath.Join(dir, "not-exists.go")), 2, Config{})
+	require.NoError(t, results[0].err)
+
+	var re *runError
+	require.ErrorAs(t, results[len(results)-2].err, &re)
+	assert.Equal(t, kindPackage, re.Kind)
+	assert.Equal(t, bad, re.File)
+	require.ErrorIs(t, results[len(results)-1].err, os.ErrNotExist)
+}
+
+func TestGenerate_ReportNames(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	reportNames := *flReportNames
+	t.Cleanup(func() { *flReportNames = reportNames })
+	*flReportNames = true
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/names\n\ngo 1.21\n"), 0644))
+	filename := filepath.Join(dir, "names.go")
+	content := "package names\n\n//colgen:News,Tag,Equipment\n\ntype News struct{ ID int }\n\ntype Tag struct{ ID int }\n\ntype Equipment struct{ ID int }\n"
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+
+	cl, err := readFile(filename)
+	require.NoError(t, err)
+
+	var logs []string
+	r := generate(cl, filename, nil, func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) })
+	require.NoError(t, r.err)
+	assert.Equal(t, []string{
+		"name: " + filename + ": Equipment => EquipmentList (plural equals singular, List suffix is added)",
+		"name: " + filename + ": News => NewsList (plural equals singular, List suffix is added)",
+		"name: " + filename + ": Tag => Tags (plural)",
+	}, logs)
+}
+
+func TestApplyFilesGenerateArgs(t *testing.T) {
+	list := *flList
+	t.Cleanup(func() { *flList = list })
+
+	var logs bytes.Buffer
+	applyFilesGenerateArgs(&logs, []*fileJob{
+		{filename: "news.go", cl: colgenLines{generateArgs: []string{"-list"}}},
+		{filename: "tags.go"},
+		{filename: "users.go", cl: colgenLines{generateArgs: []string{}}},
+	})
+	assert.Contains(t, logs.String(), "news.go and users.go have different go:generate flags")
+	assert.Equal(t, list, *flList)
+}
+
+func TestGenerate_ManualEdits(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	files := writeFixtureTree(t, t.TempDir())
+	cl, err := readFile(files[0])
+	require.NoError(t, err)
+
+	r := generate(cl, files[0], nil, t.Logf)
+	require.NoError(t, r.err)
+	require.Contains(t, string(r.fd.After), colgen.HashPrefix)
+
+	// pristine file is regenerated
+	r = generate(cl, files[0], nil, t.Logf)
+	require.NoError(t, r.err)
+
+	// hand-edited file is not overwritten
+	edited := append(bytes.Clone(r.fd.After), []byte("\n// manual edit\n")...)
+	require.NoError(t, os.WriteFile(r.fd.Filename, edited, 0644))
+
+	r = generate(cl, files[0], nil, t.Logf)
+	require.ErrorIs(t, r.err, colgen.ErrModified)
+	assert.Contains(t, r.err.Error(), "news_colgen.go")
+
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(files[0]), "news_colgen.go"))
+	require.NoError(t, err)
+	assert.Equal(t, edited, content)
+
+	// legacy file without hash is overwritten
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(files[0]), "news_colgen.go"), []byte("package news\n"), 0644))
+	r = generate(cl, files[0], nil, t.Logf)
+	require.NoError(t, r.err)
+
+	// -force overwrites manual edits
+	require.NoError(t, os.WriteFile(r.fd.Filename, edited, 0644))
+	*flForce = true
+	t.Cleanup(func() { *flForce = false })
+	r = generate(cl, files[0], nil, t.Logf)
+	require.NoError(t, r.err)
+}
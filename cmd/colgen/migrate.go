@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+// runMigrate rewrites colgen directives of files to the current syntax: colgen migrate [-dry-run] <file|dir|./...>.
+// With dry-run unified diff is printed instead of writing files. Returns error if any directive can't be translated.
+func runMigrate(w io.Writer, args []string) error {
+	fl := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fl.SetOutput(w)
+	dryRun := fl.Bool("dry-run", false, "print diff of migrated directives without writing files")
+	if err := fl.Parse(args); err != nil {
+		return err
+	}
+
+	paths := fl.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := migrateFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	var migrated, failed int
+	for _, filename := range files {
+		changed, notes, err := migrateFile(w, filename, *dryRun)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range notes {
+			fmt.Fprintf(w, "%s:%s\n", filename, n)
+		}
+
+		failed += len(notes)
+		if changed {
+			migrated++
+		}
+	}
+
+	if *dryRun {
+		fmt.Fprintf(w, "%d file(s) would be migrated\n", migrated)
+	} else {
+		fmt.Fprintf(w, "%d file(s) migrated\n", migrated)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d directive(s) can't be migrated", failed)
+	}
+
+	return nil
+}
+
+// migrateFile migrates directives of single file in place or prints diff with dry-run.
+func migrateFile(w io.Writer, filename string, dryRun bool) (bool, []colgen.MigrationNote, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return false, nil, err
+	}
+
+	result, notes, err := colgen.MigrateSource(filename, src)
+	if err != nil || bytes.Equal(src, result) {
+		return false, notes, err
+	}
+
+	if dryRun {
+		fd := colgen.FileDiff{Filename: filename, Before: src, After: result}
+		_, err = io.WriteString(w, fd.Unified())
+		return true, notes, err
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return false, notes, err
+	}
+
+	return true, notes, os.WriteFile(filename, result, fi.Mode().Perm())
+}
+
+// migrateFiles returns Go files by file, directory or recursive `./...` pattern paths.
+// Generated files, test files without -test-directives, testdata, vendor and hidden directories are skipped.
+func migrateFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		if root, ok := strings.CutSuffix(p, "..."); ok {
+			root = filepath.Clean(cmp.Or(root, "."))
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if d.IsDir() {
+					if path != root && skipMigrateDir(d.Name()) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				if isMigrateFile(path) {
+					files = append(files, path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fi, err := os.Stat(p)
+		switch {
+		case err != nil:
+			return nil, err
+		case !fi.IsDir():
+			files = append(files, p)
+			continue
+		}
+
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if path := filepath.Join(p, e.Name()); !e.IsDir() && isMigrateFile(path) {
+				files = append(files, path)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// skipMigrateDir reports whether directory is skipped like in `go list ./...`.
+func skipMigrateDir(name string) bool {
+	return name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// isMigrateFile reports whether file is Go source with possible directives, see skipDirectives.
+func isMigrateFile(path string) bool {
+	return strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, generatedSuffix) && skipDirectives(path) == ""
+}
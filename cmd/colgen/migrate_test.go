@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMigrate(t *testing.T) {
+	const (
+		src = "package news\n\n//colgen:News, Tag\n//colgen:News:index(CategoryID),MAPP(db)\n"
+		dst = "package news\n\n//colgen:News,Tag\n//colgen:News:Index(CategoryID),MapP(db)\n"
+	)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "news.go")
+	require.NoError(t, os.WriteFile(filename, []byte(src), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "testdata"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "testdata", "skip.go"), []byte(src), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "news_colgen.go"), []byte(src), 0600))
+
+	// dry-run prints diff only
+	var buf bytes.Buffer
+	require.NoError(t, runMigrate(&buf, []string{"-dry-run", dir + "/..."}))
+	assert.Contains(t, buf.String(), "+//colgen:News:Index(CategoryID),MapP(db)")
+	assert.Contains(t, buf.String(), "1 file(s) would be migrated")
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, src, string(b))
+
+	// files are rewritten in place
+	buf.Reset()
+	require.NoError(t, runMigrate(&buf, []string{dir}))
+	assert.Contains(t, buf.String(), "1 file(s) migrated")
+	b, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, dst, string(b))
+
+	// skipped files are not changed
+	b, err = os.ReadFile(filepath.Join(dir, "testdata", "skip.go"))
+	require.NoError(t, err)
+	assert.Equal(t, src, string(b))
+
+	// untranslatable directives are reported
+	bad := filepath.Join(dir, "bad.go")
+	require.NoError(t, os.WriteFile(bad, []byte("package news\n\n//colgen:News:Indx(ID)\n"), 0600))
+	buf.Reset()
+	err = runMigrate(&buf, []string{bad})
+	require.Error(t, err)
+	assert.Contains(t, buf.String(), bad+":3: //colgen:News:Indx(ID): can't translate")
+}
+
+func TestMigrateFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "a_colgen.go", "README.md", "sub/b.go", ".git/c.go", "vendor/d.go"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte("package a\n"), 0600))
+	}
+
+	files, err := migrateFiles([]string{dir + "/..."})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "sub", "b.go")}, files)
+
+	files, err = migrateFiles([]string{dir})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.go")}, files)
+
+	_, err = migrateFiles([]string{filepath.Join(dir, "missing.go")})
+	assert.Error(t, err)
+
+	// directive-like lines of generated and test files are not migrated
+	files, err = migrateFiles([]string{"../../pkg/colgen/testdata/selfref"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("../../pkg/colgen/testdata/selfref", "models.go")}, files)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// runContext is a state of a single run for $GOFILE shared by generation, injections and assistant.
+// Package of the file dir is loaded on first use and reused, so it is loaded once per run.
+type runContext struct {
+	cfg      Config
+	flags    *flag.FlagSet
+	filename string
+	cl       colgenLines // colgen lines of file with line numbers
+	st       *runStats
+
+	pkg    *packages.Package
+	pkgErr error
+	loads  int // number of package loads
+}
+
+// newRunContext returns run context of file with colgen lines.
+func newRunContext(cfg Config, flags *flag.FlagSet, filename string, cl colgenLines, st *runStats) *runContext {
+	return &runContext{cfg: cfg, flags: flags, filename: filename, cl: cl, st: st}
+}
+
+// loadPackage returns package of file dir, it is loaded on first call. Load time is added to stats.
+func (rc *runContext) loadPackage() (*packages.Package, error) {
+	if rc.pkg != nil || rc.pkgErr != nil {
+		return rc.pkg, rc.pkgErr
+	}
+
+	start := time.Now()
+	rc.pkg, rc.pkgErr = colgen.LoadPackage(context.Background(), filepath.Dir(rc.filename), buildTags())
+	rc.st.addLoadTime(time.Since(start))
+	rc.loads++
+
+	return rc.pkg, rc.pkgErr
+}
+
+// resetPackage drops loaded package, e.g. after injections changed the file, so it is loaded again on next use.
+func (rc *runContext) resetPackage() {
+	rc.pkg, rc.pkgErr = nil, nil
+}
+
+// setGeneratedMethods adds summary of generated methods of package to assistant prompts as context.
+// It is skipped if package fails to load.
+func (rc *runContext) setGeneratedMethods(aa *colgen.Assistant) {
+	pkg, err := rc.loadPackage()
+	if err != nil {
+		log.Println("generated methods are skipped:", err)
+		return
+	}
+
+	g := colgen.NewGenerator("", "", "", appVersion().String())
+	g.UsePackage(pkg)
+	aa.SetGeneratedMethods(g.GeneratedMethods(colgen.DefaultGeneratedMethodsBytes))
+}
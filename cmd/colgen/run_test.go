@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunContext_LoadPackage(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/run\n\ngo 1.21\n"), 0600))
+	filename := filepath.Join(dir, "models.go")
+	content := `package run
+
+//colgen:User
+//colgen[heavy_colgen.go]:Event
+//colgen[heavy_colgen.go]:Event:Index(Name)
+
+type User struct{ ID int }
+
+type Event struct {
+	ID   int
+	Name string
+}
+`
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+
+	cl, err := readFile(filename)
+	require.NoError(t, err)
+
+	// generation of file and routed output and assistant context share a single load
+	var st runStats
+	rc := newRunContext(Config{}, flag.CommandLine, filename, cl, &st)
+	fd := generateFile(rc, cl)
+	assert.Contains(t, string(fd.After), "type Users []User")
+	fd = generateFile(rc, cl.routes[0])
+	assert.Contains(t, string(fd.After), "func (ll Events) IndexByName() map[string]Event")
+
+	aa, err := colgen.NewAssistant(colgen.AssistantDeepSeek, "key")
+	require.NoError(t, err)
+	rc.setGeneratedMethods(aa)
+	assert.Equal(t, 1, rc.loads)
+
+	// package is loaded again after injections changed the file, so injected types are generated
+	content = `package run
+
+//colgen@newUserView(User)
+//colgen:UserView
+
+type User struct{ ID int }
+`
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+	cl, err = readFile(filename)
+	require.NoError(t, err)
+
+	rc = newRunContext(Config{}, flag.CommandLine, filename, cl, &st)
+	replaceFile(rc)
+	fd = generateFile(rc, cl)
+	assert.Contains(t, string(fd.After), "type UserViews []UserView")
+	assert.Equal(t, 2, rc.loads)
+	assert.Equal(t, 1, st.Injections)
+}
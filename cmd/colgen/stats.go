@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+// runStats is a summary of colgen run across generation, replacement and assistant phases.
+type runStats struct {
+	Entities     int            `json:"entities"`
+	Methods      map[string]int `json:"methods"`
+	Injections   int            `json:"injections"`
+	BytesWritten int            `json:"bytesWritten"`
+	LoadTimeMs   int64          `json:"loadTimeMs"`
+	InputTokens  int            `json:"inputTokens"`
+	OutputTokens int            `json:"outputTokens"`
+}
+
+// addGenerator adds generator counters to stats.
+func (s *runStats) addGenerator(st colgen.Stats) {
+	s.Entities += st.Entities
+	s.BytesWritten += st.Bytes
+	s.addLoadTime(st.LoadTime)
+
+	if s.Methods == nil {
+		s.Methods = make(map[string]int)
+	}
+	for k, v := range st.Methods {
+		s.Methods[k] += v
+	}
+}
+
+// addLoadTime adds package loading time to stats.
+func (s *runStats) addLoadTime(d time.Duration) {
+	s.LoadTimeMs += d.Milliseconds()
+}
+
+// addUsage adds assistant tokens usage to stats.
+func (s *runStats) addUsage(u colgen.Usage) {
+	s.InputTokens += u.InputTokens
+	s.OutputTokens += u.OutputTokens
+}
+
+// write prints stats as human-readable table or as JSON.
+func (s runStats) write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(s)
+	}
+
+	methods := make([]string, 0, len(s.Methods))
+	for _, k := range slices.Sorted(maps.Keys(s.Methods)) {
+		methods = append(methods, fmt.Sprintf("%s=%d", k, s.Methods[k]))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "entities\t%d\n", s.Entities)
+	fmt.Fprintf(tw, "methods\t%s\n", strings.Join(methods, " "))
+	fmt.Fprintf(tw, "injections\t%d\n", s.Injections)
+	fmt.Fprintf(tw, "bytes written\t%d\n", s.BytesWritten)
+	fmt.Fprintf(tw, "load time\t%dms\n", s.LoadTimeMs)
+	fmt.Fprintf(tw, "tokens\tin=%d out=%d\n", s.InputTokens, s.OutputTokens)
+
+	return tw.Flush()
+}
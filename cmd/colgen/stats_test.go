@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStats(t *testing.T) {
+	var st runStats
+	st.addGenerator(colgen.Stats{Entities: 2, Methods: map[string]int{"IDs": 2, "Index": 1}, Bytes: 100, LoadTime: 1500 * time.Millisecond})
+	st.addGenerator(colgen.Stats{Entities: 1, Methods: map[string]int{"IDs": 1}, Bytes: 50, LoadTime: 500 * time.Millisecond})
+	st.addUsage(colgen.Usage{InputTokens: 10, OutputTokens: 5})
+	st.Injections = 1
+
+	want := runStats{
+		Entities:     3,
+		Methods:      map[string]int{"IDs": 3, "Index": 1},
+		Injections:   1,
+		BytesWritten: 150,
+		LoadTimeMs:   2000,
+		InputTokens:  10,
+		OutputTokens: 5,
+	}
+	assert.Equal(t, want, st)
+
+	var buf bytes.Buffer
+	require.NoError(t, st.write(&buf, false))
+	assert.Contains(t, buf.String(), "methods        IDs=3 Index=1\n")
+	assert.Contains(t, buf.String(), "tokens         in=10 out=5\n")
+
+	buf.Reset()
+	require.NoError(t, st.write(&buf, true))
+	var got runStats
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vmkteam/colgen/pkg/colgen"
+)
+
+// runVet reports likely mistakes in directives of files without generation: colgen vet [<file.go>...].
+// Files default to $GOFILE. Findings don't fail the command, only parse and package loading errors do.
+func runVet(w io.Writer, files []string) error {
+	if len(files) == 0 {
+		filename := os.Getenv("GOFILE")
+		if filename == "" {
+			return errors.New("no files to vet, usage: colgen vet <file.go>...")
+		}
+		files = []string{filename}
+	}
+
+	for _, filename := range files {
+		cl, err := readFile(filename)
+		if err != nil {
+			return err
+		}
+		if cl.excluded {
+			continue
+		}
+
+		// routed lines are vetted with lines of their output
+		for _, c := range append([]colgenLines{cl}, cl.routes...) {
+			if err = vetLines(w, c, filename); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// vetLines writes findings of colgen lines of file to w.
+func vetLines(w io.Writer, cl colgenLines, filename string) error {
+	if len(cl.lines) == 0 {
+		return nil
+	}
+
+	rules, nolint, err := parseVetRules(cl)
+	if err != nil {
+		return withFile(err, filename, cl)
+	}
+
+	g := colgen.NewGenerator(cl.pkgName, *flImports, *flFuncPkg, appVersion().String())
+	g.SetBuildTags(buildTags())
+	if err = g.UsePackageDir(filepath.Dir(filename)); err != nil {
+		return withFile(err, filename, cl)
+	}
+
+	findings, err := g.Vet(rules, nolint)
+	if err != nil {
+		return withFile(err, filename, cl)
+	}
+
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s: %s\n", filename, f)
+	}
+
+	return nil
+}
+
+// parseVetRules returns rules and suppressed findings of colgen lines.
+func parseVetRules(cl colgenLines) ([]colgen.Rule, colgen.Nolint, error) {
+	rules, err := colgen.ParseRules(cl.lines, *flList)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nolint, err := colgen.ParseNolint(cl.lines)
+	return rules, nolint, err
+}
+
+// logFindings logs vet findings of rules after generation, it is used with -verbose.
+func logFindings(g *colgen.Generator, rules []colgen.Rule, cl colgenLines, filename string, logf func(format string, args ...any)) {
+	nolint, err := colgen.ParseNolint(cl.lines)
+	if err != nil {
+		logf("vet: %s: %v", filename, err)
+		return
+	}
+
+	findings, err := g.Vet(rules, nolint)
+	if err != nil {
+		logf("vet: %s: %v", filename, err)
+		return
+	}
+
+	for _, f := range findings {
+		logf("vet: %s: %s", filename, f)
+	}
+}
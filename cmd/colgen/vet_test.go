@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunVet(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	src := "package news\n\n//colgen:News,Tag\n//colgen:News:UniqueID,MapP(db)\n//colgen:nolint:CG004\n\n" +
+		"type News struct{ ID int }\n\ntype Tag struct{ Name string }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/news\n\ngo 1.21\n"), 0600))
+	filename := filepath.Join(dir, "news.go")
+	require.NoError(t, os.WriteFile(filename, []byte(src), 0600))
+
+	var buf bytes.Buffer
+	require.NoError(t, runVet(&buf, []string{filename}))
+	assert.Equal(t, filename+": CG002: News: Unique(ID): values of ID field are unique already, use IDs instead\n"+
+		filename+": CG001: Tag: entity has no ID field and custom rules, only collection type is generated, add custom rules or remove it from base entities\n", buf.String())
+
+	// no files
+	t.Setenv("GOFILE", "")
+	assert.Error(t, runVet(&buf, nil))
+
+	// invalid directive
+	require.NoError(t, os.WriteFile(filename, []byte("package news\n\n//colgen:News Tag\n"), 0600))
+	assert.Error(t, runVet(&buf, []string{filename}))
+}
@@ -0,0 +1,10 @@
+// Package domain contains types shared between packages, e.g. enums.
+package domain
+
+// Status is a publication status.
+type Status string
+
+const (
+	StatusDraft     Status = "draft"
+	StatusPublished Status = "published"
+)
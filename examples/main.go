@@ -1,23 +1,85 @@
 package main
 
-//go:generate go run ../cmd/colgen/colgen.go
+import (
+	"strings"
+
+	"github.com/vmkteam/colgen/examples/domain"
+)
+
+//go:generate go run ../cmd/colgen
 
 //colgen:News,Tag
 //colgen:News:Index(Title),Group(Title),UniqueTitle,UniqueTagIDs
+//colgen:News:IndexMultiPtr(CategoryID),Avg(ViewCount),StdDev(ViewCount),Accumulate(ViewCount)
+//colgen:News:IDsAppend,Append(Title),Missing(TagIDs,Tag),Attach(Tags,TagIDs,Tags),Merge
+//colgen:News:Group(Status),Partition(Pinned),Distinct(Title),Distinct(TagIDs),TakeWhile(Pinned),DropWhile(Pinned)
+//colgen:News:Sparse(AuthorID),UniqueSorted(TagIDs),GroupSorted(CategoryID),IndexInto,GroupInto(CategoryID)
+//colgen:News:FlattenSections,Keys(Metadata),Values(Metadata),WithTitle
+//colgen:News:Field(Author.Company.Name),FieldOr(Author.Company.Name,"unknown")
+//colgen:Tag:Exclude(0,999),Len,JSON,Head,Tail,Rotate,Paginate,Shuffle,Synced
+//colgen:Tag:Apply(trimName),SQLIn(mysql),CacheKey,Hash,Unique(Name,fold,trim),IndexExact(Name),Index(Slug())
+//colgen:News:Sortable(Title),Sort(-ViewCount,Title),First,Last,IndexCaseInsensitive(Title),Associate(URL,Title),Pairs(ID,Title)
+//colgen:Category
+//colgen:Category:FlattenSubCategories,Index(ParentID),Group(ParentID,zeronil)
+//colgen:Sale
+//colgen:Sale:Pivot(Month,Amount),Delta(Amount)
 
 func main() {
 
 }
 
 type News struct {
+	ID         int
+	CategoryID int
+	Title      string
+	URL        string
+	TagIDs     []int
+	Tags       []Tag
+	ViewCount  int
+	Status     domain.Status
+	Pinned     bool
+	AuthorID   int     // 0 if author is unknown
+	Author     *Author // nil if author is not loaded
+	Sections   [][]Paragraph
+	Metadata   map[string]string
+}
+
+type Author struct {
+	ID      int
+	Company *Company // nil for freelancers
+}
+
+type Company struct {
+	Name string
+}
+
+type Paragraph struct {
+	Text string
+}
+
+type Category struct {
+	ID            int
+	ParentID      *int
+	SubCategories []Category
+}
+
+type Sale struct {
 	ID     int
-	Title  string
-	URL    string
-	TagIDs []int
-	Tags   []Tag
+	Month  string
+	Amount float64
 }
 
 type Tag struct {
 	ID   int
 	Name string
 }
+
+// trimName trims spaces in tag name.
+func trimName(t *Tag) {
+	t.Name = strings.TrimSpace(t.Name)
+}
+
+// Slug returns lowercase tag name for URLs.
+func (t *Tag) Slug() string {
+	return strings.ToLower(t.Name)
+}
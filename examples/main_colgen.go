@@ -1,6 +1,76 @@
 // Code generated by colgen devel; DO NOT EDIT.
+// colgen:sha256:5b12c07933dd7f68a75b0bced21164acc07d9b335d5f4dd62af77d9270373d8b
 package main
 
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vmkteam/colgen/examples/domain"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type Categories []Category
+
+func (ll Categories) IDs() []int {
+	r := make([]int, len(ll))
+	for i := range ll {
+		r[i] = ll[i].ID
+	}
+	return r
+}
+
+func (ll Categories) Index() map[int]Category {
+	r := make(map[int]Category, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i]
+	}
+	return r
+}
+
+// FlattenSubCategories returns values of SubCategories of all elements of ll recursively in breadth-first order.
+func (ll Categories) FlattenSubCategories() Categories {
+	r := make(Categories, 0, len(ll))
+	for i := range ll {
+		r = append(r, ll[i].SubCategories...)
+	}
+	for i := 0; i < len(r); i++ {
+		r = append(r, r[i].SubCategories...)
+	}
+	return r
+}
+
+// IndexByParentID returns elements of ll indexed by value of ParentID, elements with nil ParentID are skipped.
+func (ll Categories) IndexByParentID() map[int]Category {
+	r := make(map[int]Category, len(ll))
+	for i := range ll {
+		if ll[i].ParentID == nil {
+			continue
+		}
+		r[*ll[i].ParentID] = ll[i]
+	}
+	return r
+}
+
+// GroupByParentID returns elements of ll grouped by value of ParentID, elements with nil ParentID are grouped by zero value.
+func (ll Categories) GroupByParentID() map[int]Categories {
+	r := make(map[int]Categories, len(ll))
+	for i := range ll {
+		var k int
+		if ll[i].ParentID != nil {
+			k = *ll[i].ParentID
+		}
+		r[k] = append(r[k], ll[i])
+	}
+	return r
+}
+
 type NewsList []News
 
 func (ll NewsList) IDs() []int {
@@ -69,6 +139,471 @@ func (ll NewsList) UniqueTagIDs() []int {
 	return r
 }
 
+// IndexByCategoryID returns pointers to elements of ll grouped by CategoryID.
+// Pointers refer to the original slice and are invalidated if ll is modified (e.g. reallocated by append).
+func (ll NewsList) IndexByCategoryID() map[int][]*News {
+	r := make(map[int][]*News, len(ll))
+	for i := range ll {
+		r[ll[i].CategoryID] = append(r[ll[i].CategoryID], &ll[i])
+	}
+	return r
+}
+
+// AvgViewCount returns arithmetic mean of ViewCount or 0 if ll is empty.
+func (ll NewsList) AvgViewCount() float64 {
+	if len(ll) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := range ll {
+		sum += float64(ll[i].ViewCount)
+	}
+	return sum / float64(len(ll))
+}
+
+// StdDevViewCount returns population standard deviation of ViewCount or 0 if ll has less than two elements.
+func (ll NewsList) StdDevViewCount() float64 {
+	if len(ll) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for i := range ll {
+		mean += float64(ll[i].ViewCount)
+	}
+	mean /= float64(len(ll))
+
+	var variance float64
+	for i := range ll {
+		d := float64(ll[i].ViewCount) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(ll)))
+}
+
+// AccumulatedViewCounts returns running totals of ViewCount: r[i] is a sum of ViewCount of elements 0..i.
+func (ll NewsList) AccumulatedViewCounts() []int {
+	r := make([]int, len(ll))
+	var sum int
+	for i := range ll {
+		sum += ll[i].ViewCount
+		r[i] = sum
+	}
+	return r
+}
+
+// AppendIDs appends ID of all elements to dst and returns the extended slice.
+// It allows reusing dst between calls, e.g. via sync.Pool.
+func (ll NewsList) AppendIDs(dst []int) []int {
+	for i := range ll {
+		dst = append(dst, ll[i].ID)
+	}
+	return dst
+}
+
+// AppendTitles appends Title of all elements to dst and returns the extended slice.
+// It allows reusing dst between calls, e.g. via sync.Pool.
+func (ll NewsList) AppendTitles(dst []string) []string {
+	for i := range ll {
+		dst = append(dst, ll[i].Title)
+	}
+	return dst
+}
+
+// MissingTagIDs returns unique values of TagIDs of all elements that are not IDs of tags in order of first occurrence.
+func (ll NewsList) MissingTagIDs(tags Tags) []int {
+	idx := make(map[int]struct{}, len(tags))
+	for i := range tags {
+		idx[tags[i].ID] = struct{}{}
+	}
+	r := make([]int, 0)
+	for i := range ll {
+		for _, v := range ll[i].TagIDs {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}
+
+// AttachTags sets Tags of elements of ll to elements of tags by TagIDs in order of TagIDs,
+// elements of ll are modified in place. IDs without element in tags are skipped.
+func (ll NewsList) AttachTags(tags Tags) {
+	idx := make(map[int]Tag, len(tags))
+	for i := range tags {
+		idx[tags[i].ID] = tags[i]
+	}
+	for i := range ll {
+		r := make([]Tag, 0, len(ll[i].TagIDs))
+		for _, id := range ll[i].TagIDs {
+			if v, ok := idx[id]; ok {
+				r = append(r, v)
+			}
+		}
+		ll[i].Tags = r
+	}
+}
+
+// Merge returns a new collection with elements of ll in order of ll, where elements of other replace elements of ll
+// with the same ID in place, and elements of other with new IDs are appended in order of other. Only the first element
+// of ll with repeated ID is replaced, the last element of other with repeated ID wins. ll and other are not modified.
+func (ll NewsList) Merge(other NewsList) NewsList {
+	idx := make(map[int]int, len(ll)+len(other))
+	r := make(NewsList, 0, len(ll)+len(other))
+	for i := range ll {
+		if _, ok := idx[ll[i].ID]; !ok {
+			idx[ll[i].ID] = len(r)
+		}
+		r = append(r, ll[i])
+	}
+	for i := range other {
+		if j, ok := idx[other[i].ID]; ok {
+			r[j] = other[i]
+			continue
+		}
+		idx[other[i].ID] = len(r)
+		r = append(r, other[i])
+	}
+	return r
+}
+
+func (ll NewsList) GroupByStatus() map[domain.Status]NewsList {
+	r := make(map[domain.Status]NewsList, len(ll))
+	for i := range ll {
+		r[ll[i].Status] = append(r[ll[i].Status], ll[i])
+	}
+	return r
+}
+
+// PartitionByPinned splits ll into elements with Pinned set and the rest, preserving order.
+func (ll NewsList) PartitionByPinned() (matched, rest NewsList) {
+	matched, rest = NewsList{}, NewsList{}
+	for i := range ll {
+		if ll[i].Pinned {
+			matched = append(matched, ll[i])
+		} else {
+			rest = append(rest, ll[i])
+		}
+	}
+	return matched, rest
+}
+
+// DistinctTitles returns unique values of Title in order of first occurrence.
+func (ll NewsList) DistinctTitles() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[ll[i].Title]; !ok {
+			idx[ll[i].Title] = struct{}{}
+			r = append(r, ll[i].Title)
+		}
+	}
+	return r
+}
+
+// DistinctTagIDs returns unique values of TagIDs of all elements in order of first occurrence.
+func (ll NewsList) DistinctTagIDs() []int {
+	idx := make(map[int]struct{}, len(ll))
+	r := make([]int, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].TagIDs {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}
+
+// TakeWhilePinned returns the longest prefix of ll with Pinned equal to v. Result shares the backing array with ll.
+func (ll NewsList) TakeWhilePinned(v bool) NewsList {
+	i := 0
+	for i < len(ll) && ll[i].Pinned == v {
+		i++
+	}
+	if i == 0 {
+		return NewsList{}
+	}
+	return ll[:i]
+}
+
+// DropWhilePinned returns elements of ll after the longest prefix with Pinned equal to v. Result shares the backing array with ll.
+func (ll NewsList) DropWhilePinned(v bool) NewsList {
+	i := 0
+	for i < len(ll) && ll[i].Pinned == v {
+		i++
+	}
+	if i == len(ll) {
+		return NewsList{}
+	}
+	return ll[i:]
+}
+
+// SparseByAuthorID returns elements of ll with non-zero AuthorID indexed by AuthorID, the last element wins for equal keys.
+func (ll NewsList) SparseByAuthorID() map[int]News {
+	r := make(map[int]News, len(ll))
+	for i := range ll {
+		if ll[i].AuthorID != 0 {
+			r[ll[i].AuthorID] = ll[i]
+		}
+	}
+	return r
+}
+
+// UniqueSortedTagIDs returns unique values of TagIDs of all elements sorted in ascending order.
+func (ll NewsList) UniqueSortedTagIDs() []int {
+	idx := make(map[int]struct{}, len(ll))
+	r := make([]int, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].TagIDs {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	slices.Sort(r)
+	return r
+}
+
+// GroupSortedByCategoryID returns elements of ll grouped by CategoryID and keys of groups sorted in ascending order.
+// Iterate over keys to process groups in order of CategoryID.
+func (ll NewsList) GroupSortedByCategoryID() (map[int]NewsList, []int) {
+	groups := make(map[int]NewsList, len(ll))
+	r := make([]int, 0, len(ll))
+	for i := range ll {
+		if _, ok := groups[ll[i].CategoryID]; !ok {
+			r = append(r, ll[i].CategoryID)
+		}
+		groups[ll[i].CategoryID] = append(groups[ll[i].CategoryID], ll[i])
+	}
+	slices.Sort(r)
+	return groups, r
+}
+
+// IndexInto fills dst with elements of ll indexed by ID and returns dst, dst is allocated if nil.
+// It allows reusing dst between calls, e.g. for paginated results. Stale keys from previous fills are not removed.
+func (ll NewsList) IndexInto(dst map[int]News) map[int]News {
+	if dst == nil {
+		dst = make(map[int]News, len(ll))
+	}
+	for i := range ll {
+		dst[ll[i].ID] = ll[i]
+	}
+	return dst
+}
+
+// GroupByCategoryIDInto appends elements of ll to groups in dst by CategoryID and returns dst, dst is allocated if nil.
+// It allows reusing dst between calls, e.g. for paginated results. Stale groups from previous fills are not removed.
+func (ll NewsList) GroupByCategoryIDInto(dst map[int]NewsList) map[int]NewsList {
+	if dst == nil {
+		dst = make(map[int]NewsList, len(ll))
+	}
+	for i := range ll {
+		dst[ll[i].CategoryID] = append(dst[ll[i].CategoryID], ll[i])
+	}
+	return dst
+}
+
+// FlattenSections returns values of all inner slices of Sections of all elements of ll in order.
+func (ll NewsList) FlattenSections() []Paragraph {
+	r := make([]Paragraph, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Sections {
+			r = append(r, v...)
+		}
+	}
+	return r
+}
+
+// MetadataKeys returns keys of Metadata of all elements of ll, order of keys of each map is not specified.
+func (ll NewsList) MetadataKeys() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for k := range ll[i].Metadata {
+			r = append(r, k)
+		}
+	}
+	return r
+}
+
+// MetadataValues returns values of Metadata of all elements of ll, order of values of each map is not specified.
+func (ll NewsList) MetadataValues() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Metadata {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// WithTitle returns a copy of News with Title set to v, n is not modified.
+func (n News) WithTitle(v string) News {
+	n.Title = v
+	return n
+}
+
+// AuthorCompanyNames returns Author.Company.Name of elements, elements with nil Author or Author.Company are skipped.
+func (ll NewsList) AuthorCompanyNames() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		if ll[i].Author == nil || ll[i].Author.Company == nil {
+			continue
+		}
+		r = append(r, ll[i].Author.Company.Name)
+	}
+	return r
+}
+
+// AuthorCompanyNamesOr returns Author.Company.Name of elements, "unknown" for elements with nil Author or Author.Company.
+func (ll NewsList) AuthorCompanyNamesOr() []string {
+	r := make([]string, len(ll))
+	for i := range ll {
+		if ll[i].Author == nil || ll[i].Author.Company == nil {
+			r[i] = "unknown"
+			continue
+		}
+		r[i] = ll[i].Author.Company.Name
+	}
+	return r
+}
+
+// Len returns number of elements in collection, e.g. for sort.Interface.
+func (ll NewsList) Len() int {
+	return len(ll)
+}
+
+// IsEmpty returns true if collection has no elements.
+func (ll NewsList) IsEmpty() bool {
+	return len(ll) == 0
+}
+
+// Swap swaps elements with indexes i and j, implements sort.Interface.
+func (ll NewsList) Swap(i, j int) {
+	ll[i], ll[j] = ll[j], ll[i]
+}
+
+// Less reports whether element i must sort before element j by Title, implements sort.Interface.
+func (ll NewsList) Less(i, j int) bool {
+	return ll[i].Title < ll[j].Title
+}
+
+// SortByViewCountDescTitle returns a copy of ll stably sorted by ViewCount in descending order, then by Title, ll is not modified.
+func (ll NewsList) SortByViewCountDescTitle() NewsList {
+	r := make(NewsList, len(ll))
+	copy(r, ll)
+	slices.SortStableFunc(r, func(a, b News) int {
+		if c := cmp.Compare(b.ViewCount, a.ViewCount); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Title, b.Title)
+	})
+	return r
+}
+
+// First returns the first element of ll or ErrEmptyCollection if ll is empty.
+func (ll NewsList) First() (News, error) {
+	if len(ll) == 0 {
+		return News{}, ErrEmptyCollection
+	}
+	return ll[0], nil
+}
+
+// Last returns the last element of ll or ErrEmptyCollection if ll is empty.
+func (ll NewsList) Last() (News, error) {
+	if len(ll) == 0 {
+		return News{}, ErrEmptyCollection
+	}
+	return ll[len(ll)-1], nil
+}
+
+// IndexByTitleCI returns elements of ll indexed by lowercased Title, the last element wins for equal keys.
+// Lookup keys must be lowercased with strings.ToLower as well.
+func (ll NewsList) IndexByTitleCI() map[string]News {
+	r := make(map[string]News, len(ll))
+	for i := range ll {
+		r[strings.ToLower(ll[i].Title)] = ll[i]
+	}
+	return r
+}
+
+// AssociateURLTitle returns Title of elements of ll indexed by URL, the last element wins for equal keys.
+func (ll NewsList) AssociateURLTitle() map[string]string {
+	r := make(map[string]string, len(ll))
+	for i := range ll {
+		r[ll[i].URL] = ll[i].Title
+	}
+	return r
+}
+
+// NewsIDTitlePair is a pair of ID and Title of News.
+type NewsIDTitlePair struct {
+	ID    int
+	Title string
+}
+
+// IDTitlePairs returns pairs of ID and Title of elements of ll in order, e.g. for ordered options.
+func (ll NewsList) IDTitlePairs() []NewsIDTitlePair {
+	r := make([]NewsIDTitlePair, len(ll))
+	for i := range ll {
+		r[i] = NewsIDTitlePair{ID: ll[i].ID, Title: ll[i].Title}
+	}
+	return r
+}
+
+type Sales []Sale
+
+func (ll Sales) IDs() []int {
+	r := make([]int, len(ll))
+	for i := range ll {
+		r[i] = ll[i].ID
+	}
+	return r
+}
+
+func (ll Sales) Index() map[int]Sale {
+	r := make(map[int]Sale, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i]
+	}
+	return r
+}
+
+// PivotMonthAmount returns Amount values of elements of ll grouped by Month in order of elements.
+func (ll Sales) PivotMonthAmount() map[string][]float64 {
+	r := make(map[string][]float64, len(ll))
+	for i := range ll {
+		r[ll[i].Month] = append(r[ll[i].Month], ll[i].Amount)
+	}
+	return r
+}
+
+// DeltaAmount returns difference of Amount between ll and prev (current - previous) by ID.
+// Elements present only in ll or only in prev have their full Amount, the last element wins for duplicate IDs.
+func (ll Sales) DeltaAmount(prev Sales) map[int]float64 {
+	r := make(map[int]float64, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i].Amount
+	}
+	old := make(map[int]float64, len(prev))
+	for i := range prev {
+		old[prev[i].ID] = prev[i].Amount
+	}
+	for id, v := range old {
+		if cur, ok := r[id]; ok {
+			r[id] = cur - v
+		} else {
+			r[id] = v
+		}
+	}
+	return r
+}
+
 type Tags []Tag
 
 func (ll Tags) IDs() []int {
@@ -86,3 +621,240 @@ func (ll Tags) Index() map[int]Tag {
 	}
 	return r
 }
+
+// Exclude returns elements of ll except ones with ID in (0, 999).
+func (ll Tags) Exclude() Tags {
+	excluded := []int{0, 999}
+	r := make(Tags, 0, len(ll))
+	for i := range ll {
+		if !slices.Contains(excluded, ll[i].ID) {
+			r = append(r, ll[i])
+		}
+	}
+	return r
+}
+
+// Len returns number of elements in collection, e.g. for sort.Interface.
+func (ll Tags) Len() int {
+	return len(ll)
+}
+
+// IsEmpty returns true if collection has no elements.
+func (ll Tags) IsEmpty() bool {
+	return len(ll) == 0
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler interface using JSON, e.g. for caching in Redis.
+func (ll Tags) MarshalBinary() ([]byte, error) {
+	return json.Marshal([]Tag(ll))
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface using JSON.
+func (ll *Tags) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, (*[]Tag)(ll))
+}
+
+// Head returns the first n elements of ll or all elements if n >= len(ll). Result shares the backing array with ll.
+func (ll Tags) Head(n int) Tags {
+	if n <= 0 || len(ll) == 0 {
+		return Tags{}
+	}
+	return ll[:min(n, len(ll))]
+}
+
+// Tail returns the last n elements of ll or all elements if n >= len(ll). Result shares the backing array with ll.
+func (ll Tags) Tail(n int) Tags {
+	if n <= 0 || len(ll) == 0 {
+		return Tags{}
+	}
+	return ll[len(ll)-min(n, len(ll)):]
+}
+
+// Rotate returns a new collection with elements of ll rotated left by n positions, negative n rotates right.
+func (ll Tags) Rotate(n int) Tags {
+	r := make(Tags, len(ll))
+	if len(ll) == 0 {
+		return r
+	}
+
+	n %= len(ll)
+	if n < 0 {
+		n += len(ll)
+	}
+	copy(r, ll[n:])
+	copy(r[len(ll)-n:], ll[:n])
+	return r
+}
+
+// Paginate returns elements of ll on page with 1-based number and given size and pagination metadata.
+// Page < 1 is treated as the first page, empty collection is returned for pages out of range and size <= 0.
+// Result shares the backing array with ll.
+func (ll Tags) Paginate(page, size int) (Tags, PaginationMeta) {
+	page = max(page, 1)
+	meta := PaginationMeta{Page: page, Size: size, TotalItems: len(ll)}
+	if size <= 0 {
+		return Tags{}, meta
+	}
+
+	meta.TotalPages = (len(ll) + size - 1) / size
+	if page > meta.TotalPages {
+		return Tags{}, meta
+	}
+
+	start := (page - 1) * size
+	return ll[start:min(start+size, len(ll))], meta
+}
+
+// Shuffle returns a new collection with elements of ll in random order, ll is not modified.
+func (ll Tags) Shuffle() Tags {
+	return ll.ShuffleWith(nil)
+}
+
+// ShuffleWith returns a new collection with elements of ll in random order of rnd, ll is not modified.
+// Global source of math/rand/v2 is used if rnd is nil, seeded rnd gives reproducible order, e.g. in tests.
+func (ll Tags) ShuffleWith(rnd *rand.Rand) Tags {
+	intN := rand.IntN
+	if rnd != nil {
+		intN = rnd.IntN
+	}
+
+	r := make(Tags, len(ll))
+	copy(r, ll)
+	for i := len(r) - 1; i > 0; i-- {
+		j := intN(i + 1)
+		r[i], r[j] = r[j], r[i]
+	}
+	return r
+}
+
+// SyncedTags is Tags guarded by sync.RWMutex, its methods are safe for concurrent use.
+// Tags itself is not safe for concurrent use. Zero value is an empty collection, it must not be copied.
+type SyncedTags struct {
+	mu sync.RWMutex
+	ll Tags
+}
+
+// NewSyncedTags returns SyncedTags with a copy of ll.
+func NewSyncedTags(ll Tags) *SyncedTags {
+	r := make(Tags, len(ll))
+	copy(r, ll)
+	return &SyncedTags{ll: r}
+}
+
+// Add appends elements to collection.
+func (s *SyncedTags) Add(vv ...Tag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ll = append(s.ll, vv...)
+}
+
+// All returns a copy of collection, it can be used without lock.
+func (s *SyncedTags) All() Tags {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := make(Tags, len(s.ll))
+	copy(r, s.ll)
+	return r
+}
+
+// Get returns the first element with given ID and true if it exists.
+func (s *SyncedTags) Get(id int) (Tag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.ll {
+		if s.ll[i].ID == id {
+			return s.ll[i], true
+		}
+	}
+	var zero Tag
+	return zero, false
+}
+
+// Len returns number of elements in collection.
+func (s *SyncedTags) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ll)
+}
+
+// Apply calls fn for each element of ll by pointer and returns ll. Default functions: trimName.
+func (ll Tags) Apply(fn func(*Tag)) Tags {
+	for i := range ll {
+		fn(&ll[i])
+	}
+	return ll
+}
+
+// trimName applies trimName to each element of ll and returns ll.
+func (ll Tags) trimName() Tags {
+	return ll.Apply(trimName)
+}
+
+// IDPlaceholders returns placeholders ?,?,... (start is ignored) and args by ID for WHERE ID IN (...) clause.
+// Empty string and no args are returned for empty collection, IN clause must be skipped by caller.
+func (ll Tags) IDPlaceholders(start int) (string, []any) {
+	args := make([]any, len(ll))
+	var sb strings.Builder
+	for i := range ll {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('?')
+		args[i] = ll[i].ID
+	}
+	return sb.String(), args
+}
+
+// CacheKey returns deterministic key of Tag built from all exported fields sorted by name.
+func (t Tag) CacheKey() string {
+	return fmt.Sprintf("Tag_%d_%q", t.ID, t.Name)
+}
+
+// Hash returns 64-bit FNV-1a hash of all exported fields of Tag sorted by name, e.g. for fast equality check in caches.
+// Fields are hashed by %v representation, so equal hashes don't guarantee equal values.
+func (t Tag) Hash() uint64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%v\x00", t.ID)
+	_, _ = fmt.Fprintf(h, "%v\x00", t.Name)
+	return h.Sum64()
+}
+
+// UniqueNames returns unique trimmed values of Name compared case-insensitively in order of first occurrence, the first seen value wins.
+func (ll Tags) UniqueNames() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		v := ll[i].Name
+		v = strings.TrimSpace(v)
+		k := strings.ToLower(v)
+		if _, ok := idx[k]; !ok {
+			idx[k] = struct{}{}
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+func (ll Tags) IndexByName() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[ll[i].Name] = ll[i]
+	}
+	return r
+}
+
+func (ll Tags) IndexBySlug() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[ll[i].Slug()] = ll[i]
+	}
+	return r
+}
+
+// ErrEmptyCollection is returned by methods that are undefined for empty collections, e.g. First.
+var ErrEmptyCollection = errors.New("empty collection")
+
+// PaginationMeta is a page metadata returned by Paginate methods.
+type PaginationMeta struct {
+	Page, Size, TotalPages, TotalItems int
+}
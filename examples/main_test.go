@@ -0,0 +1,636 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vmkteam/colgen/examples/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNilCollections calls every generated method on nil collections and checks nil receivers contract.
+func TestNilCollections(t *testing.T) {
+	// index-based methods can't be called on empty collection, MarshalBinary is checked in TestTags_MarshalBinary
+	skip := map[string]bool{"Swap": true, "Less": true, "MarshalBinary": true}
+
+	// methods with special results on nil collection, results of multi-result methods are in []any
+	special := map[string]any{
+		"IsEmpty":  true,
+		"Apply":    Tags(nil), // returns ll as is
+		"trimName": Tags(nil),
+		"Paginate": []any{Tags{}, PaginationMeta{Page: 1}}, // page 0 is the first page
+		"Merge":    NewsList{},                             // new collection, not dst of append-style methods
+	}
+
+	for _, coll := range []any{NewsList(nil), Tags(nil), Events(nil), Categories(nil), Sales(nil)} {
+		v := reflect.ValueOf(coll)
+		for i := range v.NumMethod() {
+			m, name := v.Method(i), v.Type().Method(i).Name
+			if skip[name] {
+				continue
+			}
+
+			t.Run(v.Type().Name()+"."+name, func(t *testing.T) {
+				args := make([]reflect.Value, m.Type().NumIn())
+				for j := range args {
+					args[j] = reflect.Zero(m.Type().In(j))
+				}
+
+				var out []reflect.Value
+				require.NotPanics(t, func() { out = m.Call(args) })
+
+				for j, o := range out {
+					if want, ok := special[name]; ok {
+						if ww, ok := want.([]any); ok {
+							want = ww[j]
+						}
+						assert.Equal(t, want, o.Interface())
+						continue
+					}
+
+					switch o.Kind() { //nolint:exhaustive
+					case reflect.Map:
+						assert.False(t, o.IsNil(), "map result must be non-nil")
+						assert.Zero(t, o.Len())
+					case reflect.Interface:
+						// First and Last are undefined for empty collection
+						if err, ok := o.Interface().(error); ok {
+							assert.ErrorIs(t, err, ErrEmptyCollection)
+							continue
+						}
+						assert.True(t, o.IsZero(), "result %d must be zero", j)
+					case reflect.Slice:
+						// append-style methods return dst as is
+						if len(args) > 0 && m.Type().In(0) == o.Type() {
+							assert.Equal(t, args[0].Interface(), o.Interface())
+							continue
+						}
+						assert.False(t, o.IsNil(), "slice result must be non-nil")
+						assert.Zero(t, o.Len())
+					default:
+						assert.True(t, o.IsZero(), "result %d must be zero", j)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestNewsList_IndexByCategoryID(t *testing.T) {
+	ll := NewsList{
+		{ID: 1, CategoryID: 10},
+		{ID: 2, CategoryID: 20},
+		{ID: 3, CategoryID: 10},
+	}
+
+	idx := ll.IndexByCategoryID()
+	require.Len(t, idx, 2)
+	require.Len(t, idx[10], 2)
+	require.Len(t, idx[20], 1)
+
+	// pointers refer to the original slice
+	assert.Same(t, &ll[0], idx[10][0])
+	assert.Same(t, &ll[2], idx[10][1])
+	assert.Same(t, &ll[1], idx[20][0])
+
+	// modifying via pointer is reflected in the original slice
+	idx[20][0].Title = "updated"
+	assert.Equal(t, "updated", ll[1].Title)
+
+	assert.Empty(t, NewsList(nil).IndexByCategoryID())
+}
+
+func TestNewsList_AppendIDs(t *testing.T) {
+	ll := NewsList{{ID: 1, Title: "a"}, {ID: 2, Title: "b"}}
+
+	assert.Equal(t, []int{1, 2}, ll.AppendIDs(nil))
+	assert.Equal(t, []int{0, 1, 2}, ll.AppendIDs([]int{0}))
+	assert.Equal(t, []string{"a", "b"}, ll.AppendTitles(nil))
+
+	dst := make([]int, 0, 10)
+	r := ll.AppendIDs(dst)
+	assert.Equal(t, cap(dst), cap(r), "dst must be reused")
+}
+
+func benchNewsList(n int) NewsList {
+	ll := make(NewsList, n)
+	for i := range ll {
+		ll[i] = News{ID: i, Title: strconv.Itoa(i)}
+	}
+	return ll
+}
+
+func BenchmarkNewsList_IDs(b *testing.B) {
+	ll := benchNewsList(1000)
+	b.ReportAllocs()
+	for range b.N {
+		_ = ll.IDs()
+	}
+}
+
+func BenchmarkNewsList_AppendIDs(b *testing.B) {
+	ll := benchNewsList(1000)
+	pool := sync.Pool{New: func() any { return new([]int) }}
+	b.ReportAllocs()
+	for range b.N {
+		dst := pool.Get().(*[]int)
+		*dst = ll.AppendIDs((*dst)[:0])
+		pool.Put(dst)
+	}
+}
+
+func TestTags_Exclude(t *testing.T) {
+	ll := Tags{{ID: 0}, {ID: 1}, {ID: 999}, {ID: 2}}
+	assert.Equal(t, Tags{{ID: 1}, {ID: 2}}, ll.Exclude())
+	assert.Equal(t, Tags{}, Tags{{ID: 0}}.Exclude())
+}
+
+func TestEvents_SQLScan(t *testing.T) {
+	ll := Events{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+
+	v, err := ll.Value()
+	require.NoError(t, err)
+	data, err := json.Marshal(ll)
+	require.NoError(t, err)
+	assert.Equal(t, data, v)
+
+	// []byte, string and nil sources
+	var got Events
+	require.NoError(t, got.Scan(v))
+	assert.Equal(t, ll, got)
+
+	got = nil
+	require.NoError(t, got.Scan(string(data)))
+	assert.Equal(t, ll, got)
+
+	require.NoError(t, got.Scan(nil))
+	assert.Nil(t, got)
+
+	require.Error(t, got.Scan(1))
+
+	v, err = Events(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestTags_Apply(t *testing.T) {
+	ll := Tags{{ID: 1, Name: " go "}, {ID: 2, Name: "news"}}
+	assert.Equal(t, Tags{{ID: 1, Name: "go"}, {ID: 2, Name: "news"}}, ll.trimName())
+
+	var n int
+	ll.Apply(func(*Tag) { n++ })
+	assert.Equal(t, 2, n)
+}
+
+func TestNewsList_Sortable(t *testing.T) {
+	ll := NewsList{{ID: 1, Title: "b"}, {ID: 2, Title: "c"}, {ID: 3, Title: "a"}}
+	sort.Sort(ll)
+	assert.Equal(t, []int{3, 1, 2}, ll.IDs())
+}
+
+func TestNewsList_SortByViewCountDescTitle(t *testing.T) {
+	ll := NewsList{{ID: 1, ViewCount: 5, Title: "b"}, {ID: 2, ViewCount: 9, Title: "c"}, {ID: 3, ViewCount: 5, Title: "a"}}
+	assert.Equal(t, []int{2, 3, 1}, ll.SortByViewCountDescTitle().IDs())
+	assert.Equal(t, []int{1, 2, 3}, ll.IDs(), "ll is not modified")
+	assert.NotNil(t, NewsList(nil).SortByViewCountDescTitle())
+}
+
+func TestNewsList_FirstLast(t *testing.T) {
+	ll := NewsList{{ID: 1}, {ID: 2}}
+
+	first, err := ll.First()
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.ID)
+
+	last, err := ll.Last()
+	require.NoError(t, err)
+	assert.Equal(t, 2, last.ID)
+
+	_, err = NewsList{}.First()
+	require.ErrorIs(t, err, ErrEmptyCollection)
+}
+
+func TestNewsList_IndexByTitleCI(t *testing.T) {
+	idx := NewsList{{ID: 1, Title: "Hello"}, {ID: 2, Title: "hello"}, {ID: 3, Title: "World"}}.IndexByTitleCI()
+	assert.Len(t, idx, 2)
+	assert.Equal(t, 2, idx[strings.ToLower("HELLO")].ID)
+	assert.Equal(t, 3, idx["world"].ID)
+}
+
+func TestTags_MarshalBinary(t *testing.T) {
+	ll := Tags{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+
+	data, err := ll.MarshalBinary()
+	require.NoError(t, err)
+
+	var got Tags
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Equal(t, ll, got)
+
+	// nil collection is encoded as null and decoded back as nil
+	data, err = Tags(nil).MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+
+	got = Tags{{ID: 3}}
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Nil(t, got)
+
+	var _ encoding.BinaryMarshaler = ll
+	var _ encoding.BinaryUnmarshaler = &got
+}
+
+func TestTags_HeadTail(t *testing.T) {
+	ll := Tags{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := []struct {
+		n          int
+		head, tail Tags
+	}{
+		{n: 0, head: Tags{}, tail: Tags{}},
+		{n: -1, head: Tags{}, tail: Tags{}},
+		{n: 1, head: Tags{{ID: 1}}, tail: Tags{{ID: 3}}},
+		{n: 3, head: ll, tail: ll},
+		{n: 5, head: ll, tail: ll},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.n), func(t *testing.T) {
+			assert.Equal(t, tt.head, ll.Head(tt.n))
+			assert.Equal(t, tt.tail, ll.Tail(tt.n))
+		})
+	}
+
+	// sub-collections share backing array with ll
+	assert.Same(t, &ll[0], &ll.Head(1)[0])
+	assert.Same(t, &ll[2], &ll.Tail(1)[0])
+}
+
+func TestTags_Rotate(t *testing.T) {
+	ll := Tags{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+
+	tests := []struct {
+		n    int
+		want []int
+	}{
+		{n: 0, want: []int{1, 2, 3, 4}},
+		{n: 1, want: []int{2, 3, 4, 1}},
+		{n: -1, want: []int{4, 1, 2, 3}},
+		{n: 4, want: []int{1, 2, 3, 4}},
+		{n: 6, want: []int{3, 4, 1, 2}},
+		{n: -5, want: []int{4, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.n), func(t *testing.T) {
+			got := ll.Rotate(tt.n)
+			assert.Equal(t, tt.want, got.IDs())
+			assert.NotSame(t, &ll[0], &got[0])
+		})
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4}, ll.IDs())
+}
+
+func TestSQLIn(t *testing.T) {
+	q, args := Events{{ID: 1}, {ID: 2}, {ID: 3}}.IDPlaceholders(2)
+	assert.Equal(t, "$2,$3,$4", q)
+	assert.Equal(t, []any{1, 2, 3}, args)
+
+	q, args = Tags{{ID: 1}, {ID: 2}}.IDPlaceholders(1)
+	assert.Equal(t, "?,?", q)
+	assert.Equal(t, []any{1, 2}, args)
+
+	// empty collection: caller must skip IN clause
+	q, args = Events{}.IDPlaceholders(1)
+	assert.Empty(t, q)
+	assert.Empty(t, args)
+}
+
+func TestNewsList_AvgViewCount(t *testing.T) {
+	assert.Zero(t, NewsList{}.AvgViewCount())
+	assert.InDelta(t, 5.0, NewsList{{ViewCount: 5}}.AvgViewCount(), 1e-9)
+	assert.InDelta(t, 1.5, NewsList{{ViewCount: 1}, {ViewCount: 2}}.AvgViewCount(), 1e-9)
+}
+
+func TestNewsList_StdDevViewCount(t *testing.T) {
+	assert.Zero(t, NewsList{}.StdDevViewCount())
+	assert.Zero(t, NewsList{{ViewCount: 5}}.StdDevViewCount())
+
+	var ll NewsList
+	for _, v := range []int{2, 4, 4, 4, 5, 5, 7, 9} {
+		ll = append(ll, News{ViewCount: v})
+	}
+	assert.InDelta(t, 2.0, ll.StdDevViewCount(), 1e-9)
+}
+
+func TestNewsList_AccumulatedViewCounts(t *testing.T) {
+	assert.Empty(t, NewsList{}.AccumulatedViewCounts())
+
+	ll := NewsList{{ViewCount: 3}, {ViewCount: 1}, {ViewCount: 5}}
+	got := ll.AccumulatedViewCounts()
+	require.Len(t, got, len(ll))
+	assert.Equal(t, 3, got[0])
+	assert.Equal(t, 9, got[len(got)-1])
+	assert.Equal(t, []int{3, 4, 9}, got)
+}
+
+func TestNewsList_GroupByStatus(t *testing.T) {
+	ll := NewsList{{ID: 1, Status: domain.StatusDraft}, {ID: 2, Status: domain.StatusPublished}, {ID: 3, Status: domain.StatusDraft}}
+
+	idx := ll.GroupByStatus()
+	assert.Equal(t, []int{1, 3}, idx[domain.StatusDraft].IDs())
+	assert.Equal(t, []int{2}, idx[domain.StatusPublished].IDs())
+}
+
+func TestNewsList_PartitionByPinned(t *testing.T) {
+	pinned, rest := NewsList{{ID: 1, Pinned: true}, {ID: 2}, {ID: 3, Pinned: true}}.PartitionByPinned()
+	assert.Equal(t, []int{1, 3}, pinned.IDs())
+	assert.Equal(t, []int{2}, rest.IDs())
+}
+
+func TestNewsList_Distinct(t *testing.T) {
+	ll := NewsList{{Title: "B", TagIDs: []int{3, 1}}, {Title: "A"}, {Title: "B", TagIDs: []int{1, 2}}, {Title: "C"}, {Title: "A", TagIDs: []int{3}}}
+	assert.Equal(t, []string{"B", "A", "C"}, ll.DistinctTitles())
+	assert.Equal(t, []int{3, 1, 2}, ll.DistinctTagIDs())
+}
+
+func TestNewsList_MissingTagIDs(t *testing.T) {
+	ll := NewsList{{TagIDs: []int{3, 1}}, {}, {TagIDs: []int{2, 3, 4}}}
+	assert.Equal(t, []int{3, 4}, ll.MissingTagIDs(Tags{{ID: 1}, {ID: 2}}))
+	assert.Equal(t, []int{3, 1, 2, 4}, ll.MissingTagIDs(nil))
+	assert.Empty(t, ll.MissingTagIDs(Tags{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}))
+}
+
+func TestNewsList_AttachTags(t *testing.T) {
+	ll := NewsList{{ID: 1, TagIDs: []int{3, 1}}, {ID: 2}, {ID: 3, TagIDs: []int{2, 4}, Tags: []Tag{{ID: 5}}}}
+	ll.AttachTags(Tags{{ID: 1, Name: "go"}, {ID: 2, Name: "db"}, {ID: 3, Name: "api"}})
+	assert.Equal(t, []Tag{{ID: 3, Name: "api"}, {ID: 1, Name: "go"}}, ll[0].Tags)
+	assert.Empty(t, ll[1].Tags)
+	assert.Equal(t, []Tag{{ID: 2, Name: "db"}}, ll[2].Tags)
+}
+
+func TestNewsList_AuthorCompanyNames(t *testing.T) {
+	ll := NewsList{
+		{ID: 1, Author: &Author{ID: 1, Company: &Company{Name: "vmk"}}},
+		{ID: 2},                         // nil Author
+		{ID: 3, Author: &Author{ID: 2}}, // nil Company
+		{ID: 4, Author: &Author{ID: 3, Company: &Company{}}},
+	}
+	assert.Equal(t, []string{"vmk", ""}, ll.AuthorCompanyNames())
+	assert.Equal(t, []string{"vmk", "unknown", "unknown", ""}, ll.AuthorCompanyNamesOr())
+
+	// chain is broken for all elements
+	ll = NewsList{{ID: 1}, {ID: 2, Author: &Author{}}}
+	assert.Empty(t, ll.AuthorCompanyNames())
+	assert.Equal(t, []string{"unknown", "unknown"}, ll.AuthorCompanyNamesOr())
+}
+
+func TestNewsList_Merge(t *testing.T) {
+	base := NewsList{{ID: 1, Title: "a"}, {ID: 2, Title: "b"}, {ID: 3, Title: "c"}}
+	edited := NewsList{{ID: 4, Title: "d"}, {ID: 2, Title: "B"}, {ID: 5, Title: "e"}}
+
+	r := base.Merge(edited)
+	assert.Equal(t, NewsList{{ID: 1, Title: "a"}, {ID: 2, Title: "B"}, {ID: 3, Title: "c"}, {ID: 4, Title: "d"}, {ID: 5, Title: "e"}}, r)
+	assert.Equal(t, "b", base[1].Title, "receiver is not modified")
+
+	// replacement only and append only
+	assert.Equal(t, NewsList{{ID: 1, Title: "A"}, {ID: 2, Title: "b"}, {ID: 3, Title: "c"}}, base.Merge(NewsList{{ID: 1, Title: "A"}}))
+	assert.Equal(t, edited, NewsList(nil).Merge(edited))
+	assert.Equal(t, base, base.Merge(nil))
+}
+
+func TestNewsList_SparseByAuthorID(t *testing.T) {
+	ll := NewsList{{ID: 1, AuthorID: 10}, {ID: 2}, {ID: 3, AuthorID: 20}, {ID: 4}}
+	idx := ll.SparseByAuthorID()
+	assert.Len(t, idx, 2)
+	assert.Equal(t, 1, idx[10].ID)
+	assert.Equal(t, 3, idx[20].ID)
+	assert.NotContains(t, idx, 0)
+}
+
+func TestNewsList_UniqueSortedTagIDs(t *testing.T) {
+	ll := NewsList{{TagIDs: []int{3, 1}}, {}, {TagIDs: []int{2, 3}}}
+	assert.Equal(t, []int{1, 2, 3}, ll.UniqueSortedTagIDs())
+}
+
+func TestNewsList_GroupSortedByCategoryID(t *testing.T) {
+	ll := NewsList{{ID: 1, CategoryID: 3}, {ID: 2, CategoryID: 1}, {ID: 3, CategoryID: 3}}
+	groups, keys := ll.GroupSortedByCategoryID()
+	assert.Equal(t, []int{1, 3}, keys)
+	assert.Equal(t, []int{1, 3}, groups[3].IDs())
+	assert.Equal(t, []int{2}, groups[1].IDs())
+}
+
+func TestTag_CacheKey(t *testing.T) {
+	tag := Tag{ID: 1, Name: "go"}
+	assert.Equal(t, `Tag_1_"go"`, tag.CacheKey())
+	assert.Equal(t, tag.CacheKey(), Tag{ID: 1, Name: "go"}.CacheKey())
+	assert.NotEqual(t, tag.CacheKey(), Tag{ID: 2, Name: "go"}.CacheKey())
+	assert.NotEqual(t, tag.CacheKey(), Tag{ID: 1, Name: "golang"}.CacheKey())
+}
+
+func TestTag_Hash(t *testing.T) {
+	tag := Tag{ID: 1, Name: "go"}
+	assert.Equal(t, tag.Hash(), Tag{ID: 1, Name: "go"}.Hash())
+	assert.NotEqual(t, tag.Hash(), Tag{ID: 2, Name: "go"}.Hash())
+	assert.NotEqual(t, tag.Hash(), Tag{ID: 1, Name: "golang"}.Hash())
+	assert.NotEqual(t, Tag{ID: 1, Name: "2"}.Hash(), Tag{ID: 12}.Hash())
+}
+
+func TestTags_Paginate(t *testing.T) {
+	ll := Tags{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}, {ID: 6}}
+
+	page, meta := ll.Paginate(2, 3)
+	assert.Equal(t, Tags{{ID: 4}, {ID: 5}, {ID: 6}}, page)
+	assert.Equal(t, PaginationMeta{Page: 2, Size: 3, TotalPages: 2, TotalItems: 6}, meta)
+
+	page, meta = ll.Paginate(3, 4)
+	assert.Empty(t, page)
+	assert.Equal(t, 2, meta.TotalPages)
+
+	page, meta = ll.Paginate(0, 4)
+	assert.Equal(t, Tags{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}, page)
+	assert.Equal(t, 1, meta.Page)
+
+	page, meta = ll[:5].Paginate(3, 2)
+	assert.Equal(t, Tags{{ID: 5}}, page)
+	assert.Equal(t, 3, meta.TotalPages)
+
+	page, meta = ll.Paginate(1, 0)
+	assert.Empty(t, page)
+	assert.Equal(t, 0, meta.TotalPages)
+}
+
+func TestNewsList_IndexInto(t *testing.T) {
+	idx := NewsList{{ID: 1}, {ID: 2}}.IndexInto(nil)
+	idx = NewsList{{ID: 3}}.IndexInto(idx)
+	assert.Len(t, idx, 3)
+
+	groups := NewsList{{ID: 1, CategoryID: 10}, {ID: 2, CategoryID: 20}}.GroupByCategoryIDInto(nil)
+	groups = NewsList{{ID: 3, CategoryID: 10}}.GroupByCategoryIDInto(groups)
+	assert.Equal(t, []int{1, 3}, groups[10].IDs())
+	assert.Equal(t, []int{2}, groups[20].IDs())
+}
+
+func TestTags_Shuffle(t *testing.T) {
+	ll := Tags{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	for range 10 {
+		r := ll.Shuffle()
+		assert.ElementsMatch(t, ll, r)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ll.IDs(), "receiver is not modified")
+
+	// seeded source gives the same permutation
+	r := ll.ShuffleWith(rand.New(rand.NewPCG(1, 2)))
+	assert.ElementsMatch(t, ll, r)
+	assert.Equal(t, r, ll.ShuffleWith(rand.New(rand.NewPCG(1, 2))))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ll.IDs(), "receiver is not modified")
+}
+
+// TestSyncedTags is meaningful with race detector: go test -race.
+func TestSyncedTags(t *testing.T) {
+	ll := Tags{{ID: 1, Name: "go"}}
+	s := NewSyncedTags(ll)
+	ll[0].Name = "changed"
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.Add(Tag{ID: i + 2})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = s.All().IDs()
+			_, _ = s.Get(1)
+			_ = s.Len()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 11, s.Len())
+	tag, ok := s.Get(1)
+	require.True(t, ok)
+	assert.Equal(t, "go", tag.Name, "constructor copies collection")
+	_, ok = s.Get(100)
+	assert.False(t, ok)
+
+	var empty SyncedTags
+	assert.Equal(t, 0, empty.Len())
+	assert.NotNil(t, empty.All())
+}
+
+func TestNewsList_TakeDropWhilePinned(t *testing.T) {
+	ll := NewsList{{ID: 1, Pinned: true}, {ID: 2, Pinned: true}, {ID: 3}, {ID: 4, Pinned: true}}
+	assert.Equal(t, []int{1, 2}, ll.TakeWhilePinned(true).IDs())
+	assert.Equal(t, []int{3, 4}, ll.DropWhilePinned(true).IDs())
+
+	assert.Empty(t, ll.TakeWhilePinned(false))
+	assert.Equal(t, ll.IDs(), ll.DropWhilePinned(false).IDs())
+	assert.Empty(t, ll[:2].DropWhilePinned(true))
+}
+
+func TestNewsList_FlattenSections(t *testing.T) {
+	ll := NewsList{
+		{Sections: [][]Paragraph{{{Text: "a"}, {Text: "b"}}, {{Text: "c"}}}},
+		{},
+		{Sections: [][]Paragraph{nil, {{Text: "d"}}}},
+	}
+
+	r := ll.FlattenSections()
+	assert.Len(t, r, 4)
+	assert.Equal(t, []Paragraph{{Text: "a"}, {Text: "b"}, {Text: "c"}, {Text: "d"}}, r)
+}
+
+func TestTags_UniqueNamesFoldTrim(t *testing.T) {
+	ll := Tags{{Name: " Go"}, {Name: "rust"}, {Name: "go "}, {Name: "GO"}, {Name: "Rust "}, {Name: "zig"}}
+	assert.Equal(t, []string{"Go", "rust", "zig"}, ll.UniqueNames())
+	assert.Empty(t, Tags{}.UniqueNames())
+}
+
+func TestNewsList_AssociateURLTitle(t *testing.T) {
+	ll := NewsList{{URL: "/a", Title: "A"}, {URL: "/b", Title: "B"}, {URL: "/a", Title: "A2"}}
+	assert.Equal(t, map[string]string{"/a": "A2", "/b": "B"}, ll.AssociateURLTitle())
+}
+
+func TestCategories_FlattenSubCategories(t *testing.T) {
+	// depth 2
+	ll := Categories{{ID: 1, SubCategories: []Category{{ID: 2}, {ID: 3}}}, {ID: 4, SubCategories: []Category{{ID: 5}}}}
+	assert.Equal(t, []int{2, 3, 5}, ll.FlattenSubCategories().IDs())
+
+	// depth 3, breadth-first order
+	ll = Categories{
+		{ID: 1, SubCategories: []Category{{ID: 2, SubCategories: []Category{{ID: 5}, {ID: 6}}}, {ID: 3}}},
+		{ID: 4, SubCategories: []Category{{ID: 7, SubCategories: []Category{{ID: 8}}}}},
+	}
+	assert.Equal(t, []int{2, 3, 7, 5, 6, 8}, ll.FlattenSubCategories().IDs())
+	assert.Empty(t, Categories{{ID: 1}}.FlattenSubCategories())
+}
+
+func TestCategories_ByParentID(t *testing.T) {
+	one, two := 1, 2
+	ll := Categories{{ID: 1}, {ID: 2, ParentID: &one}, {ID: 3}, {ID: 4, ParentID: &two}, {ID: 5, ParentID: &one}}
+
+	// nil ParentID is skipped by default
+	idx := ll.IndexByParentID()
+	assert.Len(t, idx, 2)
+	assert.Equal(t, 5, idx[1].ID)
+	assert.Equal(t, 4, idx[2].ID)
+	assert.Empty(t, Categories{{ID: 1}, {ID: 3}}.IndexByParentID())
+
+	// nil ParentID is grouped by zero value with zeronil
+	groups := ll.GroupByParentID()
+	assert.Equal(t, []int{1, 3}, groups[0].IDs())
+	assert.Equal(t, []int{2, 5}, groups[1].IDs())
+	assert.Equal(t, []int{4}, groups[2].IDs())
+}
+
+func TestNewsList_MetadataKeysValues(t *testing.T) {
+	ll := NewsList{{Metadata: map[string]string{"a": "1", "b": "2"}}, {}, {Metadata: map[string]string{"a": "3"}}}
+	assert.ElementsMatch(t, []string{"a", "b", "a"}, ll.MetadataKeys())
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, ll.MetadataValues())
+}
+
+func TestNewsList_IDTitlePairs(t *testing.T) {
+	ll := NewsList{{ID: 3, Title: "C"}, {ID: 1, Title: "A"}, {ID: 2, Title: "B"}}
+	assert.Equal(t, []NewsIDTitlePair{{ID: 3, Title: "C"}, {ID: 1, Title: "A"}, {ID: 2, Title: "B"}}, ll.IDTitlePairs())
+}
+
+func TestSales_PivotMonthAmount(t *testing.T) {
+	ll := Sales{{Month: "jan", Amount: 10}, {Month: "feb", Amount: 5}, {Month: "jan", Amount: 20}, {Month: "jan", Amount: 30}}
+	assert.Equal(t, map[string][]float64{"jan": {10, 20, 30}, "feb": {5}}, ll.PivotMonthAmount())
+}
+
+func TestSales_DeltaAmount(t *testing.T) {
+	prev := Sales{{ID: 1, Amount: 10}, {ID: 2, Amount: 5}, {ID: 3, Amount: 7}}
+	ll := Sales{{ID: 1, Amount: 15}, {ID: 2, Amount: 5}, {ID: 4, Amount: 3}}
+	assert.Equal(t, map[int]float64{1: 5, 2: 0, 3: 7, 4: 3}, ll.DeltaAmount(prev))
+}
+
+func TestTags_IndexExact(t *testing.T) {
+	ll := Tags{{ID: 1, Name: "go"}, {ID: 2, Name: "rust"}}
+	assert.Equal(t, map[string]Tag{"go": {ID: 1, Name: "go"}, "rust": {ID: 2, Name: "rust"}}, ll.IndexByName())
+}
+
+func TestNews_WithTitle(t *testing.T) {
+	n := News{ID: 1, Title: "old", TagIDs: []int{1}}
+	c := n.WithTitle("new")
+	assert.Equal(t, "old", n.Title)
+	assert.Equal(t, News{ID: 1, Title: "new", TagIDs: []int{1}}, c)
+}
+
+func TestTags_IndexBySlug(t *testing.T) {
+	ll := Tags{{ID: 1, Name: "Go"}, {ID: 2, Name: "Rust"}}
+	assert.Equal(t, map[string]Tag{"go": {ID: 1, Name: "Go"}, "rust": {ID: 2, Name: "Rust"}}, ll.IndexBySlug())
+}
@@ -0,0 +1,11 @@
+package main
+
+//go:generate go run ../cmd/colgen -emit-sql-scan
+
+//colgen:Event
+//colgen:Event:SQLIn(pg)
+
+type Event struct {
+	ID   int
+	Name string
+}
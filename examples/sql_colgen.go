@@ -0,0 +1,69 @@
+// Code generated by colgen devel; DO NOT EDIT.
+// colgen:sha256:6642459fca6d6696f950c2627aa859076ce2f1695377a78229a9256b8dfa3ca8
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Events []Event
+
+func (ll Events) IDs() []int {
+	r := make([]int, len(ll))
+	for i := range ll {
+		r[i] = ll[i].ID
+	}
+	return r
+}
+
+func (ll Events) Index() map[int]Event {
+	r := make(map[int]Event, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i]
+	}
+	return r
+}
+
+// Scan implements sql.Scanner interface for JSON columns.
+func (ll *Events) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*ll = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type %T for Events", src)
+	}
+	return json.Unmarshal(data, ll)
+}
+
+// Value implements driver.Valuer interface for JSON columns. Nil collection is stored as NULL.
+func (ll Events) Value() (driver.Value, error) {
+	if ll == nil {
+		return nil, nil
+	}
+	return json.Marshal(ll)
+}
+
+// IDPlaceholders returns placeholders $start,$start+1,... and args by ID for WHERE ID IN (...) clause.
+// Empty string and no args are returned for empty collection, IN clause must be skipped by caller.
+func (ll Events) IDPlaceholders(start int) (string, []any) {
+	args := make([]any, len(ll))
+	var sb strings.Builder
+	for i := range ll {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString("$" + strconv.Itoa(start+i))
+		args[i] = ll[i].ID
+	}
+	return sb.String(), args
+}
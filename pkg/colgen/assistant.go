@@ -1,7 +1,7 @@
 // Package colgen provides AI-assisted code generation and review capabilities.
-// It integrates with Deepseek's API to generate code reviews and README content.
+// It integrates with Deepseek and Claude APIs (see AssistantRegistry) to generate code reviews, README content and tests.
 //
-//	//colgen@ai:<review|readme|tests>
+//	//colgen@ai:<review|readme|tests|commitmsg|upgrade>
 package colgen
 
 import (
@@ -25,6 +25,12 @@ const (
 
 	ModeTests AssistMode = "tests"
 
+	// ModeCommitMsg requests a commit message for the diff of generated files.
+	ModeCommitMsg AssistMode = "commitmsg"
+
+	// ModeUpgrade requests modernization of the provided content to current Go idioms.
+	ModeUpgrade AssistMode = "upgrade"
+
 	AssistantDeepSeek AssistantName = "deepseek"
 	AssistantClaude   AssistantName = "claude"
 )
@@ -32,30 +38,30 @@ const (
 var ErrUnsupportedAssistMode = errors.New("unsupported assist mode")
 var ErrUnsupportedAssistName = errors.New("unsupported assist name")
 
+// ErrProvider wraps errors returned by assistant provider, e.g. network or authorization errors.
+var ErrProvider = errors.New("assistant provider failed")
+
 // Assistant provides AI-assisted code generation capabilities.
-// It requires a valid Deepseek API key for initialization.
+// It requires a valid API key of the chosen assistant for initialization.
 type Assistant struct {
-	key string
-	c   caller
+	name   AssistantName
+	key    string
+	c      Caller
+	budget PromptBudget
+
+	fallbacks []*Assistant // called in order with the same prompt if call fails
+	answered  *Assistant   // assistant that produced the last answer: itself or one of fallbacks
+
+	methods      string   // summary of generated methods, appended to review, readme and tests prompts
+	systemPrompt string   // custom system prompt for all modes, overrides default ones
+	temperature  *float64 // custom temperature for all calls, provider default is used if nil
+	usage        Usage    // total tokens usage, if caller reports it
 }
 
-// NewAssistant creates a new Assistant instance with the provided API key.
-// The key should be a valid Deepseek API key.
+// NewAssistant creates a new Assistant instance from the default registry with the provided API key.
+// Returns ErrUnsupportedAssistName if assistant is not registered.
 func NewAssistant(n AssistantName, key string) (*Assistant, error) {
-	var c caller
-	switch n {
-	case AssistantDeepSeek:
-		c = DeepSeekCaller{Key: key}
-	case AssistantClaude:
-		c = ClaudeCaller{Key: key}
-	default:
-		return nil, ErrUnsupportedAssistName
-	}
-
-	return &Assistant{
-		key: key,
-		c:   c,
-	}, nil
+	return defaultRegistry.New(n, key)
 }
 
 // IsValidMode checks if the provided mode string is a valid assistance mode.
@@ -63,17 +69,156 @@ func NewAssistant(n AssistantName, key string) (*Assistant, error) {
 // Returns ErrUnsupportedAssistMode if the mode is invalid.
 func (a *Assistant) IsValidMode(mode AssistMode) error {
 	switch mode {
-	case ModeReview, ModeReadme, ModeTests:
+	case ModeReview, ModeReadme, ModeTests, ModeCommitMsg, ModeUpgrade:
 		return nil
 	}
 
 	return fmt.Errorf("%w: %s", ErrUnsupportedAssistMode, mode)
 }
 
+// SetMaxPromptBytes overrides prompt budget of the assistant.
+func (a *Assistant) SetMaxPromptBytes(n int) {
+	a.budget.MaxBytes = n
+}
+
+// SetGeneratedMethods sets summary of colgen-generated methods (see Generator.GeneratedMethods).
+// It is appended to review, readme and tests prompts, so assistant uses existing methods instead of inventing helpers.
+func (a *Assistant) SetGeneratedMethods(summary string) {
+	a.methods = summary
+}
+
+// Usage returns total tokens usage of all calls. It is empty if Caller doesn't implement UsageCaller.
+func (a *Assistant) Usage() Usage {
+	return a.usage
+}
+
+// Model returns model name of assistant that produced the last answer (see Provider)
+// or empty string if Caller doesn't report it.
+func (a *Assistant) Model() string {
+	if m, ok := a.last().c.(modeler); ok {
+		return m.Model()
+	}
+
+	return ""
+}
+
+// SetFallbacks sets assistants called in order with the same prompt if call of assistant fails, e.g. claude is down.
+// Fallbacks without key are skipped, so error of assistant, e.g. authorization error, is returned as is
+// if no fallback has a key.
+func (a *Assistant) SetFallbacks(aa ...*Assistant) {
+	a.fallbacks = aa
+}
+
+// Provider returns name of assistant that produced the last answer: the assistant itself or one of fallbacks.
+func (a *Assistant) Provider() AssistantName {
+	return a.last().name
+}
+
+// last returns assistant that produced the last answer or a itself before any answer.
+func (a *Assistant) last() *Assistant {
+	if a.answered != nil {
+		return a.answered
+	}
+
+	return a
+}
+
+// SetTemperature overrides temperature of all calls, e.g. 0 for reviews and 0.4 for readmes.
+func (a *Assistant) SetTemperature(t float64) {
+	a.temperature = &t
+}
+
+// call calls LLM and collects tokens usage. If call fails, fallbacks with keys are called in order.
+// Errors of assistant and fallbacks are wrapped with ErrProvider.
+func (a *Assistant) call(c Code) (string, error) {
+	c.Temperature = a.temperature
+	r, err := a.callBy(a, c)
+	if err == nil {
+		a.answered = a
+		return r, nil
+	}
+
+	errs := []error{err}
+	for _, fb := range a.fallbacks {
+		if fb.key == "" {
+			continue
+		}
+
+		if r, err = a.callBy(fb, fb.withTemperature(c)); err == nil {
+			a.answered = fb
+			return r, nil
+		}
+
+		errs = append(errs, fmt.Errorf("fallback %s: %w", fb.name, err))
+	}
+
+	return "", fmt.Errorf("%w: %w", ErrProvider, errors.Join(errs...))
+}
+
+// callBy calls LLM of assistant aa and adds tokens usage to a.
+func (a *Assistant) callBy(aa *Assistant, c Code) (string, error) {
+	uc, ok := aa.c.(UsageCaller)
+	if !ok {
+		return aa.c.Call(c)
+	}
+
+	r, u, err := uc.CallWithUsage(c)
+	a.usage.InputTokens += u.InputTokens
+	a.usage.OutputTokens += u.OutputTokens
+
+	return r, err
+}
+
+// withTemperature returns Code with temperature limited by max temperature of assistant, e.g. for fallback
+// with narrower range than the assistant it replaces.
+func (a *Assistant) withTemperature(c Code) Code {
+	if tl, ok := a.c.(temperatureLimiter); ok && c.Temperature != nil && *c.Temperature > tl.MaxTemperature() {
+		t := tl.MaxTemperature()
+		c.Temperature = &t
+	}
+
+	return c
+}
+
+// SetSystemPrompt overrides default system prompts of all modes. Empty string restores defaults.
+func (a *Assistant) SetSystemPrompt(prompt string) {
+	a.systemPrompt = prompt
+}
+
+// system returns custom system prompt if it was set or default one.
+func (a *Assistant) system(def string) string {
+	if a.systemPrompt != "" {
+		return a.systemPrompt
+	}
+
+	return def
+}
+
+// withMethods appends generated methods summary to prompt.
+func (a *Assistant) withMethods(prompt string) string {
+	if a.methods == "" {
+		return prompt
+	}
+
+	return prompt + "\n\nThis package has generated collection methods (from *_colgen.go files), use them:\n" + a.methods
+}
+
 // Code represents the input for AI generation, containing both
 // a system prompt (context/instructions) and user prompt (content to process).
 type Code struct {
 	SystemPrompt, Prompt string
+
+	// Temperature overrides default temperature of the provider (0) if set.
+	Temperature *float64
+}
+
+// temperature returns temperature of the call or 0 by default.
+func (c Code) temperature() float64 {
+	if c.Temperature == nil {
+		return 0
+	}
+
+	return *c.Temperature
 }
 
 // Generate produces either a code review or README based on the assistPrompt.
@@ -86,6 +231,10 @@ func (a *Assistant) Generate(am AssistMode, content string) (code string, err er
 		code, err = a.Review(content)
 	case ModeTests:
 		code, err = a.Tests(content)
+	case ModeCommitMsg:
+		code, err = a.CommitMsg(content)
+	case ModeUpgrade:
+		code, err = a.Upgrade(content)
 	default:
 		err = fmt.Errorf("%w: %s", ErrUnsupportedAssistMode, am)
 	}
@@ -96,17 +245,71 @@ func (a *Assistant) Generate(am AssistMode, content string) (code string, err er
 // Review generates a code review for the provided Go code.
 // Returns the review as Markdown text or an error if the request fails.
 func (a *Assistant) Review(code string) (string, error) {
-	return a.c.call(Code{SystemPrompt: systemPromptReview, Prompt: code})
+	return a.callChunked(systemPromptReview, code)
 }
 
 // Readme generates a README for the provided Go code.
 // Returns the README as Markdown text or an error if the request fails.
 func (a *Assistant) Readme(code string) (string, error) {
-	return a.c.call(Code{SystemPrompt: systemPromptReadme, Prompt: code})
+	return a.callChunked(systemPromptReadme, code)
+}
+
+// callChunked reduces code to fit into prompt budget and processes chunks sequentially.
+func (a *Assistant) callChunked(systemPrompt, code string) (string, error) {
+	systemPrompt = a.system(systemPrompt)
+	budget, ok := a.budget.Without(len(systemPrompt) + len(a.withMethods("")))
+	if !ok {
+		return "", fmt.Errorf("prompt budget of %d bytes is exhausted by system prompt and methods summary", a.budget.MaxBytes)
+	}
+
+	chunks := budget.Reduce(code)
+	if len(chunks) == 1 {
+		return a.call(Code{SystemPrompt: systemPrompt, Prompt: a.withMethods(chunks[0])})
+	}
+
+	var sb strings.Builder
+	for i, chunk := range chunks {
+		r, err := a.call(Code{SystemPrompt: systemPrompt, Prompt: a.withMethods(chunk)})
+		if err != nil {
+			return "", fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(r)
+	}
+	sb.WriteString(chunkNote(len(chunks)))
+
+	return sb.String(), nil
 }
 
 func (a *Assistant) Tests(code string) (string, error) {
-	return a.c.call(Code{SystemPrompt: systemPromptTests, Prompt: code})
+	return a.call(Code{SystemPrompt: a.system(systemPromptTests), Prompt: a.withMethods(code)})
+}
+
+// CommitMsg generates a commit message for the provided unified diff.
+// Returns the message as plain text or an error if the request fails.
+func (a *Assistant) CommitMsg(diff string) (string, error) {
+	return a.call(Code{SystemPrompt: a.system(systemPromptCommitMsg), Prompt: diff})
+}
+
+// UserPromptForCommitMsg returns user prompt with unified diffs of all changed files.
+// Returns empty string if nothing was changed.
+func UserPromptForCommitMsg(files []FileDiff) string {
+	var sb strings.Builder
+	for _, f := range files {
+		if !f.Changed() {
+			continue
+		}
+
+		if sb.Len() == 0 {
+			sb.WriteString("This is diff of generated files: \n")
+		}
+		sb.WriteString(f.Unified())
+	}
+
+	return sb.String()
 }
 
 type UserTestPrompt struct {
@@ -199,6 +402,15 @@ Return code results:
  - if you want to add comments - adds it at the end of results in code comment format //.
 `
 
+const systemPromptCommitMsg = `You are a professional Go developer.
+---
+I will give you a unified diff of go files generated by colgen tool.
+Write a commit message for these changes in Conventional Commits style:
+- subject line: <type>(<optional scope>): <summary>, up to 72 characters, imperative mood.
+- blank line and a short body with a list of the most important changes.
+Return only commit message as plain text without markdown.
+`
+
 const basicLinks = `
 Your essential development resources:
 * Go
@@ -1,6 +1,7 @@
 package colgen
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -111,3 +112,263 @@ func TestTestFilename(t *testing.T) {
 		})
 	}
 }
+
+// fakeCaller returns predefined answer and stores the last Code.
+type fakeCaller struct {
+	answer string
+	err    error
+	last   *Code
+}
+
+func (f fakeCaller) Call(c Code) (string, error) {
+	if f.last != nil {
+		*f.last = c
+	}
+	return f.answer, f.err
+}
+
+func TestAssistantRegistry(t *testing.T) {
+	t.Run("default registry contains built-in assistants", func(t *testing.T) {
+		assert.Equal(t, []AssistantName{AssistantClaude, AssistantDeepSeek}, defaultRegistry.Names())
+
+		maxTemp, err := MaxTemperature(AssistantClaude)
+		require.NoError(t, err)
+		assert.InDelta(t, 1.0, maxTemp, 0)
+		maxTemp, err = MaxTemperature(AssistantDeepSeek)
+		require.NoError(t, err)
+		assert.InDelta(t, 2.0, maxTemp, 0)
+		_, err = MaxTemperature("unknown")
+		require.ErrorIs(t, err, ErrUnsupportedAssistName)
+	})
+
+	t.Run("uses default max temperature", func(t *testing.T) {
+		r := NewAssistantRegistry()
+		r.Register("fake", func(string) Caller { return fakeCaller{} })
+		maxTemp, err := r.MaxTemperature("fake")
+		require.NoError(t, err)
+		assert.InDelta(t, DefaultMaxTemperature, maxTemp, 0)
+	})
+
+	t.Run("returns error for unknown assistant", func(t *testing.T) {
+		_, err := NewAssistant("unknown", "key")
+		assert.ErrorIs(t, err, ErrUnsupportedAssistName)
+	})
+
+	t.Run("creates registered assistant", func(t *testing.T) {
+		var (
+			last   Code
+			gotKey string
+		)
+		r := NewAssistantRegistry()
+		r.Register("fake", func(key string) Caller {
+			gotKey = key
+			return fakeCaller{answer: "ok", last: &last}
+		})
+
+		a, err := r.New("fake", "fake-key")
+		require.NoError(t, err)
+		assert.Equal(t, "fake-key", gotKey)
+
+		res, err := a.Generate(ModeReview, "package main")
+		require.NoError(t, err)
+		assert.Equal(t, "ok", res)
+		assert.Equal(t, "package main", last.Prompt)
+		assert.Equal(t, systemPromptReview, last.SystemPrompt)
+	})
+
+	t.Run("panics on duplicate registration", func(t *testing.T) {
+		r := NewAssistantRegistry()
+		f := func(string) Caller { return fakeCaller{} }
+		r.Register("fake", f)
+		assert.Panics(t, func() { r.Register("fake", f) })
+		assert.Panics(t, func() { r.Register("", f) })
+		assert.Panics(t, func() { r.Register("nil", nil) })
+	})
+}
+
+func TestCommitMsg(t *testing.T) {
+	files := []FileDiff{
+		{Filename: "news_colgen.go", Before: []byte("package db\n"), After: []byte("package db\n\ntype NewsList []News\n")},
+		{Filename: "unchanged.go", Before: []byte("package db\n"), After: []byte("package db\n")},
+	}
+
+	prompt := UserPromptForCommitMsg(files)
+	assert.Contains(t, prompt, "+++ b/news_colgen.go")
+	assert.Contains(t, prompt, "+type NewsList []News")
+	assert.NotContains(t, prompt, "unchanged.go")
+	assert.Empty(t, UserPromptForCommitMsg(files[1:]))
+
+	var last Code
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller { return fakeCaller{answer: "feat: add NewsList", last: &last} })
+	a, err := r.New("fake", "")
+	require.NoError(t, err)
+	require.NoError(t, a.IsValidMode(ModeCommitMsg))
+
+	msg, err := a.Generate(ModeCommitMsg, prompt)
+	require.NoError(t, err)
+	assert.Equal(t, "feat: add NewsList", msg)
+	assert.Equal(t, prompt, last.Prompt)
+	assert.Equal(t, systemPromptCommitMsg, last.SystemPrompt)
+}
+
+func TestAssistant_GeneratedMethods(t *testing.T) {
+	g := NewGenerator("main", "", "", "devel")
+	require.NoError(t, g.UsePackageDir("../../examples"))
+
+	var last Code
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller { return fakeCaller{answer: "ok", last: &last} })
+	a, err := r.New("fake", "")
+	require.NoError(t, err)
+	a.SetGeneratedMethods(g.GeneratedMethods(DefaultGeneratedMethodsBytes))
+
+	for _, mode := range []AssistMode{ModeReview, ModeTests} {
+		_, err = a.Generate(mode, "package main")
+		require.NoError(t, err)
+		assert.Contains(t, last.Prompt, "package main")
+		assert.Contains(t, last.Prompt, "func (ll NewsList) IDs() []int")
+		assert.Contains(t, last.Prompt, "func (ll NewsList) UniqueTagIDs() []int")
+	}
+
+	// commit message has its own context
+	_, err = a.Generate(ModeCommitMsg, "diff")
+	require.NoError(t, err)
+	assert.Equal(t, "diff", last.Prompt)
+}
+
+func TestAssistant_SetSystemPrompt(t *testing.T) {
+	var last Code
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller { return fakeCaller{answer: "ok", last: &last} })
+	a, err := r.New("fake", "")
+	require.NoError(t, err)
+
+	a.SetSystemPrompt("custom")
+	for _, mode := range []AssistMode{ModeReview, ModeReadme, ModeTests, ModeCommitMsg} {
+		_, err = a.Generate(mode, "package main")
+		require.NoError(t, err)
+		assert.Equal(t, "custom", last.SystemPrompt, mode)
+	}
+
+	a.SetSystemPrompt("")
+	_, err = a.Generate(ModeReview, "package main")
+	require.NoError(t, err)
+	assert.Equal(t, systemPromptReview, last.SystemPrompt)
+}
+
+// usageCaller is a fakeCaller that reports tokens usage.
+type usageCaller struct {
+	fakeCaller
+	usage Usage
+}
+
+func (u usageCaller) CallWithUsage(c Code) (string, Usage, error) {
+	r, err := u.Call(c)
+	return r, u.usage, err
+}
+
+func TestAssistant_Usage(t *testing.T) {
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller { return fakeCaller{answer: "ok"} })
+	r.Register("usage", func(string) Caller {
+		return usageCaller{fakeCaller: fakeCaller{answer: "ok"}, usage: Usage{InputTokens: 10, OutputTokens: 3}}
+	})
+
+	a, err := r.New("usage", "")
+	require.NoError(t, err)
+	for range 2 {
+		_, err = a.Generate(ModeTests, "package main")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, Usage{InputTokens: 20, OutputTokens: 6}, a.Usage())
+
+	a, err = r.New("fake", "")
+	require.NoError(t, err)
+	_, err = a.Generate(ModeTests, "package main")
+	require.NoError(t, err)
+	assert.Equal(t, Usage{}, a.Usage())
+}
+
+func TestAssistant_SetTemperature(t *testing.T) {
+	var last Code
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller { return fakeCaller{answer: "ok", last: &last} })
+	a, err := r.New("fake", "")
+	require.NoError(t, err)
+
+	_, err = a.Generate(ModeReview, "package main")
+	require.NoError(t, err)
+	assert.Nil(t, last.Temperature)
+
+	a.SetTemperature(0.4)
+	_, err = a.Generate(ModeReadme, "package main")
+	require.NoError(t, err)
+	require.NotNil(t, last.Temperature)
+	assert.InDelta(t, 0.4, *last.Temperature, 1e-9)
+}
+
+// tempCaller is a fakeCaller with own temperature range.
+type tempCaller struct {
+	fakeCaller
+	maxTemp float64
+}
+
+func (t tempCaller) MaxTemperature() float64 {
+	return t.maxTemp
+}
+
+func TestAssistant_SetFallbacks(t *testing.T) {
+	down := errors.New("overloaded; http_status_code=529")
+	var primary, fallback Code
+	r := NewAssistantRegistry()
+	r.Register("primary", func(string) Caller { return fakeCaller{err: down, last: &primary} })
+	r.Register("auth", func(string) Caller { return fakeCaller{err: errors.New("unauthorized; http_status_code=401")} })
+	r.Register("fallback", func(string) Caller {
+		return tempCaller{fakeCaller: fakeCaller{answer: "fallback answer", last: &fallback}, maxTemp: 1}
+	})
+	r.Register("broken", func(string) Caller { return fakeCaller{err: errors.New("broken")} })
+
+	newAssistant := func(name AssistantName, key string) *Assistant {
+		a, err := r.New(name, key)
+		require.NoError(t, err)
+		return a
+	}
+
+	t.Run("primary failure is answered by fallback", func(t *testing.T) {
+		a := newAssistant("primary", "key")
+		a.SetTemperature(1.5)
+		a.SetFallbacks(newAssistant("broken", "key"), newAssistant("fallback", "key"))
+		assert.Equal(t, AssistantName("primary"), a.Provider())
+
+		got, err := a.Generate(ModeCommitMsg, "diff")
+		require.NoError(t, err)
+		assert.Equal(t, "fallback answer", got)
+		assert.Equal(t, AssistantName("fallback"), a.Provider())
+		assert.Equal(t, primary.Prompt, fallback.Prompt)
+		require.NotNil(t, fallback.Temperature)
+		assert.InDelta(t, 1.0, *fallback.Temperature, 0, "temperature is limited by fallback")
+	})
+
+	t.Run("fallback without key is skipped", func(t *testing.T) {
+		a := newAssistant("auth", "key")
+		a.SetFallbacks(newAssistant("fallback", ""))
+
+		_, err := a.Generate(ModeCommitMsg, "diff")
+		require.ErrorIs(t, err, ErrProvider)
+		assert.Equal(t, "assistant provider failed: unauthorized; http_status_code=401", err.Error())
+		status, _ := classifyPingError(err)
+		assert.Equal(t, PingAuthFailed, status)
+		assert.Equal(t, AssistantName("auth"), a.Provider())
+	})
+
+	t.Run("all failures are reported", func(t *testing.T) {
+		a := newAssistant("primary", "key")
+		a.SetFallbacks(newAssistant("broken", "key"))
+
+		_, err := a.Generate(ModeCommitMsg, "diff")
+		require.ErrorIs(t, err, ErrProvider)
+		require.ErrorIs(t, err, down)
+		assert.Contains(t, err.Error(), "fallback broken: broken")
+	})
+}
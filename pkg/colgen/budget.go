@@ -0,0 +1,179 @@
+package colgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxPromptBytes is a prompt budget for assistants without own limit.
+// It is about 25k tokens (1 token ~ 4 bytes).
+const DefaultMaxPromptBytes = 100_000
+
+// budgeter is implemented by callers with own prompt size limit.
+type budgeter interface {
+	MaxPromptBytes() int
+}
+
+// PromptBudget limits prompt size in bytes for assistant modes.
+type PromptBudget struct {
+	MaxBytes int
+}
+
+// Fits checks that s fits into budget. Zero or negative MaxBytes means no limit.
+func (b PromptBudget) Fits(s string) bool {
+	return b.MaxBytes <= 0 || len(s) <= b.MaxBytes
+}
+
+// Without returns budget for user prompt without overhead bytes, e.g. system prompt and methods summary.
+// Returns false if overhead exhausts the budget.
+func (b PromptBudget) Without(overhead int) (PromptBudget, bool) {
+	if b.MaxBytes <= 0 {
+		return b, true
+	}
+
+	b.MaxBytes -= overhead
+	return b, b.MaxBytes > 0
+}
+
+// Reduce reduces Go code to fit into budget and returns chunks to be processed sequentially.
+// Reduction pipeline:
+//   - strip function bodies of generated files (signatures are kept);
+//   - drop comments;
+//   - split into chunks by top-level blocks (separated by empty lines).
+func (b PromptBudget) Reduce(code string) []string {
+	if b.Fits(code) {
+		return []string{code}
+	}
+
+	if reduced, ok := stripGeneratedBodies(code); ok {
+		code = reduced
+		if b.Fits(code) {
+			return []string{code}
+		}
+	}
+
+	if reduced, ok := dropComments(code); ok {
+		code = reduced
+		if b.Fits(code) {
+			return []string{code}
+		}
+	}
+
+	return b.chunks(code)
+}
+
+// chunks splits code by top-level blocks into chunks that fit into budget.
+func (b PromptBudget) chunks(code string) []string {
+	var (
+		result []string
+		cur    strings.Builder
+	)
+
+	flush := func() {
+		if cur.Len() > 0 {
+			result = append(result, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, block := range strings.SplitAfter(code, "\n\n") {
+		if cur.Len()+len(block) > b.MaxBytes {
+			flush()
+		}
+
+		// split huge block by lines and huge lines by runes
+		for len(block) > b.MaxBytes {
+			n := strings.LastIndexByte(block[:b.MaxBytes], '\n') + 1
+			if n == 0 {
+				n = runeBoundary(block, b.MaxBytes)
+			}
+			result = append(result, block[:n])
+			block = block[n:]
+		}
+
+		cur.WriteString(block)
+	}
+	flush()
+
+	return result
+}
+
+// runeBoundary returns the greatest rune start position in s that is not greater than n.
+// It returns n if there is no rune start, e.g. the budget is smaller than a rune.
+func runeBoundary(s string, n int) int {
+	for i := n; i > 0; i-- {
+		if utf8.RuneStart(s[i]) {
+			return i
+		}
+	}
+
+	return n
+}
+
+// stripGeneratedBodies removes function bodies and comments inside them if code is generated.
+func stripGeneratedBodies(code string) (string, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, parser.ParseComments)
+	if err != nil || !ast.IsGenerated(f) {
+		return code, false
+	}
+
+	var bodies []*ast.BlockStmt
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+			bodies = append(bodies, fd.Body)
+			fd.Body = nil
+		}
+	}
+
+	// remove comments from stripped bodies
+	comments := f.Comments[:0]
+	for _, cg := range f.Comments {
+		inBody := false
+		for _, body := range bodies {
+			if cg.Pos() >= body.Pos() && cg.End() <= body.End() {
+				inBody = true
+				break
+			}
+		}
+
+		if !inBody {
+			comments = append(comments, cg)
+		}
+	}
+	f.Comments = comments
+
+	return printFile(fset, f)
+}
+
+// dropComments removes all comments from code.
+func dropComments(code string) (string, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", code, 0)
+	if err != nil {
+		return code, false
+	}
+
+	return printFile(fset, f)
+}
+
+// printFile prints formatted file.
+func printFile(fset *token.FileSet, f *ast.File) (string, bool) {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, f); err != nil {
+		return "", false
+	}
+
+	return buf.String(), true
+}
+
+// chunkNote returns note about chunked processing.
+func chunkNote(n int) string {
+	return fmt.Sprintf("\n\n---\n_Note: the file was too big for one request, it was processed in %d chunks and results were concatenated._\n", n)
+}
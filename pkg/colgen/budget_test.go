@@ -0,0 +1,148 @@
+package colgen
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const budgetGenerated = `// Code generated by colgen devel; DO NOT EDIT.
+
+package db
+
+// IDs returns all IDs.
+func (ll NewsList) IDs() []int {
+	// allocate result
+	r := make([]int, len(ll))
+	for i := range ll {
+		r[i] = ll[i].ID
+	}
+	return r
+}
+`
+
+const budgetSource = `package db
+
+// News is a news.
+type News struct {
+	ID int // primary key
+}
+
+// Title returns title.
+func (n News) Title() string {
+	return "title"
+}
+`
+
+func TestPromptBudget_Fits(t *testing.T) {
+	assert.True(t, PromptBudget{}.Fits("anything"))
+	assert.True(t, PromptBudget{MaxBytes: 3}.Fits("abc"))
+	assert.False(t, PromptBudget{MaxBytes: 2}.Fits("abc"))
+}
+
+func TestPromptBudget_Reduce(t *testing.T) {
+	t.Run("fits", func(t *testing.T) {
+		assert.Equal(t, []string{budgetSource}, PromptBudget{MaxBytes: len(budgetSource)}.Reduce(budgetSource))
+	})
+
+	t.Run("strips generated bodies", func(t *testing.T) {
+		chunks := PromptBudget{MaxBytes: len(budgetGenerated) - 1}.Reduce(budgetGenerated)
+		require.Len(t, chunks, 1)
+		assert.Contains(t, chunks[0], "// IDs returns all IDs.\nfunc (ll NewsList) IDs() []int\n")
+		assert.NotContains(t, chunks[0], "make")
+		assert.NotContains(t, chunks[0], "allocate result")
+	})
+
+	t.Run("keeps bodies of non generated code", func(t *testing.T) {
+		_, ok := stripGeneratedBodies(budgetSource)
+		assert.False(t, ok)
+	})
+
+	t.Run("drops comments", func(t *testing.T) {
+		chunks := PromptBudget{MaxBytes: len(budgetSource) - 1}.Reduce(budgetSource)
+		require.Len(t, chunks, 1)
+		assert.NotContains(t, chunks[0], "//")
+		assert.Contains(t, chunks[0], `return "title"`)
+	})
+
+	t.Run("splits into chunks", func(t *testing.T) {
+		const maxBytes = 40
+		code := strings.Repeat("var a = 1\n\n", 10)
+		chunks := PromptBudget{MaxBytes: maxBytes}.Reduce(code)
+		require.Greater(t, len(chunks), 1)
+		for _, c := range chunks {
+			assert.LessOrEqual(t, len(c), maxBytes)
+		}
+		assert.Equal(t, strings.TrimSpace(code), strings.TrimSpace(strings.Join(chunks, "")))
+	})
+
+	t.Run("splits huge lines", func(t *testing.T) {
+		code := strings.Repeat("x", 25)
+		chunks := PromptBudget{MaxBytes: 10}.Reduce(code)
+		assert.Equal(t, []string{"xxxxxxxxxx", "xxxxxxxxxx", "xxxxx"}, chunks)
+	})
+
+	t.Run("splits huge lines by runes", func(t *testing.T) {
+		code := strings.Repeat("я", 8)
+		chunks := PromptBudget{MaxBytes: 5}.Reduce(code)
+		assert.Equal(t, []string{"яя", "яя", "яя", "яя"}, chunks)
+		for _, c := range chunks {
+			assert.True(t, utf8.ValidString(c))
+		}
+	})
+}
+
+func TestPromptBudget_Without(t *testing.T) {
+	b, ok := PromptBudget{MaxBytes: 10}.Without(4)
+	assert.True(t, ok)
+	assert.Equal(t, 6, b.MaxBytes)
+
+	_, ok = PromptBudget{MaxBytes: 10}.Without(10)
+	assert.False(t, ok)
+
+	b, ok = PromptBudget{}.Without(10)
+	assert.True(t, ok)
+	assert.Equal(t, 0, b.MaxBytes)
+}
+
+func TestAssistant_ChunkedReview(t *testing.T) {
+	const maxBytes = 200
+
+	var calls int
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller {
+		return callerFunc(func(c Code) (string, error) {
+			calls++
+			assert.LessOrEqual(t, len(c.SystemPrompt)+len(c.Prompt), maxBytes)
+			return "review", nil
+		})
+	})
+
+	a, err := r.New("fake", "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxPromptBytes, a.budget.MaxBytes)
+
+	a.SetMaxPromptBytes(maxBytes)
+	a.SetSystemPrompt("Review the code.")
+	a.methods = "func (ll NewsList) IDs() []int"
+	res, err := a.Review(strings.Repeat("var a = 1\n\n", 10))
+	require.NoError(t, err)
+	assert.Greater(t, calls, 1)
+	assert.Equal(t, calls, strings.Count(res, "review"))
+	assert.Contains(t, res, "processed in")
+
+	t.Run("budget is exhausted", func(t *testing.T) {
+		a.SetMaxPromptBytes(10)
+		_, err := a.Review("var a = 1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exhausted")
+	})
+}
+
+// callerFunc is a Caller function adapter.
+type callerFunc func(c Code) (string, error)
+
+func (f callerFunc) Call(c Code) (string, error) { return f(c) }
@@ -0,0 +1,88 @@
+//go:build !colgen_noai
+
+package colgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+func init() {
+	RegisterAssistant(AssistantClaude, func(key string) Caller { return ClaudeCaller{Key: key} })
+	statusCoders = append(statusCoders, claudeStatusCode)
+}
+
+// claudeStatusCode returns HTTP status code of Claude API error.
+func claudeStatusCode(err error) (int, bool) {
+	var ae *anthropic.Error
+	if errors.As(err, &ae) {
+		return ae.StatusCode, true
+	}
+
+	return 0, false
+}
+
+type ClaudeCaller struct {
+	Key     string
+	BaseURL string // optional, overrides API url
+}
+
+// MaxPromptBytes returns prompt budget for 200k tokens context with room for the answer.
+func (d ClaudeCaller) MaxPromptBytes() int {
+	return 600_000
+}
+
+// MaxTemperature returns max temperature accepted by Claude API.
+func (d ClaudeCaller) MaxTemperature() float64 {
+	return 1
+}
+
+// Model returns used model name.
+func (d ClaudeCaller) Model() string {
+	return anthropic.ModelClaude3_7SonnetLatest
+}
+
+func (d ClaudeCaller) Call(c Code) (string, error) {
+	r, _, err := d.CallWithUsage(c)
+	return r, err
+}
+
+// CallWithUsage calls Claude and returns answer with tokens usage.
+func (d ClaudeCaller) CallWithUsage(c Code) (string, Usage, error) {
+	const callTimeout = 300 * time.Second
+	opts := []option.RequestOption{option.WithAPIKey(d.Key), option.WithRequestTimeout(callTimeout), option.WithEnvironmentProduction()}
+	if d.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(d.BaseURL))
+	}
+
+	client := anthropic.NewClient(opts...)
+	message, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
+		System: []anthropic.TextBlockParam{
+			{Text: c.SystemPrompt},
+		},
+		Messages: []anthropic.MessageParam{
+			anthropic.NewUserMessage(
+				[]anthropic.ContentBlockParamUnion{
+					{OfRequestTextBlock: &anthropic.TextBlockParam{Text: c.Prompt}},
+				}...,
+			),
+		},
+		Model:       d.Model(),
+		Temperature: anthropic.Float(c.temperature()),
+		MaxTokens:   10000,
+	})
+
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("claude message, err=%w", err)
+	} else if message == nil {
+		return "", Usage{}, errors.New("claude message is nil")
+	}
+
+	u := Usage{InputTokens: int(message.Usage.InputTokens), OutputTokens: int(message.Usage.OutputTokens)}
+	return message.Content[0].Text, u, nil
+}
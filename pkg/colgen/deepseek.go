@@ -0,0 +1,103 @@
+//go:build !colgen_noai
+
+package colgen
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-deepseek/deepseek"
+	"github.com/go-deepseek/deepseek/client"
+	"github.com/go-deepseek/deepseek/config"
+	"github.com/go-deepseek/deepseek/request"
+)
+
+func init() {
+	RegisterAssistant(AssistantDeepSeek, func(key string) Caller { return DeepSeekCaller{Key: key} })
+}
+
+type DeepSeekCaller struct {
+	Key     string
+	BaseURL string // optional, overrides API url
+}
+
+// MaxPromptBytes returns prompt budget for 64k tokens context with room for the answer.
+func (d DeepSeekCaller) MaxPromptBytes() int {
+	return 150_000
+}
+
+// MaxTemperature returns max temperature accepted by DeepSeek API.
+func (d DeepSeekCaller) MaxTemperature() float64 {
+	return 2
+}
+
+// Model returns used model name.
+func (d DeepSeekCaller) Model() string {
+	return deepseek.DEEPSEEK_CHAT_MODEL
+}
+
+func (d DeepSeekCaller) Call(c Code) (string, error) {
+	r, _, err := d.CallWithUsage(c)
+	return r, err
+}
+
+// CallWithUsage calls DeepSeek and returns answer with tokens usage.
+func (d DeepSeekCaller) CallWithUsage(c Code) (string, Usage, error) {
+	const callTimeout = 300
+	dc, err := deepseek.NewClientWithConfig(config.Config{
+		ApiKey:         d.Key,
+		TimeoutSeconds: callTimeout,
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	// redirect requests to BaseURL: client has no option for it
+	if cl, ok := dc.(*client.Client); ok && d.BaseURL != "" {
+		u, err := url.Parse(d.BaseURL)
+		if err != nil {
+			return "", Usage{}, err
+		}
+		cl.Client = &http.Client{Timeout: callTimeout * time.Second, Transport: baseURLTransport{base: u}}
+	}
+
+	temperature := float32(c.temperature())
+	chatReq := &request.ChatCompletionsRequest{
+		Messages: []*request.Message{
+			{
+				Role:    "system",
+				Content: c.SystemPrompt,
+			},
+			{
+				Role:    "user",
+				Content: c.Prompt,
+			},
+		},
+		Model:       d.Model(),
+		Temperature: &temperature,
+	}
+
+	chatResp, err := dc.CallChatCompletionsChat(context.Background(), chatReq)
+	if err != nil {
+		return "", Usage{}, err
+	}
+
+	var u Usage
+	if chatResp.Usage != nil {
+		u = Usage{InputTokens: chatResp.Usage.PromptTokens, OutputTokens: chatResp.Usage.CompletionTokens}
+	}
+	return chatResp.Choices[0].Message.Content, u, nil
+}
+
+// baseURLTransport sends all requests to base url.
+type baseURLTransport struct {
+	base *url.URL
+}
+
+func (t baseURLTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = t.base.Scheme, t.base.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
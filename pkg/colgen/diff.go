@@ -0,0 +1,215 @@
+package colgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileDiff represents file contents before and after colgen has written it.
+type FileDiff struct {
+	Filename      string
+	Before, After []byte
+}
+
+// Changed returns true if file contents were changed. Line endings (CRLF and LF) are not compared.
+func (fd FileDiff) Changed() bool {
+	return normalizeEOL(string(fd.Before)) != normalizeEOL(string(fd.After))
+}
+
+// normalizeEOL converts CRLF line endings to LF.
+func normalizeEOL(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// Unified returns unified diff for the file. Returns empty string if contents are equal.
+func (fd FileDiff) Unified() string {
+	return UnifiedDiff(fd.Filename, fd.Before, fd.After)
+}
+
+// diffContext is number of unchanged lines around each hunk.
+const diffContext = 3
+
+// maxDiffCells limits LCS table size, larger changes are shown as full replacement.
+const maxDiffCells = 4 << 20
+
+// diffOp is a single line operation: ' ' (equal), '-' (delete) or '+' (insert).
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns unified diff between before and after contents of the filename.
+// Returns empty string if contents are equal. Line endings (CRLF and LF) are not compared.
+func UnifiedDiff(filename string, before, after []byte) string {
+	b, a := normalizeEOL(string(before)), normalizeEOL(string(after))
+	if b == a {
+		return ""
+	}
+
+	from := "a/" + filename
+	if before == nil {
+		from = "/dev/null"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ b/%s\n", from, filename)
+	sb.WriteString(unifiedHunks(diffLines(splitLines(b), splitLines(a))))
+
+	return sb.String()
+}
+
+// splitLines splits s by new line without trailing empty line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns line operations converting a to b.
+func diffLines(a, b []string) []diffOp {
+	// strip common prefix and suffix
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		ops = append(ops, diffOp{kind: ' ', text: l})
+	}
+
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{kind: ' ', text: l})
+	}
+
+	return ops
+}
+
+// diffMiddle returns line operations using longest common subsequence.
+func diffMiddle(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for _, l := range a {
+			ops = append(ops, diffOp{kind: '-', text: l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{kind: '+', text: l})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{kind: '-', text: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{kind: '-', text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{kind: '+', text: b[j]})
+	}
+
+	return ops
+}
+
+// unifiedHunks formats line operations as unified diff hunks.
+func unifiedHunks(ops []diffOp) string {
+	// aPos[k] and bPos[k] are line counts before ops[k]
+	aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		// find next change
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// merge changes separated by less than 2*diffContext equal lines
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			j := end
+			for j < len(ops) && ops[j].kind == ' ' {
+				j++
+			}
+			if j == len(ops) || j-end > 2*diffContext {
+				break
+			}
+			end = j
+		}
+
+		start, stop := max(i-diffContext, 0), min(end+diffContext, len(ops))
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[stop]), hunkRange(bPos[start], bPos[stop]))
+		for _, op := range ops[start:stop] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+
+		i = stop
+	}
+
+	return sb.String()
+}
+
+// hunkRange returns unified diff range for lines [from, to).
+func hunkRange(from, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	}
+
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
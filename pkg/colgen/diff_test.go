@@ -0,0 +1,100 @@
+package colgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{
+			name:   "equal",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "equal with CRLF",
+			before: "a\r\nb\r\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:  "new file",
+			after: "a\nb\n",
+			want: `--- /dev/null
++++ b/x.go
+@@ -0,0 +1,2 @@
++a
++b
+`,
+		},
+		{
+			name:   "change in the middle",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			after:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: `--- a/x.go
++++ b/x.go
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`,
+		},
+		{
+			name:   "two hunks",
+			before: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			after:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n",
+			want: `--- a/x.go
++++ b/x.go
+@@ -1,4 +1,4 @@
+-1
++one
+ 2
+ 3
+ 4
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`,
+		},
+		{
+			name:   "delete all",
+			before: "a\n",
+			after:  "",
+			want: `--- a/x.go
++++ b/x.go
+@@ -1,1 +0,0 @@
+-a
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before []byte
+			if tt.before != "" {
+				before = []byte(tt.before)
+			}
+			assert.Equal(t, tt.want, UnifiedDiff("x.go", before, []byte(tt.after)))
+		})
+	}
+}
+
+func TestFileDiff_Changed(t *testing.T) {
+	assert.False(t, FileDiff{Before: []byte("a\r\nb\r\n"), After: []byte("a\nb\n")}.Changed())
+	assert.True(t, FileDiff{Before: []byte("a\r\nb\r\n"), After: []byte("a\nc\n")}.Changed())
+	assert.True(t, FileDiff{After: []byte("a\n")}.Changed())
+}
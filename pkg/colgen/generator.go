@@ -1,17 +1,30 @@
 package colgen
 
 import (
+	"bufio"
 	"bytes"
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/build"
 	"go/format"
+	"go/parser"
+	"go/token"
 	"go/types"
+	"io"
+	"maps"
+	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
@@ -20,24 +33,124 @@ import (
 )
 
 const (
-	CustomRuleUnique = "Unique"
-	CustomRuleMap    = "Map"
-	CustomRuleMapP   = "MapP"
-	CustomRuleIndex  = "Index"
-	CustomRuleGroup  = "Group"
-	FieldID          = "ID"
+	CustomRuleUnique        = "Unique"               // UniqueTagIDs => UniqueTagIDs(), Unique(Email,fold,trim) => UniqueEmails() with normalized values
+	CustomRuleMap           = "Map"                  // Map(db.User) => mapping function of values, lowercase map(db.User) for private one
+	CustomRuleMapP          = "MapP"                 // MapP(db) => mapping function with package prefix
+	CustomRuleIndex         = "Index"                // Index(UserID) => IndexByUserID() map[UserID]T, one element per key
+	CustomRuleGroup         = "Group"                // Group(UserID) => GroupByUserID() map[UserID]List
+	CustomRuleIndexMultiPtr = "IndexMultiPtr"        // IndexMultiPtr(UserID) => IndexByUserID() map[UserID][]*T
+	CustomRuleAppend        = "Append"               // Append(Title) => AppendTitles(dst)
+	CustomRuleIDsAppend     = "IDsAppend"            // IDsAppend => AppendIDs(dst)
+	CustomRuleByField       = "ByField"              // ByField(UserID) => ByUserID(), preferred alias for Index(UserID)
+	CustomRuleExclude       = "Exclude"              // Exclude(0,999) => Exclude(), ExcludeDeleted(0,999) => ExcludeDeleted() without ID in 0, 999
+	CustomRuleLen           = "Len"                  // Len => Len() and IsEmpty()
+	CustomRuleDelta         = "Delta"                // Delta(Quantity) => DeltaQuantity(prev) map[ID]Quantity
+	CustomRuleApply         = "Apply"                // Apply(processor.Enrich) => Apply(fn) and Enrich()
+	CustomRuleSortable      = "Sortable"             // Sortable(Name) => Len(), Swap() and Less() of sort.Interface by Name
+	CustomRuleSort          = "Sort"                 // Sort(-CreatedAt,ID) => SortByCreatedAtDescID() stably sorted copy by CreatedAt desc, then ID
+	CustomRuleFirst         = "First"                // First => First() (T, error)
+	CustomRuleLast          = "Last"                 // Last => Last() (T, error)
+	CustomRuleIndexCI       = "IndexCaseInsensitive" // IndexCaseInsensitive(Title) => IndexByTitleCI() map[string]T
+	CustomRuleJSON          = "JSON"                 // JSON => MarshalBinary() and UnmarshalBinary()
+	CustomRuleHead          = "Head"                 // Head => Head(n)
+	CustomRuleTail          = "Tail"                 // Tail => Tail(n)
+	CustomRuleRotate        = "Rotate"               // Rotate => Rotate(n)
+	CustomRuleSQLIn         = "SQLIn"                // SQLIn(pg) => IDPlaceholders(start) with $1,$2 or SQLIn(mysql) with ?,?
+	CustomRuleAvg           = "Avg"                  // Avg(Price) => AvgPrice()
+	CustomRuleStdDev        = "StdDev"               // StdDev(Price) => StdDevPrice()
+	CustomRuleAccumulate    = "Accumulate"           // Accumulate(Price) => AccumulatedPrices() of running totals
+	CustomRulePartition     = "Partition"            // Partition(Active) => PartitionByActive() (matched, rest)
+	CustomRuleDistinct      = "Distinct"             // Distinct(Title) => DistinctTitles() in order of first occurrence
+	CustomRuleSparse        = "Sparse"               // Sparse(AuthorID) => SparseByAuthorID() without zero values
+	CustomRuleUniqueSorted  = "UniqueSorted"         // UniqueSorted(Title) => UniqueSortedTitles()
+	CustomRuleGroupSorted   = "GroupSorted"          // GroupSorted(CategoryID) => GroupSortedByCategoryID() with sorted keys
+	CustomRuleCacheKey      = "CacheKey"             // CacheKey => T.CacheKey()
+	CustomRuleHash          = "Hash"                 // Hash => T.Hash()
+	CustomRulePaginate      = "Paginate"             // Paginate => Paginate(page, size)
+	CustomRuleIndexInto     = "IndexInto"            // IndexInto => IndexInto(dst) by ID, IndexInto(UserID) => IndexByUserIDInto(dst)
+	CustomRuleGroupInto     = "GroupInto"            // GroupInto(UserID) => GroupByUserIDInto(dst)
+	CustomRuleShuffle       = "Shuffle"              // Shuffle => Shuffle()
+	CustomRuleTakeWhile     = "TakeWhile"            // TakeWhile(Published) => TakeWhilePublished(v)
+	CustomRuleDropWhile     = "DropWhile"            // DropWhile(Published) => DropWhilePublished(v)
+	CustomRuleFlatten       = "Flatten"              // FlattenTags => FlattenTags() of slice field
+	CustomRuleFlattenSelf   = "FlattenSelf"          // FlattenSelf(SubCategories) => FlattenSubCategories() of recursive field
+	CustomRuleAssociate     = "Associate"            // Associate(URL,Title) => AssociateURLTitle() map[URL]Title
+	CustomRuleKeys          = "Keys"                 // Keys(Metadata) => MetadataKeys()
+	CustomRuleValues        = "Values"               // Values(Metadata) => MetadataValues()
+	CustomRulePairs         = "Pairs"                // Pairs(ID,Title) => IDTitlePairs() []NewsIDTitlePair
+	CustomRulePivot         = "Pivot"                // Pivot(Month,Amount) => PivotMonthAmount() map[Month][]Amount
+	CustomRuleIndexExact    = "IndexExact"           // IndexExact(UserID) is equivalent to Index(UserID)
+	CustomRuleWithField     = "With"                 // WithTitle => T.WithTitle(v) copy of entity with Title replaced
+	CustomRuleSynced        = "Synced"               // Synced => SyncedNewsList wrapper with RWMutex: Add, All, Get by ID and Len
+	CustomRuleMissing       = "Missing"              // Missing(TagIDs,Tag) => MissingTagIDs(tags Tags) []int not present in tags by ID
+	CustomRuleMerge         = "Merge"                // Merge => Merge(other) NewsList with elements of other replacing ones with the same ID
+	CustomRuleAttach        = "Attach"               // Attach(Tags,TagIDs,Tags) => AttachTags(tags Tags) fills Tags of elements by TagIDs
+	CustomRuleField         = "Field"                // Field(Author.Company.Name) => AuthorCompanyNames() skipping elements with nil Author or Company
+	CustomRuleFieldOr       = "FieldOr"              // FieldOr(Author.Company.Name,"unknown") => AuthorCompanyNamesOr() with "unknown" for nil hops
+	FieldID                 = "ID"
+
+	SQLInPostgres = "pg"
+	SQLInMySQL    = "mysql"
+
+	UniqueFold = "fold" // Unique(Email,fold) compares lowercased values
+	UniqueTrim = "trim" // Unique(Tag,trim) trims spaces of values
+
+	NilSkip = "skipnil" // Index(ParentID,skipnil) skips elements with nil pointer field, default for pointer fields
+	NilZero = "zeronil" // Group(ParentID,zeronil) keys elements with nil pointer field by zero value
 
 	ColgenPrefix    = "//colgen:"
 	InjectionPrefix = "//colgen@"
 	AssistantPrefix = "//colgen@ai:"
+	RoutePrefix     = "//colgen[" // //colgen[heavy_colgen.go,!tinygo]:Analytics routes directive to another generated file
 )
 
+// fieldArgRules are custom rules with required field argument, e.g. Index(UserID).
+var fieldArgRules = []string{
+	CustomRuleIndex, CustomRuleGroup, CustomRuleIndexMultiPtr, CustomRuleAppend, CustomRuleDelta, CustomRuleSortable,
+	CustomRuleIndexCI, CustomRuleAvg, CustomRuleStdDev, CustomRuleAccumulate, CustomRulePartition, CustomRuleDistinct,
+	CustomRuleSparse, CustomRuleGroupInto, CustomRuleKeys, CustomRuleValues, CustomRuleByField, CustomRuleUniqueSorted,
+	CustomRuleGroupSorted, CustomRuleFlattenSelf,
+}
+
+// argRules are custom rules with required argument other than a single field, e.g. Apply(processor.Enrich).
+var argRules = []string{
+	CustomRuleIndexExact, CustomRuleTakeWhile, CustomRuleDropWhile, CustomRuleAssociate, CustomRulePairs, CustomRulePivot,
+	CustomRuleExclude, CustomRuleSQLIn, CustomRuleApply, CustomRuleSort, CustomRuleMissing,
+	CustomRuleAttach, CustomRuleFieldOr,
+}
+
+// noArgRules are custom rules without arguments and fields, e.g. Len.
+var noArgRules = []string{
+	CustomRuleLen, CustomRuleFirst, CustomRuleLast, CustomRuleJSON, CustomRuleHead, CustomRuleTail, CustomRuleRotate,
+	CustomRuleCacheKey, CustomRuleHash, CustomRulePaginate, CustomRuleShuffle, CustomRuleSynced,
+	CustomRuleMerge,
+}
+
 var (
-	ErrUnknownLine   = errors.New("unknown line")
-	ErrMissingArg    = errors.New("missing arg")
-	ErrMissingType   = errors.New("missing type")
-	ErrMissingField  = errors.New("missing field")
-	ErrMissingEntity = errors.New("missing main entity")
+	ErrUnknownRule    = errors.New("unknown rule")
+	ErrUnknownLine    = errors.New("unknown line")
+	ErrMissingArg     = errors.New("missing arg")
+	ErrMissingType    = errors.New("missing type")
+	ErrMissingField   = errors.New("missing field")
+	ErrMissingEntity  = errors.New("missing main entity")
+	ErrDuplicateRule  = errors.New("duplicate rule")
+	ErrNotGenerated   = errors.New("nothing generated")
+	ErrFormat         = errors.New("format failed")
+	ErrOptionalRule   = errors.New("optional marker is not allowed")
+	ErrFieldType      = errors.New("invalid field type")
+	ErrInvalidArg     = errors.New("invalid arg")
+	ErrUnusedImport   = errors.New("unused import")
+	ErrNotInWorkspace = errors.New("module is not in go.work workspace")
+	ErrLoadPackage    = errors.New("failed to load package")
+	ErrInvalidEntity  = errors.New("invalid entity name")
+	ErrUnexported     = errors.New("unexported field in exported method")
+	ErrIllTyped       = errors.New("entity has type errors")
+	ErrGeneratedType  = errors.New("entity is declared in generated file")
+	ErrMapFunc        = errors.New("map function not found")
+	ErrFieldMethod    = errors.New("method is named as entity field")
+
+	// ErrEmptyCollection is declared in generated code for methods that are undefined for empty collections, e.g. First.
+	ErrEmptyCollection = errors.New("empty collection")
 )
 
 type Entity struct {
@@ -45,44 +158,65 @@ type Entity struct {
 }
 
 func NewEntity(name string, useList bool) Entity {
-	list, pl := name+"List", ""
-	if !useList {
-		pl = inflection.Plural(name)
+	return NewEntityName(name, useList).Entity
+}
+
+// Reasons of collection name choice, see NewEntityName.
+const (
+	NameReasonPlural   = "plural"                                       // Tag => Tags
+	NameReasonList     = "-list"                                        // Tag => TagList
+	NameReasonFallback = "plural equals singular, List suffix is added" // News => NewsList, Equipment => EquipmentList
+)
+
+// EntityName is an entity with collection name and the reason of its choice.
+type EntityName struct {
+	Entity
+	Reason string
+}
+
+// String returns name mapping for reports: News => NewsList (plural equals singular, List suffix is added).
+func (en EntityName) String() string {
+	return en.Name + " => " + en.List + " (" + en.Reason + ")"
+}
+
+// NewEntityName returns entity with collection name: plural of name, or name with List suffix for useList
+// and for names which plural equals singular, e.g. News or Equipment.
+func NewEntityName(name string, useList bool) EntityName {
+	if useList {
+		return EntityName{Entity: Entity{Name: name, List: name + "List"}, Reason: NameReasonList}
+	}
+
+	if pl := inflection.Plural(name); pl != name {
+		return EntityName{Entity: Entity{Name: name, List: pl}, Reason: NameReasonPlural}
 	}
 
-	if !useList && pl != name {
-		list = pl
+	return EntityName{Entity: Entity{Name: name, List: name + "List"}, Reason: NameReasonFallback}
+}
+
+// EntityNames returns collection names of entities of rules in order of rules.
+func EntityNames(rules []Rule) []EntityName {
+	r := make([]EntityName, 0, len(rules))
+	for _, rule := range rules {
+		r = append(r, NewEntityName(rule.EntityName, rule.UseListSuffix))
 	}
-	return Entity{Name: name, List: list}
+
+	return r
 }
 
 func ParseRules(lines []string, useListSuffix bool) ([]Rule, error) {
 	var result []Rule
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		var (
-			rr  []Rule
-			err error
-		)
+		// nolint directives are used by Vet only
+		line, _ = CutNolint(strings.TrimSpace(line))
 
 		// skip empty lines
 		if line == "" {
 			continue
 		}
 
-		switch {
-		// detect custom generators: // colgen:News:UniqueTagIDs, Map
-		case strings.Contains(line, ":"):
-			rr, err = parseCustomRule(line)
-		// detect main entities like: //colgen:News,Tag or //colgen:News
-		case strings.Contains(line, ",") || !strings.Contains(line, " "):
-			rr, err = parseEntities(line)
-		default: // fail on unknow lines
-			return nil, fmt.Errorf("%w: %q", ErrUnknownLine, line)
-		}
-
+		rr, err := parseLine(line)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %q", err, line)
+			return nil, err
 		}
 
 		result = append(result, rr...)
@@ -94,9 +228,40 @@ func ParseRules(lines []string, useListSuffix bool) ([]Rule, error) {
 	return merged, err
 }
 
-// validateRules validates Rules for BaseGen parameter and MapP/Map.
+// parseLine parses single non-empty directive line without validation of the whole rule set.
+func parseLine(line string) ([]Rule, error) {
+	var (
+		rr  []Rule
+		err error
+	)
+
+	switch {
+	// detect custom generators: // colgen:News:UniqueTagIDs, Map
+	case strings.Contains(line, ":"):
+		rr, err = parseCustomRule(line)
+	// detect main entities like: //colgen:News,Tag or //colgen:News
+	case strings.Contains(line, ",") || !strings.Contains(line, " "):
+		rr, err = parseEntities(line)
+	default: // fail on unknow lines
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLine, line)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", err, line)
+	}
+
+	return rr, nil
+}
+
+// validateRules validates Rules for BaseGen parameter, MapP/Map and optional markers.
 func validateRules(rules []Rule) error {
 	for _, r := range rules {
+		for _, cr := range r.CustomRules {
+			if cr.Optional && !hasField(cr.Name) {
+				return fmt.Errorf("%w: %s for %s", ErrOptionalRule, r.EntityName, cr.Name)
+			}
+		}
+
 		if r.BaseGen {
 			continue
 		}
@@ -143,78 +308,270 @@ func mergeRules(rules []Rule, useListSuffix bool) []Rule {
 	return result
 }
 
+// hasField checks that custom rule uses entity field.
+func hasField(name string) bool {
+	if name == CustomRuleApply || slices.Contains(noArgRules, name) {
+		return false
+	}
+
+	return !isMapP(name)
+}
+
+// applyFuncs returns comma separated functions from Apply rules.
+func applyFuncs(rules []CustomRule) string {
+	var ff []string
+	for _, cr := range rules {
+		if cr.Name == CustomRuleApply {
+			ff = append(ff, cr.Arg)
+		}
+	}
+
+	return strings.Join(ff, ", ")
+}
+
 // isMapP checks string for Map/MapP/map/mapp.
 func isMapP(s string) bool {
 	s = strings.ToLower(s)
 	return s == strings.ToLower(CustomRuleMap) || s == strings.ToLower(CustomRuleMapP)
 }
 
-// reNameArg is regexp for `Index(db.User)` lookalike string.
-var reNameArg = regexp.MustCompile(`(?mi)^(\w+)\(([\w.]+)\)$`)
+// reNameArg is regexp for `Index(db.User)`, `Associate(URL,Title)`, `Sort(-CreatedAt,ID)` or `Index(Slug())` lookalike string.
+var reNameArg = regexp.MustCompile(`(?mi)^(\w+)\(((?:-?[\w.]+|\w+\(\))(?:,-?[\w.]+)*)\)$`)
+
+// reNameValues is regexp for rules with literal values: `Exclude(0, 999)`, `ExcludeDrafts("draft")`, `TakeWhile(Status,"draft")`
+// or `FieldOr(Author.Company.Name,"unknown")`.
+var reNameValues = regexp.MustCompile(`(?mi)^(` + CustomRuleExclude + `\w*|` + CustomRuleTakeWhile + `|` + CustomRuleDropWhile + `|` + CustomRuleFieldOr + `)\(([^()]+)\)$`)
+
+// splitRules splits custom rules by comma except commas in parentheses: `Index(ID),Exclude(1,2)`.
+func splitRules(s string) []string {
+	var (
+		result       []string
+		depth, start int
+	)
+
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				result = append(result, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(result, s[start:])
+}
 
 // parseCustomRule parses custom rules like `//colgen:News:UniqueTagIDs,Map`.
 func parseCustomRule(line string) ([]Rule, error) {
-	var rule Rule
 	ll := strings.Split(line, ":")
 	if len(ll) != 2 {
 		return nil, fmt.Errorf("%w: %q", ErrUnknownLine, line)
 	}
 
-	// set entity name
-	rule.EntityName = ll[0]
-
 	// process all custom generators
-	for _, l := range strings.Split(ll[1], ",") {
-		name, arg := l, ""
-		matches := reNameArg.FindStringSubmatch(l)
-		if len(matches) == 3 {
-			name, arg = matches[1], matches[2]
+	rule := Rule{EntityName: ll[0]}
+	for _, l := range splitRules(ll[1]) {
+		cr, err := parseRuleItem(l)
+		if err != nil {
+			return nil, err
 		}
 
-		var cr CustomRule
-		switch {
-		case strings.HasPrefix(name, CustomRuleUnique): // UniqueTagIDs, UniqueEpisodeID
-			cr.Name = CustomRuleUnique
-			cr.Field = strings.TrimPrefix(name, CustomRuleUnique)
-		case isMapP(name): // MapP(db), Map(db.User), mapp(db), map(db)
-			if arg == "" {
-				return nil, fmt.Errorf("%w: %q", ErrMissingArg, l)
-			}
+		rule.CustomRules = append(rule.CustomRules, cr)
+	}
 
-			cr.Name = name
-			cr.Arg = arg
-		case name == CustomRuleIndex || name == CustomRuleGroup: // Index(UserID) or Group(UserID)
-			if arg == "" {
-				return nil, fmt.Errorf("%w: %q", ErrMissingArg, l)
-			}
+	return []Rule{rule}, nil
+}
+
+// parseRuleItem parses one custom rule like `Index(UserID)`, `UniqueTagIDs` or optional `Index(Slug)?`.
+func parseRuleItem(l string) (CustomRule, error) {
+	// optional rule: Index(Slug)? is skipped if field is missing
+	l, optional := strings.CutSuffix(l, "?")
+
+	name, arg := l, ""
+	matches := reNameArg.FindStringSubmatch(l)
+	if len(matches) != 3 {
+		matches = reNameValues.FindStringSubmatch(l)
+	}
+	if len(matches) == 3 {
+		name, arg = matches[1], matches[2]
+	}
+
+	if arg == "" && (isMapP(name) || slices.Contains(fieldArgRules, name) || slices.Contains(argRules, name)) {
+		return CustomRule{}, fmt.Errorf("%w: %q", ErrMissingArg, l)
+	}
+
+	cr, err := customRule(name, arg)
+	if err != nil {
+		return cr, fmt.Errorf("%w: %q", err, l)
+	}
 
-			cr.Name = name
-			cr.Field = arg
-		default: // Field, like ID => IDs()
-			cr.Field = name
+	cr.Optional = optional
+	return cr, nil
+}
+
+// customRule returns custom rule by name and arg. Required arg is checked by parseRuleItem.
+func customRule(name, arg string) (CustomRule, error) {
+	switch {
+	case (name == CustomRuleIndex || name == CustomRuleByField || name == CustomRuleGroup) && strings.Contains(arg, ","): // nil mode of pointer field
+		field, mode, _ := strings.Cut(arg, ",")
+		if mode != NilSkip && mode != NilZero {
+			return CustomRule{}, fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidArg, mode, NilSkip, NilZero)
 		}
 
-		rule.CustomRules = append(rule.CustomRules, cr)
+		return CustomRule{Name: name, Field: field, Arg: mode}, nil
+	case slices.Contains(fieldArgRules, name):
+		return CustomRule{Name: name, Field: arg}, nil
+	case name == CustomRuleUnique && arg != "": // with modifiers
+		field, mods, _ := strings.Cut(arg, ",")
+		return CustomRule{Name: CustomRuleUnique, Field: field, Arg: mods}, validateUniqueModifiers(mods)
+	case strings.HasPrefix(name, CustomRuleUnique): // UniqueTagIDs, UniqueEpisodeID
+		return CustomRule{Name: CustomRuleUnique, Field: strings.TrimPrefix(name, CustomRuleUnique)}, nil
+	case isMapP(name), name == CustomRuleApply:
+		return CustomRule{Name: name, Arg: arg}, nil
+	case name == CustomRuleIndexExact: // alias of Index
+		return CustomRule{Name: CustomRuleIndex, Field: arg}, nil
+	case name == CustomRuleTakeWhile || name == CustomRuleDropWhile:
+		// TakeWhile(Published,true): value is kept to report it, generated method accepts value as param
+		field, value, _ := strings.Cut(arg, ",")
+		return CustomRule{Name: name, Field: strings.TrimSpace(field), Arg: strings.TrimSpace(value)}, nil
+	case name == CustomRuleAssociate || name == CustomRulePairs || name == CustomRulePivot: // key and value fields
+		key, value, _ := strings.Cut(arg, ",")
+		if key == "" || value == "" || strings.Contains(value, ",") {
+			return CustomRule{}, fmt.Errorf("%w: expected key and value fields", ErrInvalidArg)
+		}
+
+		return CustomRule{Name: name, Field: key, Arg: value}, nil
+	case name == CustomRuleMissing: // field and entity of package referenced by field
+		field, entity, _ := strings.Cut(arg, ",")
+		if field == "" || !token.IsIdentifier(entity) {
+			return CustomRule{}, fmt.Errorf("%w: expected field and entity of package, e.g. Missing(TagIDs,Tag)", ErrInvalidArg)
+		}
+
+		return CustomRule{Name: name, Field: field, Arg: entity}, nil
+	case name == CustomRuleAttach: // target field, key field and collection of target entity
+		args := strings.Split(arg, ",")
+		if len(args) != 3 || !token.IsIdentifier(args[0]) || !token.IsIdentifier(args[1]) || !token.IsIdentifier(args[2]) {
+			return CustomRule{}, fmt.Errorf("%w: expected target field, key field and collection, e.g. Attach(Tags,TagIDs,Tags)", ErrInvalidArg)
+		}
+
+		return CustomRule{Name: name, Field: args[0], Arg: args[1] + "," + args[2]}, nil
+	case name == CustomRuleField && arg != "" || name == CustomRuleFieldOr: // selector path of nested fields, default value of FieldOr
+		path, value, _ := strings.Cut(arg, ",")
+		if err := validatePath(path); err != nil {
+			return CustomRule{}, err
+		}
+
+		value = strings.TrimSpace(value)
+		if name == CustomRuleFieldOr && value == "" {
+			return CustomRule{}, fmt.Errorf("%w: expected selector path and default value, e.g. FieldOr(Author.Company.Name,\"unknown\")", ErrInvalidArg)
+		}
+
+		return CustomRule{Name: name, Field: path, Arg: value}, nil
+	case name == CustomRuleSort: // keys with optional minus for descending order, the first key is checked as field
+		keys := strings.Split(arg, ",")
+		return CustomRule{Name: name, Field: strings.TrimPrefix(keys[0], "-"), Arg: arg}, validateSortKeys(keys)
+	case name == CustomRuleIndexInto: // by ID if field is omitted
+		return CustomRule{Name: name, Field: cmp.Or(arg, FieldID)}, nil
+	case name == CustomRuleIDsAppend: // alias of Append(ID)
+		return CustomRule{Name: CustomRuleAppend, Field: FieldID}, nil
+	case name == CustomRuleExclude || hasRulePrefix(name, CustomRuleExclude) && arg != "": // with optional method name suffix
+		return CustomRule{Name: CustomRuleExclude, Field: FieldID, Arg: arg, Suffix: strings.TrimPrefix(name, CustomRuleExclude)}, nil
+	case name == CustomRuleSQLIn:
+		if arg != SQLInPostgres && arg != SQLInMySQL {
+			return CustomRule{}, fmt.Errorf("%w: expected %s or %s", ErrInvalidArg, SQLInPostgres, SQLInMySQL)
+		}
+
+		return CustomRule{Name: name, Field: FieldID, Arg: arg}, nil
+	case slices.Contains(noArgRules, name):
+		return CustomRule{Name: name}, nil
 	}
 
-	return []Rule{rule}, nil
+	// Field, like ID => IDs()
+	return CustomRule{Field: name}, nil
 }
 
-// parseEntities parses main entities like `//colgen:News,Tag`.
-//
-//nolint:unparam
+// validatePath checks selector path of nested fields, e.g. Author.Company.Name.
+func validatePath(path string) error {
+	hops := strings.Split(path, ".")
+	if len(hops) < 2 {
+		return fmt.Errorf("%w: expected selector path of nested fields, e.g. Author.Company.Name", ErrInvalidArg)
+	}
+
+	for _, h := range hops {
+		if !token.IsIdentifier(h) {
+			return fmt.Errorf("%w: %s is not a field of selector path %s", ErrInvalidArg, h, path)
+		}
+	}
+
+	return nil
+}
+
+// validateSortKeys checks that keys of Sort rule are not empty and not repeated, e.g. Sort(-CreatedAt,ID).
+func validateSortKeys(keys []string) error {
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		field := strings.TrimPrefix(key, "-")
+		if field == "" || strings.HasPrefix(field, "-") {
+			return fmt.Errorf("%w: invalid sort key %q", ErrInvalidArg, key)
+		}
+		if _, ok := seen[field]; ok {
+			return fmt.Errorf("%w: repeated sort key %s", ErrInvalidArg, field)
+		}
+		seen[field] = struct{}{}
+	}
+
+	return nil
+}
+
+// parseEntities parses main entities like `//colgen:News,Tag`. Each entity must be a Go identifier with optional
+// package qualifier, e.g. db.User, and must not be repeated in the line.
 func parseEntities(line string) ([]Rule, error) {
 	ll := strings.Split(line, ",")
 	r := make([]Rule, 0, len(ll))
+	seen := make(map[string]struct{}, len(ll))
 	for _, l := range ll {
-		r = append(r, Rule{EntityName: l, BaseGen: true})
+		name := strings.TrimSpace(l)
+		if !isEntityName(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEntity, l)
+		}
+
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("%w: duplicate %q", ErrInvalidEntity, name)
+		}
+		seen[name] = struct{}{}
+
+		r = append(r, Rule{EntityName: name, BaseGen: true})
 	}
 
 	return r, nil
 }
 
+// isEntityName checks that name is a Go identifier with optional package qualifier: News or db.News.
+func isEntityName(name string) bool {
+	pkgName, typeName, ok := strings.Cut(name, ".")
+	if !ok {
+		return token.IsIdentifier(name)
+	}
+
+	return token.IsIdentifier(pkgName) && token.IsIdentifier(typeName)
+}
+
+// Stats contains generation counters.
+type Stats struct {
+	Entities int            `json:"entities"` // processed entities
+	Methods  map[string]int `json:"methods"`  // generated methods by rule, e.g. IDs, Index, Unique
+	Bytes    int            `json:"bytes"`    // size of generated code
+	LoadTime time.Duration  `json:"loadTime"` // package loading time
+}
+
 type Generator struct {
-	buf bytes.Buffer // current buffer
+	buf       bytes.Buffer // current buffer
+	generated bool         // buf contains generated code
 
 	err         error    // generation error
 	pkgName     string   // main pkg
@@ -222,10 +579,26 @@ type Generator struct {
 	imports     []string // additional imports
 	version     string   // colgen version
 
+	autoImports map[string]struct{}              // imports required by generated code, e.g. slices
+	sqlScan     bool                             // generate sql.Scanner & driver.Valuer for collections
+	verbose     func(format string, args ...any) // logger for verbose messages, might be nil
+	outputFile  string                           // generated file name, e.g. main_colgen.go
+	strict      bool                             // fail on custom imports that are not used by generated code
+	unused      []string                         // custom imports that are not used by generated code
+	unexported  bool                             // allow unexported fields in exported methods
+	renameMeth  bool                             // add List suffix to collection methods named as entity fields
+	buildTags   []string                         // additional build tags for package loading, e.g. integration
+	constraint  string                           // build constraint of generated file, e.g. !tinygo
+
+	needEmptyErr       bool  // generated code uses ErrEmptyCollection
+	needPaginationMeta bool  // generated code uses PaginationMeta
+	stats              Stats // generation counters
+
 	pkg *packages.Package // parsed go packages
 }
 
 // NewGenerator returns new Generator. Do not forget to use `UsePackageDir` method.
+// Generator is not safe for concurrent use, create one Generator per file.
 func NewGenerator(pkgName, imports, funcPkgName, version string) *Generator {
 	g := &Generator{
 		pkgName:     pkgName,
@@ -241,13 +614,161 @@ func NewGenerator(pkgName, imports, funcPkgName, version string) *Generator {
 	return g
 }
 
+// SetSQLScan enables generation of sql.Scanner and driver.Valuer (JSON) for collection types.
+func (g *Generator) SetSQLScan(v bool) {
+	g.sqlScan = v
+}
+
+// SetStrictImports enables failing generation if custom imports are not used by generated code.
+func (g *Generator) SetStrictImports(v bool) {
+	g.strict = v
+}
+
+// SetAllowUnexported allows custom rules exposing unexported fields via exported methods, e.g. Index(secret).
+// By default unexported fields are allowed only in unexported methods, e.g. field rule secretScores.
+func (g *Generator) SetAllowUnexported(v bool) {
+	g.unexported = v
+}
+
+// SetRenameFieldMethods adds List suffix to collection methods named as entity fields, e.g. NamesList() of Name rule
+// for entity with Names field. By default such methods fail generation: ll.Names() and ll[i].Names are easily confused.
+func (g *Generator) SetRenameFieldMethods(v bool) {
+	g.renameMeth = v
+}
+
+// UnusedImports returns custom imports that are not used by the last generation.
+func (g *Generator) UnusedImports() []string {
+	return slices.Clone(g.unused)
+}
+
+// unusedImports returns custom imports without references like `db.` in generated body.
+func (g *Generator) unusedImports(body []byte) []string {
+	var r []string
+	for _, imp := range g.imports {
+		if !bytes.Contains(body, []byte(path.Base(imp)+".")) {
+			r = append(r, imp)
+		}
+	}
+
+	return r
+}
+
+// Stats returns counters of the last generation.
+func (g *Generator) Stats() Stats {
+	st := g.stats
+	st.Methods = maps.Clone(g.stats.Methods)
+
+	return st
+}
+
+// count increments generated methods counter by rule.
+func (g *Generator) count(rule string) {
+	if g.stats.Methods == nil {
+		g.stats.Methods = make(map[string]int)
+	}
+
+	g.stats.Methods[rule]++
+}
+
+// SetOutputFile sets name of generated file. It is used to detect declarations from previous generation,
+// e.g. ErrEmptyCollection, that must not be treated as declared elsewhere in package.
+func (g *Generator) SetOutputFile(filename string) {
+	g.outputFile = filename
+}
+
+// SetVerbose sets logger for verbose messages, e.g. skipped optional rules.
+func (g *Generator) SetVerbose(logf func(format string, args ...any)) {
+	g.verbose = logf
+}
+
+// logf writes verbose message if logger was set.
+func (g *Generator) logf(format string, args ...any) {
+	if g.verbose != nil {
+		g.verbose(format, args...)
+	}
+}
+
+// SetBuildTags sets additional build tags for package loading, e.g. from -tags flag.
+// Files excluded by build constraints are not loaded, so their types are missing.
+func (g *Generator) SetBuildTags(tags []string) {
+	g.buildTags = tags
+}
+
+// SetBuildConstraint sets build constraint expression of generated file, e.g. !tinygo. It is written as
+// `//go:build` line before header, so generated file is excluded from builds that don't satisfy it.
+func (g *Generator) SetBuildConstraint(expr string) {
+	g.constraint = expr
+}
+
 // UsePackageDir parses path for go packages.
 func (g *Generator) UsePackageDir(path string) error {
-	g.pkg, g.err = loadPackage(path)
+	return g.UsePackageDirWithContext(context.Background(), path)
+}
+
+// UsePackageDirWithContext parses path for go packages. Loading is cancelled with ctx.
+func (g *Generator) UsePackageDirWithContext(ctx context.Context, path string) error {
+	start := time.Now()
+	g.pkg, g.err = loadPackage(ctx, path, g.buildTags)
+	g.stats.LoadTime = time.Since(start)
 
 	return g.err
 }
 
+// UsePackage uses package loaded by LoadPackage, e.g. shared with Replacer and assistant within a run.
+func (g *Generator) UsePackage(pkg *packages.Package) {
+	g.pkg, g.err = pkg, nil
+}
+
+// DefaultGeneratedMethodsBytes is a default size limit for GeneratedMethods summary.
+const DefaultGeneratedMethodsBytes = 8 * 1024
+
+// GeneratedMethods returns signatures of exported methods declared in *_colgen.go files of loaded package,
+// one per line, e.g. `func (ll NewsList) IDs() []int`. Summary is truncated to maxBytes.
+func (g *Generator) GeneratedMethods(maxBytes int) string {
+	if g.pkg == nil || g.pkg.Types == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	qf := types.RelativeTo(g.pkg.Types)
+	scope := g.pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		named, ok := scope.Lookup(name).Type().(*types.Named)
+		if !ok {
+			continue
+		}
+
+		for i := range named.NumMethods() {
+			m := named.Method(i)
+			if !m.Exported() || !strings.HasSuffix(g.pkg.Fset.Position(m.Pos()).Filename, "_colgen.go") {
+				continue
+			}
+
+			sig := m.Type().(*types.Signature)
+			recv := strings.TrimSpace(sig.Recv().Name() + " " + types.TypeString(sig.Recv().Type(), qf))
+			line := fmt.Sprintf("func (%s) %s%s\n", recv, m.Name(), strings.TrimPrefix(types.TypeString(sig, qf), "func"))
+			if sb.Len()+len(line) > maxBytes {
+				sb.WriteString("...\n")
+				return sb.String()
+			}
+
+			sb.WriteString(line)
+		}
+	}
+
+	return sb.String()
+}
+
+// declaredOutside checks that name is declared in package outside of generated output file.
+func (g *Generator) declaredOutside(name string) bool {
+	obj := g.lookupType(name)
+	if obj == nil {
+		return false
+	}
+
+	return filepath.Base(g.pkg.Fset.Position(obj.Pos()).Filename) != filepath.Base(g.outputFile)
+}
+
 // lookupTypes returns type for given struct name or nil if not found.
 func (g *Generator) lookupType(s string) types.Object {
 	if g.pkg == nil {
@@ -257,6 +778,26 @@ func (g *Generator) lookupType(s string) types.Object {
 	return g.pkg.Types.Scope().Lookup(s)
 }
 
+// lookupQualified returns object of the package by name or of its imports by qualified name, e.g. db.Row, or nil if not found.
+func (g *Generator) lookupQualified(s string) types.Object {
+	pkgName, name, ok := strings.Cut(s, ".")
+	if !ok {
+		return g.lookupType(s)
+	}
+
+	if g.pkg == nil {
+		return nil
+	}
+
+	for _, imp := range g.pkg.Imports {
+		if imp.Name == pkgName && imp.Types != nil {
+			return imp.Types.Scope().Lookup(name)
+		}
+	}
+
+	return nil
+}
+
 func (g *Generator) SetError(err error, msg ...string) {
 	if err != nil && g.err == nil {
 		// wrap err if msg was set
@@ -279,6 +820,10 @@ type CustomRule struct {
 	Name  string // rule name, might be empty for `Field` generator
 	Field string // current `Field`
 	Arg   string // Optional Arg in ().
+	// Suffix is a suffix of generated method name, e.g. Deleted for ExcludeDeleted(0,999).
+	Suffix string
+
+	Optional bool // skip rule if field is missing, e.g. Index(Slug)?
 }
 
 // P writes string to Buffer.
@@ -300,131 +845,1092 @@ func (g *Generator) T(tmpl string, data TemplateData) {
 	g.SetError(t.Execute(&g.buf, data), "template")
 }
 
-// genHead generates Header for file with imports.
+// addImport adds import required by generated code.
+func (g *Generator) addImport(path string) {
+	if g.autoImports == nil {
+		g.autoImports = make(map[string]struct{})
+	}
+
+	g.autoImports[path] = struct{}{}
+}
+
+// addImports adds imports required by generated code.
+func (g *Generator) addImports(paths []string) {
+	for _, p := range paths {
+		g.addImport(p)
+	}
+}
+
+// addPackageImport adds import by package name if package is imported by current package.
+func (g *Generator) addPackageImport(name string) {
+	if g.pkg == nil {
+		return
+	}
+
+	for _, imp := range g.pkg.Imports {
+		if imp.Name == name {
+			g.addImport(imp.PkgPath)
+			return
+		}
+	}
+
+	g.logf("import for %s not found, use -imports flag", name)
+}
+
+// allImports returns sorted custom and auto imports without duplicates.
+func (g *Generator) allImports() []string {
+	imports := slices.Clone(g.imports)
+	for i := range g.autoImports {
+		imports = append(imports, i)
+	}
+	slices.Sort(imports)
+
+	return slices.Compact(imports)
+}
+
+// genHead generates Header for file with imports. Build constraint is the first line of file.
 func (g *Generator) genHead() {
+	if g.constraint != "" {
+		g.P("//go:build %s", g.constraint).L()
+		g.L()
+	}
 	g.P(`// Code generated by colgen %v; DO NOT EDIT.`, g.version)
 	g.L()
 	g.P("package %s", g.pkgName).L()
 	g.L()
 
-	if len(g.imports) > 0 {
+	if imports := g.allImports(); len(imports) > 0 {
 		g.P("import (").L()
-		for _, i := range g.imports {
+		for _, i := range imports {
 			g.P("%q", i).L()
 		}
 		g.P(")")
 	}
 }
 
-// Generate generates all code.
+// Generate generates all code and returns unformatted source.
+// Use Format to get `go fmt` version or GenerateTo to write formatted source directly.
+// Returned source is not changed by Format and later Generate calls.
 func (g *Generator) Generate(rules []Rule) ([]byte, error) {
+	rr, err := g.Resolve(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	// body is generated by Resolve before head: it collects required imports
+	g.buf = bytes.Buffer{}
+	for _, e := range rr.Entities {
+		g.buf.Write(e.code)
+	}
+
+	if g.needEmptyErr && !g.declaredOutside("ErrEmptyCollection") {
+		g.genEmptyErr()
+	}
+
+	if g.needPaginationMeta && !g.declaredOutside("PaginationMeta") {
+		g.genPaginationMeta()
+	}
+
+	body := g.buf.Bytes()
+	g.unused = g.unusedImports(body)
+	if g.strict && len(g.unused) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnusedImport, strings.Join(g.unused, ", "))
+	}
+
+	// body is kept by the previous buffer, new one starts with head
+	g.buf = bytes.Buffer{}
 	g.genHead()
 	g.L()
-	for _, r := range rules {
-		if err := g.generateByRule(r); err != nil {
-			return nil, fmt.Errorf("%w: %s", err, r.EntityName)
-		}
+	_, err = g.buf.Write(body)
+	g.SetError(err, "body")
+	if g.err != nil {
+		return nil, g.err
 	}
-	return g.buf.Bytes(), g.err
+
+	g.generated = true
+	g.stats.Bytes = g.buf.Len()
+
+	return g.buf.Bytes(), nil
 }
 
-// Format returns current Buffer as `go fmt`.
-func (g *Generator) Format() ([]byte, error) {
-	return format.Source(g.buf.Bytes())
+// GenerateTo generates all code and writes formatted source to w.
+// If formatting fails, unformatted source is written and error wraps ErrFormat.
+func (g *Generator) GenerateTo(rules []Rule, w io.Writer) error {
+	if _, err := g.Generate(rules); err != nil {
+		return err
+	}
+
+	data, fmtErr := g.Format()
+	if fmtErr != nil {
+		data = WithHash(g.buf.Bytes())
+		g.stats.Bytes = len(data)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return fmtErr
 }
 
-// generateByRule generates code by Rule to Buffer.
-func (g *Generator) generateByRule(rule Rule) error {
-	fields := typeMapFromType(g.lookupType(rule.EntityName))
-	if len(fields) == 0 {
-		return fmt.Errorf("%w: %s", ErrMissingType, rule.EntityName)
+// Format returns generated source as `go fmt` with content hash line (see WithHash).
+// Generated source is not changed, so repeated calls return the same result. Returns ErrNotGenerated if Generate was not called.
+func (g *Generator) Format() ([]byte, error) {
+	if !g.generated {
+		return nil, ErrNotGenerated
+	}
+
+	data, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFormat, err)
 	}
 
-	// create entity
-	e := NewEntity(rule.EntityName, rule.UseListSuffix)
+	data = WithHash(data)
+	g.stats.Bytes = len(data)
+
+	return data, nil
+}
 
-	// process base generation
-	idType, hasID := fields[FieldID]
-	if rule.BaseGen {
-		g.genType(e)
+// genBase generates collection type with IDs and Index by ID, and sql.Scanner if enabled, to Buffer.
+func (g *Generator) genBase(rc *ruleContext) {
+	e := rc.entity
+	g.genType(e)
+	g.L()
+	if rc.hasID {
+		idType := rc.idField.Type
+		g.addImports(rc.idField.Imports)
 		g.L()
-		if hasID {
-			g.L()
-			g.genField(TemplateData{FieldType: idType, FieldName: FieldID, Entity: e})
-			g.L()
-			g.genIndex(TemplateData{FieldType: idType, FieldName: FieldID, Entity: e})
-			g.L()
-		}
-	}
-
-	// process custom generation
-	for _, cr := range rule.CustomRules {
-		fType, hasF := fields[cr.Field]
-		switch cr.Name {
-		case CustomRuleMap, CustomRuleMapP:
-			g.genMap(cr.Name, TemplateData{FieldType: cr.Arg, Entity: e}, false, rule.BaseGen)
-		case strings.ToLower(CustomRuleMap):
-			g.genMap(CustomRuleMap, TemplateData{FieldType: cr.Arg, Entity: e}, true, rule.BaseGen)
-		case strings.ToLower(CustomRuleMapP):
-			g.genMap(CustomRuleMapP, TemplateData{FieldType: cr.Arg, Entity: e}, true, rule.BaseGen)
-		case CustomRuleUnique:
-			if strings.HasPrefix(fType, "[]") {
-				g.genUniqueFieldSlice(TemplateData{FieldType: strings.TrimPrefix(fType, "[]"), FieldName: cr.Field, Entity: e})
-			} else {
-				g.genUniqueField(TemplateData{FieldType: fType, FieldName: cr.Field, Entity: e})
-			}
-		case CustomRuleIndex:
-			g.genIndex(TemplateData{FieldType: fType, FieldName: cr.Field, FuncName: "By" + cr.Field, Entity: e})
-		case CustomRuleGroup:
-			g.genGroup(TemplateData{FieldType: fType, FieldName: cr.Field, FuncName: "By" + cr.Field, Entity: e})
-		case "":
-			g.genField(TemplateData{FieldType: fType, FieldName: cr.Field, Entity: e})
-		}
+		g.genField(TemplateData{FieldType: idType, FieldName: FieldID, Entity: e})
+		g.L()
+		g.genIndex(TemplateData{FieldType: idType, FieldName: FieldID, FuncName: CustomRuleIndex, Entity: e})
 		g.L()
+		g.count("IDs")
+		g.count(CustomRuleIndex)
+	}
 
-		// check for good type and name
-		if !hasF && (!isMapP(cr.Name)) {
-			return fmt.Errorf("%w: %s", ErrMissingField, cr.Field)
-		}
+	if g.sqlScan {
+		g.genSQLScan(TemplateData{Entity: e})
+		g.L()
+		g.count("SQLScan")
+	}
+}
+
+// exportsField checks that custom rule generates exported method for field. Field rule and rules with field prefix
+// generate methods named after field, e.g. secretScores() or limitsKeys(), that are exported only for exported field.
+// Other rules add exported prefix, e.g. IndexBysecretScore().
+func exportsField(name string) bool {
+	switch name {
+	case "", CustomRuleKeys, CustomRuleValues, CustomRulePairs, CustomRuleSort, CustomRuleField, CustomRuleFieldOr:
+		return false
+	}
+
+	return true
+}
+
+// checkIndexName checks that IndexBy<Field> method of Index or IndexMultiPtr rule is generated once and adds it to indexes.
+func checkIndexName(indexes map[string]string, cr CustomRule, name string) error {
+	funcName := CustomRuleIndex + "By" + name
+	if rule, ok := indexes[funcName]; ok {
+		return fmt.Errorf("%w: %s(%s) and %s(%s) both generate %s", ErrDuplicateRule, rule, cr.Field, cr.Name, cr.Field, funcName)
 	}
+	indexes[funcName] = cr.Name
 
 	return nil
 }
 
+// usesFieldType checks that code generated by custom rule contains field type.
+func usesFieldType(name string) bool {
+	switch name {
+	case "", CustomRuleUnique, CustomRuleFlatten, CustomRuleUniqueSorted, CustomRuleGroupSorted, CustomRuleDistinct, CustomRuleIndex, CustomRuleSparse, CustomRuleAssociate, CustomRulePairs, CustomRulePivot, CustomRuleWithField, CustomRuleIndexInto, CustomRuleGroupInto, CustomRuleTakeWhile, CustomRuleDropWhile, CustomRuleByField, CustomRuleGroup, CustomRuleIndexMultiPtr,
+		CustomRuleAppend, CustomRuleExclude, CustomRuleDelta, CustomRuleAccumulate, CustomRuleMissing:
+		return true
+	}
+
+	return false
+}
+
+// ruleKind returns rule name for stats, field rules are counted as Field.
+func ruleKind(cr CustomRule) string {
+	if cr.Name == "" {
+		return "Field"
+	}
+
+	return cr.Name
+}
+
 type TemplateData struct {
 	Entity    Entity
 	FieldType string
 	FieldName string
 	FuncName  string
+	Args      string // rule arguments, e.g. values for Exclude
+	IDType    string // type of entity ID field
+	Key       string // key statement of normalized value, e.g. k := strings.ToLower(v)
+	Value     string // description of normalized value
+	ValueName string // value field name, e.g. Title for Associate(URL,Title)
+	Param     string // param name of collection argument, e.g. tags for Missing(TagIDs,Tag)
+	Ref       string // element of collection argument, e.g. tags[i] or &tags[i] for pointers
 }
 
+// Nil collections contract: every generated method must be safe to call on a nil collection.
+// Methods return empty non-nil maps and slices, false for booleans and zero values otherwise.
+// Append-style methods return dst as is, Apply-style methods return ll as is. IsEmpty returns true.
+
 // genType writes collection Type to Buffer.
 func (g *Generator) genType(e Entity) {
 	g.P("type %s []%s", e.List, e.Name)
 }
 
-// genField generates Field to Buffer.
+// genSQLScan generates sql.Scanner and driver.Valuer implementations using JSON to Buffer.
+func (g *Generator) genSQLScan(data TemplateData) {
+	const tmpl = `
+// Scan implements sql.Scanner interface for JSON columns.
+func (ll *{{.Entity.List}}) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*ll = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type %T for {{.Entity.List}}", src)
+	}
+	return json.Unmarshal(data, ll)
+}
+
+// Value implements driver.Valuer interface for JSON columns. Nil collection is stored as NULL.
+func (ll {{.Entity.List}}) Value() (driver.Value, error) {
+	if ll == nil {
+		return nil, nil
+	}
+	return json.Marshal(ll)
+}`
+
+	g.addImport("database/sql/driver")
+	g.addImport("encoding/json")
+	g.addImport("fmt")
+	g.T(tmpl, data)
+}
+
+// genJSON generates encoding.BinaryMarshaler and encoding.BinaryUnmarshaler implementations using JSON to Buffer.
+func (g *Generator) genJSON(data TemplateData) {
+	const tmpl = `
+// MarshalBinary implements encoding.BinaryMarshaler interface using JSON, e.g. for caching in Redis.
+func (ll {{.Entity.List}}) MarshalBinary() ([]byte, error) {
+	return json.Marshal([]{{.Entity.Name}}(ll))
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface using JSON.
+func (ll *{{.Entity.List}}) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, (*[]{{.Entity.Name}})(ll))
+}`
+
+	g.addImport("encoding/json")
+	g.T(tmpl, data)
+}
+
+// genWithField generates immutable update method of entity to Buffer. FuncName is a receiver name, Args is a parameter name.
+func (g *Generator) genWithField(data TemplateData) {
+	const tmpl = `
+// With{{.FieldName}} returns a copy of {{.Entity.Name}} with {{.FieldName}} set to {{.Args}}, {{.FuncName}} is not modified.
+func ({{.FuncName}} {{.Entity.Name}}) With{{.FieldName}}({{.Args}} {{.FieldType}}) {{.Entity.Name}} {
+	{{.FuncName}}.{{.FieldName}} = {{.Args}}
+	return {{.FuncName}}
+}`
+
+	g.T(tmpl, data)
+}
+
+// genCacheKey generates CacheKey method of entity to Buffer. FuncName is a receiver name, Args are Sprintf arguments.
+func (g *Generator) genCacheKey(data TemplateData) {
+	const tmpl = `
+// CacheKey returns deterministic key of {{.Entity.Name}} built from all exported fields sorted by name.
+func ({{.FuncName}} {{.Entity.Name}}) CacheKey() string {
+	return fmt.Sprintf({{.Args}})
+}`
+
+	g.addImport("fmt")
+	g.T(tmpl, data)
+}
+
+// genHash generates FNV-1a Hash method of entity to Buffer. FuncName is a receiver name, Args are hasher writes.
+func (g *Generator) genHash(data TemplateData) {
+	const tmpl = `
+// Hash returns 64-bit FNV-1a hash of all exported fields of {{.Entity.Name}} sorted by name, e.g. for fast equality check in caches.
+// Fields are hashed by %v representation, so equal hashes don't guarantee equal values.
+func ({{.FuncName}} {{.Entity.Name}}) Hash() uint64 {
+	h := fnv.New64a()
+	{{.Args}}
+	return h.Sum64()
+}`
+
+	g.addImport("fmt")
+	g.addImport("hash/fnv")
+	g.T(tmpl, data)
+}
+
+// genField generates Field to Buffer.
 func (g *Generator) genField(data TemplateData) {
 	const tmpl = `
-func (ll {{.Entity.List}}) {{.FuncName}}() []{{.FieldType}} {
-	r := make([]{{.FieldType}}, len(ll))
+func (ll {{.Entity.List}}) {{.FuncName}}() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, len(ll))
+	for i := range ll {
+		r[i] = ll[i].{{.FieldName}}
+	}
+	return r
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	g.T(tmpl, data)
+}
+
+// genPath generates Field of selector path through nested fields to Buffer. Key is nil check of pointer fields.
+func (g *Generator) genPath(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}} returns {{.FieldName}} of elements{{if .Key}}, elements with nil {{.Value}} are skipped{{end}}.
+func (ll {{.Entity.List}}) {{.FuncName}}() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		{{- if .Key}}
+		if {{.Key}} {
+			continue
+		}
+		{{- end}}
+		r = append(r, ll[i].{{.FieldName}})
+	}
+	return r
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(strings.ReplaceAll(data.FieldName, ".", "")))
+	g.T(tmpl, data)
+}
+
+// genPathOr generates FieldOr of selector path through nested fields with default value Args to Buffer.
+func (g *Generator) genPathOr(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}}Or returns {{.FieldName}} of elements, {{.Args}} for elements with nil {{.Value}}.
+func (ll {{.Entity.List}}) {{.FuncName}}Or() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, len(ll))
+	for i := range ll {
+		if {{.Key}} {
+			r[i] = {{.Args}}
+			continue
+		}
+		r[i] = ll[i].{{.FieldName}}
+	}
+	return r
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(strings.ReplaceAll(data.FieldName, ".", "")))
+	g.T(tmpl, data)
+}
+
+// genAppendField generates append-style Field collector to Buffer.
+func (g *Generator) genAppendField(data TemplateData) {
+	const tmpl = `
+// Append{{.FuncName}} appends {{.FieldName}} of all elements to dst and returns the extended slice.
+// It allows reusing dst between calls, e.g. via sync.Pool.
+func (ll {{.Entity.List}}) Append{{.FuncName}}(dst []{{.FieldType}}) []{{.FieldType}} {
+	for i := range ll {
+		dst = append(dst, ll[i].{{.FieldName}})
+	}
+	return dst
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	g.T(tmpl, data)
+}
+
+// genAvg generates Avg of numeric field to Buffer.
+func (g *Generator) genAvg(data TemplateData) {
+	const tmpl = `
+// Avg{{.FieldName}} returns arithmetic mean of {{.FieldName}} or 0 if ll is empty.
+func (ll {{.Entity.List}}) Avg{{.FieldName}}() float64 {
+	if len(ll) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := range ll {
+		sum += float64(ll[i].{{.FieldName}})
+	}
+	return sum / float64(len(ll))
+}`
+
+	g.T(tmpl, data)
+}
+
+// genStdDev generates population standard deviation of numeric field to Buffer.
+func (g *Generator) genStdDev(data TemplateData) {
+	const tmpl = `
+// StdDev{{.FieldName}} returns population standard deviation of {{.FieldName}} or 0 if ll has less than two elements.
+func (ll {{.Entity.List}}) StdDev{{.FieldName}}() float64 {
+	if len(ll) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for i := range ll {
+		mean += float64(ll[i].{{.FieldName}})
+	}
+	mean /= float64(len(ll))
+
+	var variance float64
+	for i := range ll {
+		d := float64(ll[i].{{.FieldName}}) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(ll)))
+}`
+
+	g.addImport("math")
+	g.T(tmpl, data)
+}
+
+// genPartition generates Partition by bool field to Buffer.
+func (g *Generator) genPartition(data TemplateData) {
+	const tmpl = `
+// PartitionBy{{.FieldName}} splits ll into elements with {{.FieldName}} set and the rest, preserving order.
+func (ll {{.Entity.List}}) PartitionBy{{.FieldName}}() (matched, rest {{.Entity.List}}) {
+	matched, rest = {{.Entity.List}}{}, {{.Entity.List}}{}
+	for i := range ll {
+		if ll[i].{{.FieldName}} {
+			matched = append(matched, ll[i])
+		} else {
+			rest = append(rest, ll[i])
+		}
+	}
+	return matched, rest
+}`
+
+	g.T(tmpl, data)
+}
+
+// genAccumulate generates running totals of numeric field to Buffer.
+func (g *Generator) genAccumulate(data TemplateData) {
+	const tmpl = `
+// Accumulated{{.FuncName}} returns running totals of {{.FieldName}}: r[i] is a sum of {{.FieldName}} of elements 0..i.
+func (ll {{.Entity.List}}) Accumulated{{.FuncName}}() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, len(ll))
+	var sum {{.FieldType}}
+	for i := range ll {
+		sum += ll[i].{{.FieldName}}
+		r[i] = sum
+	}
+	return r
+}`
+
+	data.FuncName = inflection.Plural(data.FieldName)
+	g.T(tmpl, data)
+}
+
+// genHeadRule generates Head to Buffer.
+func (g *Generator) genHeadRule(data TemplateData) {
+	const tmpl = `
+// Head returns the first n elements of ll or all elements if n >= len(ll). Result shares the backing array with ll.
+func (ll {{.Entity.List}}) Head(n int) {{.Entity.List}} {
+	if n <= 0 || len(ll) == 0 {
+		return {{.Entity.List}}{}
+	}
+	return ll[:min(n, len(ll))]
+}`
+
+	g.T(tmpl, data)
+}
+
+// genTailRule generates Tail to Buffer.
+func (g *Generator) genTailRule(data TemplateData) {
+	const tmpl = `
+// Tail returns the last n elements of ll or all elements if n >= len(ll). Result shares the backing array with ll.
+func (ll {{.Entity.List}}) Tail(n int) {{.Entity.List}} {
+	if n <= 0 || len(ll) == 0 {
+		return {{.Entity.List}}{}
+	}
+	return ll[len(ll)-min(n, len(ll)):]
+}`
+
+	g.T(tmpl, data)
+}
+
+// genRotate generates Rotate to Buffer.
+func (g *Generator) genRotate(data TemplateData) {
+	const tmpl = `
+// Rotate returns a new collection with elements of ll rotated left by n positions, negative n rotates right.
+func (ll {{.Entity.List}}) Rotate(n int) {{.Entity.List}} {
+	r := make({{.Entity.List}}, len(ll))
+	if len(ll) == 0 {
+		return r
+	}
+
+	n %= len(ll)
+	if n < 0 {
+		n += len(ll)
+	}
+	copy(r, ll[n:])
+	copy(r[len(ll)-n:], ll[:n])
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genShuffle generates Fisher-Yates Shuffle and ShuffleWith of random source to Buffer.
+func (g *Generator) genShuffle(data TemplateData) {
+	const tmpl = `
+// Shuffle returns a new collection with elements of ll in random order, ll is not modified.
+func (ll {{.Entity.List}}) Shuffle() {{.Entity.List}} {
+	return ll.ShuffleWith(nil)
+}
+
+// ShuffleWith returns a new collection with elements of ll in random order of rnd, ll is not modified.
+// Global source of math/rand/v2 is used if rnd is nil, seeded rnd gives reproducible order, e.g. in tests.
+func (ll {{.Entity.List}}) ShuffleWith(rnd *rand.Rand) {{.Entity.List}} {
+	intN := rand.IntN
+	if rnd != nil {
+		intN = rnd.IntN
+	}
+
+	r := make({{.Entity.List}}, len(ll))
+	copy(r, ll)
+	for i := len(r) - 1; i > 0; i-- {
+		j := intN(i + 1)
+		r[i], r[j] = r[j], r[i]
+	}
+	return r
+}`
+
+	g.addImport("math/rand/v2")
+	g.T(tmpl, data)
+}
+
+// genMerge generates merge of two collections by ID to Buffer.
+func (g *Generator) genMerge(data TemplateData) {
+	const tmpl = `
+// Merge returns a new collection with elements of ll in order of ll, where elements of other replace elements of ll
+// with the same ID in place, and elements of other with new IDs are appended in order of other. Only the first element
+// of ll with repeated ID is replaced, the last element of other with repeated ID wins. ll and other are not modified.
+func (ll {{.Entity.List}}) Merge(other {{.Entity.List}}) {{.Entity.List}} {
+	idx := make(map[{{.IDType}}]int, len(ll)+len(other))
+	r := make({{.Entity.List}}, 0, len(ll)+len(other))
+	for i := range ll {
+		if _, ok := idx[ll[i].ID]; !ok {
+			idx[ll[i].ID] = len(r)
+		}
+		r = append(r, ll[i])
+	}
+	for i := range other {
+		if j, ok := idx[other[i].ID]; ok {
+			r[j] = other[i]
+			continue
+		}
+		idx[other[i].ID] = len(r)
+		r = append(r, other[i])
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genSynced generates Synced<List> wrapper guarded by sync.RWMutex to Buffer. Get by ID is generated if IDType is set.
+func (g *Generator) genSynced(data TemplateData) {
+	const tmpl = `
+// Synced{{.Entity.List}} is {{.Entity.List}} guarded by sync.RWMutex, its methods are safe for concurrent use.
+// {{.Entity.List}} itself is not safe for concurrent use. Zero value is an empty collection, it must not be copied.
+type Synced{{.Entity.List}} struct {
+	mu sync.RWMutex
+	ll {{.Entity.List}}
+}
+
+// NewSynced{{.Entity.List}} returns Synced{{.Entity.List}} with a copy of ll.
+func NewSynced{{.Entity.List}}(ll {{.Entity.List}}) *Synced{{.Entity.List}} {
+	r := make({{.Entity.List}}, len(ll))
+	copy(r, ll)
+	return &Synced{{.Entity.List}}{ll: r}
+}
+
+// Add appends elements to collection.
+func (s *Synced{{.Entity.List}}) Add(vv ...{{.Entity.Name}}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ll = append(s.ll, vv...)
+}
+
+// All returns a copy of collection, it can be used without lock.
+func (s *Synced{{.Entity.List}}) All() {{.Entity.List}} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := make({{.Entity.List}}, len(s.ll))
+	copy(r, s.ll)
+	return r
+}
+{{- if .IDType}}
+
+// Get returns the first element with given ID and true if it exists.
+func (s *Synced{{.Entity.List}}) Get(id {{.IDType}}) ({{.Entity.Name}}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.ll {
+		if s.ll[i].ID == id {
+			return s.ll[i], true
+		}
+	}
+	var zero {{.Entity.Name}}
+	return zero, false
+}
+{{- end}}
+
+// Len returns number of elements in collection.
+func (s *Synced{{.Entity.List}}) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ll)
+}`
+
+	g.addImport("sync")
+	g.T(tmpl, data)
+}
+
+// genWhile generates TakeWhile or DropWhile by field value to Buffer.
+func (g *Generator) genWhile(data TemplateData, drop bool) {
+	const tmpl = `
+// TakeWhile{{.FieldName}} returns the longest prefix of ll with {{.FieldName}} equal to v. Result shares the backing array with ll.
+func (ll {{.Entity.List}}) TakeWhile{{.FieldName}}(v {{.FieldType}}) {{.Entity.List}} {
+	i := 0
+	for i < len(ll) && ll[i].{{.FieldName}} == v {
+		i++
+	}
+	if i == 0 {
+		return {{.Entity.List}}{}
+	}
+	return ll[:i]
+}`
+
+	const tmplDrop = `
+// DropWhile{{.FieldName}} returns elements of ll after the longest prefix with {{.FieldName}} equal to v. Result shares the backing array with ll.
+func (ll {{.Entity.List}}) DropWhile{{.FieldName}}(v {{.FieldType}}) {{.Entity.List}} {
+	i := 0
+	for i < len(ll) && ll[i].{{.FieldName}} == v {
+		i++
+	}
+	if i == len(ll) {
+		return {{.Entity.List}}{}
+	}
+	return ll[i:]
+}`
+
+	if drop {
+		g.T(tmplDrop, data)
+		return
+	}
+
+	g.T(tmpl, data)
+}
+
+// genSQLIn generates IN-clause placeholders and args by ID to Buffer.
+func (g *Generator) genSQLIn(data TemplateData) {
+	const tmpl = `
+// {{.FieldName}}Placeholders returns placeholders {{if eq .Args "pg"}}$start,$start+1,...{{else}}?,?,... (start is ignored){{end}} and args by {{.FieldName}} for WHERE {{.FieldName}} IN (...) clause.
+// Empty string and no args are returned for empty collection, IN clause must be skipped by caller.
+func (ll {{.Entity.List}}) {{.FieldName}}Placeholders(start int) (string, []any) {
+	args := make([]any, len(ll))
+	var sb strings.Builder
+	for i := range ll {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		{{if eq .Args "pg"}}sb.WriteString("$" + strconv.Itoa(start+i)){{else}}sb.WriteByte('?'){{end}}
+		args[i] = ll[i].{{.FieldName}}
+	}
+	return sb.String(), args
+}`
+
+	g.addImport("strings")
+	if data.Args == SQLInPostgres {
+		g.addImport("strconv")
+	}
+	g.T(tmpl, data)
+}
+
+// genLen generates Len and IsEmpty to Buffer.
+func (g *Generator) genLen(data TemplateData) {
+	const tmpl = `
+// Len returns number of elements in collection, e.g. for sort.Interface.
+func (ll {{.Entity.List}}) Len() int {
+	return len(ll)
+}
+
+// IsEmpty returns true if collection has no elements.
+func (ll {{.Entity.List}}) IsEmpty() bool {
+	return len(ll) == 0
+}`
+
+	g.T(tmpl, data)
+}
+
+// genDelta generates difference of numeric field between collection and previous snapshot by ID to Buffer.
+func (g *Generator) genDelta(data TemplateData) {
+	const tmpl = `
+// Delta{{.FieldName}} returns difference of {{.FieldName}} between ll and prev (current - previous) by ID.
+// Elements present only in ll or only in prev have their full {{.FieldName}}, the last element wins for duplicate IDs.
+func (ll {{.Entity.List}}) Delta{{.FieldName}}(prev {{.Entity.List}}) map[{{.IDType}}]{{.FieldType}} {
+	r := make(map[{{.IDType}}]{{.FieldType}}, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i].{{.FieldName}}
+	}
+	old := make(map[{{.IDType}}]{{.FieldType}}, len(prev))
+	for i := range prev {
+		old[prev[i].ID] = prev[i].{{.FieldName}}
+	}
+	for id, v := range old {
+		if cur, ok := r[id]; ok {
+			r[id] = cur - v
+		} else {
+			r[id] = v
+		}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genApply generates Apply of function to each element by pointer to Buffer. Args are default functions for doc comment.
+func (g *Generator) genApply(data TemplateData) {
+	const tmpl = `
+// Apply calls fn for each element of ll by pointer and returns ll. Default functions: {{.Args}}.
+func (ll {{.Entity.List}}) Apply(fn func(*{{.Entity.Name}})) {{.Entity.List}} {
+	for i := range ll {
+		fn(&ll[i])
+	}
+	return ll
+}`
+
+	g.T(tmpl, data)
+}
+
+// genApplyFunc generates named method applying function to each element to Buffer. Args is a function, e.g. processor.Enrich.
+func (g *Generator) genApplyFunc(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}} applies {{.Args}} to each element of ll and returns ll.
+func (ll {{.Entity.List}}) {{.FuncName}}() {{.Entity.List}} {
+	return ll.Apply({{.Args}})
+}`
+
+	g.T(tmpl, data)
+}
+
+// genSortable generates Swap and Less of sort.Interface by field to Buffer, Args is a less expression.
+// Len is generated separately by genLen.
+func (g *Generator) genSortable(data TemplateData) {
+	const tmpl = `
+// Swap swaps elements with indexes i and j, implements sort.Interface.
+func (ll {{.Entity.List}}) Swap(i, j int) {
+	ll[i], ll[j] = ll[j], ll[i]
+}
+
+// Less reports whether element i must sort before element j by {{.FieldName}}, implements sort.Interface.
+func (ll {{.Entity.List}}) Less(i, j int) bool {
+	return {{.Args}}
+}`
+
+	g.T(tmpl, data)
+}
+
+// genSort generates SortBy<Keys> returning stably sorted copy of collection to Buffer.
+// Args is a body of comparison function, Value is a description of sort keys.
+func (g *Generator) genSort(data TemplateData) {
+	const tmpl = `
+// SortBy{{.FuncName}} returns a copy of ll stably sorted by {{.Value}}, ll is not modified.
+func (ll {{.Entity.List}}) SortBy{{.FuncName}}() {{.Entity.List}} {
+	r := make({{.Entity.List}}, len(ll))
+	copy(r, ll)
+	slices.SortStableFunc(r, func(a, b {{.Entity.Name}}) int {
+		{{.Args}}
+	})
+	return r
+}`
+
+	g.addImport("slices")
+	g.T(tmpl, data)
+}
+
+// genEmptyErr declares ErrEmptyCollection once per generated file.
+func (g *Generator) genEmptyErr() {
+	g.addImport("errors")
+	g.L()
+	g.P("// ErrEmptyCollection is returned by methods that are undefined for empty collections, e.g. First.").L()
+	g.P("var ErrEmptyCollection = errors.New(%q)", ErrEmptyCollection.Error()).L()
+}
+
+// genPaginationMeta declares PaginationMeta once per generated file.
+func (g *Generator) genPaginationMeta() {
+	const tmpl = `
+// PaginationMeta is a page metadata returned by Paginate methods.
+type PaginationMeta struct {
+	Page, Size, TotalPages, TotalItems int
+}`
+
+	g.L()
+	g.T(tmpl, TemplateData{})
+	g.L()
+}
+
+// genPaginate generates Paginate to Buffer.
+func (g *Generator) genPaginate(data TemplateData) {
+	const tmpl = `
+// Paginate returns elements of ll on page with 1-based number and given size and pagination metadata.
+// Page < 1 is treated as the first page, empty collection is returned for pages out of range and size <= 0.
+// Result shares the backing array with ll.
+func (ll {{.Entity.List}}) Paginate(page, size int) ({{.Entity.List}}, PaginationMeta) {
+	page = max(page, 1)
+	meta := PaginationMeta{Page: page, Size: size, TotalItems: len(ll)}
+	if size <= 0 {
+		return {{.Entity.List}}{}, meta
+	}
+
+	meta.TotalPages = (len(ll) + size - 1) / size
+	if page > meta.TotalPages {
+		return {{.Entity.List}}{}, meta
+	}
+
+	start := (page - 1) * size
+	return ll[start:min(start+size, len(ll))], meta
+}`
+
+	g.T(tmpl, data)
+}
+
+// genFirstLast generates First or Last returning ErrEmptyCollection for empty collection to Buffer. Args is an index expression.
+func (g *Generator) genFirstLast(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}} returns the {{if eq .FuncName "First"}}first{{else}}last{{end}} element of ll or ErrEmptyCollection if ll is empty.
+func (ll {{.Entity.List}}) {{.FuncName}}() ({{.Entity.Name}}, error) {
+	if len(ll) == 0 {
+		return {{.Entity.Name}}{}, ErrEmptyCollection
+	}
+	return ll[{{.Args}}], nil
+}`
+
+	g.T(tmpl, data)
+}
+
+// genExclude generates filter by static list of excluded values to Buffer. FuncName is a method name, e.g. ExcludeDeleted,
+// Args are Go literals of values.
+func (g *Generator) genExclude(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}} returns elements of ll except ones with {{.FieldName}} in ({{.Args}}).
+func (ll {{.Entity.List}}) {{.FuncName}}() {{.Entity.List}} {
+	excluded := []{{.FieldType}}{ {{.Args}} }
+	r := make({{.Entity.List}}, 0, len(ll))
+	for i := range ll {
+		if !slices.Contains(excluded, ll[i].{{.FieldName}}) {
+			r = append(r, ll[i])
+		}
+	}
+	return r
+}`
+
+	g.addImport("slices")
+	g.T(tmpl, data)
+}
+
+// literalValues returns Go literals of static values for field: values of string fields are quoted unless already quoted,
+// other values must be literals or constants, e.g. 0, -1, true or domain.StatusDeleted.
+func literalValues(f entityField, values []string) ([]string, error) {
+	r := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if _, err := strconv.Unquote(v); err != nil && isStringField(f.typ, false) {
+			v = strconv.Quote(v)
+		}
+
+		expr, err := parser.ParseExpr(v)
+		if err != nil || !isLiteralExpr(expr) {
+			return nil, fmt.Errorf("%w: %s value %s is not a literal or constant", ErrInvalidArg, f.Name, v)
+		}
+
+		r = append(r, v)
+	}
+
+	return r, nil
+}
+
+// isLiteralExpr checks that expression is a literal or constant reference, optionally negated: 1, -1, "a", true or pkg.Const.
+func isLiteralExpr(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.BasicLit, *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		_, ok := e.X.(*ast.Ident)
+		return ok
+	case *ast.UnaryExpr:
+		_, ok := e.X.(*ast.BasicLit)
+		return ok && (e.Op == token.SUB || e.Op == token.ADD)
+	}
+
+	return false
+}
+
+// genIndex generates Index to Buffer. FuncName is a full method name: Index, IndexByField or ByField.
+func (g *Generator) genIndex(data TemplateData) {
+	const tmpl = `
+func (ll {{.Entity.List}}) {{.FuncName}}() map[{{.FieldType}}]{{.Entity.Name}} {
+	r := make(map[{{.FieldType}}]{{.Entity.Name}}, len(ll))
 	for i := range ll {
-		r[i] = ll[i].{{.FieldName}}
+		r[ll[i].{{.FieldName}}] = ll[i]
 	}
 	return r
 }`
 
-	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
 	g.T(tmpl, data)
 }
 
-// genIndex generates Index to Buffer.
-func (g *Generator) genIndex(data TemplateData) {
+// genIndexPtr generates Index by pointer field to Buffer: map is keyed by pointed-to value.
+// Elements with nil field are skipped or keyed by zero value with NilZero in Args.
+func (g *Generator) genIndexPtr(data TemplateData) {
 	const tmpl = `
-func (ll {{.Entity.List}}) Index{{.FuncName}}() map[{{.FieldType}}]{{.Entity.Name}} {
+{{- if eq .Args "zeronil"}}
+// {{.FuncName}} returns elements of ll indexed by value of {{.FieldName}}, elements with nil {{.FieldName}} are indexed by zero value.
+{{- else}}
+// {{.FuncName}} returns elements of ll indexed by value of {{.FieldName}}, elements with nil {{.FieldName}} are skipped.
+{{- end}}
+func (ll {{.Entity.List}}) {{.FuncName}}() map[{{.FieldType}}]{{.Entity.Name}} {
 	r := make(map[{{.FieldType}}]{{.Entity.Name}}, len(ll))
 	for i := range ll {
-		r[ll[i].{{.FieldName}}] = ll[i]
+{{- if eq .Args "zeronil"}}
+		var k {{.FieldType}}
+		if ll[i].{{.FieldName}} != nil {
+			k = *ll[i].{{.FieldName}}
+		}
+		r[k] = ll[i]
+{{- else}}
+		if ll[i].{{.FieldName}} == nil {
+			continue
+		}
+		r[*ll[i].{{.FieldName}}] = ll[i]
+{{- end}}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genIndexBytes generates Index by []byte field to Buffer, map is keyed by string conversion of field.
+func (g *Generator) genIndexBytes(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}} returns elements of ll indexed by string({{.FieldName}}), nil and empty {{.FieldName}} have the same key.
+func (ll {{.Entity.List}}) {{.FuncName}}() map[string]{{.Entity.Name}} {
+	r := make(map[string]{{.Entity.Name}}, len(ll))
+	for i := range ll {
+		r[string(ll[i].{{.FieldName}})] = ll[i]
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genIndexInto generates Index filling existing map to Buffer. FuncName is Index or IndexByField.
+func (g *Generator) genIndexInto(data TemplateData) {
+	const tmpl = `
+// {{.FuncName}}Into fills dst with elements of ll indexed by {{.FieldName}} and returns dst, dst is allocated if nil.
+// It allows reusing dst between calls, e.g. for paginated results. Stale keys from previous fills are not removed.
+func (ll {{.Entity.List}}) {{.FuncName}}Into(dst map[{{.FieldType}}]{{.Entity.Name}}) map[{{.FieldType}}]{{.Entity.Name}} {
+	if dst == nil {
+		dst = make(map[{{.FieldType}}]{{.Entity.Name}}, len(ll))
+	}
+	for i := range ll {
+		dst[ll[i].{{.FieldName}}] = ll[i]
+	}
+	return dst
+}`
+
+	g.T(tmpl, data)
+}
+
+// genIndexCI generates case-insensitive Index by string field to Buffer.
+func (g *Generator) genIndexCI(data TemplateData) {
+	const tmpl = `
+// IndexBy{{.FieldName}}CI returns elements of ll indexed by lowercased {{.FieldName}}, the last element wins for equal keys.
+// Lookup keys must be lowercased with strings.ToLower as well.
+func (ll {{.Entity.List}}) IndexBy{{.FieldName}}CI() map[string]{{.Entity.Name}} {
+	r := make(map[string]{{.Entity.Name}}, len(ll))
+	for i := range ll {
+		r[strings.ToLower(ll[i].{{.FieldName}})] = ll[i]
+	}
+	return r
+}`
+
+	g.addImport("strings")
+	g.T(tmpl, data)
+}
+
+// genSparse generates Index of elements with non-zero field to Buffer. Args is a zero value of field, e.g. 0, "" or nil.
+func (g *Generator) genSparse(data TemplateData) {
+	const tmpl = `
+// SparseBy{{.FieldName}} returns elements of ll with non-zero {{.FieldName}} indexed by {{.FieldName}}, the last element wins for equal keys.
+func (ll {{.Entity.List}}) SparseBy{{.FieldName}}() map[{{.FieldType}}]{{.Entity.Name}} {
+	r := make(map[{{.FieldType}}]{{.Entity.Name}}, len(ll))
+	for i := range ll {
+		if ll[i].{{.FieldName}} != {{.Args}} {
+			r[ll[i].{{.FieldName}}] = ll[i]
+		}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genAssociate generates map of key field to value field to Buffer. Args is a type of value field.
+func (g *Generator) genAssociate(data TemplateData) {
+	const tmpl = `
+// Associate{{.FieldName}}{{.ValueName}} returns {{.ValueName}} of elements of ll indexed by {{.FieldName}}, the last element wins for equal keys.
+func (ll {{.Entity.List}}) Associate{{.FieldName}}{{.ValueName}}() map[{{.FieldType}}]{{.Args}} {
+	r := make(map[{{.FieldType}}]{{.Args}}, len(ll))
+	for i := range ll {
+		r[ll[i].{{.FieldName}}] = ll[i].{{.ValueName}}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genPivot generates map of key field to values of value field to Buffer. Args is a type of value field.
+func (g *Generator) genPivot(data TemplateData) {
+	const tmpl = `
+// Pivot{{.FieldName}}{{.ValueName}} returns {{.ValueName}} values of elements of ll grouped by {{.FieldName}} in order of elements.
+func (ll {{.Entity.List}}) Pivot{{.FieldName}}{{.ValueName}}() map[{{.FieldType}}][]{{.Args}} {
+	r := make(map[{{.FieldType}}][]{{.Args}}, len(ll))
+	for i := range ll {
+		r[ll[i].{{.FieldName}}] = append(r[ll[i].{{.FieldName}}], ll[i].{{.ValueName}})
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genPairs generates pairs of two fields in order of elements with named pair type to Buffer. Args is a type of second field.
+func (g *Generator) genPairs(data TemplateData) {
+	const tmpl = `
+// {{.Entity.Name}}{{.FieldName}}{{.ValueName}}Pair is a pair of {{.FieldName}} and {{.ValueName}} of {{.Entity.Name}}.
+type {{.Entity.Name}}{{.FieldName}}{{.ValueName}}Pair struct {
+	{{.FieldName}} {{.FieldType}}
+	{{.ValueName}} {{.Args}}
+}
+
+// {{.FieldName}}{{.ValueName}}Pairs returns pairs of {{.FieldName}} and {{.ValueName}} of elements of ll in order, e.g. for ordered options.
+func (ll {{.Entity.List}}) {{.FieldName}}{{.ValueName}}Pairs() []{{.Entity.Name}}{{.FieldName}}{{.ValueName}}Pair {
+	r := make([]{{.Entity.Name}}{{.FieldName}}{{.ValueName}}Pair, len(ll))
+	for i := range ll {
+		r[i] = {{.Entity.Name}}{{.FieldName}}{{.ValueName}}Pair{ {{- .FieldName}}: ll[i].{{.FieldName}}, {{.ValueName}}: ll[i].{{.ValueName -}} }
 	}
 	return r
 }`
@@ -446,6 +1952,352 @@ func (ll {{.Entity.List}}) Group{{.FuncName}}() map[{{.FieldType}}]{{.Entity.Lis
 	g.T(tmpl, data)
 }
 
+// genGroupPtr generates Group by pointer field to Buffer: groups are keyed by pointed-to value.
+// Elements with nil field are skipped or grouped by zero value with NilZero in Args.
+func (g *Generator) genGroupPtr(data TemplateData) {
+	const tmpl = `
+{{- if eq .Args "zeronil"}}
+// Group{{.FuncName}} returns elements of ll grouped by value of {{.FieldName}}, elements with nil {{.FieldName}} are grouped by zero value.
+{{- else}}
+// Group{{.FuncName}} returns elements of ll grouped by value of {{.FieldName}}, elements with nil {{.FieldName}} are skipped.
+{{- end}}
+func (ll {{.Entity.List}}) Group{{.FuncName}}() map[{{.FieldType}}]{{.Entity.List}} {
+	r := make(map[{{.FieldType}}]{{.Entity.List}}, len(ll))
+	for i := range ll {
+{{- if eq .Args "zeronil"}}
+		var k {{.FieldType}}
+		if ll[i].{{.FieldName}} != nil {
+			k = *ll[i].{{.FieldName}}
+		}
+		r[k] = append(r[k], ll[i])
+{{- else}}
+		if ll[i].{{.FieldName}} == nil {
+			continue
+		}
+		r[*ll[i].{{.FieldName}}] = append(r[*ll[i].{{.FieldName}}], ll[i])
+{{- end}}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genGroupInto generates Group filling existing map to Buffer.
+func (g *Generator) genGroupInto(data TemplateData) {
+	const tmpl = `
+// Group{{.FuncName}}Into appends elements of ll to groups in dst by {{.FieldName}} and returns dst, dst is allocated if nil.
+// It allows reusing dst between calls, e.g. for paginated results. Stale groups from previous fills are not removed.
+func (ll {{.Entity.List}}) Group{{.FuncName}}Into(dst map[{{.FieldType}}]{{.Entity.List}}) map[{{.FieldType}}]{{.Entity.List}} {
+	if dst == nil {
+		dst = make(map[{{.FieldType}}]{{.Entity.List}}, len(ll))
+	}
+	for i := range ll {
+		dst[ll[i].{{.FieldName}}] = append(dst[ll[i].{{.FieldName}}], ll[i])
+	}
+	return dst
+}`
+
+	g.T(tmpl, data)
+}
+
+// genIndexMultiPtr generates one-to-many Index with pointers to Buffer.
+func (g *Generator) genIndexMultiPtr(data TemplateData) {
+	const tmpl = `
+// Index{{.FuncName}} returns pointers to elements of ll grouped by {{.FieldName}}.
+// Pointers refer to the original slice and are invalidated if ll is modified (e.g. reallocated by append).
+func (ll {{.Entity.List}}) Index{{.FuncName}}() map[{{.FieldType}}][]*{{.Entity.Name}} {
+	r := make(map[{{.FieldType}}][]*{{.Entity.Name}}, len(ll))
+	for i := range ll {
+		r[ll[i].{{.FieldName}}] = append(r[ll[i].{{.FieldName}}], &ll[i])
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genFlatten generates all values of slice field of all elements to Buffer.
+func (g *Generator) genFlatten(data TemplateData) {
+	const tmpl = `
+// Flatten{{.FieldName}} returns values of {{.FieldName}} of all elements of ll in order.
+func (ll {{.Entity.List}}) Flatten{{.FieldName}}() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		r = append(r, ll[i].{{.FieldName}}...)
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genMapKeys generates keys of map field of all elements to Buffer. FieldType is a key type.
+func (g *Generator) genMapKeys(data TemplateData) {
+	const tmpl = `
+// {{.FieldName}}Keys returns keys of {{.FieldName}} of all elements of ll, order of keys of each map is not specified.
+func (ll {{.Entity.List}}) {{.FieldName}}Keys() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		for k := range ll[i].{{.FieldName}} {
+			r = append(r, k)
+		}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genMapValues generates values of map field of all elements to Buffer. FieldType is a value type.
+func (g *Generator) genMapValues(data TemplateData) {
+	const tmpl = `
+// {{.FieldName}}Values returns values of {{.FieldName}} of all elements of ll, order of values of each map is not specified.
+func (ll {{.Entity.List}}) {{.FieldName}}Values() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].{{.FieldName}} {
+			r = append(r, v)
+		}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genFlattenSelf generates all values of recursive slice field ([]T of T) of all elements at any depth to Buffer.
+// Result is used as a queue, so values are returned in breadth-first order.
+func (g *Generator) genFlattenSelf(data TemplateData) {
+	const tmpl = `
+// Flatten{{.FieldName}} returns values of {{.FieldName}} of all elements of ll recursively in breadth-first order.
+func (ll {{.Entity.List}}) Flatten{{.FieldName}}() {{.Entity.List}} {
+	r := make({{.Entity.List}}, 0, len(ll))
+	for i := range ll {
+		r = append(r, ll[i].{{.FieldName}}...)
+	}
+	for i := 0; i < len(r); i++ {
+		r = append(r, r[i].{{.FieldName}}...)
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genFlattenDeep generates all values of double-nested slice field ([][]T) of all elements to Buffer.
+func (g *Generator) genFlattenDeep(data TemplateData) {
+	const tmpl = `
+// Flatten{{.FieldName}} returns values of all inner slices of {{.FieldName}} of all elements of ll in order.
+func (ll {{.Entity.List}}) Flatten{{.FieldName}}() []{{.FieldType}} {
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].{{.FieldName}} {
+			r = append(r, v...)
+		}
+	}
+	return r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genMissing generates unique values of field that are not IDs of elements of another collection to Buffer.
+// Values of slice fields are flattened. Args is a collection type and Param is its param name.
+func (g *Generator) genMissing(data TemplateData, slice bool) {
+	const tmpl = `
+// Missing{{.FuncName}} returns unique values of {{.FieldName}} that are not IDs of {{.Param}} in order of first occurrence.
+func (ll {{.Entity.List}}) Missing{{.FuncName}}({{.Param}} {{.Args}}) []{{.FieldType}} {
+	idx := make(map[{{.FieldType}}]struct{}, len({{.Param}}))
+	for i := range {{.Param}} {
+		idx[{{.Param}}[i].ID] = struct{}{}
+	}
+	r := make([]{{.FieldType}}, 0)
+	for i := range ll {
+		if _, ok := idx[ll[i].{{.FieldName}}]; !ok {
+			idx[ll[i].{{.FieldName}}] = struct{}{}
+			r = append(r, ll[i].{{.FieldName}})
+		}
+	}
+	return r
+}`
+
+	const tmplSlice = `
+// Missing{{.FuncName}} returns unique values of {{.FieldName}} of all elements that are not IDs of {{.Param}} in order of first occurrence.
+func (ll {{.Entity.List}}) Missing{{.FuncName}}({{.Param}} {{.Args}}) []{{.FieldType}} {
+	idx := make(map[{{.FieldType}}]struct{}, len({{.Param}}))
+	for i := range {{.Param}} {
+		idx[{{.Param}}[i].ID] = struct{}{}
+	}
+	r := make([]{{.FieldType}}, 0)
+	for i := range ll {
+		for _, v := range ll[i].{{.FieldName}} {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	if slice {
+		g.T(tmplSlice, data)
+	} else {
+		g.T(tmpl, data)
+	}
+}
+
+// genAttach generates filling of field of all elements by key field from another collection to Buffer.
+// FieldType is an element type of field, IDType is a type of key, Args is a collection type and Param is its param name.
+func (g *Generator) genAttach(data TemplateData, slice bool) {
+	const tmpl = `
+// Attach{{.FieldName}} sets {{.FieldName}} of elements of ll to elements of {{.Param}} by {{.ValueName}}, elements of ll are
+// modified in place. {{.FieldName}} is set to zero value if {{.Param}} has no element with such ID.
+func (ll {{.Entity.List}}) Attach{{.FieldName}}({{.Param}} {{.Args}}) {
+	idx := make(map[{{.IDType}}]{{.FieldType}}, len({{.Param}}))
+	for i := range {{.Param}} {
+		idx[{{.Param}}[i].ID] = {{.Ref}}
+	}
+	for i := range ll {
+		ll[i].{{.FieldName}} = idx[ll[i].{{.ValueName}}]
+	}
+}`
+
+	const tmplSlice = `
+// Attach{{.FieldName}} sets {{.FieldName}} of elements of ll to elements of {{.Param}} by {{.ValueName}} in order of {{.ValueName}},
+// elements of ll are modified in place. IDs without element in {{.Param}} are skipped.
+func (ll {{.Entity.List}}) Attach{{.FieldName}}({{.Param}} {{.Args}}) {
+	idx := make(map[{{.IDType}}]{{.FieldType}}, len({{.Param}}))
+	for i := range {{.Param}} {
+		idx[{{.Param}}[i].ID] = {{.Ref}}
+	}
+	for i := range ll {
+		r := make([]{{.FieldType}}, 0, len(ll[i].{{.ValueName}}))
+		for _, id := range ll[i].{{.ValueName}} {
+			if v, ok := idx[id]; ok {
+				r = append(r, v)
+			}
+		}
+		ll[i].{{.FieldName}} = r
+	}
+}`
+
+	if slice {
+		g.T(tmplSlice, data)
+	} else {
+		g.T(tmpl, data)
+	}
+}
+
+// genUniqueSorted generates unique values of field sorted in ascending order to Buffer. Values of slice fields are
+// flattened. Args is a statement sorting r, see sortStmt.
+func (g *Generator) genUniqueSorted(data TemplateData, slice bool) {
+	const tmpl = `
+// UniqueSorted{{.FuncName}} returns unique values of {{.FieldName}} sorted in ascending order.
+func (ll {{.Entity.List}}) UniqueSorted{{.FuncName}}() []{{.FieldType}} {
+	idx := make(map[{{.FieldType}}]struct{}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[ll[i].{{.FieldName}}]; !ok {
+			idx[ll[i].{{.FieldName}}] = struct{}{}
+			r = append(r, ll[i].{{.FieldName}})
+		}
+	}
+	{{.Args}}
+	return r
+}`
+
+	const tmplSlice = `
+// UniqueSorted{{.FuncName}} returns unique values of {{.FieldName}} of all elements sorted in ascending order.
+func (ll {{.Entity.List}}) UniqueSorted{{.FuncName}}() []{{.FieldType}} {
+	idx := make(map[{{.FieldType}}]struct{}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].{{.FieldName}} {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	{{.Args}}
+	return r
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	if slice {
+		g.T(tmplSlice, data)
+		return
+	}
+
+	g.T(tmpl, data)
+}
+
+// genGroupSorted generates Group with group keys sorted in ascending order to Buffer. Args is a statement sorting r, see sortStmt.
+func (g *Generator) genGroupSorted(data TemplateData) {
+	const tmpl = `
+// GroupSortedBy{{.FieldName}} returns elements of ll grouped by {{.FieldName}} and keys of groups sorted in ascending order.
+// Iterate over keys to process groups in order of {{.FieldName}}.
+func (ll {{.Entity.List}}) GroupSortedBy{{.FieldName}}() (map[{{.FieldType}}]{{.Entity.List}}, []{{.FieldType}}) {
+	groups := make(map[{{.FieldType}}]{{.Entity.List}}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		if _, ok := groups[ll[i].{{.FieldName}}]; !ok {
+			r = append(r, ll[i].{{.FieldName}})
+		}
+		groups[ll[i].{{.FieldName}}] = append(groups[ll[i].{{.FieldName}}], ll[i])
+	}
+	{{.Args}}
+	return groups, r
+}`
+
+	g.T(tmpl, data)
+}
+
+// genDistinct generates unique values of field in order of first occurrence to Buffer.
+// Values of slice fields are flattened.
+func (g *Generator) genDistinct(data TemplateData, slice bool) {
+	const tmpl = `
+// Distinct{{.FuncName}} returns unique values of {{.FieldName}} in order of first occurrence.
+func (ll {{.Entity.List}}) Distinct{{.FuncName}}() []{{.FieldType}} {
+	idx := make(map[{{.FieldType}}]struct{}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[ll[i].{{.FieldName}}]; !ok {
+			idx[ll[i].{{.FieldName}}] = struct{}{}
+			r = append(r, ll[i].{{.FieldName}})
+		}
+	}
+	return r
+}`
+
+	const tmplSlice = `
+// Distinct{{.FuncName}} returns unique values of {{.FieldName}} of all elements in order of first occurrence.
+func (ll {{.Entity.List}}) Distinct{{.FuncName}}() []{{.FieldType}} {
+	idx := make(map[{{.FieldType}}]struct{}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].{{.FieldName}} {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}`
+
+	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	if slice {
+		g.T(tmplSlice, data)
+		return
+	}
+
+	g.T(tmpl, data)
+}
+
 // genUniqueField generates Unique Field to Buffer.
 func (g *Generator) genUniqueField(data TemplateData) {
 	const tmpl = `
@@ -464,10 +2316,237 @@ func (ll {{.Entity.List}}) Unique{{.FuncName}}() []{{.FieldType}} {
 	}
 	return r    
 }`
-	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	data.FuncName = lastRuneToLower(inflection.Plural(strings.TrimSuffix(data.FieldName, "()")))
+	g.T(tmpl, data)
+}
+
+// genUniqueBytes generates Unique Field for []byte field in order of first occurrence to Buffer.
+func (g *Generator) genUniqueBytes(data TemplateData) {
+	const tmpl = `
+// Unique{{.FuncName}} returns unique values of {{.FieldName}} compared by content in order of first occurrence,
+// nil and empty {{.FieldName}} are equal.
+func (ll {{.Entity.List}}) Unique{{.FuncName}}() []{{.FieldType}} {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[string(ll[i].{{.FieldName}})]; !ok {
+			idx[string(ll[i].{{.FieldName}})] = struct{}{}
+			r = append(r, ll[i].{{.FieldName}})
+		}
+	}
+	return r
+}`
+	data.FuncName = lastRuneToLower(inflection.Plural(strings.TrimSuffix(data.FieldName, "()")))
+	g.T(tmpl, data)
+}
+
+// genUniqueNormalized generates Unique Field with normalized string values in order of first occurrence to Buffer.
+// Values of slice fields are flattened. Modifiers are UniqueFold and UniqueTrim.
+func (g *Generator) genUniqueNormalized(data TemplateData, modifiers []string, slice bool) {
+	const tmpl = `
+// Unique{{.FuncName}} returns unique {{.Value}} in order of first occurrence, the first seen value wins.
+func (ll {{.Entity.List}}) Unique{{.FuncName}}() []{{.FieldType}} {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]{{.FieldType}}, 0, len(ll))
+	for i := range ll {
+	{{- if .Args}}
+		for _, v := range ll[i].{{.FieldName}} {
+			{{.Key}}
+			if _, ok := idx[k]; !ok {
+				idx[k] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	{{- else}}
+		v := ll[i].{{.FieldName}}
+		{{.Key}}
+		if _, ok := idx[k]; !ok {
+			idx[k] = struct{}{}
+			r = append(r, v)
+		}
+	{{- end}}
+	}
+	return r
+}`
+
+	// strings functions require string, e.g. for type Email string
+	str, trim := "v", "v = strings.TrimSpace(v)"
+	if data.FieldType != "string" {
+		str, trim = "string(v)", "v = "+data.FieldType+"(strings.TrimSpace(string(v)))"
+	}
+
+	key, desc := "k := "+str, "values of "+data.FieldName
+	if slice {
+		data.Args = "slice"
+		desc += " of all elements"
+	}
+	if slices.Contains(modifiers, UniqueTrim) {
+		key, desc = trim+"\n"+key, "trimmed "+desc
+	}
+	if slices.Contains(modifiers, UniqueFold) {
+		key, desc = strings.Replace(key, "k := "+str, "k := strings.ToLower("+str+")", 1), desc+" compared case-insensitively"
+	}
+
+	data.Key = key
+	data.Value = desc
+	data.FuncName = lastRuneToLower(inflection.Plural(strings.TrimSuffix(data.FieldName, "()")))
+
+	g.addImport("strings")
 	g.T(tmpl, data)
 }
 
+// validateUniqueModifiers checks comma separated modifiers of Unique: fold and trim, each at most once.
+func validateUniqueModifiers(mods string) error {
+	if mods == "" {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range strings.Split(mods, ",") {
+		if (m != UniqueFold && m != UniqueTrim) || seen[m] {
+			return fmt.Errorf("%w: modifier %q, expected %s or %s", ErrInvalidArg, m, UniqueFold, UniqueTrim)
+		}
+		seen[m] = true
+	}
+
+	return nil
+}
+
+// methodRules are rules accepting niladic method instead of field, e.g. Index(Slug()).
+var methodRules = []string{CustomRuleIndex, CustomRuleUnique, CustomRuleGroup}
+
+// isMethodRef checks that field of rule references method, e.g. Slug() of Index(Slug()).
+func isMethodRef(field string) bool {
+	return strings.HasSuffix(field, "()")
+}
+
+// methodField returns method of entity t as field, e.g. Slug() for `func (n News) Slug() string`. Method must have
+// no params and one result. Pointer receiver methods are allowed: generated code calls them on addressable ll[i].
+func methodField(t types.Object, ref string, pkg *types.Package) (entityField, bool, error) {
+	if t == nil {
+		return entityField{}, false, nil
+	}
+
+	name := strings.TrimSuffix(ref, "()")
+	sel := types.NewMethodSet(types.NewPointer(t.Type())).Lookup(t.Pkg(), name)
+	if sel == nil || sel.Kind() != types.MethodVal {
+		return entityField{}, false, nil
+	}
+
+	sig, ok := sel.Type().(*types.Signature)
+	if !ok || sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return entityField{}, false, fmt.Errorf("%w: method %s of %s must have no params and one result", ErrFieldType, ref, t.Name())
+	}
+
+	typ := sig.Results().At(0).Type()
+	f := entityField{
+		Name:       ref,
+		FullType:   typ.String(),
+		IsExported: sel.Obj().Exported(),
+		IsNumeric:  hasBasicInfo(typ, types.IsNumeric),
+		IsOrdered:  hasBasicInfo(typ, types.IsOrdered),
+		IsBool:     hasBasicInfo(typ, types.IsBoolean),
+		IsMap:      isMapType(typ),
+		typ:        typ,
+	}
+	f.Type, f.Imports = qualifiedType(typ, pkg)
+
+	return f, true, nil
+}
+
+// prefixRules are rules written as rule name with field suffix, e.g. WithTitle => News.WithTitle(v) News,
+// FlattenSections => FlattenSections() for [][]T or []T field.
+var prefixRules = []string{CustomRuleWithField, CustomRuleFlatten}
+
+// resolvePrefixRule resolves field rule named as prefix rule, e.g. WithTitle, to the prefix rule for field Title.
+// Field rule is kept if entity has field with such name, e.g. WithTax, or has no field for the prefix rule.
+// Prefix must be followed by uppercase rune, so FlattenedAt is a field rule.
+func resolvePrefixRule(cr CustomRule, fields map[string]entityField) CustomRule {
+	if cr.Name != "" {
+		return cr
+	}
+
+	if _, ok := fields[cr.Field]; ok {
+		return cr
+	}
+
+	for _, prefix := range prefixRules {
+		field := strings.TrimPrefix(cr.Field, prefix)
+		if _, ok := fields[field]; ok && hasRulePrefix(cr.Field, prefix) {
+			cr.Name, cr.Field = prefix, field
+			return cr
+		}
+	}
+
+	return cr
+}
+
+// hasRulePrefix checks that name is rule prefix followed by uppercase rune, e.g. ExcludeDeleted for Exclude,
+// but not a field like Excluded.
+func hasRulePrefix(name, prefix string) bool {
+	rest, ok := strings.CutPrefix(name, prefix)
+	r, _ := utf8.DecodeRuneInString(rest)
+
+	return ok && unicode.IsUpper(r)
+}
+
+// isByteSlice checks that t has []byte underlying type, e.g. json.RawMessage.
+func isByteSlice(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// checkComparable checks that field (or element of slice field) can be used as map key by rule.
+func checkComparable(f entityField, slice bool, rule string) error {
+	t := f.typ
+	if t == nil {
+		return fmt.Errorf("%w: %s must be comparable for %s", ErrFieldType, f.Name, rule)
+	}
+
+	if s, ok := t.Underlying().(*types.Slice); ok && slice {
+		t = s.Elem()
+	}
+
+	if !types.Comparable(t) {
+		return fmt.Errorf("%w: %s must be comparable for %s, use method returning comparable key instead, e.g. %s(Key())", ErrFieldType, f.Name, rule, rule)
+	}
+
+	return nil
+}
+
+// isMapType checks that t has map underlying type.
+func isMapType(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Map)
+	return ok
+}
+
+// isSelfSlice checks that field is a slice of entity t itself, e.g. SubCategories []Category of Category.
+func isSelfSlice(f entityField, t types.Object) bool {
+	return t != nil && f.FullType == "[]"+t.Type().String()
+}
+
+// isStringField checks that field (or element of slice field) has string underlying type.
+func isStringField(t types.Type, slice bool) bool {
+	if t == nil {
+		return false
+	}
+
+	if s, ok := t.Underlying().(*types.Slice); ok && slice {
+		t = s.Elem()
+	}
+
+	return hasBasicInfo(t, types.IsString)
+}
+
 // genUniqueFieldSlice generates Unique Field (slice) to Buffer.
 func (g *Generator) genUniqueFieldSlice(data TemplateData) {
 	const tmpl = `
@@ -489,10 +2568,47 @@ func (ll {{.Entity.List}}) Unique{{.FuncName}}() []{{.FieldType}} {
 	return r    
 }
 `
-	data.FuncName = lastRuneToLower(inflection.Plural(data.FieldName))
+	data.FuncName = lastRuneToLower(inflection.Plural(strings.TrimSuffix(data.FieldName, "()")))
 	g.T(tmpl, data)
 }
 
+// checkMapFunc checks that Map/MapP function used by genMap is available: it must be declared in current package
+// if funcPkgName is empty, otherwise func package must be imported by current package or set in custom imports.
+// Import of func package is added automatically.
+func (g *Generator) checkMapFunc(rule string) error {
+	if g.pkg == nil {
+		return nil
+	}
+
+	method := CustomRuleMap
+	if strings.EqualFold(rule, CustomRuleMapP) {
+		method = CustomRuleMapP
+	}
+
+	if g.funcPkgName == "" {
+		if _, ok := g.lookupType(method).(*types.Func); !ok {
+			return fmt.Errorf("%w: %s is not declared in package %s, set package with %s via -funcpkg flag (default is current package)", ErrMapFunc, method, g.pkg.Name, method)
+		}
+
+		return nil
+	}
+
+	for _, imp := range g.pkg.Imports {
+		if imp.Name == g.funcPkgName {
+			g.addImport(imp.PkgPath)
+			return nil
+		}
+	}
+
+	for _, imp := range g.imports {
+		if path.Base(imp) == g.funcPkgName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: package %s from -funcpkg is not imported by package %s, add it with -imports flag", ErrMapFunc, g.funcPkgName, g.pkg.Name)
+}
+
 // genMap generates List Converter to Buffer.
 func (g *Generator) genMap(method string, data TemplateData, isLower, hasType bool) {
 	s := "func New%s(in []%s) %s { return %s(in, New%s) }"
@@ -506,18 +2622,12 @@ func (g *Generator) genMap(method string, data TemplateData, isLower, hasType bo
 		returnType = "[]" + data.Entity.Name
 	}
 
-	// set input Type
-	inputType := data.FieldType
-	if !strings.Contains(data.FieldType, ".") {
-		inputType += "." + data.Entity.Name
-	}
-
 	if g.funcPkgName != "" {
 		method = g.funcPkgName + "." + method
 	}
 
 	g.L()
-	g.P(s, data.Entity.List, inputType, returnType, method, data.Entity.Name)
+	g.P(s, data.Entity.List, data.FieldType, returnType, method, data.Entity.Name)
 	g.L()
 }
 
@@ -551,27 +2661,170 @@ func firsRuneToLower(s string) string {
 	return string(r)
 }
 
-// loadPackage loads go pkg.
-func loadPackage(path string) (*packages.Package, error) {
-	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedImports}
-	pkgs, err := packages.Load(cfg, path)
+// LoadPackage loads go package of dir with build tags. Loaded package can be shared by generators and replacer
+// of the same dir with UsePackage, so the package is loaded once.
+func LoadPackage(ctx context.Context, dir string, tags []string) (*packages.Package, error) {
+	return loadPackage(ctx, dir, tags)
+}
+
+// loadPackage loads go pkg from dir. Packages are loaded within module (or go.work workspace) of dir, not the process CWD.
+// GOWORK and GOFLAGS are taken from the environment.
+//
+// Type errors are tolerated: package might not compile until generated code is written, e.g. NewsList.IDs() is used
+// before generation. Types of such package are partially available, so entities are checked by checkEntity before
+// generation. Parse and list errors (syntax errors, missing imports) still fail loading.
+func loadPackage(ctx context.Context, dir string, tags []string) (*packages.Package, error) {
+	cfg := &packages.Config{Context: ctx, Dir: dir, Mode: packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedImports}
+	if len(tags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(tags, ",")}
+	}
+	pkgs, err := packages.Load(cfg, ".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to load package '%s' for inspection: %w", path, err)
+		return nil, wrapWorkspaceErr(fmt.Errorf("%w '%s' for inspection: %w", ErrLoadPackage, dir, err), dir)
 	}
 
-	if packages.PrintErrors(pkgs) > 0 {
-		return nil, fmt.Errorf("package errors: %v", packages.PrintErrors(pkgs))
+	var errs []string
+	for _, e := range pkgs[0].Errors {
+		if e.Kind != packages.TypeError {
+			errs = append(errs, e.Error())
+		}
+	}
+
+	if len(errs) > 0 || pkgs[0].Types == nil {
+		packages.PrintErrors(pkgs)
+		return nil, wrapWorkspaceErr(fmt.Errorf("%w '%s': %s", ErrLoadPackage, dir, strings.Join(errs, "; ")), dir)
 	}
 
 	return pkgs[0], nil
 }
 
+// MatchBuildTags checks that build constraints of file, `//go:build` line and name suffixes like _linux.go,
+// are satisfied for current platform with additional build tags, e.g. from -tags flag.
+func MatchBuildTags(filename string, tags []string) (bool, error) {
+	bc := build.Default
+	bc.BuildTags = append(slices.Clone(bc.BuildTags), tags...)
+
+	return bc.MatchFile(filepath.Dir(filename), filepath.Base(filename))
+}
+
+// typeErrors returns type errors of loaded package tolerated by loadPackage.
+func typeErrors(pkg *packages.Package) []string {
+	if pkg == nil {
+		return nil
+	}
+
+	var errs []string
+	for _, e := range pkg.Errors {
+		if e.Kind == packages.TypeError {
+			errs = append(errs, e.Error())
+		}
+	}
+
+	return errs
+}
+
+// checkEntity checks that entity type is declared and its fields type-check, so code can be generated for entity
+// of package with unrelated type errors. Type errors of package are added to the returned error.
+func (g *Generator) checkEntity(name string) error {
+	obj := g.lookupType(name)
+	errs := typeErrors(g.pkg)
+	switch {
+	case obj == nil && len(errs) > 0:
+		return fmt.Errorf("%w: %s, package has type errors: %s", ErrMissingType, name, strings.Join(errs, "; "))
+	case obj == nil:
+		return fmt.Errorf("%w: %s", ErrMissingType, name)
+	}
+
+	// //colgen:NewsList targets collection from previous generation instead of element struct
+	if filename := g.pkg.Fset.Position(obj.Pos()).Filename; isGeneratedFile(filename) {
+		return fmt.Errorf("%w: %s in %s, directives must target element struct, e.g. //colgen:News for NewsList", ErrGeneratedType, name, filepath.Base(filename))
+	}
+
+	for _, f := range typeSliceFromType(obj, g.pkg.Types) {
+		if hasInvalidType(f.typ) {
+			return fmt.Errorf("%w: %s.%s: %s", ErrIllTyped, name, f.Name, strings.Join(errs, "; "))
+		}
+	}
+
+	if len(errs) > 0 {
+		g.logf("%s: ignoring type errors unrelated to entity: %s", name, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// isGeneratedFile checks that file is generated by colgen: it has _colgen.go suffix or colgen header.
+func isGeneratedFile(filename string) bool {
+	if strings.HasSuffix(filename, "_colgen.go") {
+		return true
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	// header follows optional build constraint
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "//go:build ") {
+			continue
+		}
+
+		return strings.HasPrefix(line, versionPrefix)
+	}
+
+	return false
+}
+
+// hasInvalidType checks that type or its element types are invalid, e.g. undefined type of struct field.
+func hasInvalidType(t types.Type) bool {
+	switch v := t.(type) {
+	case *types.Basic:
+		return v.Kind() == types.Invalid
+	case *types.Pointer:
+		return hasInvalidType(v.Elem())
+	case *types.Slice:
+		return hasInvalidType(v.Elem())
+	case *types.Array:
+		return hasInvalidType(v.Elem())
+	case *types.Chan:
+		return hasInvalidType(v.Elem())
+	case *types.Map:
+		return hasInvalidType(v.Key()) || hasInvalidType(v.Elem())
+	}
+
+	return false
+}
+
+// wrapWorkspaceErr wraps err with ErrNotInWorkspace if module of dir is not listed in go.work.
+func wrapWorkspaceErr(err error, dir string) error {
+	if !strings.Contains(err.Error(), "workspace modules") {
+		return err
+	}
+
+	return fmt.Errorf("%w: add module of %s with `go work use`: %w", ErrNotInWorkspace, dir, err)
+}
+
 type entityField struct {
 	Name       string
-	Type       string
-	FullType   string
+	Type       string   // type relative to output package, e.g. []domain.Status
+	FullType   string   // type with full package paths
+	Imports    []string // import paths of other packages used by Type
 	IsExported bool
+	IsNumeric  bool
+	IsOrdered  bool // supports < operator
+	IsBool     bool
+	IsMap      bool
 	Level      int
+	// IsAmbiguous is set for field promoted from several embedded structs at the same depth, e.g. CreatedAt
+	// of both embedded Base and Audit. Selector of such field doesn't compile.
+	IsAmbiguous bool
+
+	typ      types.Type
+	embedded bool // embedded struct, it only shadows promoted fields with the same name
 }
 
 // fillStructTypes fills sTypes with all fields.
@@ -597,6 +2850,7 @@ func fillStructTypes(typ types.Type, indentLevel int, eTypes *[]entityField) {
 
 			if field.Embedded() {
 				// fmt.Printf("%s[mbed] %s (%s)\n", indent, field.Name(), field.Type())
+				*eTypes = append(*eTypes, entityField{Name: field.Name(), Level: indentLevel, embedded: true})
 				fillStructTypes(field.Type(), indentLevel+1, eTypes)
 			} else {
 				// fmt.Printf("%s%s: %s\n", indent, field.Name(), field.Type())
@@ -605,35 +2859,274 @@ func fillStructTypes(typ types.Type, indentLevel int, eTypes *[]entityField) {
 					Type:       field.Type().String(),
 					Level:      indentLevel,
 					IsExported: field.Exported(),
+					IsNumeric:  hasBasicInfo(field.Type(), types.IsNumeric),
+					IsOrdered:  hasBasicInfo(field.Type(), types.IsOrdered),
+					IsBool:     hasBasicInfo(field.Type(), types.IsBoolean),
+					IsMap:      isMapType(field.Type()),
+					typ:        field.Type(),
 				})
 			}
 		}
 	}
 }
 
-// typeMapFromType returns field => type for given type.
-func typeMapFromType(t types.Object) map[string]string {
-	eTypes := typeSliceFromType(t)
-	sTypes := make(map[string]string)
+// promoteFields applies Go promotion rules to fields of embedded structs: field with the least depth wins and
+// fields with the same name at the least depth are ambiguous. Shadowed fields and embedded structs are removed.
+func promoteFields(ff []entityField) []entityField {
+	depth := make(map[string]int, len(ff))
+	count := make(map[string]int, len(ff))
+	for _, f := range ff {
+		d, ok := depth[f.Name]
+		switch {
+		case !ok || f.Level < d:
+			depth[f.Name], count[f.Name] = f.Level, 1
+		case f.Level == d:
+			count[f.Name]++
+		}
+	}
+
+	r := make([]entityField, 0, len(ff))
+	seen := make(map[string]struct{}, len(ff))
+	for _, f := range ff {
+		if _, ok := seen[f.Name]; ok || f.Level != depth[f.Name] {
+			continue
+		}
+		seen[f.Name] = struct{}{}
+
+		f.IsAmbiguous = count[f.Name] > 1
+		if !f.embedded {
+			r = append(r, f)
+		}
+	}
+
+	return r
+}
+
+// isNumericField checks that field of given type has numeric underlying type.
+func isNumericField(t types.Object, name string) bool {
+	f, ok := findField(t, name)
+	return ok && f.IsNumeric
+}
+
+// lessExpr returns Less expression for field of given type: < for ordered types, Before for time.Time
+// and false < true for booleans. Returns false if field can't be compared.
+func lessExpr(t types.Object, name string) (string, bool) {
+	f, ok := findField(t, name)
+	switch {
+	case !ok:
+		return "", false
+	case f.IsOrdered:
+		return fmt.Sprintf("ll[i].%[1]s < ll[j].%[1]s", name), true
+	case f.FullType == "time.Time":
+		return fmt.Sprintf("ll[i].%[1]s.Before(ll[j].%[1]s)", name), true
+	case f.IsBool:
+		return fmt.Sprintf("!ll[i].%[1]s && ll[j].%[1]s", name), true
+	}
+
+	return "", false
+}
+
+// compareExpr returns comparison expression of elements a and b by field of given type: cmp.Compare for ordered
+// types and Compare for time.Time. Elements are swapped for descending order. Returns false if field can't be compared.
+func (g *Generator) compareExpr(f entityField, name string, desc bool) (string, bool) {
+	a, b := "a", "b"
+	if desc {
+		a, b = b, a
+	}
+
+	switch {
+	case f.IsOrdered:
+		g.addImport("cmp")
+		return fmt.Sprintf("cmp.Compare(%[1]s.%[3]s, %[2]s.%[3]s)", a, b, name), true
+	case f.FullType == "time.Time":
+		return fmt.Sprintf("%[1]s.%[3]s.Compare(%[2]s.%[3]s)", a, b, name), true
+	}
+
+	return "", false
+}
+
+// compareFunc composes comparison expressions of sort keys into body of comparison function:
+// result of the first key that differs is returned, the last key breaks ties.
+func compareFunc(exprs []string) string {
+	var sb strings.Builder
+	for _, e := range exprs[:len(exprs)-1] {
+		fmt.Fprintf(&sb, "if c := %s; c != 0 {\n\t\t\treturn c\n\t\t}\n\t\t", e)
+	}
+	sb.WriteString("return " + exprs[len(exprs)-1])
+
+	return sb.String()
+}
+
+// sortStmt returns statement sorting values of type t in slice r in ascending order: slices.Sort for ordered types
+// and Before for time.Time. Element type is used for slice types if elem is set. Returns false if values can't be sorted.
+func (g *Generator) sortStmt(t types.Type, elem bool) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+
+	if s, ok := t.Underlying().(*types.Slice); ok && elem {
+		t = s.Elem()
+	}
+
+	switch {
+	case hasBasicInfo(t, types.IsOrdered):
+		g.addImport("slices")
+		return "slices.Sort(r)", true
+	case types.TypeString(t, nil) == "time.Time":
+		g.addImport("sort")
+		return "sort.Slice(r, func(i, j int) bool { return r[i].Before(r[j]) })", true
+	}
+
+	return "", false
+}
+
+// exportedFields returns exported fields of t sorted by name, ambiguous fields are skipped.
+func exportedFields(t types.Object) []entityField {
+	fields := slices.DeleteFunc(typeSliceFromType(t, nil), func(f entityField) bool { return !f.IsExported || f.IsAmbiguous })
+	slices.SortStableFunc(fields, func(a, b entityField) int { return strings.Compare(a.Name, b.Name) })
+
+	return fields
+}
+
+// cacheKeyArgs returns Sprintf arguments for CacheKey: format `"News_%d_%q"` and exported fields of t sorted by name.
+// Integers are formatted with %d, strings with %q, booleans with %t and other types with %v.
+func cacheKeyArgs(t types.Object, name, recv string) string {
+	fields := exportedFields(t)
+	format, args := name, make([]string, 1, len(fields)+1)
+	for _, f := range fields {
+		verb := "%v"
+		switch {
+		case hasBasicInfo(f.typ, types.IsInteger):
+			verb = "%d"
+		case hasBasicInfo(f.typ, types.IsString):
+			verb = "%q"
+		case f.IsBool:
+			verb = "%t"
+		}
+
+		format += "_" + verb
+		args = append(args, recv+"."+f.Name)
+	}
+
+	args[0] = strconv.Quote(format)
+	return strings.Join(args, ", ")
+}
+
+// hashWrites returns statements writing exported fields of t sorted by name to hasher h. Values are separated
+// by zero byte, so "a","bc" and "ab","c" have different hashes.
+func hashWrites(t types.Object, recv string) string {
+	fields := exportedFields(t)
+	ww := make([]string, 0, len(fields))
+	for _, f := range fields {
+		ww = append(ww, fmt.Sprintf(`_, _ = fmt.Fprintf(h, "%%v\x00", %s.%s)`, recv, f.Name))
+	}
+
+	return strings.Join(ww, "\n\t")
+}
+
+// receiverName returns receiver name for entity methods: first lowercased letter of entity name, e.g. n for News.
+func receiverName(name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r))
+}
+
+// zeroExpr returns zero value expression of field for comparison: 0 for numbers, "" for strings, nil for pointers
+// and T{} for comparable structs and arrays. Returns false if field is not comparable.
+func zeroExpr(f entityField) (string, bool) {
+	if f.typ == nil || !types.Comparable(f.typ) {
+		return "", false
+	}
+
+	switch u := f.typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return `""`, true
+		case u.Info()&types.IsNumeric != 0:
+			return "0", true
+		case u.Info()&types.IsBoolean != 0:
+			return "false", true
+		}
+	case *types.Pointer, *types.Interface, *types.Chan:
+		return "nil", true
+	case *types.Struct, *types.Array:
+		return "(" + f.Type + "{})", true
+	}
+
+	return "", false
+}
+
+// findField returns field of given type by name.
+func findField(t types.Object, name string) (entityField, bool) {
+	if t == nil {
+		return entityField{}, false
+	}
+
+	for _, f := range typeSliceFromType(t, t.Pkg()) {
+		if f.Name == name && !f.IsAmbiguous {
+			return f, true
+		}
+	}
+
+	return entityField{}, false
+}
+
+// hasBasicInfo checks that underlying type is basic type with given info, e.g. types.IsOrdered.
+func hasBasicInfo(t types.Type, info types.BasicInfo) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&info != 0
+}
+
+// isRealField checks that field of given type has integer or float underlying type, e.g. can be converted to float64.
+func isRealField(t types.Object, name string) bool {
+	f, ok := findField(t, name)
+	return ok && f.IsNumeric && f.IsOrdered
+}
+
+// typeMapFromType returns field name => field for given type, ambiguous fields are skipped. Field types are relative to pkg.
+func typeMapFromType(t types.Object, pkg *types.Package) map[string]entityField {
+	eTypes := typeSliceFromType(t, pkg)
+	sTypes := make(map[string]entityField)
 	for _, v := range eTypes {
-		sTypes[v.Name] = v.Type
+		if v.IsAmbiguous {
+			continue
+		}
+		sTypes[v.Name] = v
 	}
 
 	return sTypes
 }
 
-// typeMapFromType returns field => type for given type.
-func typeSliceFromType(t types.Object) []entityField {
+// typeSliceFromType returns all fields of given type including promoted fields of embedded structs, shadowed fields
+// are skipped and ambiguous fields are marked with IsAmbiguous. Field types are relative to pkg, e.g. domain.Status or Status for pkg types.
+func typeSliceFromType(t types.Object, pkg *types.Package) []entityField {
 	if t == nil {
 		return nil
 	}
 
 	var eTypes []entityField
 	fillStructTypes(t.Type(), 0, &eTypes)
+	eTypes = promoteFields(eTypes)
 	for i, e := range eTypes {
 		eTypes[i].FullType = e.Type
-		eTypes[i].Type = path.Base(e.Type)
+		eTypes[i].Type, eTypes[i].Imports = qualifiedType(e.typ, pkg)
 	}
 
 	return eTypes
 }
+
+// qualifiedType returns type name relative to pkg and import paths of other packages used by type,
+// e.g. map[domain.Status][]db.User with domain and db import paths.
+func qualifiedType(t types.Type, pkg *types.Package) (string, []string) {
+	var imports []string
+	s := types.TypeString(t, func(p *types.Package) string {
+		if p == pkg {
+			return ""
+		}
+
+		imports = append(imports, p.Path())
+		return p.Name()
+	})
+
+	return s, imports
+}
@@ -1,8 +1,15 @@
 package colgen
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/types"
 	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -55,6 +62,24 @@ func TestParseRules(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "IndexExact is Index",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:IndexExact(OrderNumber)",
+				},
+			},
+			want: []Rule{
+				{
+					EntityName: "Tag",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Index", Field: "OrderNumber"},
+					},
+				},
+			},
+		},
 		{
 			name: "simple",
 			args: args{
@@ -72,6 +97,330 @@ func TestParseRules(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "optional",
+			args: args{
+				lines: []string{
+					"News",
+					"News:Index(Slug)?,UniqueTagIDs?",
+				},
+			},
+			want: []Rule{
+				{
+					EntityName: "News",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Index", Field: "Slug", Optional: true},
+						{Name: "Unique", Field: "TagIDs", Optional: true},
+					},
+				},
+			},
+		},
+		{
+			name: "optional map",
+			args: args{
+				lines: []string{
+					"News",
+					"News:MapP(db)?",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Exclude with suffix",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:ExcludeDeleted(0, 999),Excluded",
+				},
+			},
+			want: []Rule{
+				{
+					EntityName: "Tag",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Exclude", Field: "ID", Arg: "0, 999", Suffix: "Deleted"},
+						{Field: "Excluded"},
+					},
+				},
+			},
+		},
+		{
+			name: "SQLIn with unknown style",
+			args: args{
+				lines: []string{
+					"News",
+					"News:SQLIn(oracle)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "SQLIn without style",
+			args: args{
+				lines: []string{
+					"News",
+					"News:SQLIn",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "TakeWhile with value",
+			args: args{
+				lines: []string{
+					"News",
+					"News:TakeWhile(Published,true),DropWhile(Published)",
+				},
+			},
+			want: []Rule{
+				{
+					EntityName: "News",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "TakeWhile", Field: "Published", Arg: "true"},
+						{Name: "DropWhile", Field: "Published"},
+					},
+				},
+			},
+		},
+		{
+			name: "Unique unknown modifier",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:Unique(Name,upper)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unique duplicate modifier",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:Unique(Name,fold,fold)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Index and Group with nil mode",
+			args: args{lines: []string{"Category", "Category:Index(ParentID,zeronil),Group(ParentID,skipnil)"}},
+			want: []Rule{
+				{
+					EntityName: "Category",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Index", Field: "ParentID", Arg: "zeronil"},
+						{Name: "Group", Field: "ParentID", Arg: "skipnil"},
+					},
+				},
+			},
+		},
+		{
+			name:    "Index with unknown nil mode",
+			args:    args{lines: []string{"Category", "Category:Index(ParentID,nilzero)"}},
+			wantErr: true,
+		},
+		{
+			name: "Associate without value",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:Associate(Name)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Associate with extra field",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:Associate(Name,ID,OrderNumber)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "FlattenSelf without field",
+			args: args{
+				lines: []string{
+					"Category",
+					"Category:FlattenSelf",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Keys without field",
+			args: args{
+				lines: []string{
+					"Item",
+					"Item:Keys",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Pairs without second field",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:Pairs(ID)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Pivot without value field",
+			args: args{
+				lines: []string{
+					"Item",
+					"Item:Pivot(Status)",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Sort with descending key",
+			args: args{
+				lines: []string{
+					"Item",
+					"Item:Sort(-CreatedAt,ID)",
+				},
+			},
+			want: []Rule{
+				{
+					EntityName: "Item",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Sort", Field: "CreatedAt", Arg: "-CreatedAt,ID"},
+					},
+				},
+			},
+		},
+		{
+			name:    "Sort with repeated key",
+			args:    args{lines: []string{"Item", "Item:Sort(ID,-ID)"}},
+			wantErr: true,
+		},
+		{
+			name:    "Sort without keys",
+			args:    args{lines: []string{"Item", "Item:Sort"}},
+			wantErr: true,
+		},
+		{
+			name: "Missing with field and entity",
+			args: args{lines: []string{"News", "News:Missing(CategoryID,Category)"}},
+			want: []Rule{
+				{
+					EntityName: "News",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Missing", Field: "CategoryID", Arg: "Category"},
+					},
+				},
+			},
+		},
+		{
+			name:    "Missing without entity",
+			args:    args{lines: []string{"News", "News:Missing(CategoryID)"}},
+			wantErr: true,
+		},
+		{
+			name:    "Missing with qualified entity",
+			args:    args{lines: []string{"News", "News:Missing(CategoryID,db.Category)"}},
+			wantErr: true,
+		},
+		{
+			name: "Attach with target, key and collection",
+			args: args{lines: []string{"Post", "Post:Attach(Tags,TagIDs,Tags)"}},
+			want: []Rule{
+				{
+					EntityName: "Post",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Attach", Field: "Tags", Arg: "TagIDs,Tags"},
+					},
+				},
+			},
+		},
+		{
+			name:    "Attach without collection",
+			args:    args{lines: []string{"Post", "Post:Attach(Tags,TagIDs)"}},
+			wantErr: true,
+		},
+		{
+			name: "Field and FieldOr with selector path",
+			args: args{lines: []string{"Post", `Post:Field(Author.Company.Name),FieldOr(Author.Company.Name, "unknown")`}},
+			want: []Rule{
+				{
+					EntityName: "Post",
+					BaseGen:    true,
+					CustomRules: []CustomRule{
+						{Name: "Field", Field: "Author.Company.Name"},
+						{Name: "FieldOr", Field: "Author.Company.Name", Arg: `"unknown"`},
+					},
+				},
+			},
+		},
+		{
+			name:    "Field without selector path",
+			args:    args{lines: []string{"Post", "Post:Field(Author)"}},
+			wantErr: true,
+		},
+		{
+			name:    "FieldOr without default",
+			args:    args{lines: []string{"Post", "Post:FieldOr(Author.Company.Name)"}},
+			wantErr: true,
+		},
+		{
+			name:    "FieldOr with empty field",
+			args:    args{lines: []string{"Post", `Post:FieldOr(Author..Name,"unknown")`}},
+			wantErr: true,
+		},
+		{
+			name: "IndexExact without field",
+			args: args{
+				lines: []string{
+					"Tag",
+					"Tag:IndexExact",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "entities with spaces",
+			args: args{
+				lines: []string{"News, Tag"},
+			},
+			want: []Rule{
+				{EntityName: "News", BaseGen: true},
+				{EntityName: "Tag", BaseGen: true},
+			},
+		},
+		{
+			name:    "empty entity",
+			args:    args{lines: []string{"News, ,Tag"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid entity",
+			args:    args{lines: []string{"News,Tag!"}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate entity",
+			args:    args{lines: []string{"News,Tag,News"}},
+			wantErr: true,
+		},
+		{
+			name:    "custom rule without colon",
+			args:    args{lines: []string{"NewsIndex(Title)"}},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -80,6 +429,9 @@ func TestParseRules(t *testing.T) {
 				t.Errorf("ParseRules() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseRules() \n\tgot  = %v, \n\twant = %v", got, tt.want)
 			}
@@ -87,8 +439,53 @@ func TestParseRules(t *testing.T) {
 	}
 }
 
+func TestParseEntities(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "News, ,Tag", want: `invalid entity name: " ": "News, ,Tag"`},
+		{line: "News,db.Tag,db.Tag", want: `invalid entity name: duplicate "db.Tag": "News,db.Tag,db.Tag"`},
+		{line: "News,Tag(ID)", want: `invalid entity name: "Tag(ID)": "News,Tag(ID)"`},
+		{line: "News,db.", want: `invalid entity name: "db.": "News,db."`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := ParseRules([]string{tt.line}, false)
+			if !errors.Is(err, ErrInvalidEntity) || err.Error() != tt.want {
+				t.Errorf("ParseRules() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseRules([]string{"News,db.Tag"}, false); err != nil {
+		t.Errorf("ParseRules() error = %v for qualified entity", err)
+	}
+}
+
+func TestSplitRules(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "IDs", want: []string{"IDs"}},
+		{in: "Index(ID),Group(Name)", want: []string{"Index(ID)", "Group(Name)"}},
+		{in: "Exclude(1,2),UniqueIDs", want: []string{"Exclude(1,2)", "UniqueIDs"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := splitRules(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitRules() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGenerator_Generate(t *testing.T) {
 	const want = `// Code generated by colgen devel; DO NOT EDIT.
+// colgen:sha256:39983a13cbbae116107a47bdedc874de8da3ada7a84436f90a4614bfb4b219b8
 package newsportal
 
 import (
@@ -229,3 +626,2581 @@ Tag:Index(OrderNumber),OrderNumber,UniqueOrderNumber,Group(Name)
 		os.Stdout.Write(dataF)
 	}
 }
+
+func TestGenerator_CustomRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{
+			name:  "IndexMultiPtr",
+			lines: []string{"News", "News:IndexMultiPtr(CategoryID)"},
+			want: `
+// IndexByCategoryID returns pointers to elements of ll grouped by CategoryID.
+// Pointers refer to the original slice and are invalidated if ll is modified (e.g. reallocated by append).
+func (ll NewsList) IndexByCategoryID() map[int][]*News {
+	r := make(map[int][]*News, len(ll))
+	for i := range ll {
+		r[ll[i].CategoryID] = append(r[ll[i].CategoryID], &ll[i])
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "IDsAppend",
+			lines: []string{"News", "News:IDsAppend"},
+			want: `
+// AppendIDs appends ID of all elements to dst and returns the extended slice.
+// It allows reusing dst between calls, e.g. via sync.Pool.
+func (ll NewsList) AppendIDs(dst []int) []int {
+	for i := range ll {
+		dst = append(dst, ll[i].ID)
+	}
+	return dst
+}
+`,
+		},
+		{
+			name:  "Append",
+			lines: []string{"Tag", "Tag:Append(Name)"},
+			want: `
+// AppendNames appends Name of all elements to dst and returns the extended slice.
+// It allows reusing dst between calls, e.g. via sync.Pool.
+func (ll Tags) AppendNames(dst []string) []string {
+	for i := range ll {
+		dst = append(dst, ll[i].Name)
+	}
+	return dst
+}
+`,
+		},
+		{
+			name:  "ByField",
+			lines: []string{"Tag", "Tag:ByField(Name),Index(Name)"},
+			want: `
+func (ll Tags) ByName() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[ll[i].Name] = ll[i]
+	}
+	return r
+}
+
+func (ll Tags) IndexByName() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[ll[i].Name] = ll[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Len",
+			lines: []string{"Tag", "Tag:Len"},
+			want: `
+// Len returns number of elements in collection, e.g. for sort.Interface.
+func (ll Tags) Len() int {
+	return len(ll)
+}
+
+// IsEmpty returns true if collection has no elements.
+func (ll Tags) IsEmpty() bool {
+	return len(ll) == 0
+}
+`,
+		},
+		{
+			name:  "Delta int64",
+			lines: []string{"Tag", "Tag:Delta(OrderNumber)"},
+			want: `
+// DeltaOrderNumber returns difference of OrderNumber between ll and prev (current - previous) by ID.
+// Elements present only in ll or only in prev have their full OrderNumber, the last element wins for duplicate IDs.
+func (ll Tags) DeltaOrderNumber(prev Tags) map[int]int64 {
+	r := make(map[int]int64, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i].OrderNumber
+	}
+	old := make(map[int]int64, len(prev))
+	for i := range prev {
+		old[prev[i].ID] = prev[i].OrderNumber
+	}
+	for id, v := range old {
+		if cur, ok := r[id]; ok {
+			r[id] = cur - v
+		} else {
+			r[id] = v
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Delta float64",
+			lines: []string{"Item", "Item:Delta(Price)"},
+			want: `
+// DeltaPrice returns difference of Price between ll and prev (current - previous) by ID.
+// Elements present only in ll or only in prev have their full Price, the last element wins for duplicate IDs.
+func (ll Items) DeltaPrice(prev Items) map[int]float64 {
+	r := make(map[int]float64, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i].Price
+	}
+	old := make(map[int]float64, len(prev))
+	for i := range prev {
+		old[prev[i].ID] = prev[i].Price
+	}
+	for id, v := range old {
+		if cur, ok := r[id]; ok {
+			r[id] = cur - v
+		} else {
+			r[id] = v
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Apply",
+			lines: []string{"News", "News:Apply(fmt.Println),Apply(enrich)"},
+			want: `
+// Apply calls fn for each element of ll by pointer and returns ll. Default functions: fmt.Println, enrich.
+func (ll NewsList) Apply(fn func(*News)) NewsList {
+	for i := range ll {
+		fn(&ll[i])
+	}
+	return ll
+}
+
+// Println applies fmt.Println to each element of ll and returns ll.
+func (ll NewsList) Println() NewsList {
+	return ll.Apply(fmt.Println)
+}
+
+// enrich applies enrich to each element of ll and returns ll.
+func (ll NewsList) enrich() NewsList {
+	return ll.Apply(enrich)
+}
+`,
+		},
+		{
+			name:  "Apply imports",
+			lines: []string{"News", "News:Apply(fmt.Println)"},
+			want: `
+import (
+	"fmt"
+)
+`,
+		},
+		{
+			name:  "Sortable",
+			lines: []string{"Tag", "Tag:Sortable(Name)"},
+			want: `
+// Len returns number of elements in collection, e.g. for sort.Interface.
+func (ll Tags) Len() int {
+	return len(ll)
+}
+
+// IsEmpty returns true if collection has no elements.
+func (ll Tags) IsEmpty() bool {
+	return len(ll) == 0
+}
+
+// Swap swaps elements with indexes i and j, implements sort.Interface.
+func (ll Tags) Swap(i, j int) {
+	ll[i], ll[j] = ll[j], ll[i]
+}
+
+// Less reports whether element i must sort before element j by Name, implements sort.Interface.
+func (ll Tags) Less(i, j int) bool {
+	return ll[i].Name < ll[j].Name
+}
+`,
+		},
+		{
+			name:  "Synced",
+			lines: []string{"Tag", "Tag:Synced"},
+			want: `
+// SyncedTags is Tags guarded by sync.RWMutex, its methods are safe for concurrent use.
+// Tags itself is not safe for concurrent use. Zero value is an empty collection, it must not be copied.
+type SyncedTags struct {
+	mu sync.RWMutex
+	ll Tags
+}
+
+// NewSyncedTags returns SyncedTags with a copy of ll.
+func NewSyncedTags(ll Tags) *SyncedTags {
+	r := make(Tags, len(ll))
+	copy(r, ll)
+	return &SyncedTags{ll: r}
+}
+
+// Add appends elements to collection.
+func (s *SyncedTags) Add(vv ...Tag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ll = append(s.ll, vv...)
+}
+
+// All returns a copy of collection, it can be used without lock.
+func (s *SyncedTags) All() Tags {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r := make(Tags, len(s.ll))
+	copy(r, s.ll)
+	return r
+}
+
+// Get returns the first element with given ID and true if it exists.
+func (s *SyncedTags) Get(id int) (Tag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.ll {
+		if s.ll[i].ID == id {
+			return s.ll[i], true
+		}
+	}
+	var zero Tag
+	return zero, false
+}
+
+// Len returns number of elements in collection.
+func (s *SyncedTags) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.ll)
+}
+`,
+		},
+		{
+			name:  "Synced without ID",
+			lines: []string{"Stock", "Stock:Synced"},
+			want: `
+	return r
+}
+
+// Len returns number of elements in collection.
+func (s *SyncedStocks) Len() int {
+`,
+		},
+		{
+			name:  "Missing",
+			lines: []string{"News", "News:Missing(CategoryID,Category)"},
+			want: `
+// MissingCategoryIDs returns unique values of CategoryID that are not IDs of categories in order of first occurrence.
+func (ll NewsList) MissingCategoryIDs(categories Categories) []int {
+	idx := make(map[int]struct{}, len(categories))
+	for i := range categories {
+		idx[categories[i].ID] = struct{}{}
+	}
+	r := make([]int, 0)
+	for i := range ll {
+		if _, ok := idx[ll[i].CategoryID]; !ok {
+			idx[ll[i].CategoryID] = struct{}{}
+			r = append(r, ll[i].CategoryID)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Missing slice",
+			lines: []string{"Item", "Item:Missing(Tags,Label)"},
+			want: `
+// MissingTags returns unique values of Tags of all elements that are not IDs of labels in order of first occurrence.
+func (ll Items) MissingTags(labels Labels) []string {
+	idx := make(map[string]struct{}, len(labels))
+	for i := range labels {
+		idx[labels[i].ID] = struct{}{}
+	}
+	r := make([]string, 0)
+	for i := range ll {
+		for _, v := range ll[i].Tags {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Attach",
+			lines: []string{"Post", "Post:Attach(Tags,TagIDs,Tags)"},
+			want: `
+// AttachTags sets Tags of elements of ll to elements of tags by TagIDs in order of TagIDs,
+// elements of ll are modified in place. IDs without element in tags are skipped.
+func (ll Posts) AttachTags(tags Tags) {
+	idx := make(map[int]Tag, len(tags))
+	for i := range tags {
+		idx[tags[i].ID] = tags[i]
+	}
+	for i := range ll {
+		r := make([]Tag, 0, len(ll[i].TagIDs))
+		for _, id := range ll[i].TagIDs {
+			if v, ok := idx[id]; ok {
+				r = append(r, v)
+			}
+		}
+		ll[i].Tags = r
+	}
+}
+`,
+		},
+		{
+			name:  "Attach pointers",
+			lines: []string{"Post", "Post:Attach(Labels,LabelIDs,Labels)"},
+			want: `
+func (ll Posts) AttachLabels(labels Labels) {
+	idx := make(map[string]*Label, len(labels))
+	for i := range labels {
+		idx[labels[i].ID] = &labels[i]
+	}
+	for i := range ll {
+		r := make([]*Label, 0, len(ll[i].LabelIDs))
+`,
+		},
+		{
+			name:  "Attach pointer",
+			lines: []string{"Post", "Post:Attach(Category,CategoryID,Categories)"},
+			want: `
+// AttachCategory sets Category of elements of ll to elements of categories by CategoryID, elements of ll are
+// modified in place. Category is set to zero value if categories has no element with such ID.
+func (ll Posts) AttachCategory(categories Categories) {
+	idx := make(map[int]*Category, len(categories))
+	for i := range categories {
+		idx[categories[i].ID] = &categories[i]
+	}
+	for i := range ll {
+		ll[i].Category = idx[ll[i].CategoryID]
+	}
+}
+`,
+		},
+		{
+			name:  "Field",
+			lines: []string{"Post", "Post:Field(Author.Company.Name)"},
+			want: `
+// AuthorCompanyNames returns Author.Company.Name of elements, elements with nil Author or Author.Company are skipped.
+func (ll Posts) AuthorCompanyNames() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		if ll[i].Author == nil || ll[i].Author.Company == nil {
+			continue
+		}
+		r = append(r, ll[i].Author.Company.Name)
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Field of pointer",
+			lines: []string{"Post", "Post:Field(Author.Company.Founded)"},
+			want: `
+func (ll Posts) AuthorCompanyFoundeds() []*time.Time {`,
+		},
+		{
+			name:  "Field with optional missing hop",
+			lines: []string{"Post", "Post:Field(Author.Title)?,Field(Category.ID)"},
+			want: `
+// CategoryIDs returns Category.ID of elements, elements with nil Category are skipped.`,
+		},
+		{
+			name:  "FieldOr",
+			lines: []string{"Post", `Post:FieldOr(Author.Company.Name,"unknown"),FieldOr(Author.Company.Rating,-1),FieldOr(Author.Profile.Bio,"")`},
+			want: `
+// AuthorCompanyNamesOr returns Author.Company.Name of elements, "unknown" for elements with nil Author or Author.Company.
+func (ll Posts) AuthorCompanyNamesOr() []string {
+	r := make([]string, len(ll))
+	for i := range ll {
+		if ll[i].Author == nil || ll[i].Author.Company == nil {
+			r[i] = "unknown"
+			continue
+		}
+		r[i] = ll[i].Author.Company.Name
+	}
+	return r
+}
+
+// AuthorCompanyRatingsOr returns Author.Company.Rating of elements, -1 for elements with nil Author or Author.Company.
+func (ll Posts) AuthorCompanyRatingsOr() []float64 {
+	r := make([]float64, len(ll))
+	for i := range ll {
+		if ll[i].Author == nil || ll[i].Author.Company == nil {
+			r[i] = -1
+			continue
+		}
+		r[i] = ll[i].Author.Company.Rating
+	}
+	return r
+}
+
+// AuthorProfileBiosOr returns Author.Profile.Bio of elements, "" for elements with nil Author.
+`,
+		},
+		{
+			name:  "FieldOr of named type and pointer",
+			lines: []string{"Post", `Post:FieldOr(Author.Company.Status,"active"),FieldOr(Author.Company.Founded,nil)`},
+			want: `
+		if ll[i].Author == nil || ll[i].Author.Company == nil {
+			r[i] = "active"
+			continue
+		}
+		r[i] = ll[i].Author.Company.Status`,
+		},
+		{
+			name:  "Merge",
+			lines: []string{"Label", "Label:Merge"},
+			want: `
+// Merge returns a new collection with elements of ll in order of ll, where elements of other replace elements of ll
+// with the same ID in place, and elements of other with new IDs are appended in order of other. Only the first element
+// of ll with repeated ID is replaced, the last element of other with repeated ID wins. ll and other are not modified.
+func (ll Labels) Merge(other Labels) Labels {
+	idx := make(map[string]int, len(ll)+len(other))
+	r := make(Labels, 0, len(ll)+len(other))
+	for i := range ll {
+		if _, ok := idx[ll[i].ID]; !ok {
+			idx[ll[i].ID] = len(r)
+		}
+		r = append(r, ll[i])
+	}
+	for i := range other {
+		if j, ok := idx[other[i].ID]; ok {
+			r[j] = other[i]
+			continue
+		}
+		idx[other[i].ID] = len(r)
+		r = append(r, other[i])
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Synced imports",
+			lines: []string{"Tag", "Tag:Synced"},
+			want: `
+import (
+	"sync"
+)
+`,
+		},
+		{
+			name:  "Sort",
+			lines: []string{"Item", "Item:Sort(CreatedAt,ID)"},
+			want: `
+// SortByCreatedAtID returns a copy of ll stably sorted by CreatedAt, then by ID, ll is not modified.
+func (ll Items) SortByCreatedAtID() Items {
+	r := make(Items, len(ll))
+	copy(r, ll)
+	slices.SortStableFunc(r, func(a, b Item) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return r
+}
+`,
+		},
+		{
+			name:  "Sort mixed order",
+			lines: []string{"Item", "Item:Sort(-Price,Status,-CreatedAt)"},
+			want: `
+// SortByPriceDescStatusCreatedAtDesc returns a copy of ll stably sorted by Price in descending order, then by Status, then by CreatedAt in descending order, ll is not modified.
+func (ll Items) SortByPriceDescStatusCreatedAtDesc() Items {
+	r := make(Items, len(ll))
+	copy(r, ll)
+	slices.SortStableFunc(r, func(a, b Item) int {
+		if c := cmp.Compare(b.Price, a.Price); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Status, b.Status); c != 0 {
+			return c
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return r
+}
+`,
+		},
+		{
+			name:  "Sort single key",
+			lines: []string{"Tag", "Tag:Sort(-Name)"},
+			want: `
+	slices.SortStableFunc(r, func(a, b Tag) int {
+		return cmp.Compare(b.Name, a.Name)
+	})
+`,
+		},
+		{
+			name:  "Sort imports",
+			lines: []string{"Tag", "Tag:Sort(Name)"},
+			want: `
+import (
+	"cmp"
+	"slices"
+)
+`,
+		},
+		{
+			name:  "Sortable time",
+			lines: []string{"Item", "Item:Sortable(CreatedAt)"},
+			want: `
+	return ll[i].CreatedAt.Before(ll[j].CreatedAt)
+`,
+		},
+		{
+			name:  "Sortable bool",
+			lines: []string{"Item", "Item:Sortable(Active)"},
+			want: `
+	return !ll[i].Active && ll[j].Active
+`,
+		},
+		{
+			name:  "Sortable with Len",
+			lines: []string{"Tag", "Tag:Len,Sortable(Name),Len"},
+			want: `
+func (ll Tags) IsEmpty() bool {
+	return len(ll) == 0
+}
+
+// Swap swaps elements with indexes i and j, implements sort.Interface.
+`,
+		},
+		{
+			name:  "First Last",
+			lines: []string{"Tag", "Tag:First,Last"},
+			want: `
+// First returns the first element of ll or ErrEmptyCollection if ll is empty.
+func (ll Tags) First() (Tag, error) {
+	if len(ll) == 0 {
+		return Tag{}, ErrEmptyCollection
+	}
+	return ll[0], nil
+}
+
+// Last returns the last element of ll or ErrEmptyCollection if ll is empty.
+func (ll Tags) Last() (Tag, error) {
+	if len(ll) == 0 {
+		return Tag{}, ErrEmptyCollection
+	}
+	return ll[len(ll)-1], nil
+}
+`,
+		},
+		{
+			name:  "IndexCaseInsensitive",
+			lines: []string{"Tag", "Tag:IndexCaseInsensitive(Name)"},
+			want: `
+import (
+	"strings"
+)
+`,
+		},
+		{
+			name:  "IndexCaseInsensitive method",
+			lines: []string{"Tag", "Tag:IndexCaseInsensitive(Name)"},
+			want: `
+// IndexByNameCI returns elements of ll indexed by lowercased Name, the last element wins for equal keys.
+// Lookup keys must be lowercased with strings.ToLower as well.
+func (ll Tags) IndexByNameCI() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[strings.ToLower(ll[i].Name)] = ll[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "JSON",
+			lines: []string{"Tag", "Tag:JSON"},
+			want: `
+// MarshalBinary implements encoding.BinaryMarshaler interface using JSON, e.g. for caching in Redis.
+func (ll Tags) MarshalBinary() ([]byte, error) {
+	return json.Marshal([]Tag(ll))
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler interface using JSON.
+func (ll *Tags) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, (*[]Tag)(ll))
+}
+`,
+		},
+		{
+			name:  "JSON imports",
+			lines: []string{"Tag", "Tag:JSON"},
+			want: `
+import (
+	"encoding/json"
+)
+`,
+		},
+		{
+			name:  "Head Tail",
+			lines: []string{"Tag", "Tag:Head,Tail"},
+			want: `
+// Head returns the first n elements of ll or all elements if n >= len(ll). Result shares the backing array with ll.
+func (ll Tags) Head(n int) Tags {
+	if n <= 0 || len(ll) == 0 {
+		return Tags{}
+	}
+	return ll[:min(n, len(ll))]
+}
+
+// Tail returns the last n elements of ll or all elements if n >= len(ll). Result shares the backing array with ll.
+func (ll Tags) Tail(n int) Tags {
+	if n <= 0 || len(ll) == 0 {
+		return Tags{}
+	}
+	return ll[len(ll)-min(n, len(ll)):]
+}
+`,
+		},
+		{
+			name:  "Rotate",
+			lines: []string{"Tag", "Tag:Rotate"},
+			want: `
+// Rotate returns a new collection with elements of ll rotated left by n positions, negative n rotates right.
+func (ll Tags) Rotate(n int) Tags {
+	r := make(Tags, len(ll))
+	if len(ll) == 0 {
+		return r
+	}
+
+	n %= len(ll)
+	if n < 0 {
+		n += len(ll)
+	}
+	copy(r, ll[n:])
+	copy(r[len(ll)-n:], ll[:n])
+	return r
+}
+`,
+		},
+		{
+			name:  "SQLIn pg",
+			lines: []string{"Tag", "Tag:SQLIn(pg)"},
+			want: `
+// IDPlaceholders returns placeholders $start,$start+1,... and args by ID for WHERE ID IN (...) clause.
+// Empty string and no args are returned for empty collection, IN clause must be skipped by caller.
+func (ll Tags) IDPlaceholders(start int) (string, []any) {
+	args := make([]any, len(ll))
+	var sb strings.Builder
+	for i := range ll {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString("$" + strconv.Itoa(start+i))
+		args[i] = ll[i].ID
+	}
+	return sb.String(), args
+}
+`,
+		},
+		{
+			name:  "SQLIn pg imports",
+			lines: []string{"Tag", "Tag:SQLIn(pg)"},
+			want: `
+import (
+	"strconv"
+	"strings"
+)
+`,
+		},
+		{
+			name:  "SQLIn mysql",
+			lines: []string{"Tag", "Tag:SQLIn(mysql)"},
+			want: `
+// IDPlaceholders returns placeholders ?,?,... (start is ignored) and args by ID for WHERE ID IN (...) clause.
+// Empty string and no args are returned for empty collection, IN clause must be skipped by caller.
+func (ll Tags) IDPlaceholders(start int) (string, []any) {
+	args := make([]any, len(ll))
+	var sb strings.Builder
+	for i := range ll {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('?')
+		args[i] = ll[i].ID
+	}
+	return sb.String(), args
+}
+`,
+		},
+		{
+			name:  "Avg",
+			lines: []string{"Item", "Item:Avg(Price)"},
+			want: `
+// AvgPrice returns arithmetic mean of Price or 0 if ll is empty.
+func (ll Items) AvgPrice() float64 {
+	if len(ll) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := range ll {
+		sum += float64(ll[i].Price)
+	}
+	return sum / float64(len(ll))
+}
+`,
+		},
+		{
+			name:  "StdDev",
+			lines: []string{"Stock", "Stock:StdDev(Quantity)"},
+			want: `
+import (
+	"math"
+)
+`,
+		},
+		{
+			name:  "StdDev method",
+			lines: []string{"Item", "Item:StdDev(Price)"},
+			want: `
+// StdDevPrice returns population standard deviation of Price or 0 if ll has less than two elements.
+func (ll Items) StdDevPrice() float64 {
+	if len(ll) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for i := range ll {
+		mean += float64(ll[i].Price)
+	}
+	mean /= float64(len(ll))
+
+	var variance float64
+	for i := range ll {
+		d := float64(ll[i].Price) - mean
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(ll)))
+}
+`,
+		},
+		{
+			name:  "Accumulate",
+			lines: []string{"Stock", "Stock:Accumulate(Quantity)"},
+			want: `
+// AccumulatedQuantities returns running totals of Quantity: r[i] is a sum of Quantity of elements 0..i.
+func (ll Stocks) AccumulatedQuantities() []int {
+	r := make([]int, len(ll))
+	var sum int
+	for i := range ll {
+		sum += ll[i].Quantity
+		r[i] = sum
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Group by local named type",
+			lines: []string{"Item", "Item:Group(Status)"},
+			want: `
+func (ll Items) GroupByStatus() map[ItemStatus]Items {
+	r := make(map[ItemStatus]Items, len(ll))`,
+		},
+		{
+			name:  "Index by type from another package",
+			lines: []string{"Item", "Item:Index(CreatedAt)"},
+			want: `
+import (
+	"time"
+)
+`,
+		},
+		{
+			name:  "Partition",
+			lines: []string{"Item", "Item:Partition(Active)"},
+			want: `
+// PartitionByActive splits ll into elements with Active set and the rest, preserving order.
+func (ll Items) PartitionByActive() (matched, rest Items) {
+	matched, rest = Items{}, Items{}
+	for i := range ll {
+		if ll[i].Active {
+			matched = append(matched, ll[i])
+		} else {
+			rest = append(rest, ll[i])
+		}
+	}
+	return matched, rest
+}
+`,
+		},
+		{
+			name:  "Distinct",
+			lines: []string{"Tag", "Tag:Distinct(Name)"},
+			want: `
+// DistinctNames returns unique values of Name in order of first occurrence.
+func (ll Tags) DistinctNames() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[ll[i].Name]; !ok {
+			idx[ll[i].Name] = struct{}{}
+			r = append(r, ll[i].Name)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Distinct slice",
+			lines: []string{"Item", "Item:Distinct(Tags)"},
+			want: `
+// DistinctTags returns unique values of Tags of all elements in order of first occurrence.
+func (ll Items) DistinctTags() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Tags {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Sparse",
+			lines: []string{"News", "News:Sparse(CategoryID)"},
+			want: `
+// SparseByCategoryID returns elements of ll with non-zero CategoryID indexed by CategoryID, the last element wins for equal keys.
+func (ll NewsList) SparseByCategoryID() map[int]News {
+	r := make(map[int]News, len(ll))
+	for i := range ll {
+		if ll[i].CategoryID != 0 {
+			r[ll[i].CategoryID] = ll[i]
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Sparse string",
+			lines: []string{"Item", "Item:Sparse(Status)"},
+			want: `
+func (ll Items) SparseByStatus() map[ItemStatus]Item {
+	r := make(map[ItemStatus]Item, len(ll))
+	for i := range ll {
+		if ll[i].Status != "" {
+			r[ll[i].Status] = ll[i]
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Sparse struct",
+			lines: []string{"Item", "Item:Sparse(CreatedAt)"},
+			want: `
+		if ll[i].CreatedAt != (time.Time{}) {
+			r[ll[i].CreatedAt] = ll[i]
+		}
+`,
+		},
+		{
+			name:  "UniqueSorted",
+			lines: []string{"Tag", "Tag:UniqueSorted(Name)"},
+			want: `
+// UniqueSortedNames returns unique values of Name sorted in ascending order.
+func (ll Tags) UniqueSortedNames() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[ll[i].Name]; !ok {
+			idx[ll[i].Name] = struct{}{}
+			r = append(r, ll[i].Name)
+		}
+	}
+	slices.Sort(r)
+	return r
+}
+`,
+		},
+		{
+			name:  "UniqueSorted slice",
+			lines: []string{"Item", "Item:UniqueSorted(Tags)"},
+			want: `
+// UniqueSortedTags returns unique values of Tags of all elements sorted in ascending order.
+func (ll Items) UniqueSortedTags() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Tags {
+			if _, ok := idx[v]; !ok {
+				idx[v] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	slices.Sort(r)
+	return r
+}
+`,
+		},
+		{
+			name:  "GroupSorted",
+			lines: []string{"News", "News:GroupSorted(CategoryID)"},
+			want: `
+// GroupSortedByCategoryID returns elements of ll grouped by CategoryID and keys of groups sorted in ascending order.
+// Iterate over keys to process groups in order of CategoryID.
+func (ll NewsList) GroupSortedByCategoryID() (map[int]NewsList, []int) {
+	groups := make(map[int]NewsList, len(ll))
+	r := make([]int, 0, len(ll))
+	for i := range ll {
+		if _, ok := groups[ll[i].CategoryID]; !ok {
+			r = append(r, ll[i].CategoryID)
+		}
+		groups[ll[i].CategoryID] = append(groups[ll[i].CategoryID], ll[i])
+	}
+	slices.Sort(r)
+	return groups, r
+}
+`,
+		},
+		{
+			name:  "GroupSorted time",
+			lines: []string{"Item", "Item:GroupSorted(CreatedAt)"},
+			want: `
+func (ll Items) GroupSortedByCreatedAt() (map[time.Time]Items, []time.Time) {
+	groups := make(map[time.Time]Items, len(ll))
+	r := make([]time.Time, 0, len(ll))
+	for i := range ll {
+		if _, ok := groups[ll[i].CreatedAt]; !ok {
+			r = append(r, ll[i].CreatedAt)
+		}
+		groups[ll[i].CreatedAt] = append(groups[ll[i].CreatedAt], ll[i])
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Before(r[j]) })
+	return groups, r
+}
+`,
+		},
+		{
+			name:  "WithField",
+			lines: []string{"Tag", "Tag:WithName"},
+			want: `
+// WithName returns a copy of Tag with Name set to v, t is not modified.
+func (t Tag) WithName(v string) Tag {
+	t.Name = v
+	return t
+}
+`,
+		},
+		{
+			name:  "Prefix rules field rule",
+			lines: []string{"Stock", "Stock:WithTax,WithQuantity,FlattenedAt"},
+			want: `
+func (ll Stocks) WithTaxes() []bool {
+	r := make([]bool, len(ll))
+	for i := range ll {
+		r[i] = ll[i].WithTax
+	}
+	return r
+}
+
+// WithQuantity returns a copy of Stock with Quantity set to v, s is not modified.
+func (s Stock) WithQuantity(v int) Stock {
+	s.Quantity = v
+	return s
+}
+
+func (ll Stocks) FlattenedAts() []time.Time {
+	r := make([]time.Time, len(ll))
+	for i := range ll {
+		r[i] = ll[i].FlattenedAt
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Method",
+			lines: []string{"Tag", "Tag:Index(Slug()),Unique(Slug()),Group(Level())"},
+			want: `
+func (ll Tags) IndexBySlug() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[ll[i].Slug()] = ll[i]
+	}
+	return r
+}
+
+func (ll Tags) UniqueSlugs() []string {
+	idx := make(map[string]struct{}, len(ll))
+	for i := range ll {
+		if _, ok := idx[ll[i].Slug()]; !ok {
+			idx[ll[i].Slug()] = struct{}{}
+		}
+	}
+
+	r, i := make([]string, len(idx)), 0
+	for k := range idx {
+		r[i] = k
+		i++
+	}
+	return r
+}
+
+func (ll Tags) GroupByLevel() map[int64]Tags {
+	r := make(map[int64]Tags, len(ll))
+	for i := range ll {
+		r[ll[i].Level()] = append(r[ll[i].Level()], ll[i])
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Byte slice",
+			lines: []string{"Stock", "Stock:Index(Checksum),Unique(Checksum)"},
+			want: `
+// IndexByChecksum returns elements of ll indexed by string(Checksum), nil and empty Checksum have the same key.
+func (ll Stocks) IndexByChecksum() map[string]Stock {
+	r := make(map[string]Stock, len(ll))
+	for i := range ll {
+		r[string(ll[i].Checksum)] = ll[i]
+	}
+	return r
+}
+
+// UniqueChecksums returns unique values of Checksum compared by content in order of first occurrence,
+// nil and empty Checksum are equal.
+func (ll Stocks) UniqueChecksums() [][]byte {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([][]byte, 0, len(ll))
+	for i := range ll {
+		if _, ok := idx[string(ll[i].Checksum)]; !ok {
+			idx[string(ll[i].Checksum)] = struct{}{}
+			r = append(r, ll[i].Checksum)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "CacheKey",
+			lines: []string{"Tag", "Tag:CacheKey"},
+			want: `
+// CacheKey returns deterministic key of Tag built from all exported fields sorted by name.
+func (t Tag) CacheKey() string {
+	return fmt.Sprintf("Tag_%d_%q_%d", t.ID, t.Name, t.OrderNumber)
+}
+`,
+		},
+		{
+			name:  "CacheKey with other types",
+			lines: []string{"Item", "Item:CacheKey"},
+			want: `
+func (i Item) CacheKey() string {
+	return fmt.Sprintf("Item_%t_%v_%d_%v_%q_%v", i.Active, i.CreatedAt, i.ID, i.Price, i.Status, i.Tags)
+}
+`,
+		},
+		{
+			name:  "Hash",
+			lines: []string{"Tag", "Tag:Hash"},
+			want: `
+// Hash returns 64-bit FNV-1a hash of all exported fields of Tag sorted by name, e.g. for fast equality check in caches.
+// Fields are hashed by %v representation, so equal hashes don't guarantee equal values.
+func (t Tag) Hash() uint64 {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%v\x00", t.ID)
+	_, _ = fmt.Fprintf(h, "%v\x00", t.Name)
+	_, _ = fmt.Fprintf(h, "%v\x00", t.OrderNumber)
+	return h.Sum64()
+}
+`,
+		},
+		{
+			name:  "Paginate",
+			lines: []string{"Tag", "Tag:Paginate"},
+			want: `
+// Paginate returns elements of ll on page with 1-based number and given size and pagination metadata.
+// Page < 1 is treated as the first page, empty collection is returned for pages out of range and size <= 0.
+// Result shares the backing array with ll.
+func (ll Tags) Paginate(page, size int) (Tags, PaginationMeta) {
+	page = max(page, 1)
+	meta := PaginationMeta{Page: page, Size: size, TotalItems: len(ll)}
+	if size <= 0 {
+		return Tags{}, meta
+	}
+
+	meta.TotalPages = (len(ll) + size - 1) / size
+	if page > meta.TotalPages {
+		return Tags{}, meta
+	}
+
+	start := (page - 1) * size
+	return ll[start:min(start+size, len(ll))], meta
+}
+`,
+		},
+		{
+			name:  "IndexInto",
+			lines: []string{"Tag", "Tag:IndexInto"},
+			want: `
+// IndexInto fills dst with elements of ll indexed by ID and returns dst, dst is allocated if nil.
+// It allows reusing dst between calls, e.g. for paginated results. Stale keys from previous fills are not removed.
+func (ll Tags) IndexInto(dst map[int]Tag) map[int]Tag {
+	if dst == nil {
+		dst = make(map[int]Tag, len(ll))
+	}
+	for i := range ll {
+		dst[ll[i].ID] = ll[i]
+	}
+	return dst
+}
+`,
+		},
+		{
+			name:  "IndexInto by field",
+			lines: []string{"Tag", "Tag:IndexInto(Name)"},
+			want: `
+func (ll Tags) IndexByNameInto(dst map[string]Tag) map[string]Tag {`,
+		},
+		{
+			name:  "GroupInto",
+			lines: []string{"News", "News:GroupInto(CategoryID)"},
+			want: `
+// GroupByCategoryIDInto appends elements of ll to groups in dst by CategoryID and returns dst, dst is allocated if nil.
+// It allows reusing dst between calls, e.g. for paginated results. Stale groups from previous fills are not removed.
+func (ll NewsList) GroupByCategoryIDInto(dst map[int]NewsList) map[int]NewsList {
+	if dst == nil {
+		dst = make(map[int]NewsList, len(ll))
+	}
+	for i := range ll {
+		dst[ll[i].CategoryID] = append(dst[ll[i].CategoryID], ll[i])
+	}
+	return dst
+}
+`,
+		},
+		{
+			name:  "Shuffle",
+			lines: []string{"Tag", "Tag:Shuffle"},
+			want: `
+// Shuffle returns a new collection with elements of ll in random order, ll is not modified.
+func (ll Tags) Shuffle() Tags {
+	return ll.ShuffleWith(nil)
+}
+
+// ShuffleWith returns a new collection with elements of ll in random order of rnd, ll is not modified.
+// Global source of math/rand/v2 is used if rnd is nil, seeded rnd gives reproducible order, e.g. in tests.
+func (ll Tags) ShuffleWith(rnd *rand.Rand) Tags {
+	intN := rand.IntN
+	if rnd != nil {
+		intN = rnd.IntN
+	}
+
+	r := make(Tags, len(ll))
+	copy(r, ll)
+	for i := len(r) - 1; i > 0; i-- {
+		j := intN(i + 1)
+		r[i], r[j] = r[j], r[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "TakeWhile",
+			lines: []string{"Item", "Item:TakeWhile(Active)"},
+			want: `
+// TakeWhileActive returns the longest prefix of ll with Active equal to v. Result shares the backing array with ll.
+func (ll Items) TakeWhileActive(v bool) Items {
+	i := 0
+	for i < len(ll) && ll[i].Active == v {
+		i++
+	}
+	if i == 0 {
+		return Items{}
+	}
+	return ll[:i]
+}
+`,
+		},
+		{
+			name:  "DropWhile",
+			lines: []string{"Item", "Item:DropWhile(Status)"},
+			want: `
+// DropWhileStatus returns elements of ll after the longest prefix with Status equal to v. Result shares the backing array with ll.
+func (ll Items) DropWhileStatus(v ItemStatus) Items {
+	i := 0
+	for i < len(ll) && ll[i].Status == v {
+		i++
+	}
+	if i == len(ll) {
+		return Items{}
+	}
+	return ll[i:]
+}
+`,
+		},
+		{
+			name:  "Flatten",
+			lines: []string{"Item", "Item:FlattenTags"},
+			want: `
+// FlattenTags returns values of Tags of all elements of ll in order.
+func (ll Items) FlattenTags() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		r = append(r, ll[i].Tags...)
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Flatten deep",
+			lines: []string{"News", "News:FlattenSections"},
+			want: `
+// FlattenSections returns values of all inner slices of Sections of all elements of ll in order.
+func (ll NewsList) FlattenSections() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Sections {
+			r = append(r, v...)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Unique fold trim",
+			lines: []string{"Tag", "Tag:Unique(Name,fold,trim)"},
+			want: `
+// UniqueNames returns unique trimmed values of Name compared case-insensitively in order of first occurrence, the first seen value wins.
+func (ll Tags) UniqueNames() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		v := ll[i].Name
+		v = strings.TrimSpace(v)
+		k := strings.ToLower(v)
+		if _, ok := idx[k]; !ok {
+			idx[k] = struct{}{}
+			r = append(r, v)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Unique fold slice",
+			lines: []string{"Item", "Item:Unique(Tags,fold)"},
+			want: `
+// UniqueTags returns unique values of Tags of all elements compared case-insensitively in order of first occurrence, the first seen value wins.
+func (ll Items) UniqueTags() []string {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Tags {
+			k := strings.ToLower(v)
+			if _, ok := idx[k]; !ok {
+				idx[k] = struct{}{}
+				r = append(r, v)
+			}
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Unique trim named",
+			lines: []string{"Item", "Item:Unique(Status,trim)"},
+			want: `
+// UniqueStatuses returns unique trimmed values of Status in order of first occurrence, the first seen value wins.
+func (ll Items) UniqueStatuses() []ItemStatus {
+	idx := make(map[string]struct{}, len(ll))
+	r := make([]ItemStatus, 0, len(ll))
+	for i := range ll {
+		v := ll[i].Status
+		v = ItemStatus(strings.TrimSpace(string(v)))
+		k := string(v)
+		if _, ok := idx[k]; !ok {
+			idx[k] = struct{}{}
+			r = append(r, v)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Associate",
+			lines: []string{"Tag", "Tag:Associate(Name,OrderNumber)"},
+			want: `
+// AssociateNameOrderNumber returns OrderNumber of elements of ll indexed by Name, the last element wins for equal keys.
+func (ll Tags) AssociateNameOrderNumber() map[string]int64 {
+	r := make(map[string]int64, len(ll))
+	for i := range ll {
+		r[ll[i].Name] = ll[i].OrderNumber
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "promoted embedded field",
+			lines: []string{"Account", "Account:Index(UpdatedBy)"},
+			want: `
+func (ll Accounts) IndexByUpdatedBy() map[string]Account {`,
+		},
+		{
+			name:  "Flatten self",
+			lines: []string{"Category", "Category:FlattenSubCategories"},
+			want: `
+// FlattenSubCategories returns values of SubCategories of all elements of ll recursively in breadth-first order.
+func (ll Categories) FlattenSubCategories() Categories {
+	r := make(Categories, 0, len(ll))
+	for i := range ll {
+		r = append(r, ll[i].SubCategories...)
+	}
+	for i := 0; i < len(r); i++ {
+		r = append(r, r[i].SubCategories...)
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Index by pointer",
+			lines: []string{"Category", "Category:Index(ParentID)"},
+			want: `
+// IndexByParentID returns elements of ll indexed by value of ParentID, elements with nil ParentID are skipped.
+func (ll Categories) IndexByParentID() map[int]Category {
+	r := make(map[int]Category, len(ll))
+	for i := range ll {
+		if ll[i].ParentID == nil {
+			continue
+		}
+		r[*ll[i].ParentID] = ll[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "ByField by pointer",
+			lines: []string{"Category", "Category:ByField(ParentID)"},
+			want: `
+// ByParentID returns elements of ll indexed by value of ParentID, elements with nil ParentID are skipped.
+func (ll Categories) ByParentID() map[int]Category {
+	r := make(map[int]Category, len(ll))
+	for i := range ll {
+		if ll[i].ParentID == nil {
+			continue
+		}
+		r[*ll[i].ParentID] = ll[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Index by pointer with zero key",
+			lines: []string{"Category", "Category:Index(ParentID,zeronil)"},
+			want: `
+// IndexByParentID returns elements of ll indexed by value of ParentID, elements with nil ParentID are indexed by zero value.
+func (ll Categories) IndexByParentID() map[int]Category {
+	r := make(map[int]Category, len(ll))
+	for i := range ll {
+		var k int
+		if ll[i].ParentID != nil {
+			k = *ll[i].ParentID
+		}
+		r[k] = ll[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Group by pointer",
+			lines: []string{"Category", "Category:Group(ParentID,skipnil)"},
+			want: `
+// GroupByParentID returns elements of ll grouped by value of ParentID, elements with nil ParentID are skipped.
+func (ll Categories) GroupByParentID() map[int]Categories {
+	r := make(map[int]Categories, len(ll))
+	for i := range ll {
+		if ll[i].ParentID == nil {
+			continue
+		}
+		r[*ll[i].ParentID] = append(r[*ll[i].ParentID], ll[i])
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Group by pointer with zero key",
+			lines: []string{"Category", "Category:Group(ParentID,zeronil)"},
+			want: `
+// GroupByParentID returns elements of ll grouped by value of ParentID, elements with nil ParentID are grouped by zero value.
+func (ll Categories) GroupByParentID() map[int]Categories {
+	r := make(map[int]Categories, len(ll))
+	for i := range ll {
+		var k int
+		if ll[i].ParentID != nil {
+			k = *ll[i].ParentID
+		}
+		r[k] = append(r[k], ll[i])
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "FlattenSelf",
+			lines: []string{"Category", "Category:FlattenSelf(SubCategories)"},
+			want: `
+// FlattenSubCategories returns values of SubCategories of all elements of ll recursively in breadth-first order.
+func (ll Categories) FlattenSubCategories() Categories {
+	r := make(Categories, 0, len(ll))
+	for i := range ll {
+		r = append(r, ll[i].SubCategories...)
+	}
+	for i := 0; i < len(r); i++ {
+		r = append(r, r[i].SubCategories...)
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Keys",
+			lines: []string{"Stock", "Stock:Keys(Labels),Values(Labels)"},
+			want: `
+// LabelsKeys returns keys of Labels of all elements of ll, order of keys of each map is not specified.
+func (ll Stocks) LabelsKeys() []string {
+	r := make([]string, 0, len(ll))
+	for i := range ll {
+		for k := range ll[i].Labels {
+			r = append(r, k)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Values",
+			lines: []string{"Stock", "Stock:Keys(Labels),Values(Labels)"},
+			want: `
+// LabelsValues returns values of Labels of all elements of ll, order of values of each map is not specified.
+func (ll Stocks) LabelsValues() []ItemStatus {
+	r := make([]ItemStatus, 0, len(ll))
+	for i := range ll {
+		for _, v := range ll[i].Labels {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Pairs",
+			lines: []string{"Tag", "Tag:Pairs(ID,Name)"},
+			want: `
+// TagIDNamePair is a pair of ID and Name of Tag.
+type TagIDNamePair struct {
+	ID   int
+	Name string
+}
+
+// IDNamePairs returns pairs of ID and Name of elements of ll in order, e.g. for ordered options.
+func (ll Tags) IDNamePairs() []TagIDNamePair {
+	r := make([]TagIDNamePair, len(ll))
+	for i := range ll {
+		r[i] = TagIDNamePair{ID: ll[i].ID, Name: ll[i].Name}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Pivot",
+			lines: []string{"Item", "Item:Pivot(Status,Price)"},
+			want: `
+// PivotStatusPrice returns Price values of elements of ll grouped by Status in order of elements.
+func (ll Items) PivotStatusPrice() map[ItemStatus][]float64 {
+	r := make(map[ItemStatus][]float64, len(ll))
+	for i := range ll {
+		r[ll[i].Status] = append(r[ll[i].Status], ll[i].Price)
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "IndexExact",
+			lines: []string{"Tag", "Tag:IndexExact(Name)"},
+			want: `
+func (ll Tags) IndexByName() map[string]Tag {
+	r := make(map[string]Tag, len(ll))
+	for i := range ll {
+		r[ll[i].Name] = ll[i]
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Exclude imports",
+			lines: []string{"Tag", "Tag:Exclude(0, 999),Index(Name)"},
+			want: `
+import (
+	"slices"
+)
+`,
+		},
+		{
+			name:  "Exclude strings",
+			lines: []string{"Label", `Label:ExcludeDrafts(draft, "deleted")`},
+			want: `
+// ExcludeDrafts returns elements of ll except ones with ID in ("draft", "deleted").
+func (ll Labels) ExcludeDrafts() Labels {
+	excluded := []string{"draft", "deleted"}
+	r := make(Labels, 0, len(ll))
+	for i := range ll {
+		if !slices.Contains(excluded, ll[i].ID) {
+			r = append(r, ll[i])
+		}
+	}
+	return r
+}
+`,
+		},
+		{
+			name:  "Exclude",
+			lines: []string{"Tag", "Tag:Exclude(0, 999),Index(Name)"},
+			want: `
+// Exclude returns elements of ll except ones with ID in (0, 999).
+func (ll Tags) Exclude() Tags {
+	excluded := []int{0, 999}
+	r := make(Tags, 0, len(ll))
+	for i := range ll {
+		if !slices.Contains(excluded, ll[i].ID) {
+			r = append(r, ll[i])
+		}
+	}
+	return r
+}
+`,
+		},
+	}
+
+	// load package once for all cases
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("colgen", "", "", "devel")
+			g.pkg = pkg
+			rules, err := ParseRules(tt.lines, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = g.Generate(rules); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := g.Format()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(got), tt.want) {
+				t.Errorf("Generate() result does not contain:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGenerator_SQLScan(t *testing.T) {
+	const want = `
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type Categories []Category
+
+func (ll Categories) IDs() []int {
+	r := make([]int, len(ll))
+	for i := range ll {
+		r[i] = ll[i].ID
+	}
+	return r
+}
+
+func (ll Categories) Index() map[int]Category {
+	r := make(map[int]Category, len(ll))
+	for i := range ll {
+		r[ll[i].ID] = ll[i]
+	}
+	return r
+}
+
+// Scan implements sql.Scanner interface for JSON columns.
+func (ll *Categories) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*ll = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type %T for Categories", src)
+	}
+	return json.Unmarshal(data, ll)
+}
+
+// Value implements driver.Valuer interface for JSON columns. Nil collection is stored as NULL.
+func (ll Categories) Value() (driver.Value, error) {
+	if ll == nil {
+		return nil, nil
+	}
+	return json.Marshal(ll)
+}
+`
+
+	g := NewGenerator("colgen", "", "", "devel")
+	g.SetSQLScan(true)
+	rules, err := ParseRules([]string{"Category"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = g.UsePackageDir("."); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = g.Generate(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := g.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(string(got), want) {
+		t.Errorf("Generate() different result:\ngot  = %s\nwant = %s", got, want)
+	}
+}
+
+func TestGenerator_GenerateTo(t *testing.T) {
+	g := NewGenerator("colgen", "", "", "devel")
+	if _, err := g.Format(); !errors.Is(err, ErrNotGenerated) {
+		t.Fatalf("Format() before Generate: got err = %v, want %v", err, ErrNotGenerated)
+	}
+
+	rules, err := ParseRules([]string{"Category"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = g.UsePackageDir("."); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = g.GenerateTo(rules, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Format is idempotent and matches streamed output
+	for range 2 {
+		got, err := g.Format()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, buf.Bytes()) {
+			t.Errorf("Format() = %s, want %s", got, buf.Bytes())
+		}
+	}
+
+	// Format and next Generate do not change returned source
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Clone(data)
+	if _, err = g.Format(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = g.Generate(rules); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("Generate() result was changed:\ngot  = %s\nwant = %s", data, want)
+	}
+}
+
+func TestMatchBuildTags(t *testing.T) {
+	tests := []struct {
+		filename string
+		tags     []string
+		want     bool
+	}{
+		{filename: "models.go", want: true},
+		{filename: "models_integration.go", want: false},
+		{filename: "models_integration.go", tags: []string{"integration"}, want: true},
+		{filename: "models_plan9.go", tags: []string{"integration"}, want: runtime.GOOS == "plan9"},
+	}
+
+	for _, tt := range tests {
+		got, err := MatchBuildTags(filepath.Join("testdata", "buildtags", tt.filename), tt.tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("MatchBuildTags(%s, %v) = %v, want %v", tt.filename, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestGenerator_SetBuildTags(t *testing.T) {
+	rules, err := ParseRules([]string{"Fixture"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator("buildtags", "", "", "devel")
+	if err = g.UsePackageDir("testdata/buildtags"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = g.Generate(rules); !errors.Is(err, ErrMissingType) {
+		t.Errorf("Generate() without tags: got err = %v, want %v", err, ErrMissingType)
+	}
+
+	g.SetBuildTags([]string{"integration"})
+	if err = g.UsePackageDir("testdata/buildtags"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("type Fixtures []Fixture")) {
+		t.Errorf("Generate() with tags = %s, want Fixtures collection", data)
+	}
+}
+
+func TestGenerator_OptionalRules(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(lines ...string) (string, []string, error) {
+		var logs []string
+		g := NewGenerator("colgen", "", "", "devel")
+		g.pkg = pkg
+		g.SetVerbose(func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) })
+
+		rules, err := ParseRules(lines, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := g.Generate(rules)
+		return string(data), logs, err
+	}
+
+	t.Run("skip missing field", func(t *testing.T) {
+		got, logs, err := generate("Tag", "Tag:Index(Slug)?,Index(Name)?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(got, "IndexBySlug") || !strings.Contains(got, "IndexByName") {
+			t.Errorf("Generate() unexpected result:\n%s", got)
+		}
+		if len(logs) != 1 || !strings.Contains(logs[0], "Slug") {
+			t.Errorf("Generate() logs = %v, want skipped Slug", logs)
+		}
+	})
+
+	t.Run("error on missing field", func(t *testing.T) {
+		_, _, err := generate("Tag", "Tag:Index(Slug)")
+		if !errors.Is(err, ErrMissingField) {
+			t.Errorf("Generate() error = %v, want %v", err, ErrMissingField)
+		}
+	})
+}
+
+func TestGenerator_RuleErrors(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  error
+	}{
+		{name: "non-numeric field", lines: []string{"Tag", "Tag:Delta(Name)"}, want: ErrFieldType},
+		{name: "missing field", lines: []string{"Tag", "Tag:Delta(Quantity)"}, want: ErrMissingField},
+		{name: "missing ID", lines: []string{"Stock", "Stock:Delta(Quantity)"}, want: ErrMissingField},
+		{name: "index and multi pointer index", lines: []string{"News", "News:Index(CategoryID),IndexMultiPtr(CategoryID)"}, want: ErrDuplicateRule},
+		{name: "duplicate index", lines: []string{"Tag", "Tag:Index(Name),IndexExact(Name)"}, want: ErrDuplicateRule},
+		{name: "duplicate exclude", lines: []string{"Tag", "Tag:Exclude(0),Exclude(1)"}, want: ErrDuplicateRule},
+		{name: "invalid exclude value", lines: []string{"Tag", "Tag:Exclude(1 2)"}, want: ErrInvalidArg},
+		{name: "unsigned delta", lines: []string{"News", "News:Delta(Views)"}, want: ErrFieldType},
+		{name: "unordered sortable", lines: []string{"Item", "Item:Sortable(Tags)"}, want: ErrFieldType},
+		{name: "non-string case-insensitive index", lines: []string{"Tag", "Tag:IndexCaseInsensitive(ID)"}, want: ErrFieldType},
+		{name: "SQLIn without ID", lines: []string{"Stock", "Stock:SQLIn(pg)"}, want: ErrMissingField},
+		{name: "non-numeric avg", lines: []string{"Tag", "Tag:Avg(Name)"}, want: ErrFieldType},
+		{name: "non-numeric std dev", lines: []string{"Item", "Item:StdDev(CreatedAt)"}, want: ErrFieldType},
+		{name: "non-numeric accumulate", lines: []string{"Tag", "Tag:Accumulate(Name)"}, want: ErrFieldType},
+		{name: "non-bool partition", lines: []string{"Item", "Item:Partition(Price)"}, want: ErrFieldType},
+		{name: "unordered unique sorted", lines: []string{"Item", "Item:UniqueSorted(Active)"}, want: ErrFieldType},
+		{name: "unexported field in exported method", lines: []string{"Stock", "Stock:Group(reserved)"}, want: ErrUnexported},
+		{name: "IndexInto without ID", lines: []string{"Stock", "Stock:IndexInto"}, want: ErrMissingField},
+		{name: "non-comparable take while", lines: []string{"Item", "Item:TakeWhile(Tags)"}, want: ErrFieldType},
+		{name: "non-slice flatten", lines: []string{"Tag", "Tag:FlattenName"}, want: ErrFieldType},
+		{name: "unique modifiers non-string", lines: []string{"Tag", "Tag:Unique(ID,fold)"}, want: ErrFieldType},
+		{name: "associate missing value", lines: []string{"Tag", "Tag:Associate(Name,Title)"}, want: ErrMissingField},
+		{name: "non-comparable associate", lines: []string{"Item", "Item:Associate(Tags,ID)"}, want: ErrFieldType},
+		{name: "ambiguous embedded field", lines: []string{"Account", "Account:Index(CreatedAt)"}, want: ErrMissingField},
+		{name: "non-recursive flatten self", lines: []string{"News", "News:FlattenSelf(Sections)"}, want: ErrFieldType},
+		{name: "non-map keys", lines: []string{"Tag", "Tag:Keys(Name)"}, want: ErrFieldType},
+		{name: "non-map values", lines: []string{"Item", "Item:Values(Tags)"}, want: ErrFieldType},
+		{name: "pairs missing field", lines: []string{"Tag", "Tag:Pairs(ID,Title)"}, want: ErrMissingField},
+		{name: "non-comparable pivot", lines: []string{"Item", "Item:Pivot(Tags,Price)"}, want: ErrFieldType},
+		{name: "with missing field", lines: []string{"Tag", "Tag:WithTitle"}, want: ErrMissingField},
+		{name: "missing method", lines: []string{"Tag", "Tag:Index(Title())"}, want: ErrMissingField},
+		{name: "method with params", lines: []string{"Tag", "Tag:Index(Rename())"}, want: ErrFieldType},
+		{name: "method is not supported", lines: []string{"Tag", "Tag:Sparse(Slug())"}, want: ErrInvalidArg},
+		{name: "non-comparable index", lines: []string{"Item", "Item:Index(Tags)"}, want: ErrFieldType},
+		{name: "non-comparable by field", lines: []string{"Item", "Item:ByField(Tags)"}, want: ErrFieldType},
+		{name: "by field pointer to non-comparable", lines: []string{"Post", "Post:ByField(Category)"}, want: ErrFieldType},
+		{name: "non-comparable group", lines: []string{"Item", "Item:Group(Tags)"}, want: ErrFieldType},
+		{name: "non-comparable index into", lines: []string{"Item", "Item:IndexInto(Tags)"}, want: ErrFieldType},
+		{name: "non-comparable group into", lines: []string{"Item", "Item:GroupInto(Tags)"}, want: ErrFieldType},
+		{name: "bytes group into", lines: []string{"Stock", "Stock:GroupInto(Checksum)"}, want: ErrFieldType},
+		{name: "pointer index into", lines: []string{"Category", "Category:IndexInto(ParentID)"}, want: ErrFieldType},
+		{name: "pointer group into", lines: []string{"Category", "Category:GroupInto(ParentID)"}, want: ErrFieldType},
+		{name: "method named as field", lines: []string{"Glossary", "Glossary:Len"}, want: ErrFieldMethod},
+		{name: "nil mode of non-pointer", lines: []string{"Tag", "Tag:Group(Name,zeronil)"}, want: ErrFieldType},
+		{name: "pointer to non-comparable", lines: []string{"Post", "Post:Index(Category)"}, want: ErrFieldType},
+		{name: "non-comparable unique", lines: []string{"Stock", "Stock:Unique(Labels)"}, want: ErrFieldType},
+		{name: "non-comparable sparse", lines: []string{"Item", "Item:Sparse(Tags)"}, want: ErrFieldType},
+		{name: "unordered sort key", lines: []string{"Item", "Item:Sort(ID,Tags)"}, want: ErrFieldType},
+		{name: "missing sort key", lines: []string{"Item", "Item:Sort(ID,Title)"}, want: ErrMissingField},
+		{name: "duplicate sort", lines: []string{"Item", "Item:Sort(-Price,ID),Sort(-Price,ID)"}, want: ErrDuplicateRule},
+		{name: "missing entity of missing", lines: []string{"News", "News:Missing(CategoryID,Section)"}, want: ErrMissingType},
+		{name: "missing ID of missing", lines: []string{"News", "News:Missing(CategoryID,Stock)"}, want: ErrMissingField},
+		{name: "missing field of missing", lines: []string{"News", "News:Missing(TagIDs,Tag)"}, want: ErrMissingField},
+		{name: "missing with other ID type", lines: []string{"News", "News:Missing(CategoryID,Label)"}, want: ErrFieldType},
+		{name: "attach missing key", lines: []string{"Post", "Post:Attach(Tags,TagID,Tags)"}, want: ErrMissingField},
+		{name: "attach missing target", lines: []string{"Post", "Post:Attach(Sections,TagIDs,Tags)"}, want: ErrMissingField},
+		{name: "attach non-entity target", lines: []string{"Post", "Post:Attach(TagIDs,TagIDs,Tags)"}, want: ErrFieldType},
+		{name: "attach other collection", lines: []string{"Post", "Post:Attach(Tags,TagIDs,Labels)"}, want: ErrInvalidArg},
+		{name: "attach scalar key of slice", lines: []string{"Post", "Post:Attach(Tags,CategoryID,Tags)"}, want: ErrFieldType},
+		{name: "attach other key type", lines: []string{"Post", "Post:Attach(Labels,TagIDs,Labels)"}, want: ErrFieldType},
+		{name: "attach without ID", lines: []string{"Stock", "Stock:Attach(Quantity,Quantity,Stocks)"}, want: ErrFieldType},
+		{name: "merge without ID", lines: []string{"Stock", "Stock:Merge"}, want: ErrMissingField},
+		{name: "field missing head", lines: []string{"Post", "Post:Field(Editor.Name)"}, want: ErrMissingField},
+		{name: "field missing hop", lines: []string{"Post", "Post:Field(Author.Company.Title)"}, want: ErrMissingField},
+		{name: "field of non-struct", lines: []string{"Post", "Post:Field(Author.Name.Length)"}, want: ErrMissingField},
+		{name: "field of unexported", lines: []string{"Post", "Post:Field(Author.Company.revenue)"}, want: ErrUnexported},
+		{name: "field or without pointers", lines: []string{"Author", `Author:FieldOr(Profile.Bio,"")`}, want: ErrInvalidArg},
+		{name: "field or unquoted string", lines: []string{"Post", "Post:FieldOr(Author.Company.Name,unknown)"}, want: ErrInvalidArg},
+		{name: "field or number for string", lines: []string{"Post", "Post:FieldOr(Author.Company.Name,1)"}, want: ErrFieldType},
+		{name: "field or string for number", lines: []string{"Post", `Post:FieldOr(Author.Company.Rating,"1")`}, want: ErrFieldType},
+		{name: "field or float for int", lines: []string{"Post", "Post:FieldOr(Author.Company.Employees,1.5)"}, want: ErrFieldType},
+		{name: "field or nil for string", lines: []string{"Post", "Post:FieldOr(Author.Company.Name,nil)"}, want: ErrFieldType},
+		{name: "duplicate sortable", lines: []string{"Item", "Item:Sortable(Price),Sortable(ID)"}, want: ErrDuplicateRule},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("colgen", "", "", "devel")
+			g.pkg = pkg
+
+			rules, err := ParseRules(tt.lines, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = g.Generate(rules); !errors.Is(err, tt.want) {
+				t.Errorf("Generate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEntityName(t *testing.T) {
+	tests := []struct {
+		name    string
+		useList bool
+		want    string
+	}{
+		{name: "Tag", want: "Tag => Tags (plural)"},
+		{name: "Category", want: "Category => Categories (plural)"},
+		{name: "Tag", useList: true, want: "Tag => TagList (-list)"},
+		{name: "News", want: "News => NewsList (plural equals singular, List suffix is added)"},
+		{name: "Equipment", want: "Equipment => EquipmentList (plural equals singular, List suffix is added)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			en := NewEntityName(tt.name, tt.useList)
+			if got := en.String(); got != tt.want {
+				t.Errorf("NewEntityName() = %s, want %s", got, tt.want)
+			}
+			if e := NewEntity(tt.name, tt.useList); e != en.Entity {
+				t.Errorf("NewEntity() = %v, want %v", e, en.Entity)
+			}
+		})
+	}
+
+	rules, err := ParseRules([]string{"News,Tag", "Tag:Len"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EntityName{
+		{Entity: Entity{Name: "News", List: "NewsList"}, Reason: NameReasonFallback},
+		{Entity: Entity{Name: "Tag", List: "Tags"}, Reason: NameReasonPlural},
+	}
+	if got := EntityNames(rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("EntityNames() = %v, want %v", got, want)
+	}
+}
+
+func TestGenerator_SortableLen(t *testing.T) {
+	g := NewGenerator("colgen", "", "", "devel")
+	if err := g.UsePackageDir("."); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"Tag", "Tag:Sortable(Name),Len"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(string(data), "func (ll Tags) Len() int"); n != 1 {
+		t.Errorf("Generate() Len() generated %d times, want 1", n)
+	}
+}
+
+func TestGenerator_MapFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		funcPkg string
+		imports string
+		want    string
+		wantErr error
+	}{
+		{name: "declared in package", line: "News:MapP(db)", want: "return MapP(in, NewNews)"},
+		{name: "missing in package", line: "News:Map(db)", wantErr: ErrMapFunc},
+		{name: "imported func package", line: "News:Map(db)", funcPkg: "time", want: "return time.Map(in, NewNews)"},
+		{name: "custom imported func package", line: "News:MapP(db)", funcPkg: "common", imports: "pkg/common", want: "return common.MapP(in, NewNews)"},
+		{name: "func package is not imported", line: "News:MapP(db)", funcPkg: "common", wantErr: ErrMapFunc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("colgen", tt.imports, tt.funcPkg, "devel")
+			if err := g.UsePackageDir("."); err != nil {
+				t.Fatal(err)
+			}
+
+			rules, err := ParseRules([]string{"News", tt.line}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := g.Generate(rules)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Generate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("Generate() = %s, want %q", data, tt.want)
+			}
+
+			if tt.funcPkg == "time" && !strings.Contains(string(data), `"time"`) {
+				t.Errorf("Generate() = %s, want import of func package", data)
+			}
+		})
+	}
+}
+
+func TestGenerator_MapInterface(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []string
+		wantErr error
+	}{
+		{name: "local interface", lines: []string{"Label", "Label:MapP(Row)"}, want: []string{"func NewLabels(in []Row) Labels { return MapP(in, NewLabel) }"}},
+		{name: "imported interface without constructor", lines: []string{"Tag", "Tag:MapP(fmt.Stringer)"}, want: []string{`"fmt"`, "func NewTags(in []fmt.Stringer) Tags { return MapP(in, NewTag) }"}},
+		{name: "package is suffixed by entity", lines: []string{"Tag", "Tag:MapP(db)"}, want: []string{"func NewTags(in []db.Tag) Tags"}},
+		{name: "constructor accepts interface", lines: []string{"Author", "Author:MapP(Row)"}, wantErr: ErrMapFunc},
+		{name: "constructor accepts other interface", lines: []string{"Label", "Label:MapP(fmt.Stringer)"}, wantErr: ErrMapFunc},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("colgen", "", "", "devel")
+			g.pkg = pkg
+
+			rules, err := ParseRules(tt.lines, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := g.Generate(rules)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Generate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Generate() = %s, want %q", data, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerator_UsePackageDirWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	g := NewGenerator("colgen", "", "", "devel")
+	if err := g.UsePackageDirWithContext(ctx, "."); err == nil {
+		t.Error("UsePackageDirWithContext() expected error for cancelled context")
+	}
+}
+
+func TestGenerator_ErrEmptyCollection(t *testing.T) {
+	const decl = "var ErrEmptyCollection = errors.New(\"empty collection\")"
+	tests := []struct {
+		name       string
+		dir        string
+		outputFile string
+		want       bool
+	}{
+		// examples declare ErrEmptyCollection in main_colgen.go
+		{name: "regenerate same file", dir: "../../examples", outputFile: "main_colgen.go", want: true},
+		{name: "declared in other generated file", dir: "../../examples", outputFile: "sql_colgen.go", want: false},
+		// colgen package declares ErrEmptyCollection in generator.go
+		{name: "declared in package", dir: ".", outputFile: "main_colgen.go", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("main", "", "", "devel")
+			g.SetOutputFile(tt.outputFile)
+			if err := g.UsePackageDir(tt.dir); err != nil {
+				t.Fatal(err)
+			}
+
+			rules, err := ParseRules([]string{"News", "News:First"}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := g.Generate(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := string(data)
+			if strings.Contains(got, decl) != tt.want || strings.Contains(got, `"errors"`) != tt.want {
+				t.Errorf("Generate() declaration = %v, want %v:\n%s", !tt.want, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGenerator_TypeErrors(t *testing.T) {
+	g := NewGenerator("typeerrors", "", "", "devel")
+	if err := g.UsePackageDir("testdata/typeerrors"); err != nil {
+		t.Fatalf("UsePackageDir() error = %v, type errors must be tolerated", err)
+	}
+
+	var logs []string
+	g.SetVerbose(func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) })
+
+	rules, err := ParseRules([]string{"News", "News:Index(Title)"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatalf("Generate() error = %v for entity without type errors", err)
+	}
+	if !strings.Contains(string(data), "func (ll NewsList) IDs() []int {") {
+		t.Errorf("Generate() = %s, want NewsList.IDs", data)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "NewsList") {
+		t.Errorf("Generate() logs = %v, want ignored type errors", logs)
+	}
+
+	tests := []struct {
+		entity string
+		want   error
+	}{
+		{entity: "Broken", want: ErrIllTyped},
+		{entity: "Missing", want: ErrMissingType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entity, func(t *testing.T) {
+			rules, err := ParseRules([]string{tt.entity}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = g.Generate(rules)
+			if !errors.Is(err, tt.want) || !strings.Contains(err.Error(), "UndefinedAuthor") {
+				t.Errorf("Generate() error = %v, want %v with type errors", err, tt.want)
+			}
+		})
+	}
+
+	// syntax errors still fail loading
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":  "module example.com/broken\n\ngo 1.21\n",
+		"main.go": "package broken\n\ntype News struct {\n",
+	})
+	if err := g.UsePackageDir(dir); !errors.Is(err, ErrLoadPackage) {
+		t.Errorf("UsePackageDir() error = %v, want %v", err, ErrLoadPackage)
+	}
+}
+
+func TestGenerator_GeneratedTypes(t *testing.T) {
+	g := NewGenerator("generated", "", "", "devel")
+	if err := g.UsePackageDir("testdata/generated"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entity string
+		want   error
+	}{
+		{entity: "News"},
+		{entity: "NewsList", want: ErrGeneratedType}, // declared in news_colgen.go
+		{entity: "TagList", want: ErrGeneratedType},  // declared in file with colgen header
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entity, func(t *testing.T) {
+			rules, err := ParseRules([]string{tt.entity}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = g.Generate(rules)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Generate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_PaginationMeta(t *testing.T) {
+	g := NewGenerator("colgen", "", "", "devel")
+	if err := g.UsePackageDir("."); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"News,Tag", "News:Paginate", "Tag:Paginate"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Count(string(data), "type PaginationMeta struct {"); got != 1 {
+		t.Errorf("Generate() PaginationMeta declarations = %d, want 1:\n%s", got, data)
+	}
+}
+
+func TestGenerator_PairsOnce(t *testing.T) {
+	g := NewGenerator("colgen", "", "", "devel")
+	if err := g.UsePackageDir("."); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"Tag", "Tag:Pairs(ID,Name)", "Tag:Len,Pairs(ID,Name),Pairs(Name,ID)"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for want, n := range map[string]int{"type TagIDNamePair struct {": 1, ") IDNamePairs() ": 1, "type TagNameIDPair struct {": 1} {
+		if got := strings.Count(string(data), want); got != n {
+			t.Errorf("Generate() %q declarations = %d, want %d:\n%s", want, got, n, data)
+		}
+	}
+
+	if _, err = g.Format(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerator_GeneratedMethods(t *testing.T) {
+	g := NewGenerator("main", "", "", "devel")
+	if err := g.UsePackageDir("../../examples"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := g.GeneratedMethods(DefaultGeneratedMethodsBytes)
+	for _, want := range []string{
+		"func (ll NewsList) IDs() []int\n",
+		"func (ll NewsList) IndexByTitle() map[string]News\n",
+		"func (ll *Events) Scan(src any) error\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("GeneratedMethods() = %s, want %s", got, want)
+		}
+	}
+
+	// user methods are skipped
+	if strings.Contains(got, "trimName") {
+		t.Errorf("GeneratedMethods() contains unexported methods: %s", got)
+	}
+
+	// truncated
+	if got = g.GeneratedMethods(40); len(got) > 40+len("...\n") || !strings.HasSuffix(got, "...\n") {
+		t.Errorf("GeneratedMethods() not truncated: %s", got)
+	}
+}
+
+func TestGenerator_Stats(t *testing.T) {
+	g := NewGenerator("colgen", "", "", "devel")
+	if err := g.UsePackageDir("."); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"News,Tag", "Tag:Index(Name),OrderNumber,Len,Sortable(Name)", "News:CategoryID,Index(Slug)?"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = g.GenerateTo(rules, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	got := g.Stats()
+	want := map[string]int{"IDs": 2, "Index": 3, "Field": 2, "Len": 1, "Sortable": 1}
+	if got.Entities != 2 || !reflect.DeepEqual(got.Methods, want) {
+		t.Errorf("Stats() = %+v, want entities=2 methods=%v", got, want)
+	}
+	if got.Bytes != buf.Len() || got.LoadTime <= 0 {
+		t.Errorf("Stats() bytes = %d, load time = %v, want bytes = %d", got.Bytes, got.LoadTime, buf.Len())
+	}
+}
+
+func TestGenerator_UnusedImports(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"News", "News:Apply(fmt.Println)"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator("colgen", "fmt,github.com/vmkteam/db", "", "devel")
+	g.pkg = pkg
+	if _, err = g.Generate(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := g.UnusedImports(), []string{"github.com/vmkteam/db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnusedImports() = %v, want %v", got, want)
+	}
+
+	g.SetStrictImports(true)
+	if _, err = g.Generate(rules); !errors.Is(err, ErrUnusedImport) {
+		t.Errorf("Generate() error = %v, want %v", err, ErrUnusedImport)
+	}
+}
+
+// writeFiles writes files with contents to dir, creating subdirectories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadPackage_Workspace(t *testing.T) {
+	// go.work must be detected from target dir, workspace mode doesn't allow -mod=mod.
+	// Modules a and b are in workspace, module c is not.
+	t.Setenv("GOWORK", "")
+	t.Setenv("GOFLAGS", "")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/go.mod": "module example.com/a\n\ngo 1.21\n\nrequire example.com/b v0.0.0\n",
+		"a/a.go":   "package a\n\nimport \"example.com/b\"\n\n//colgen:User\n\ntype User struct {\n\tID   int\n\tRole b.Role\n}\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.21\n",
+		"b/b.go":   "package b\n\ntype Role struct {\n\tName string\n}\n",
+		"c/go.mod": "module example.com/c\n\ngo 1.21\n",
+		"c/c.go":   "package c\n\ntype Tag struct {\n\tID int\n}\n",
+		"go.work":  "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n",
+	})
+
+	g := NewGenerator("a", "", "", "devel")
+	if err := g.UsePackageDir(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"User"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = g.Generate(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewGenerator("c", "", "", "devel").UsePackageDir(filepath.Join(dir, "c"))
+	if !errors.Is(err, ErrNotInWorkspace) {
+		t.Errorf("UsePackageDir() error = %v, want %v", err, ErrNotInWorkspace)
+	}
+}
+
+func TestGenerator_GroupByBool(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []string
+	g := NewGenerator("colgen", "", "", "devel")
+	g.pkg = pkg
+	g.SetVerbose(func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) })
+
+	rules, err := ParseRules([]string{"Item", "Item:Group(Active)"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = g.Generate(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 1 || !strings.Contains(logs[0], "Partition(Active)") {
+		t.Errorf("Generate() logs = %v, want Partition suggestion", logs)
+	}
+}
+
+func TestGenerator_WhileValue(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs []string
+	g := NewGenerator("colgen", "", "", "devel")
+	g.pkg = pkg
+	g.SetVerbose(func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) })
+
+	rules, err := ParseRules([]string{"Item", "Item:TakeWhile(Active,true)"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "TakeWhileActive(v bool) Items") {
+		t.Errorf("Generate() = %s, want TakeWhileActive(v)", data)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "value true") {
+		t.Errorf("Generate() logs = %v, want ignored value", logs)
+	}
+}
+
+func TestGenerator_AllowUnexported(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		line  string
+		allow bool
+		want  string
+	}{
+		{name: "unexported method", line: "Stock:reserved", want: "func (ll Stocks) reserveds() []int {"},
+		{name: "unexported prefixed method", line: "Stock:Keys(limits)", want: "func (ll Stocks) limitsKeys() []string {"},
+		{name: "exported method", line: "Stock:Index(reserved)", allow: true, want: "func (ll Stocks) IndexByreserved() map[int]Stock {"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("colgen", "", "", "devel")
+			g.pkg = pkg
+			g.SetAllowUnexported(tt.allow)
+
+			rules, err := ParseRules([]string{"Stock", tt.line}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := g.Generate(rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(got), tt.want) {
+				t.Errorf("Generate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_RenameFieldMethods(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseRules([]string{"Glossary", "Glossary:Name,Len,Index(Len)"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Glossary has IDs, Names and Len fields: methods of base generation, Name and Len rules fail generation
+	g := NewGenerator("colgen", "", "", "devel")
+	g.pkg = pkg
+	if _, err = g.Generate(rules); !errors.Is(err, ErrFieldMethod) || !strings.Contains(err.Error(), "-rename-field-methods to generate IDsList()") {
+		t.Fatalf("Generate() error = %v, want %v with override", err, ErrFieldMethod)
+	}
+
+	// and are renamed with List suffix
+	var logs []string
+	g = NewGenerator("colgen", "", "", "devel")
+	g.pkg = pkg
+	g.SetRenameFieldMethods(true)
+	g.SetVerbose(func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) })
+	got, err := g.Generate(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"func (ll Glossaries) IDsList() []int {",
+		"func (ll Glossaries) NamesList() []string {",
+		"// LenList returns number of elements in collection",
+		"func (ll Glossaries) LenList() int {",
+		"func (ll Glossaries) IsEmpty() bool {",
+		"func (ll Glossaries) IndexByLen() map[int]Glossary {",
+		"r[ll[i].Len] = ll[i]",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("Generate() = %s, want %s", got, want)
+		}
+	}
+	for _, unwanted := range []string{") IDs()", ") Names()", ") Len()"} {
+		if strings.Contains(string(got), unwanted) {
+			t.Errorf("Generate() = %s, want without %s", got, unwanted)
+		}
+	}
+	if len(logs) != 3 || !strings.Contains(logs[0], "IDs() is renamed to IDsList()") {
+		t.Errorf("Generate() logs = %v, want renamed methods", logs)
+	}
+}
+
+func TestQualifiedType(t *testing.T) {
+	pkg := types.NewPackage("example.com/app", "app")
+	domain := types.NewPackage("example.com/domain", "domain")
+	status := types.NewNamed(types.NewTypeName(0, domain, "Status", nil), types.Typ[types.String], nil)
+	local := types.NewNamed(types.NewTypeName(0, pkg, "Kind", nil), types.Typ[types.String], nil)
+
+	tests := []struct {
+		typ         types.Type
+		want        string
+		wantImports []string
+	}{
+		{typ: types.Typ[types.Int], want: "int"},
+		{typ: local, want: "Kind"},
+		{typ: status, want: "domain.Status", wantImports: []string{"example.com/domain"}},
+		{typ: types.NewSlice(status), want: "[]domain.Status", wantImports: []string{"example.com/domain"}},
+		{typ: types.NewMap(status, types.NewPointer(local)), want: "map[domain.Status]*Kind", wantImports: []string{"example.com/domain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, imports := qualifiedType(tt.typ, pkg)
+			if got != tt.want || !reflect.DeepEqual(imports, tt.wantImports) {
+				t.Errorf("qualifiedType() = %v %v, want %v %v", got, imports, tt.want, tt.wantImports)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package colgen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// HashPrefix is a prefix of header line with sha256 hash of generated file, e.g. `// colgen:sha256:<hash>`.
+	HashPrefix = "// colgen:sha256:"
+
+	// versionPrefix is a prefix of header line with colgen version.
+	versionPrefix = "// Code generated by colgen "
+)
+
+// ErrModified is returned by VerifyHash if generated file was edited after generation.
+var ErrModified = errors.New("generated file was modified")
+
+// ContentHash returns sha256 hash of generated source. Version and hash lines are excluded,
+// so files generated by different colgen versions with the same content have the same hash.
+// Line endings are normalized, so CRLF checkouts don't change hash.
+func ContentHash(src []byte) string {
+	h := sha256.New()
+	for _, line := range bytes.SplitAfter([]byte(normalizeEOL(string(src))), []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(versionPrefix)) || bytes.HasPrefix(line, []byte(HashPrefix)) {
+			continue
+		}
+		h.Write(line)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// WithHash returns src with hash line after version line. Existing hash line is replaced.
+func WithHash(src []byte) []byte {
+	hashLine := []byte(HashPrefix + ContentHash(src) + "\n")
+
+	var r bytes.Buffer
+	added := false
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		switch {
+		case bytes.HasPrefix(line, []byte(HashPrefix)):
+			continue
+		case !added && bytes.HasPrefix(line, []byte(versionPrefix)):
+			r.Write(line)
+			r.Write(hashLine)
+			added = true
+			continue
+		}
+		r.Write(line)
+	}
+
+	if !added {
+		return append(hashLine, r.Bytes()...)
+	}
+
+	return r.Bytes()
+}
+
+// VerifyHash checks that generated source matches its hash line. Sources without hash line, e.g.
+// generated by previous colgen versions, are not verified.
+func VerifyHash(src []byte) error {
+	for _, line := range bytes.Split([]byte(normalizeEOL(string(src))), []byte("\n")) {
+		if want, ok := bytes.CutPrefix(line, []byte(HashPrefix)); ok {
+			if got := ContentHash(src); got != string(want) {
+				return fmt.Errorf("%w: hash %s, want %s", ErrModified, got, want)
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
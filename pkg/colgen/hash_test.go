@@ -0,0 +1,52 @@
+package colgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHash(t *testing.T) {
+	src := []byte("// Code generated by colgen devel; DO NOT EDIT.\n\npackage main\n\ntype Tags []Tag\n")
+
+	got := WithHash(src)
+	lines := strings.Split(string(got), "\n")
+	require.True(t, strings.HasPrefix(lines[1], HashPrefix))
+	assert.Equal(t, HashPrefix+ContentHash(src), lines[1])
+
+	// idempotent
+	assert.Equal(t, got, WithHash(got))
+
+	// version line is excluded
+	other := strings.Replace(string(got), "colgen devel", "colgen v1.2.3", 1)
+	require.NoError(t, VerifyHash([]byte(other)))
+}
+
+func TestVerifyHash(t *testing.T) {
+	src := WithHash([]byte("// Code generated by colgen devel; DO NOT EDIT.\n\npackage main\n\ntype Tags []Tag\n"))
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr error
+	}{
+		{name: "pristine", src: string(src)},
+		{name: "pristine with CRLF", src: strings.ReplaceAll(string(src), "\n", "\r\n")},
+		{name: "hand-edited", src: string(src) + "\nfunc (ll Tags) Custom() {}\n", wantErr: ErrModified},
+		{name: "legacy without hash", src: "// Code generated by colgen devel; DO NOT EDIT.\n\npackage main\n\n// edited\n"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyHash([]byte(tt.src))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
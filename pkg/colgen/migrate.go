@@ -0,0 +1,157 @@
+package colgen
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// MigrationNote describes a directive that can't be translated to the current syntax automatically.
+type MigrationNote struct {
+	Line      int
+	Directive string
+	Message   string
+}
+
+func (n MigrationNote) String() string {
+	return fmt.Sprintf("%d: %s: %s", n.Line, n.Directive, n.Message)
+}
+
+// migrateRules are custom rules with arguments which names are canonicalized case-insensitively, e.g. index(ID) => Index(ID).
+var migrateRules = slices.Concat(fieldArgRules, argRules, []string{CustomRuleUnique, CustomRuleIndexInto})
+
+// reMigrateItem is regexp for rule item with arguments: `index(ID)` or optional `Index(Slug)?`.
+var reMigrateItem = regexp.MustCompile(`^(\w+)(\(.*\)\??)$`)
+
+// reMigrateSpaces is regexp for spaces around delimiters of directive: `News: Index(ID), Len`.
+var reMigrateSpaces = regexp.MustCompile(`\s*([,:()])\s*`)
+
+// MigrateSource rewrites `//colgen:` directives of Go source to the current canonical syntax:
+//   - spaces around delimiters are removed: `News: Index(ID), Len` => `News:Index(ID),Len`;
+//   - Map and MapP casing is canonicalized: `MAPP(db)`, `Mapp(db)` => `MapP(db)`, `mapP(db)` => `mapp(db)`;
+//   - names of rules with arguments are written in canonical case: `index(UserID)` => `Index(UserID)`.
+//
+// Only comment text is changed. Directives that can't be parsed after migration are reported as notes.
+func MigrateSource(filename string, src []byte) ([]byte, []MigrationNote, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %w", filename, err)
+	}
+
+	var (
+		result = slices.Clone(src)
+		notes  []MigrationNote
+	)
+
+	// replace from the end of file to keep offsets of previous comments
+	for _, cg := range slices.Backward(f.Comments) {
+		for _, c := range slices.Backward(cg.List) {
+			directive, ok := strings.CutPrefix(c.Text, ColgenPrefix)
+			if !ok {
+				continue
+			}
+
+			pos := fset.Position(c.Pos())
+			migrated, err := MigrateDirective(directive)
+			if rest, _ := CutNolint(migrated); err == nil && rest != "" {
+				_, err = parseLine(rest)
+			}
+			if err != nil {
+				notes = append(notes, MigrationNote{Line: pos.Line, Directive: c.Text, Message: "can't translate: " + err.Error()})
+				continue
+			}
+
+			if migrated == directive {
+				continue
+			}
+
+			text := ColgenPrefix + migrated
+			result = slices.Concat(result[:pos.Offset], []byte(text), result[pos.Offset+len(c.Text):])
+		}
+	}
+
+	slices.Reverse(notes)
+
+	return result, notes, nil
+}
+
+// MigrateDirective returns directive text without `//colgen:` prefix in the current canonical syntax.
+// Rules with arguments which are unknown even case-insensitively, e.g. Indx(ID), are returned as ErrUnknownRule.
+func MigrateDirective(directive string) (string, error) {
+	directive, codes := CutNolint(directive)
+	if len(codes) > 0 {
+		nolint := ColgenPrefix + NolintPrefix + strings.Join(codes, ",")
+		if directive == "" {
+			return strings.TrimPrefix(nolint, ColgenPrefix), nil
+		}
+
+		migrated, err := MigrateDirective(directive)
+		return migrated + " " + nolint, err
+	}
+
+	directive = migrateSpaces(strings.TrimSpace(directive))
+	entity, rules, ok := strings.Cut(directive, ":")
+	if !ok {
+		return directive, nil
+	}
+
+	items := splitRules(rules)
+	for i, item := range items {
+		migrated, err := migrateItem(item)
+		if err != nil {
+			return directive, err
+		}
+
+		items[i] = migrated
+	}
+
+	return entity + ":" + strings.Join(items, ","), nil
+}
+
+// migrateSpaces removes spaces around delimiters outside of quoted values: `Exclude(1, 2)` => `Exclude(1,2)`.
+func migrateSpaces(s string) string {
+	parts := strings.Split(s, `"`)
+	for i := range parts {
+		// odd parts are quoted values
+		if i%2 == 0 {
+			parts[i] = reMigrateSpaces.ReplaceAllString(parts[i], "$1")
+		}
+	}
+
+	return strings.Join(parts, `"`)
+}
+
+// migrateItem canonicalizes name of single rule item with arguments, e.g. `mapP(db)` => `mapp(db)`.
+func migrateItem(item string) (string, error) {
+	matches := reMigrateItem.FindStringSubmatch(item)
+	if len(matches) != 3 {
+		return item, nil
+	}
+
+	name, rest := matches[1], matches[2]
+	switch {
+	case isMapP(name) && unicode.IsLower(rune(name[0])): // private constructors
+		name = strings.ToLower(name)
+	case strings.EqualFold(name, CustomRuleMap):
+		name = CustomRuleMap
+	case strings.EqualFold(name, CustomRuleMapP):
+		name = CustomRuleMapP
+	case len(name) > len(CustomRuleExclude) && strings.EqualFold(name[:len(CustomRuleExclude)], CustomRuleExclude) &&
+		hasRulePrefix(CustomRuleExclude+name[len(CustomRuleExclude):], CustomRuleExclude): // excludeDeleted(0,999)
+		name = CustomRuleExclude + name[len(CustomRuleExclude):]
+	default:
+		i := slices.IndexFunc(migrateRules, func(r string) bool { return strings.EqualFold(r, name) })
+		if i == -1 {
+			return item, fmt.Errorf("%w: %q", ErrUnknownRule, item)
+		}
+
+		name = migrateRules[i]
+	}
+
+	return name + rest, nil
+}
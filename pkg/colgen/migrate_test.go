@@ -0,0 +1,84 @@
+package colgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDirective(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "News,Tag", want: "News,Tag"},
+		{in: "News, Tag", want: "News,Tag"},
+		{in: "News: Index(ID), Len", want: "News:Index(ID),Len"},
+		{in: "News:MAPP(db)", want: "News:MapP(db)"},
+		{in: "News:Mapp(db)", want: "News:MapP(db)"},
+		{in: "News:MAP(db)", want: "News:Map(db)"},
+		{in: "News:mapP(db)", want: "News:mapp(db)"},
+		{in: "News:mAp(db)", want: "News:map(db)"},
+		{in: "News:index(UserID),group(CategoryID)?", want: "News:Index(UserID),Group(CategoryID)?"},
+		{in: `News:ExcludeDrafts("my draft", "old")`, want: `News:ExcludeDrafts("my draft","old")`},
+		{in: "News:TagIDs,UniqueTagIDs", want: "News:TagIDs,UniqueTagIDs"},
+		{in: "News:excludeDeleted(0,999)", want: "News:ExcludeDeleted(0,999)"},
+		{in: "nolint: CG001", want: "nolint:CG001"},
+		{in: "News: UniqueID  //colgen:nolint:CG002, CG003", want: "News:UniqueID //colgen:nolint:CG002,CG003"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := MigrateDirective(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := MigrateDirective("News:Indx(ID)")
+	assert.ErrorIs(t, err, ErrUnknownRule)
+}
+
+func TestMigrateSource(t *testing.T) {
+	src := `package news
+
+//go:generate colgen
+//colgen:News, Tag
+//colgen:News: index(CategoryID), MAPP(db)
+//colgen:Tag:Unknown(ID)
+// colgen:News:mapp(db) is not a directive
+
+type News struct{ ID, CategoryID int }
+
+type Tag struct{ ID int }
+`
+	want := `package news
+
+//go:generate colgen
+//colgen:News,Tag
+//colgen:News:Index(CategoryID),MapP(db)
+//colgen:Tag:Unknown(ID)
+// colgen:News:mapp(db) is not a directive
+
+type News struct{ ID, CategoryID int }
+
+type Tag struct{ ID int }
+`
+
+	got, notes, err := MigrateSource("news.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got))
+	require.Len(t, notes, 1)
+	assert.Equal(t, 6, notes[0].Line)
+	assert.Equal(t, "//colgen:Tag:Unknown(ID)", notes[0].Directive)
+	assert.Contains(t, notes[0].Message, "can't translate")
+
+	// canonical source is not changed
+	again, notes, err := MigrateSource("news.go", got)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(again))
+	assert.Len(t, notes, 1)
+
+	_, _, err = MigrateSource("broken.go", []byte("package"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package colgen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PingStatus is a result of assistant health check.
+type PingStatus string
+
+const (
+	PingOK           PingStatus = "OK"
+	PingAuthFailed   PingStatus = "AUTH"
+	PingUnknownModel PingStatus = "MODEL"
+	PingNetworkError PingStatus = "NETWORK"
+	PingFailed       PingStatus = "FAIL"
+)
+
+// PingResult represents assistant health check result.
+type PingResult struct {
+	Status    PingStatus
+	Model     string
+	Latency   time.Duration
+	Diagnosis string // remediation hint for failed checks
+	Err       error
+}
+
+// modeler is implemented by callers with known model name.
+type modeler interface {
+	Model() string
+}
+
+// Ping performs a minimal chat call and classifies its error.
+func (a *Assistant) Ping() PingResult {
+	r := PingResult{Model: a.Model()}
+
+	now := time.Now()
+	_, r.Err = a.call(Code{SystemPrompt: "You are a health check.", Prompt: "Reply with OK."})
+	r.Latency = time.Since(now)
+	r.Status, r.Diagnosis = classifyPingError(r.Err)
+
+	return r
+}
+
+// reStatusCode is regexp for status code in deepseek errors: `err: ...; http_status_code=401`.
+var reStatusCode = regexp.MustCompile(`http_status_code=(\d+)`)
+
+// statusCoders return HTTP status code of typed provider errors, providers add them in init.
+var statusCoders []func(err error) (int, bool)
+
+// statusCode returns HTTP status code from assistant error or 0.
+func statusCode(err error) int {
+	for _, sc := range statusCoders {
+		if code, ok := sc(err); ok {
+			return code
+		}
+	}
+
+	if m := reStatusCode.FindStringSubmatch(err.Error()); len(m) == 2 {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+
+	return 0
+}
+
+// classifyPingError returns status and diagnosis for ping error.
+func classifyPingError(err error) (PingStatus, string) {
+	if err == nil {
+		return PingOK, ""
+	}
+
+	var ne net.Error
+	switch code := statusCode(err); {
+	case code == 401 || code == 403:
+		return PingAuthFailed, fmt.Sprintf("authentication failed (%d): check API key, use `colgen -write-key=<key> -ai=<assistant>`", code)
+	case code == 404 || code == 400:
+		return PingUnknownModel, fmt.Sprintf("request rejected (%d): model is unknown or not available for this key", code)
+	case code != 0:
+		return PingFailed, fmt.Sprintf("unexpected response (%d): %v", code, err)
+	case errors.As(err, &ne), strings.Contains(err.Error(), "connection refused"), strings.Contains(err.Error(), "no such host"):
+		return PingNetworkError, "network error: check connection and proxy settings (HTTPS_PROXY)"
+	}
+
+	return PingFailed, err.Error()
+}
@@ -0,0 +1,29 @@
+package colgen
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyPingError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want PingStatus
+	}{
+		{name: "nil", err: nil, want: PingOK},
+		{name: "deepseek auth", err: errors.New("err: invalid key; http_status_code=401"), want: PingAuthFailed},
+		{name: "deepseek model", err: errors.New("err: model; http_status_code=400"), want: PingUnknownModel},
+		{name: "connection refused", err: errors.New("dial tcp: connection refused"), want: PingNetworkError},
+		{name: "other", err: errors.New("boom"), want: PingFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := classifyPingError(tt.err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !colgen_noai
+
+package colgen
+
+// NoAI reports a restricted build with colgen_noai tag. Assistant providers are compiled in this build.
+const NoAI = false
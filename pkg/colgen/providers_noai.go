@@ -0,0 +1,7 @@
+//go:build colgen_noai
+
+package colgen
+
+// NoAI reports a restricted build with colgen_noai tag: assistant providers and their HTTP callers are not compiled in,
+// so no code can be sent to assistant APIs. Providers registered by other packages are still available.
+const NoAI = true
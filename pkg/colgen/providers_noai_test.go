@@ -0,0 +1,19 @@
+//go:build colgen_noai
+
+package colgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoAI(t *testing.T) {
+	assert.True(t, NoAI)
+	assert.Empty(t, AssistantNames())
+
+	_, err := NewAssistant(AssistantClaude, "key")
+	assert.ErrorIs(t, err, ErrUnsupportedAssistName)
+	_, err = NewAssistant(AssistantDeepSeek, "key")
+	assert.ErrorIs(t, err, ErrUnsupportedAssistName)
+}
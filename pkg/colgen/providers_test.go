@@ -0,0 +1,115 @@
+//go:build !colgen_noai
+
+package colgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	deepSeekOK = `{"id":"1","object":"chat.completion","model":"deepseek-chat","choices":[{"index":0,"message":{"role":"assistant","content":"OK"},"finish_reason":"stop"}]}`
+	claudeOK   = `{"id":"1","type":"message","role":"assistant","model":"claude","content":[{"type":"text","text":"OK"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+	apiErr     = `{"type":"error","error":{"type":"error","message":"error"}}`
+)
+
+func TestAssistant_Ping(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   PingStatus
+	}{
+		{name: "ok", status: http.StatusOK, want: PingOK},
+		{name: "unauthorized", status: http.StatusUnauthorized, want: PingAuthFailed},
+		{name: "forbidden", status: http.StatusForbidden, want: PingAuthFailed},
+		{name: "not found", status: http.StatusNotFound, want: PingUnknownModel},
+		{name: "bad request", status: http.StatusBadRequest, want: PingUnknownModel},
+		{name: "conflict", status: http.StatusConflict, want: PingFailed},
+	}
+
+	callers := map[string]func(url string) Caller{
+		"deepseek": func(url string) Caller { return DeepSeekCaller{Key: "key", BaseURL: url} },
+		"claude":   func(url string) Caller { return ClaudeCaller{Key: "key", BaseURL: url} },
+	}
+
+	for cn, newCaller := range callers {
+		for _, tt := range tests {
+			t.Run(cn+" "+tt.name, func(t *testing.T) {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(tt.status)
+					switch {
+					case tt.status != http.StatusOK:
+						fmt.Fprint(w, apiErr)
+					case cn == "claude":
+						fmt.Fprint(w, claudeOK)
+					default:
+						fmt.Fprint(w, deepSeekOK)
+					}
+				}))
+				defer srv.Close()
+
+				a := &Assistant{c: newCaller(srv.URL)}
+				r := a.Ping()
+				assert.Equal(t, tt.want, r.Status, r.Err)
+				assert.NotEmpty(t, r.Model)
+				if tt.want != PingOK {
+					assert.NotEmpty(t, r.Diagnosis)
+				}
+			})
+		}
+	}
+
+	t.Run("network error", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+
+		a := &Assistant{c: DeepSeekCaller{Key: "key", BaseURL: srv.URL}}
+		r := a.Ping()
+		assert.Equal(t, PingNetworkError, r.Status, r.Err)
+	})
+}
+
+func TestCaller_Temperature(t *testing.T) {
+	callers := map[string]struct {
+		newCaller func(url string) Caller
+		answer    string
+	}{
+		"deepseek": {newCaller: func(url string) Caller { return DeepSeekCaller{Key: "key", BaseURL: url} }, answer: deepSeekOK},
+		"claude":   {newCaller: func(url string) Caller { return ClaudeCaller{Key: "key", BaseURL: url} }, answer: claudeOK},
+	}
+
+	for name, tc := range callers {
+		t.Run(name, func(t *testing.T) {
+			var temperatures []float64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Temperature float64 `json:"temperature"`
+				}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				temperatures = append(temperatures, req.Temperature)
+
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tc.answer)
+			}))
+			defer srv.Close()
+
+			c := tc.newCaller(srv.URL)
+			temp := 0.4
+			_, err := c.Call(Code{SystemPrompt: "system", Prompt: "prompt"})
+			require.NoError(t, err)
+			_, err = c.Call(Code{SystemPrompt: "system", Prompt: "prompt", Temperature: &temp})
+			require.NoError(t, err)
+
+			require.Len(t, temperatures, 2)
+			assert.Zero(t, temperatures[0])
+			assert.InDelta(t, 0.4, temperatures[1], 1e-6)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package colgen
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// DefaultMaxTemperature is a max temperature for assistants without own range.
+const DefaultMaxTemperature = 1.0
+
+// temperatureLimiter is implemented by callers with own temperature range 0..MaxTemperature.
+type temperatureLimiter interface {
+	MaxTemperature() float64
+}
+
+// CallerFactory creates a Caller for the given API key.
+type CallerFactory func(key string) Caller
+
+// AssistantRegistry holds all known assistants by name.
+// Built-in assistants register themselves in init(), third-party packages can do the same via RegisterAssistant.
+type AssistantRegistry struct {
+	mu        sync.RWMutex
+	factories map[AssistantName]CallerFactory
+}
+
+// NewAssistantRegistry returns empty AssistantRegistry.
+func NewAssistantRegistry() *AssistantRegistry {
+	return &AssistantRegistry{factories: make(map[AssistantName]CallerFactory)}
+}
+
+// defaultRegistry is used by NewAssistant and RegisterAssistant.
+var defaultRegistry = NewAssistantRegistry()
+
+// RegisterAssistant registers assistant factory in the default registry.
+func RegisterAssistant(name AssistantName, factory CallerFactory) {
+	defaultRegistry.Register(name, factory)
+}
+
+// MaxTemperature returns max temperature of assistant from the default registry.
+func MaxTemperature(name AssistantName) (float64, error) {
+	return defaultRegistry.MaxTemperature(name)
+}
+
+// AssistantNames returns sorted names of all assistants in the default registry.
+func AssistantNames() []AssistantName {
+	return defaultRegistry.Names()
+}
+
+// Register adds assistant factory by name. It panics on empty name, nil factory or duplicate registration.
+func (r *AssistantRegistry) Register(name AssistantName, factory CallerFactory) {
+	if name == "" || factory == nil {
+		panic("colgen: invalid assistant registration")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		panic(fmt.Sprintf("colgen: assistant %s is already registered", name))
+	}
+
+	r.factories[name] = factory
+}
+
+// New creates a new Assistant by name with the provided API key.
+// Returns ErrUnsupportedAssistName if assistant is not registered.
+func (r *AssistantRegistry) New(name AssistantName, key string) (*Assistant, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAssistName, name)
+	}
+
+	c := factory(key)
+	budget := PromptBudget{MaxBytes: DefaultMaxPromptBytes}
+	if b, ok := c.(budgeter); ok {
+		budget.MaxBytes = b.MaxPromptBytes()
+	}
+
+	return &Assistant{
+		name:   name,
+		key:    key,
+		c:      c,
+		budget: budget,
+	}, nil
+}
+
+// MaxTemperature returns max temperature of assistant by name or DefaultMaxTemperature if caller doesn't report it.
+// Returns ErrUnsupportedAssistName if assistant is not registered.
+func (r *AssistantRegistry) MaxTemperature(name AssistantName) (float64, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedAssistName, name)
+	}
+
+	if tl, ok := factory("").(temperatureLimiter); ok {
+		return tl.MaxTemperature(), nil
+	}
+
+	return DefaultMaxTemperature, nil
+}
+
+// Names returns sorted names of all registered assistants.
+func (r *AssistantRegistry) Names() []AssistantName {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]AssistantName, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
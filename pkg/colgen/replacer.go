@@ -2,10 +2,14 @@ package colgen
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"go/types"
 	"io"
 	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -15,12 +19,15 @@ import (
 // Replacer.
 //   //colgen@NewCall(db)
 //   //colgen@NewUser(db)
+//   //colgen@NewUser(db,full,opts)
 //   //colgen@newUserSummary(dating.User,full,json)
 
 type Field struct {
 	Name string
 	Type string
 	Tag  string
+
+	IsAmbiguous bool // field is promoted from several embedded structs, it is skipped with comment
 }
 
 type ReplaceRule struct {
@@ -32,17 +39,45 @@ type ReplaceRule struct {
 	Arg      string
 	IsFull   bool
 	WithJSON bool
+	IsLocal  bool   // Arg is a type from the current package
+	WithOpts bool   // constructor accepts variadic options applied after field mapping: opts ...<Entity>Option
+	Order    string // order of fields in full mode: FieldOrderSource (default) or FieldOrderAlpha
 
 	Fields []Field
+
+	rawArg string // Arg as written in rule
 }
 
-var reNewFullNameArg = regexp.MustCompile(`(?mi)^//colgen@(New|new)(\w+)\(([\w.,]+)\)$`)
+// EmbeddedName returns name of embedded field for Arg: db.User => User.
+func (r ReplaceRule) EmbeddedName() string {
+	if _, name, ok := strings.Cut(r.Arg, "."); ok {
+		return name
+	}
+
+	return r.Arg
+}
+
+// Field orders of full mode, e.g. //colgen@newUserSummary(db.User,full,order=alpha).
+// Source order is a declaration order of struct fields. Fields promoted from embedded struct are expanded in place
+// of the embedding field in their own declaration order, recursively. Field promoted from several embedded structs
+// at the same depth (ambiguous) is placed at its first occurrence, shadowed fields are skipped.
+// Alpha order sorts fields by name, so moving fields or embedded structs in source doesn't change the output.
+const (
+	FieldOrderSource = "source"
+	FieldOrderAlpha  = "alpha"
+)
+
+var reNewFullNameArg = regexp.MustCompile(`(?mi)^//colgen@(New|new)(\w+)\(([\w.,=]+)\)$`)
 
 // ParseReplaceRule parses replaceRule to struct. Examples:
 //
 //	//colgen@NewCall(db)
 //	//colgen@NewUser(db)
 //	//colgen@newUserSummary(dating.User,full,json)
+//	//colgen@newUserSummary(dating.User,full,order=alpha)
+//	//colgen@NewUser(db,full,opts)
+//
+// Arg without package is converted to package.Entity, Replacer.Generate resolves it as a local type if it exists.
 func ParseReplaceRule(rule string) (ReplaceRule, error) {
 	r := ReplaceRule{Find: rule}
 	matches := reNewFullNameArg.FindStringSubmatch(rule)
@@ -55,7 +90,7 @@ func ParseReplaceRule(rule string) (ReplaceRule, error) {
 
 	for i, arg := range strings.Split(matches[3], ",") {
 		if i == 0 {
-			r.Arg = arg
+			r.Arg, r.rawArg = arg, arg
 			continue
 		}
 
@@ -64,13 +99,17 @@ func ParseReplaceRule(rule string) (ReplaceRule, error) {
 			r.IsFull = true
 		case "json":
 			r.WithJSON = true
+		case "opts":
+			r.WithOpts = true
+		case "order=" + FieldOrderSource, "order=" + FieldOrderAlpha:
+			r.Order = strings.TrimPrefix(arg, "order=")
 		default:
 			return r, fmt.Errorf("%w: %s", ErrUnknownLine, arg)
 		}
 	}
 
 	// validate conflicts
-	if r.WithJSON && !r.IsFull {
+	if (r.WithJSON || r.Order != "") && !r.IsFull {
 		return r, fmt.Errorf("%w: %s", ErrMissingArg, "full")
 	}
 
@@ -105,10 +144,67 @@ func NewReplacer() *Replacer {
 	return &Replacer{}
 }
 
-// UsePackageDir parses path for go packages.
-func (rl *Replacer) UsePackageDir(path string) (err error) {
-	rl.pkg, err = loadPackage(path)
-	return
+// PackageInfo is a read-only summary of loaded package for diagnostics.
+type PackageInfo struct {
+	PkgPath     string
+	TypeCount   int
+	ImportCount int
+}
+
+// String returns PackageInfo for logging.
+func (pi PackageInfo) String() string {
+	return fmt.Sprintf("package %s: %d types, %d imports", pi.PkgPath, pi.TypeCount, pi.ImportCount)
+}
+
+// UsePackageDir parses path for go packages and returns summary of loaded package.
+func (rl *Replacer) UsePackageDir(path string) (*PackageInfo, error) {
+	pkg, err := loadPackage(context.Background(), path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rl.UsePackage(pkg), nil
+}
+
+// UsePackage uses package loaded by LoadPackage and returns its summary.
+func (rl *Replacer) UsePackage(pkg *packages.Package) *PackageInfo {
+	rl.pkg = pkg
+	pi := &PackageInfo{PkgPath: pkg.PkgPath, ImportCount: len(pkg.Imports)}
+	if pkg.Types != nil {
+		pi.TypeCount = typeCount(pkg.Types.Scope())
+	}
+
+	return pi
+}
+
+// typeCount returns number of declared types in scope, funcs, vars and consts are skipped.
+func typeCount(scope *types.Scope) int {
+	n := 0
+	for _, name := range scope.Names() {
+		if _, ok := scope.Lookup(name).(*types.TypeName); ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// lookupLocal returns type from the current package or nil if not found.
+func (rl *Replacer) lookupLocal(name string) types.Object {
+	if rl.pkg == nil || rl.pkg.Types == nil {
+		return nil
+	}
+
+	return rl.pkg.Types.Scope().Lookup(name)
+}
+
+// findType returns type for the rule Arg.
+func (rl *Replacer) findType(rule ReplaceRule) types.Object {
+	if rule.IsLocal {
+		return rl.lookupLocal(rule.Arg)
+	}
+
+	return rl.findImportedType(rule.Arg)
 }
 
 func (rl *Replacer) findImportedType(fullTypeName string) types.Object {
@@ -130,6 +226,7 @@ func (rl *Replacer) findImportedType(fullTypeName string) types.Object {
 	return nil // не найде
 }
 
+// newFields returns exported fields for full mode in order of rule, see FieldOrderSource.
 func newFields(rule ReplaceRule, fields []entityField) []Field {
 	if !rule.IsFull {
 		return nil
@@ -141,6 +238,12 @@ func newFields(rule ReplaceRule, fields []entityField) []Field {
 			continue
 		}
 
+		// ambiguous selector in.Name doesn't compile
+		if f.IsAmbiguous {
+			ff = append(ff, Field{Name: f.Name, IsAmbiguous: true})
+			continue
+		}
+
 		// create json tag
 		tag := ""
 		if rule.WithJSON {
@@ -164,10 +267,14 @@ func newFields(rule ReplaceRule, fields []entityField) []Field {
 		ff = append(ff, Field{Name: f.Name, Type: f.Type, Tag: tag})
 	}
 
+	if rule.Order == FieldOrderAlpha {
+		slices.SortStableFunc(ff, func(a, b Field) int { return strings.Compare(a.Name, b.Name) })
+	}
+
 	return ff
 }
 
-// Generate generates Replace code for Rule.
+// Generate generates Replace code for Rule. All rules are resolved before replacement, duplicates are rejected.
 func (rl *Replacer) Generate(rules []string) ([]ReplaceRule, error) {
 	// parse rules
 	rr, err := ParseReplaceRules(rules)
@@ -175,11 +282,25 @@ func (rl *Replacer) Generate(rules []string) ([]ReplaceRule, error) {
 		return nil, err
 	}
 
+	// detect duplicates: the same directive generates the same type twice
+	seen := make(map[string]struct{}, len(rr))
+	for _, r := range rr {
+		if _, ok := seen[r.Find]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateRule, r.Find)
+		}
+		seen[r.Find] = struct{}{}
+	}
+
 	// process rules
 	for i, r := range rr {
+		// use type from the current package: //colgen@NewUserView(User)
+		if !strings.Contains(r.rawArg, ".") && rl.lookupLocal(r.rawArg) != nil {
+			r.Arg, r.IsLocal = r.rawArg, true
+		}
+
 		// extract field for FullMode
 		if r.IsFull {
-			fields := typeSliceFromType(rl.findImportedType(r.Arg))
+			fields := typeSliceFromType(rl.findType(r), rl.pkg.Types)
 			if len(fields) == 0 {
 				return nil, fmt.Errorf("%w: %s", ErrMissingType, r.Arg)
 			}
@@ -199,22 +320,91 @@ func (rl *Replacer) Generate(rules []string) ([]ReplaceRule, error) {
 	return rr, nil
 }
 
+// Apply replaces directives in Go source content with generated code.
+// Only standalone line comments are replaced by their positions in AST,
+// so the same text inside doc comments, strings or other comments is kept as is.
+func (rl *Replacer) Apply(content []byte, rules []ReplaceRule) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse file: %w", err)
+	}
+
+	idx := make(map[string]ReplaceRule, len(rules))
+	for _, r := range rules {
+		idx[r.Find] = r
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+
+	// find directives by position
+	var (
+		rr    []replacement
+		found = make(map[string]struct{}, len(rules))
+	)
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			r, ok := idx[c.Text]
+			if !ok || fset.Position(c.Pos()).Column != 1 {
+				continue
+			}
+
+			rr = append(rr, replacement{start: fset.Position(c.Pos()).Offset, end: fset.Position(c.End()).Offset, text: r.Replace})
+			found[r.Find] = struct{}{}
+		}
+	}
+
+	for _, r := range rules {
+		if _, ok := found[r.Find]; !ok {
+			return nil, fmt.Errorf("%w: directive not found: %s", ErrUnknownLine, r.Find)
+		}
+	}
+
+	// keep CRLF line endings, e.g. for files checked out on Windows
+	crlf := bytes.Contains(content, []byte("\r\n"))
+
+	// replace from the end to keep offsets
+	result := bytes.Clone(content)
+	for i := len(rr) - 1; i >= 0; i-- {
+		r := rr[i]
+		text := r.text
+		if crlf {
+			text = strings.ReplaceAll(text, "\n", "\r\n")
+		}
+		result = slices.Concat(result[:r.start], []byte(text), result[r.end:])
+	}
+
+	return result, nil
+}
+
 func (rl *Replacer) generateByRule(rule ReplaceRule) (string, error) {
 	const tmpl = `
-type {{.Entity}} struct { {{if .IsFull}}{{range .Fields}}
-    {{.Name}} {{.Type}} {{.Tag}}{{end}}{{else}}
+type {{.Entity}} struct { {{if .IsFull}}{{range .Fields}}{{if .IsAmbiguous}}
+    // {{.Name}} is skipped: ambiguous selector in {{$.Arg}}{{else}}
+    {{.Name}} {{.Type}} {{.Tag}}{{end}}{{end}}{{else}}
     {{.Arg}}{{end}}
 }
-
-func {{.Cmd}}{{.Entity}}(in *{{.Arg}}) *{{.Entity}} {
+{{if .WithOpts}}
+// {{.Entity}}Option changes {{.Entity}} in {{.Cmd}}{{.Entity}} after field mapping.
+type {{.Entity}}Option func(*{{.Entity}})
+{{end}}
+func {{.Cmd}}{{.Entity}}(in *{{.Arg}}{{if .WithOpts}}, opts ...{{.Entity}}Option{{end}}) *{{.Entity}} {
 	if in == nil {
 		return nil
 	}
 
-	return &{{.Entity}}{ {{if .IsFull}}{{range .Fields}}
-        {{.Name}}: in.{{.Name}},{{end}}{{else}}
-        {{.Entity}}: *in,{{end}}
+	{{if .WithOpts}}r := {{else}}return {{end}}&{{.Entity}}{ {{if .IsFull}}{{range .Fields}}{{if not .IsAmbiguous}}
+        {{.Name}}: in.{{.Name}},{{end}}{{end}}{{else}}
+        {{.EmbeddedName}}: *in,{{end}}
+	}{{if .WithOpts}}
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r{{end}}
 }
 `
 	var buf bytes.Buffer
@@ -1,9 +1,11 @@
 package colgen
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -28,6 +30,7 @@ func TestParseReplaceRule(t *testing.T) {
 				Cmd:    "New",
 				Entity: "Call",
 				Arg:    "db.Call",
+				rawArg: "db",
 			},
 			wantErr: false,
 		},
@@ -43,9 +46,48 @@ func TestParseReplaceRule(t *testing.T) {
 				Arg:      "dating.User",
 				IsFull:   true,
 				WithJSON: true,
+				rawArg:   "dating.User",
 			},
 			wantErr: false,
 		},
+		{
+			name: "//colgen@newUserSummary(dating.User,full,order=alpha)",
+			args: args{
+				rule: "//colgen@newUserSummary(dating.User,full,order=alpha)",
+			},
+			want: ReplaceRule{
+				Find:   "//colgen@newUserSummary(dating.User,full,order=alpha)",
+				Cmd:    "new",
+				Entity: "UserSummary",
+				Arg:    "dating.User",
+				IsFull: true,
+				Order:  FieldOrderAlpha,
+				rawArg: "dating.User",
+			},
+		},
+		{
+			name: "//colgen@NewUser(db,full,opts)",
+			args: args{rule: "//colgen@NewUser(db,full,opts)"},
+			want: ReplaceRule{
+				Find:     "//colgen@NewUser(db,full,opts)",
+				Cmd:      "New",
+				Entity:   "User",
+				Arg:      "db.User",
+				IsFull:   true,
+				WithOpts: true,
+				rawArg:   "db",
+			},
+		},
+		{
+			name:    "order without full",
+			args:    args{rule: "//colgen@newUserSummary(dating.User,order=source)"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown order",
+			args:    args{rule: "//colgen@newUserSummary(dating.User,full,order=random)"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -54,6 +96,9 @@ func TestParseReplaceRule(t *testing.T) {
 				t.Errorf("ParseReplaceRule() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ParseReplaceRule() got = %v, want %v", got, tt.want)
 			}
@@ -109,6 +154,130 @@ func NewUser(in *db.User) *User {
 `,
 			wantErr: false,
 		},
+		{
+			name: "local type",
+			arg:  "//colgen@NewTagView(Tag)",
+			want: `
+type TagView struct { 
+    Tag
+}
+
+func NewTagView(in *Tag) *TagView {
+	if in == nil {
+		return nil
+	}
+
+	return &TagView{ 
+        Tag: *in,
+	}
+}
+`,
+		},
+		{
+			name: "local type full",
+			arg:  "//colgen@newTagSummary(Tag,full,json)",
+			want: `
+type TagSummary struct { 
+    ID int |json:"tagSummaryId"|
+    OrderNumber int64 |json:"orderNumber"|
+    Name string |json:"name"|
+}
+
+func newTagSummary(in *Tag) *TagSummary {
+	if in == nil {
+		return nil
+	}
+
+	return &TagSummary{ 
+        ID: in.ID,
+        OrderNumber: in.OrderNumber,
+        Name: in.Name,
+	}
+}
+`,
+		},
+		{
+			name: "local type with options",
+			arg:  "//colgen@NewTagView(Tag,opts)",
+			want: `
+type TagView struct { 
+    Tag
+}
+
+// TagViewOption changes TagView in NewTagView after field mapping.
+type TagViewOption func(*TagView)
+
+func NewTagView(in *Tag, opts ...TagViewOption) *TagView {
+	if in == nil {
+		return nil
+	}
+
+	r := &TagView{ 
+        Tag: *in,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+`,
+		},
+		{
+			name: "local type full with options",
+			arg:  "//colgen@newTagSummary(Tag,full,opts)",
+			want: `
+type TagSummary struct { 
+    ID int 
+    OrderNumber int64 
+    Name string 
+}
+
+// TagSummaryOption changes TagSummary in newTagSummary after field mapping.
+type TagSummaryOption func(*TagSummary)
+
+func newTagSummary(in *Tag, opts ...TagSummaryOption) *TagSummary {
+	if in == nil {
+		return nil
+	}
+
+	r := &TagSummary{ 
+        ID: in.ID,
+        OrderNumber: in.OrderNumber,
+        Name: in.Name,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+`,
+		},
+		{
+			name: "embedded bases full",
+			arg:  "//colgen@newAccountSummary(Account,full)",
+			want: `
+type AccountSummary struct { 
+    // CreatedAt is skipped: ambiguous selector in Account
+    UpdatedBy string 
+    ID int 
+    Login string 
+}
+
+func newAccountSummary(in *Account) *AccountSummary {
+	if in == nil {
+		return nil
+	}
+
+	return &AccountSummary{ 
+        UpdatedBy: in.UpdatedBy,
+        ID: in.ID,
+        Login: in.Login,
+	}
+}
+`,
+		},
 		{
 			skip: true,
 			name: "",
@@ -146,7 +315,7 @@ func newUserSummary(in *db.User) *UserSummary {
 	for _, tt := range tests {
 		// load packages
 		rl := NewReplacer()
-		err := rl.UsePackageDir(filepath.Dir("."))
+		_, err := rl.UsePackageDir(filepath.Dir("."))
 		if err != nil {
 			t.Errorf("UsePackageDir() error = %v, wantErr %v", err, tt.wantErr)
 			return
@@ -171,3 +340,145 @@ func newUserSummary(in *db.User) *UserSummary {
 		})
 	}
 }
+
+func TestReplacer_UsePackageDir(t *testing.T) {
+	rl := NewReplacer()
+	pi, err := rl.UsePackageDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pi.PkgPath != "github.com/vmkteam/colgen/pkg/colgen" {
+		t.Errorf("UsePackageDir() PkgPath = %v", pi.PkgPath)
+	}
+
+	if pi.TypeCount == 0 || pi.ImportCount == 0 {
+		t.Errorf("UsePackageDir() got empty info = %v", pi)
+	}
+
+	// Sums type is counted, Sum func is not
+	if pi, err = rl.UsePackageDir("testdata/testcontext"); err != nil || pi.TypeCount != 1 {
+		t.Errorf("UsePackageDir() TypeCount = %v, want 1, err = %v", pi, err)
+	}
+
+	if _, err = rl.UsePackageDir("./not-exists"); err == nil {
+		t.Errorf("UsePackageDir() expected error")
+	}
+}
+
+func TestReplacer_Apply(t *testing.T) {
+	const content = `package colgen
+
+// Example:
+//
+//colgen@NewTagView(Tag)
+//	//colgen@NewTagView(Tag)
+//colgen@NewTagView(Tag)
+
+//colgen@NewTagView(Tag,full)
+
+const example = "//colgen@NewTagView(Tag)"
+`
+	const want = `package colgen
+
+// Example:
+//
+type TagView struct{}
+//	//colgen@NewTagView(Tag)
+type TagView struct{}
+
+type TagViewFull struct{}
+
+const example = "//colgen@NewTagView(Tag)"
+`
+
+	rules := []ReplaceRule{
+		{Find: "//colgen@NewTagView(Tag)", Replace: "type TagView struct{}"},
+		{Find: "//colgen@NewTagView(Tag,full)", Replace: "type TagViewFull struct{}"},
+	}
+
+	rl := NewReplacer()
+	got, err := rl.Apply([]byte(content), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("Apply() got = %s, want %s", got, want)
+	}
+
+	// rules order does not matter
+	slices.Reverse(rules)
+	got, err = rl.Apply([]byte(content), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != want {
+		t.Errorf("Apply() reversed got = %s, want %s", got, want)
+	}
+
+	// missing directive
+	if _, err = rl.Apply([]byte("package colgen\n"), rules); err == nil {
+		t.Errorf("Apply() expected error for missing directive")
+	}
+
+	// CRLF line endings are kept
+	crlf := func(s string) []byte { return []byte(strings.ReplaceAll(s, "\n", "\r\n")) }
+	rules = []ReplaceRule{{Find: "//colgen@NewTagView(Tag)", Replace: "type TagView struct {\n\tID int\n}"}}
+	got, err = rl.Apply(crlf("package colgen\n\n//colgen@NewTagView(Tag)\n\nvar x int\n"), rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := crlf("package colgen\n\ntype TagView struct {\n\tID int\n}\n\nvar x int\n"); string(got) != string(w) {
+		t.Errorf("Apply() CRLF got = %q, want %q", got, w)
+	}
+}
+
+func TestReplacer_GenerateDuplicates(t *testing.T) {
+	rl := NewReplacer()
+	_, err := rl.Generate([]string{"//colgen@NewCall(db)", "//colgen@NewCall(db)"})
+	if !errors.Is(err, ErrDuplicateRule) {
+		t.Errorf("Generate() error = %v, want %v", err, ErrDuplicateRule)
+	}
+}
+
+func TestReplacer_GenerateFieldOrder(t *testing.T) {
+	rl := NewReplacer()
+	if _, err := rl.UsePackageDir("testdata/fieldorder"); err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(rule, entity string) string {
+		t.Helper()
+		rr, err := rl.Generate([]string{rule})
+		if err != nil {
+			t.Fatalf("Generate(%s) error = %v", rule, err)
+		}
+
+		return strings.ReplaceAll(rr[0].Replace, entity, "User")
+	}
+
+	// source order: embedded fields are expanded in place, unexported fields don't affect output
+	source := generate("//colgen@newUserDTO(User,full)", "User")
+	if moved := generate("//colgen@newUserDTO(UserMoved,full,order=source)", "UserMoved"); moved != source {
+		t.Errorf("source order got = %v, want %v", moved, source)
+	}
+
+	want := "ID int \n    CreatedAt time.Time \n    UpdatedBy string \n    Name string \n    Email string \n"
+	if !strings.Contains(source, want) {
+		t.Errorf("source order got = %v, want fields %v", source, want)
+	}
+
+	// alpha order doesn't depend on positions of fields and embedded structs
+	alpha := generate("//colgen@newUserDTO(User,full,order=alpha)", "User")
+	if shuffled := generate("//colgen@newUserDTO(UserShuffled,full,order=alpha)", "UserShuffled"); shuffled != alpha {
+		t.Errorf("alpha order got = %v, want %v", shuffled, alpha)
+	}
+
+	want = "CreatedAt time.Time \n    Email string \n    ID int \n    Name string \n    UpdatedBy string \n"
+	if !strings.Contains(alpha, want) {
+		t.Errorf("alpha order got = %v, want fields %v", alpha, want)
+	}
+}
@@ -0,0 +1,188 @@
+package colgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// ResolvedRules are rules resolved against package types with methods to be generated, e.g. for code review tools.
+// JSON schema is stable: fields are only added, existing fields are not renamed or removed.
+type ResolvedRules struct {
+	Package  string           `json:"package"`
+	Entities []ResolvedEntity `json:"entities"`
+}
+
+// ResolvedEntity is a rule of entity with methods and functions to be generated in order of generation.
+type ResolvedEntity struct {
+	Name    string           `json:"name"`    // element struct, e.g. News
+	List    string           `json:"list"`    // collection type, e.g. NewsList
+	BaseGen bool             `json:"baseGen"` // collection type with IDs and Index by ID
+	Methods []ResolvedMethod `json:"methods"`
+
+	code []byte // generated code of entity
+}
+
+// ResolvedMethod is a generated method or function of entity rule.
+type ResolvedMethod struct {
+	Rule      string `json:"rule"`            // rule kind, e.g. Index, Field for field rule and Base for base generation
+	Field     string `json:"field,omitempty"` // rule field, e.g. CategoryID
+	Name      string `json:"name"`            // method or function name, e.g. IndexByCategoryID
+	Signature string `json:"signature"`       // e.g. func (ll NewsList) IndexByCategoryID() map[int]News
+}
+
+// ruleBase is a rule kind of methods from base generation.
+const ruleBase = "Base"
+
+// listSuffix is a suffix of collection methods named as entity fields, see SetRenameFieldMethods.
+const listSuffix = "List"
+
+// Resolve resolves rules against types of loaded package: entities and fields are looked up, prefix and optional
+// rules are resolved and methods to be generated are collected. Generated code is kept for Generate and not returned.
+func (g *Generator) Resolve(rules []Rule) (ResolvedRules, error) {
+	g.buf = bytes.Buffer{}
+	g.autoImports = nil
+	g.needEmptyErr = false
+	g.needPaginationMeta = false
+	g.generated = false
+	g.unused = nil
+	g.stats.Entities, g.stats.Methods = len(rules), nil
+
+	rr := ResolvedRules{Package: g.pkgName, Entities: make([]ResolvedEntity, 0, len(rules))}
+	for _, r := range rules {
+		e, err := g.resolveRule(r)
+		if err != nil {
+			return ResolvedRules{}, fmt.Errorf("%w: %s", err, r.EntityName)
+		}
+
+		rr.Entities = append(rr.Entities, e)
+	}
+
+	return rr, nil
+}
+
+// resolveRule generates code by Rule to Buffer and returns resolved entity with its code and methods.
+func (g *Generator) resolveRule(rule Rule) (ResolvedEntity, error) {
+	if err := g.checkEntity(rule.EntityName); err != nil {
+		return ResolvedEntity{}, err
+	}
+
+	t := g.lookupType(rule.EntityName)
+	fields := typeMapFromType(t, g.pkg.Types)
+	if len(fields) == 0 {
+		return ResolvedEntity{}, fmt.Errorf("%w: %s", ErrMissingType, rule.EntityName)
+	}
+
+	rc := newRuleContext(rule, t, fields)
+	re := ResolvedEntity{Name: rc.entity.Name, List: rc.entity.List, BaseGen: rule.BaseGen, Methods: []ResolvedMethod{}}
+	start := g.buf.Len()
+	if rule.BaseGen {
+		g.genBase(rc)
+		if err := g.checkFieldMethods(rc, start); err != nil {
+			return ResolvedEntity{}, err
+		}
+
+		re.Methods = append(re.Methods, funcDecls(g.buf.Bytes()[start:], ruleBase, "")...)
+	}
+
+	// process custom generation
+	for _, cr := range rule.CustomRules {
+		cr = resolvePrefixRule(cr, fields)
+		from := g.buf.Len()
+		err := g.generateCustomRule(rc, cr)
+		switch {
+		case errors.Is(err, errSkipRule):
+			continue
+		case err != nil:
+			return ResolvedEntity{}, err
+		}
+
+		if err = g.checkFieldMethods(rc, from); err != nil {
+			return ResolvedEntity{}, err
+		}
+
+		g.L()
+		g.count(ruleKind(cr))
+		re.Methods = append(re.Methods, funcDecls(g.buf.Bytes()[from:], ruleKind(cr), strings.TrimSuffix(cr.Field, "()"))...)
+	}
+
+	re.code = bytes.Clone(g.buf.Bytes()[start:])
+
+	return re, nil
+}
+
+// checkFieldMethods checks that collection methods generated to Buffer from offset are not named as entity fields,
+// e.g. Names() of Name rule and Names field. Such methods are renamed with List suffix by SetRenameFieldMethods.
+func (g *Generator) checkFieldMethods(rc *ruleContext, from int) error {
+	code := g.buf.Bytes()[from:]
+	renamed := false
+	for _, m := range funcDecls(code, "", "") {
+		if _, ok := rc.fields[m.Name]; !ok || !isListMethod(m.Signature, rc.entity.List) {
+			continue
+		}
+
+		newName := m.Name + listSuffix
+		if !g.renameMeth {
+			return fmt.Errorf("%w: %s() of %s and %s.%s, rename the field or use -rename-field-methods to generate %s()",
+				ErrFieldMethod, m.Name, rc.entity.List, rc.entity.Name, m.Name, newName)
+		}
+
+		g.logf("%s: %s() is renamed to %s(), %s has %s field", rc.rule.EntityName, m.Name, newName, rc.entity.Name, m.Name)
+		code, renamed = renameMethod(code, rc.entity.List, m.Name, newName), true
+	}
+
+	if renamed {
+		g.buf.Truncate(from)
+		g.buf.Write(code)
+	}
+
+	return nil
+}
+
+// isListMethod reports whether signature is a method of collection: `func (ll NewsList) IDs() []int`.
+func isListMethod(signature, list string) bool {
+	return strings.HasPrefix(signature, "func (ll "+list+")") || strings.HasPrefix(signature, "func (ll *"+list+")")
+}
+
+// renameMethod renames method of collection in generated code: declaration, doc comment and calls on ll.
+func renameMethod(code []byte, list, name, newName string) []byte {
+	re := regexp.MustCompile(`(func \(ll \*?` + regexp.QuoteMeta(list) + `\) |// |\bll\.)` + name + `\b`)
+	return re.ReplaceAll(code, []byte("${1}"+newName))
+}
+
+// funcDecls returns methods and functions declared in generated code of rule. Code that doesn't parse,
+// e.g. with invalid custom imports, has no methods: it is reported by Format.
+func funcDecls(code []byte, rule, field string) []ResolvedMethod {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", append([]byte("package p\n"), code...), parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	var r []ResolvedMethod
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		// signature only
+		sig := *fd
+		sig.Doc, sig.Body = nil, nil
+
+		var sb strings.Builder
+		if err := printer.Fprint(&sb, fset, &sig); err != nil {
+			continue
+		}
+
+		r = append(r, ResolvedMethod{Rule: rule, Field: field, Name: fd.Name.Name, Signature: sb.String()})
+	}
+
+	return r
+}
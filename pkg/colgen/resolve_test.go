@@ -0,0 +1,75 @@
+package colgen
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func TestGenerator_Resolve(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	require.NoError(t, err)
+
+	g := NewGenerator("colgen", "", "", "devel")
+	g.pkg = pkg
+
+	rules, err := ParseRules([]string{
+		"News,Tag",
+		"News:CategoryID,Index(CategoryID),Group(CategoryID),Len",
+		"Tag:UniqueName,Index(Slug)?,Sort(-OrderNumber,Name),MapP(db)",
+	}, false)
+	require.NoError(t, err)
+
+	rr, err := g.Resolve(rules)
+	require.NoError(t, err)
+
+	got, err := json.MarshalIndent(rr, "", "  ")
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "resolved.golden.json")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0600))
+	}
+
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got), "schema changes must be deliberate, run go test -update to update golden file")
+
+	// Generate consumes resolved rules
+	code, err := g.Generate(rules)
+	require.NoError(t, err)
+	for _, e := range rr.Entities {
+		assert.Contains(t, string(code), string(e.code))
+	}
+
+	_, err = g.Resolve([]Rule{{EntityName: "Missing", BaseGen: true}})
+	require.ErrorIs(t, err, ErrMissingType)
+}
+
+func TestFuncDecls(t *testing.T) {
+	code := []byte(`
+// IDs returns IDs.
+func (ll NewsList) IDs() []int {
+	return nil
+}
+
+type NewsIDTitlePair struct{ ID int }
+
+func NewNewsList(in []db.News) NewsList { return MapP(in, NewNews) }
+`)
+
+	assert.Equal(t, []ResolvedMethod{
+		{Rule: "Base", Name: "IDs", Signature: "func (ll NewsList) IDs() []int"},
+		{Rule: "Base", Name: "NewNewsList", Signature: "func NewNewsList(in []db.News) NewsList"},
+	}, funcDecls(code, "Base", ""))
+
+	assert.Empty(t, funcDecls([]byte("func {"), "Base", ""))
+}
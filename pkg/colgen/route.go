@@ -0,0 +1,45 @@
+package colgen
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// Output is a generated file of directives routed by RoutePrefix, e.g. `//colgen[heavy_colgen.go,!tinygo]:Analytics`.
+type Output struct {
+	Filename   string // generated file name in the same dir, e.g. heavy_colgen.go
+	Constraint string // build constraint expression of generated file, e.g. !tinygo
+}
+
+// CutRoute cuts route of directive line with RoutePrefix and returns output and the rest of line without prefix:
+// `//colgen[heavy_colgen.go,!tinygo]:Analytics,Event` => {heavy_colgen.go, !tinygo}, `Analytics,Event`.
+// File name must have _colgen.go suffix, constraint is optional and returned in canonical form.
+func CutRoute(line string) (Output, string, error) {
+	route, ok := strings.CutPrefix(strings.TrimSpace(line), RoutePrefix)
+	if !ok {
+		return Output{}, line, fmt.Errorf("%w: expected %s prefix", ErrUnknownLine, RoutePrefix)
+	}
+
+	route, rest, ok := strings.Cut(route, "]:")
+	if !ok {
+		return Output{}, line, fmt.Errorf("%w: %q, expected %s<file>[,<constraint>]]:<rules>", ErrUnknownLine, line, RoutePrefix)
+	}
+
+	filename, expr, _ := strings.Cut(route, ",")
+	out := Output{Filename: strings.TrimSpace(filename)}
+	if !strings.HasSuffix(out.Filename, "_colgen.go") || filepath.Base(out.Filename) != out.Filename {
+		return Output{}, line, fmt.Errorf("%w: output %q, expected file name with _colgen.go suffix in the same dir", ErrInvalidArg, out.Filename)
+	}
+
+	if expr = strings.TrimSpace(expr); expr != "" {
+		x, err := constraint.Parse("//go:build " + expr)
+		if err != nil {
+			return Output{}, line, fmt.Errorf("%w: build constraint %q: %w", ErrInvalidArg, expr, err)
+		}
+		out.Constraint = x.String()
+	}
+
+	return out, rest, nil
+}
@@ -0,0 +1,86 @@
+package colgen
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCutRoute(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    Output
+		rest    string
+		wantErr error
+	}{
+		{line: "//colgen[heavy_colgen.go,!tinygo]:Analytics,Event", want: Output{Filename: "heavy_colgen.go", Constraint: "!tinygo"}, rest: "Analytics,Event"},
+		{line: "//colgen[heavy_colgen.go]:Analytics:Index(UserID)", want: Output{Filename: "heavy_colgen.go"}, rest: "Analytics:Index(UserID)"},
+		{line: "//colgen[ heavy_colgen.go , linux&&(amd64||arm64) ]:Event", want: Output{Filename: "heavy_colgen.go", Constraint: "linux && (amd64 || arm64)"}, rest: "Event"},
+		{line: "//colgen[heavy_colgen.go,!tinygo]Analytics", wantErr: ErrUnknownLine},
+		{line: "//colgen[heavy.go]:Analytics", wantErr: ErrInvalidArg},
+		{line: "//colgen[sub/heavy_colgen.go]:Analytics", wantErr: ErrInvalidArg},
+		{line: "//colgen[heavy_colgen.go,!]:Analytics", wantErr: ErrInvalidArg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			out, rest, err := CutRoute(tt.line)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+			assert.Equal(t, tt.rest, rest)
+		})
+	}
+}
+
+func TestGenerator_BuildConstraint(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	require.NoError(t, err)
+
+	g := NewGenerator("colgen", "", "", "devel")
+	g.pkg = pkg
+	g.SetBuildConstraint("!tinygo")
+
+	rules, err := ParseRules([]string{"Tag", "Tag:Index(Name)"}, false)
+	require.NoError(t, err)
+
+	_, err = g.Generate(rules)
+	require.NoError(t, err)
+	data, err := g.Format()
+	require.NoError(t, err)
+
+	// constraint is the first line after gofmt and hash
+	lines := strings.Split(string(data), "\n")
+	require.Greater(t, len(lines), 3)
+	assert.Equal(t, "//go:build !tinygo", lines[0])
+	assert.Empty(t, lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], versionPrefix))
+	assert.True(t, strings.HasPrefix(lines[3], HashPrefix))
+	require.NoError(t, VerifyHash(data))
+
+	// go/build excludes file for tinygo
+	filename := filepath.Join(t.TempDir(), "heavy_colgen.go")
+	require.NoError(t, os.WriteFile(filename, data, 0600))
+
+	ok, err := MatchBuildTags(filename, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = MatchBuildTags(filename, []string{"tinygo"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// file with constraint is still detected as generated
+	renamed := filepath.Join(filepath.Dir(filename), "heavy.go")
+	require.NoError(t, os.Rename(filename, renamed))
+	assert.True(t, isGeneratedFile(renamed))
+}
@@ -0,0 +1,945 @@
+package colgen
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+)
+
+// errSkipRule is returned by rule handlers for rules that are already generated, e.g. the second Len.
+var errSkipRule = errors.New("rule is skipped")
+
+// ruleContext is a state of custom rules generation for one entity.
+type ruleContext struct {
+	rule    Rule
+	entity  Entity
+	typ     types.Object
+	fields  map[string]entityField
+	idField entityField
+	hasID   bool
+
+	// current custom rule with its field, name is a field or method name for func names, e.g. Slug for Slug()
+	cr   CustomRule
+	f    entityField
+	name string
+
+	hasApply, hasLen, hasSortable bool
+	pairs                         map[string]struct{} // generated Pairs by fields
+	sorts                         map[string]struct{} // keys of SortBy methods
+	excludes                      map[string]struct{} // suffixes of Exclude methods
+	indexes                       map[string]string   // IndexBy<Field> methods => rule, Index and IndexMultiPtr share names
+}
+
+// newRuleContext returns ruleContext for entity of rule.
+func newRuleContext(rule Rule, t types.Object, fields map[string]entityField) *ruleContext {
+	idField, hasID := fields[FieldID]
+
+	return &ruleContext{
+		rule:     rule,
+		entity:   NewEntity(rule.EntityName, rule.UseListSuffix),
+		typ:      t,
+		fields:   fields,
+		idField:  idField,
+		hasID:    hasID,
+		pairs:    make(map[string]struct{}),
+		sorts:    make(map[string]struct{}),
+		excludes: make(map[string]struct{}),
+		indexes:  make(map[string]string),
+	}
+}
+
+// data returns TemplateData of current rule field.
+func (rc *ruleContext) data() TemplateData {
+	return TemplateData{FieldType: rc.f.Type, FieldName: rc.cr.Field, Entity: rc.entity}
+}
+
+// ruleHandler generates code of current rule of ruleContext to Buffer.
+type ruleHandler func(g *Generator, rc *ruleContext) error
+
+// ruleHandlers are custom rule handlers grouped by kind. Field rule has empty name.
+var ruleHandlers = map[string]ruleHandler{
+	CustomRuleMap:                   (*Generator).mapRules,
+	CustomRuleMapP:                  (*Generator).mapRules,
+	strings.ToLower(CustomRuleMap):  (*Generator).mapRules,
+	strings.ToLower(CustomRuleMapP): (*Generator).mapRules,
+	CustomRuleUnique:                (*Generator).uniqueRules,
+	CustomRuleUniqueSorted:          (*Generator).uniqueRules,
+	CustomRuleGroupSorted:           (*Generator).uniqueRules,
+	CustomRuleDistinct:              (*Generator).uniqueRules,
+	CustomRuleMissing:               (*Generator).refRules,
+	CustomRuleAttach:                (*Generator).refRules,
+	CustomRuleField:                 (*Generator).pathRules,
+	CustomRuleFieldOr:               (*Generator).pathRules,
+	CustomRuleIndex:                 (*Generator).indexRules,
+	CustomRuleIndexMultiPtr:         (*Generator).indexRules,
+	CustomRuleIndexCI:               (*Generator).indexRules,
+	CustomRuleIndexInto:             (*Generator).indexRules,
+	CustomRuleSparse:                (*Generator).indexRules,
+	CustomRuleByField:               (*Generator).indexRules,
+	CustomRuleGroup:                 (*Generator).indexRules,
+	CustomRuleGroupInto:             (*Generator).indexRules,
+	CustomRuleFlatten:               (*Generator).nestedRules,
+	CustomRuleFlattenSelf:           (*Generator).nestedRules,
+	CustomRuleKeys:                  (*Generator).nestedRules,
+	CustomRuleValues:                (*Generator).nestedRules,
+	CustomRuleAssociate:             (*Generator).pairRules,
+	CustomRulePairs:                 (*Generator).pairRules,
+	CustomRulePivot:                 (*Generator).pairRules,
+	CustomRuleAvg:                   (*Generator).numericRules,
+	CustomRuleStdDev:                (*Generator).numericRules,
+	CustomRuleAccumulate:            (*Generator).numericRules,
+	CustomRuleDelta:                 (*Generator).numericRules,
+	CustomRuleLen:                   (*Generator).listRules,
+	CustomRuleSortable:              (*Generator).listRules,
+	CustomRuleSort:                  (*Generator).sortRules,
+	CustomRuleApply:                 (*Generator).listRules,
+	CustomRuleFirst:                 (*Generator).listRules,
+	CustomRuleLast:                  (*Generator).listRules,
+	CustomRulePaginate:              (*Generator).listRules,
+	CustomRuleHead:                  (*Generator).sliceRules,
+	CustomRuleTail:                  (*Generator).sliceRules,
+	CustomRuleRotate:                (*Generator).sliceRules,
+	CustomRuleShuffle:               (*Generator).sliceRules,
+	CustomRuleSynced:                (*Generator).sliceRules,
+	CustomRuleMerge:                 (*Generator).sliceRules,
+	CustomRuleJSON:                  (*Generator).entityRules,
+	CustomRuleWithField:             (*Generator).entityRules,
+	CustomRuleCacheKey:              (*Generator).entityRules,
+	CustomRuleHash:                  (*Generator).entityRules,
+	"":                              (*Generator).fieldRules,
+	CustomRuleAppend:                (*Generator).fieldRules,
+	CustomRuleExclude:               (*Generator).fieldRules,
+	CustomRulePartition:             (*Generator).fieldRules,
+	CustomRuleTakeWhile:             (*Generator).fieldRules,
+	CustomRuleDropWhile:             (*Generator).fieldRules,
+	CustomRuleSQLIn:                 (*Generator).fieldRules,
+}
+
+// generateCustomRule checks field of custom rule and generates it to Buffer.
+// Returns errSkipRule for missing field of optional rule.
+func (g *Generator) generateCustomRule(rc *ruleContext, cr CustomRule) error {
+	// check for good type and name, selector path of Field and FieldOr is checked by its first field
+	head, _, _ := strings.Cut(cr.Field, ".")
+	f, hasF := rc.fields[head]
+	if isMethodRef(cr.Field) {
+		if !slices.Contains(methodRules, cr.Name) {
+			return fmt.Errorf("%w: method %s is not supported by %s, expected %s", ErrInvalidArg, cr.Field, cr.Name, strings.Join(methodRules, ", "))
+		}
+
+		var err error
+		if f, hasF, err = methodField(rc.typ, cr.Field, g.pkg.Types); err != nil {
+			return err
+		}
+	}
+
+	if !hasF && hasField(cr.Name) {
+		if !cr.Optional {
+			return fmt.Errorf("%w: %s", ErrMissingField, cr.Field)
+		}
+
+		g.logf("skipping optional rule %s(%s): missing field in %s", cr.Name, cr.Field, rc.rule.EntityName)
+		return errSkipRule
+	}
+
+	if hasF && !f.IsExported && exportsField(cr.Name) && !g.unexported {
+		return fmt.Errorf("%w: %s(%s) for %s, use -allow-unexported to expose unexported fields", ErrUnexported, cr.Name, cr.Field, rc.rule.EntityName)
+	}
+
+	// field type from another package requires import, e.g. map[domain.Status]News
+	if usesFieldType(cr.Name) {
+		g.addImports(f.Imports)
+	}
+
+	rc.cr, rc.f, rc.name = cr, f, strings.TrimSuffix(cr.Field, "()")
+	if handler, ok := ruleHandlers[cr.Name]; ok {
+		return handler(g, rc)
+	}
+
+	return nil
+}
+
+// mapRules generates Map and MapP rules to Buffer. Lowercase rules generate private functions.
+func (g *Generator) mapRules(rc *ruleContext) error {
+	cr := rc.cr
+	if err := g.checkMapFunc(cr.Name); err != nil {
+		return err
+	}
+
+	input, err := g.mapInput(cr, rc.rule.EntityName)
+	if err != nil {
+		return err
+	}
+
+	data := TemplateData{FieldType: input, Entity: rc.entity}
+	switch cr.Name {
+	case strings.ToLower(CustomRuleMap):
+		g.genMap(CustomRuleMap, data, true, rc.rule.BaseGen)
+	case strings.ToLower(CustomRuleMapP):
+		g.genMap(CustomRuleMapP, data, true, rc.rule.BaseGen)
+	default:
+		g.genMap(cr.Name, data, false, rc.rule.BaseGen)
+	}
+
+	return nil
+}
+
+// mapInput returns input element type of Map or MapP rule: package arg is suffixed by entity, e.g. db => db.News.
+// Interface of the package or of its imports, e.g. MapP(Row) or MapP(db.Row), is used as is. Constructor of entity
+// must accept the interface for Map and pointer to it for MapP, it is checked if constructor is declared in the package.
+func (g *Generator) mapInput(cr CustomRule, entity string) (string, error) {
+	obj, iface := g.lookupQualified(cr.Arg), false
+	if obj != nil {
+		_, isType := obj.(*types.TypeName)
+		iface = isType && types.IsInterface(obj.Type())
+	}
+
+	switch {
+	case !iface && !strings.Contains(cr.Arg, "."):
+		return cr.Arg + "." + entity, nil
+	case !iface:
+		return cr.Arg, nil
+	}
+
+	if obj.Pkg() != g.pkg.Types {
+		g.addImport(obj.Pkg().Path())
+	}
+
+	prefix := "New"
+	if cr.Name == strings.ToLower(cr.Name) {
+		prefix = "new"
+	}
+
+	fn, ok := g.lookupType(prefix + entity).(*types.Func)
+	if !ok {
+		return cr.Arg, nil
+	}
+
+	want := obj.Type()
+	if isMapPointer(cr.Name) {
+		want = types.NewPointer(want)
+	}
+
+	params := fn.Type().(*types.Signature).Params()
+	if params.Len() != 1 || !types.Identical(params.At(0).Type(), want) {
+		return "", fmt.Errorf("%w: %s%s must accept %s for %s(%s)", ErrMapFunc, prefix, entity, types.TypeString(want, types.RelativeTo(g.pkg.Types)), cr.Name, cr.Arg)
+	}
+
+	return cr.Arg, nil
+}
+
+// isMapPointer checks that rule is MapP or mapp: constructor accepts pointer to input element.
+func isMapPointer(rule string) bool {
+	return strings.EqualFold(rule, CustomRuleMapP)
+}
+
+// uniqueRules generates Unique, UniqueSorted, GroupSorted and Distinct rules to Buffer.
+func (g *Generator) uniqueRules(rc *ruleContext) error {
+	cr, f := rc.cr, rc.f
+	elemType, slice := strings.CutPrefix(f.Type, "[]")
+	switch cr.Name {
+	case CustomRuleUnique:
+		return g.uniqueRule(rc)
+	case CustomRuleDistinct:
+		g.genDistinct(TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity}, slice)
+		return nil
+	case CustomRuleGroupSorted:
+		elemType, slice = f.Type, false
+	}
+
+	sortBy, ok := g.sortStmt(f.typ, slice)
+	if !ok {
+		return fmt.Errorf("%w: %s must be ordered or time.Time for %s", ErrFieldType, cr.Field, cr.Name)
+	}
+
+	data := TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity, Args: sortBy}
+	if cr.Name == CustomRuleGroupSorted {
+		g.genGroupSorted(data)
+	} else {
+		g.genUniqueSorted(data, slice)
+	}
+
+	return nil
+}
+
+// refRules generates Missing and Attach rules of fields referencing IDs of another entity of package to Buffer.
+func (g *Generator) refRules(rc *ruleContext) error {
+	if rc.cr.Name == CustomRuleAttach {
+		return g.attachRule(rc)
+	}
+
+	// Missing: elements of field, or field itself, must have the same type as ID of referenced entity
+	cr, f := rc.cr, rc.f
+	rule := fmt.Sprintf("%s(%s,%s)", cr.Name, cr.Field, cr.Arg)
+	id, err := g.refID(cr.Arg, rule)
+	if err != nil {
+		return err
+	}
+
+	elemType, slice := strings.CutPrefix(f.Type, "[]")
+	if !isRefKey(f.typ, slice, id) {
+		return fmt.Errorf("%w: %s must be %s or []%s as %s.%s for %s", ErrFieldType, cr.Field, id.Type, id.Type, cr.Arg, FieldID, rule)
+	}
+
+	list := NewEntity(cr.Arg, rc.rule.UseListSuffix).List
+	g.genMissing(TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity, Args: list, Param: firsRuneToLower(list)}, slice)
+
+	return nil
+}
+
+// attachRule generates Attach rule to Buffer: target field of entity, []entity or pointers is filled from collection
+// by key field, e.g. Attach(Tags,TagIDs,Tags) fills Tags []Tag by TagIDs []int.
+func (g *Generator) attachRule(rc *ruleContext) error {
+	cr, f := rc.cr, rc.f
+	key, list, _ := strings.Cut(cr.Arg, ",")
+	rule := fmt.Sprintf("%s(%s,%s)", cr.Name, cr.Field, cr.Arg)
+	kf, ok := rc.fields[key]
+	if !ok {
+		return fmt.Errorf("%w: %s for %s", ErrMissingField, key, rule)
+	}
+
+	// element of target field is an entity of package: Tag of []Tag or []*Tag, Category of Category or *Category
+	elem, slice := f.typ, false
+	if s, ok := elem.(*types.Slice); ok {
+		elem, slice = s.Elem(), true
+	}
+	p, ptr := elem.(*types.Pointer)
+	if ptr {
+		elem = p.Elem()
+	}
+	named, ok := elem.(*types.Named)
+	if !ok || named.Obj().Pkg() != g.pkg.Types {
+		return fmt.Errorf("%w: %s must be entity of package, slice of entities or pointers for %s", ErrFieldType, cr.Field, rule)
+	}
+
+	entity := named.Obj().Name()
+	if want := NewEntity(entity, rc.rule.UseListSuffix).List; list != want {
+		return fmt.Errorf("%w: %s for %s, collection of %s is %s", ErrInvalidArg, list, rule, entity, want)
+	}
+
+	id, err := g.refID(entity, rule)
+	if err != nil {
+		return err
+	}
+
+	if !isRefKey(kf.typ, slice, id) {
+		keyType := id.Type
+		if slice {
+			keyType = "[]" + keyType
+		}
+
+		return fmt.Errorf("%w: %s must be %s as %s.%s for %s", ErrFieldType, key, keyType, entity, FieldID, rule)
+	}
+
+	g.addImports(kf.Imports)
+	param := firsRuneToLower(list)
+	data := TemplateData{FieldType: entity, FieldName: cr.Field, Entity: rc.entity, IDType: id.Type, ValueName: key, Args: list, Param: param, Ref: param + "[i]"}
+	if ptr {
+		data.FieldType, data.Ref = "*"+entity, "&"+data.Ref
+	}
+	g.genAttach(data, slice)
+
+	return nil
+}
+
+// refID returns ID field of entity of package referenced by rule, e.g. Tag of Missing(TagIDs,Tag).
+func (g *Generator) refID(entity, rule string) (entityField, error) {
+	fields := typeMapFromType(g.lookupType(entity), g.pkg.Types)
+	if len(fields) == 0 {
+		return entityField{}, fmt.Errorf("%w: %s for %s", ErrMissingType, entity, rule)
+	}
+
+	id, ok := fields[FieldID]
+	if !ok || id.typ == nil {
+		return entityField{}, fmt.Errorf("%w: %s.%s for %s", ErrMissingField, entity, FieldID, rule)
+	}
+
+	return id, nil
+}
+
+// isRefKey checks that type of field, or its elements for slice, is comparable type of referenced ID.
+func isRefKey(typ types.Type, slice bool, id entityField) bool {
+	if s, ok := typ.(*types.Slice); ok && slice {
+		typ = s.Elem()
+	} else if slice {
+		return false
+	}
+
+	return typ != nil && types.Identical(typ, id.typ) && types.Comparable(typ)
+}
+
+// pathRules generates Field and FieldOr rules of selector path through nested fields to Buffer, e.g. Author.Company.Name.
+// Pointer fields of path are checked for nil: Field skips such elements, FieldOr uses default value for them.
+func (g *Generator) pathRules(rc *ruleContext) error {
+	cr := rc.cr
+	hops := strings.Split(cr.Field, ".")
+	rule := cr.Name + "(" + cr.Field + ")"
+
+	var nilChecks, nilHops []string
+	typ, sel := rc.f.typ, "ll[i]."+hops[0]
+	for _, h := range hops[1:] {
+		elem := typ
+		p, ptr := elem.(*types.Pointer)
+		if ptr {
+			elem = p.Elem()
+			nilChecks, nilHops = append(nilChecks, sel+" == nil"), append(nilHops, strings.TrimPrefix(sel, "ll[i]."))
+		}
+
+		v := structField(elem, h)
+		if v == nil && cr.Optional {
+			g.logf("skipping optional rule %s: missing field %s in %s", rule, h, rc.rule.EntityName)
+			return errSkipRule
+		} else if v == nil {
+			return fmt.Errorf("%w: %s of %s for %s", ErrMissingField, h, strings.TrimPrefix(sel, "ll[i]."), rule)
+		}
+		if !v.Exported() && !g.unexported {
+			return fmt.Errorf("%w: %s for %s, use -allow-unexported to expose unexported fields", ErrUnexported, h, rule)
+		}
+
+		typ, sel = v.Type(), sel+"."+h
+	}
+
+	fieldType, imports := qualifiedType(typ, g.pkg.Types)
+	g.addImports(imports)
+	data := TemplateData{FieldType: fieldType, FieldName: cr.Field, Entity: rc.entity, Key: strings.Join(nilChecks, " || "), Value: strings.Join(nilHops, " or ")}
+	if cr.Name == CustomRuleField {
+		g.genPath(data)
+		return nil
+	}
+
+	if len(nilChecks) == 0 {
+		return fmt.Errorf("%w: %s has no pointer fields, default value %s of %s is never used", ErrInvalidArg, cr.Field, cr.Arg, cr.Name)
+	}
+
+	value, err := defaultValue(typ, cr.Arg, g.pkg.Types)
+	if err != nil {
+		return fmt.Errorf("%w for %s", err, rule)
+	}
+
+	data.Args = value
+	g.genPathOr(data)
+
+	return nil
+}
+
+// structField returns direct field of struct type by name or nil.
+func structField(typ types.Type, name string) *types.Var {
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+
+	for i := range st.NumFields() {
+		if v := st.Field(i); v.Name() == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// defaultValue checks that value is a typed literal or constant of package assignable to typ: quoted string for
+// strings, integer for integers, number for floats, true or false for booleans, e.g. "unknown" or -1.
+func defaultValue(typ types.Type, value string, pkg *types.Package) (string, error) {
+	tv, err := types.Eval(token.NewFileSet(), pkg, token.NoPos, value)
+	if err != nil || tv.Value == nil && !tv.IsNil() {
+		return "", fmt.Errorf("%w: default value %s is not a literal or constant", ErrInvalidArg, value)
+	}
+
+	ok := types.AssignableTo(tv.Type, typ)
+	if b, isBasic := typ.Underlying().(*types.Basic); isBasic && tv.Value != nil {
+		switch k := tv.Value.Kind(); {
+		case b.Info()&types.IsString != 0:
+			ok = ok && k == constant.String
+		case b.Info()&types.IsInteger != 0:
+			ok = ok && constant.ToInt(tv.Value).Kind() == constant.Int
+		case b.Info()&types.IsFloat != 0:
+			ok = ok && (k == constant.Int || k == constant.Float)
+		case b.Info()&types.IsBoolean != 0:
+			ok = ok && k == constant.Bool
+		}
+	}
+
+	if !ok {
+		return "", fmt.Errorf("%w: default value %s is not %s", ErrFieldType, value, types.TypeString(typ, types.RelativeTo(pkg)))
+	}
+
+	return value, nil
+}
+
+// uniqueRule generates Unique rule with optional modifiers to Buffer.
+func (g *Generator) uniqueRule(rc *ruleContext) error {
+	cr, f := rc.cr, rc.f
+	elemType, slice := strings.CutPrefix(f.Type, "[]")
+	switch {
+	case cr.Arg != "":
+		if !isStringField(f.typ, slice) {
+			return fmt.Errorf("%w: %s must be string for %s(%s,%s)", ErrFieldType, cr.Field, cr.Name, cr.Field, cr.Arg)
+		}
+
+		g.genUniqueNormalized(TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity}, strings.Split(cr.Arg, ","), slice)
+	case isByteSlice(f.typ):
+		// []byte is not comparable: values are keyed by string(v)
+		g.genUniqueBytes(rc.data())
+	default:
+		if err := checkComparable(f, slice, cr.Name); err != nil {
+			return err
+		}
+
+		if slice {
+			g.genUniqueFieldSlice(TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity})
+		} else {
+			g.genUniqueField(rc.data())
+		}
+	}
+
+	return nil
+}
+
+// indexRules generates Index, IndexMultiPtr, IndexCaseInsensitive, IndexInto, Sparse, ByField, Group and GroupInto rules to Buffer.
+func (g *Generator) indexRules(rc *ruleContext) error {
+	cr, f, data := rc.cr, rc.f, rc.data()
+	switch cr.Name {
+	case CustomRuleIndex, CustomRuleByField: // ByField is an alias of Index without prefix of func name
+		data.FuncName = "By" + rc.name
+		if cr.Name == CustomRuleIndex {
+			if err := checkIndexName(rc.indexes, cr, rc.name); err != nil {
+				return err
+			}
+
+			data.FuncName = CustomRuleIndex + data.FuncName
+		}
+
+		if isByteSlice(f.typ) {
+			data.FieldType = ""
+			g.genIndexBytes(data)
+			return nil
+		}
+
+		ptr, err := pointerKey(rc, &data)
+		switch {
+		case err != nil:
+			return err
+		case ptr:
+			g.genIndexPtr(data)
+			return nil
+		}
+
+		if err = checkComparable(f, false, cr.Name); err != nil {
+			return err
+		}
+
+		g.genIndex(data)
+	case CustomRuleIndexMultiPtr:
+		if err := checkIndexName(rc.indexes, cr, rc.name); err != nil {
+			return err
+		}
+
+		data.FuncName = "By" + cr.Field
+		g.genIndexMultiPtr(data)
+	case CustomRuleIndexCI:
+		if f.Type != "string" {
+			return fmt.Errorf("%w: %s must be string for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		g.genIndexCI(TemplateData{FieldName: cr.Field, Entity: rc.entity})
+	case CustomRuleIndexInto:
+		data.FuncName = CustomRuleIndex + "By" + cr.Field
+		if cr.Field == FieldID {
+			data.FuncName = CustomRuleIndex
+		}
+
+		if err := checkIntoKey(rc); err != nil {
+			return err
+		}
+
+		g.genIndexInto(data)
+	case CustomRuleSparse:
+		zero, ok := zeroExpr(f)
+		if !ok {
+			return fmt.Errorf("%w: %s must be comparable for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		data.Args = zero
+		g.genSparse(data)
+	case CustomRuleGroup:
+		if f.IsBool {
+			g.logf("%s: Group(%s) by bool field, consider Partition(%s) returning two collections", rc.rule.EntityName, cr.Field, cr.Field)
+		}
+
+		data.FuncName = "By" + rc.name
+		ptr, err := pointerKey(rc, &data)
+		if err != nil {
+			return err
+		}
+
+		if ptr {
+			g.genGroupPtr(data)
+			return nil
+		}
+
+		if err = checkComparable(f, false, cr.Name); err != nil {
+			return err
+		}
+
+		g.genGroup(data)
+	case CustomRuleGroupInto:
+		if err := checkIntoKey(rc); err != nil {
+			return err
+		}
+
+		data.FuncName = "By" + cr.Field
+		g.genGroupInto(data)
+	}
+
+	return nil
+}
+
+// pointerKey checks key field of Index or Group and reports whether it is a pointer: data is keyed by pointed-to value
+// with nil mode of rule, skipnil by default. Nil mode of non-pointer field is an error.
+func pointerKey(rc *ruleContext, data *TemplateData) (bool, error) {
+	cr, f := rc.cr, rc.f
+	ptr, ok := f.typ.(*types.Pointer)
+	if !ok || !strings.HasPrefix(f.Type, "*") {
+		if cr.Arg != "" {
+			return false, fmt.Errorf("%w: %s must be pointer for %s(%s,%s)", ErrFieldType, cr.Field, cr.Name, cr.Field, cr.Arg)
+		}
+
+		return false, nil
+	}
+
+	if !types.Comparable(ptr.Elem()) {
+		return false, fmt.Errorf("%w: %s must point to comparable type for %s", ErrFieldType, cr.Field, cr.Name)
+	}
+
+	data.FieldType, data.Args = strings.TrimPrefix(f.Type, "*"), cmp.Or(cr.Arg, NilSkip)
+	return true, nil
+}
+
+// checkIntoKey checks key field of IndexInto and GroupInto. Unlike Index and Group, dst is keyed by field itself,
+// so []byte and pointer fields keyed by value in Index and Group are rejected and key must be comparable.
+func checkIntoKey(rc *ruleContext) error {
+	cr, f := rc.cr, rc.f
+	if isByteSlice(f.typ) || strings.HasPrefix(f.Type, "*") {
+		return fmt.Errorf("%w: %s of %s type is not supported by %s, use %s(%s) keyed by value instead",
+			ErrFieldType, cr.Field, f.Type, cr.Name, strings.TrimSuffix(cr.Name, "Into"), cr.Field)
+	}
+
+	return checkComparable(f, false, cr.Name)
+}
+
+// nestedRules generates Flatten, FlattenSelf, Keys and Values rules of slice and map fields to Buffer.
+func (g *Generator) nestedRules(rc *ruleContext) error {
+	cr, f := rc.cr, rc.f
+	switch {
+	case cr.Name == CustomRuleKeys || cr.Name == CustomRuleValues:
+		if !f.IsMap {
+			return fmt.Errorf("%w: %s must be map for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		m := f.typ.Underlying().(*types.Map)
+		elem := m.Key()
+		if cr.Name == CustomRuleValues {
+			elem = m.Elem()
+		}
+
+		elemType, imports := qualifiedType(elem, g.pkg.Types)
+		g.addImports(imports)
+		if cr.Name == CustomRuleKeys {
+			g.genMapKeys(TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity})
+		} else {
+			g.genMapValues(TemplateData{FieldType: elemType, FieldName: cr.Field, Entity: rc.entity})
+		}
+	case isSelfSlice(f, rc.typ):
+		g.genFlattenSelf(TemplateData{FieldName: cr.Field, Entity: rc.entity})
+	case cr.Name == CustomRuleFlattenSelf:
+		return fmt.Errorf("%w: %s must be []%s for %s", ErrFieldType, cr.Field, rc.rule.EntityName, cr.Name)
+	case strings.HasPrefix(f.Type, "[][]"):
+		g.genFlattenDeep(TemplateData{FieldType: strings.TrimPrefix(f.Type, "[][]"), FieldName: cr.Field, Entity: rc.entity})
+	case strings.HasPrefix(f.Type, "[]"):
+		g.genFlatten(TemplateData{FieldType: strings.TrimPrefix(f.Type, "[]"), FieldName: cr.Field, Entity: rc.entity})
+	default:
+		return fmt.Errorf("%w: %s must be slice for %s", ErrFieldType, cr.Field, cr.Name)
+	}
+
+	return nil
+}
+
+// pairRules generates Associate, Pairs and Pivot rules of key and value fields to Buffer.
+// Pairs of the same fields from several lines are generated once with its pair type.
+func (g *Generator) pairRules(rc *ruleContext) error {
+	cr, f := rc.cr, rc.f
+	vf, ok := rc.fields[cr.Arg]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMissingField, cr.Arg)
+	}
+	if !vf.IsExported && !g.unexported {
+		return fmt.Errorf("%w: %s(%s,%s) for %s, use -allow-unexported to expose unexported fields", ErrUnexported, cr.Name, cr.Field, cr.Arg, rc.rule.EntityName)
+	}
+	if cr.Name != CustomRulePairs && (f.typ == nil || !types.Comparable(f.typ)) {
+		return fmt.Errorf("%w: %s must be comparable for %s", ErrFieldType, cr.Field, cr.Name)
+	}
+
+	g.addImports(vf.Imports)
+	data := rc.data()
+	data.Args, data.ValueName = vf.Type, cr.Arg
+	switch cr.Name {
+	case CustomRuleAssociate:
+		g.genAssociate(data)
+	case CustomRulePivot:
+		g.genPivot(data)
+	default:
+		key := cr.Field + "," + cr.Arg
+		if _, ok := rc.pairs[key]; ok {
+			return errSkipRule
+		}
+
+		g.genPairs(data)
+		rc.pairs[key] = struct{}{}
+	}
+
+	return nil
+}
+
+// numericRules generates Avg, StdDev, Accumulate and Delta rules of numeric fields to Buffer.
+func (g *Generator) numericRules(rc *ruleContext) error {
+	cr, f, data := rc.cr, rc.f, rc.data()
+	switch cr.Name {
+	case CustomRuleAvg, CustomRuleStdDev:
+		if !isRealField(rc.typ, cr.Field) {
+			return fmt.Errorf("%w: %s must be integer or float for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		data.FieldType = ""
+		if cr.Name == CustomRuleStdDev {
+			g.genStdDev(data)
+		} else {
+			g.genAvg(data)
+		}
+	case CustomRuleAccumulate:
+		if !isNumericField(rc.typ, cr.Field) {
+			return fmt.Errorf("%w: %s must be numeric for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		g.genAccumulate(data)
+	case CustomRuleDelta:
+		if !rc.hasID {
+			return fmt.Errorf("%w: %s for %s", ErrMissingField, FieldID, cr.Name)
+		}
+		if !isNumericField(rc.typ, cr.Field) || hasBasicInfo(f.typ, types.IsUnsigned) {
+			return fmt.Errorf("%w: %s must be signed numeric for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		g.addImports(rc.idField.Imports)
+		data.IDType = rc.idField.Type
+		g.genDelta(data)
+	}
+
+	return nil
+}
+
+// listRules generates Len, Sortable, Apply, First, Last and Paginate rules to Buffer.
+// Len is generated once: by Len or by Sortable bundle.
+func (g *Generator) listRules(rc *ruleContext) error {
+	cr, e := rc.cr, rc.entity
+	switch cr.Name {
+	case CustomRuleLen:
+		// Len might be already generated by Sortable
+		if rc.hasLen {
+			return errSkipRule
+		}
+
+		g.genLen(TemplateData{Entity: e})
+		rc.hasLen = true
+	case CustomRuleSortable:
+		if rc.hasSortable {
+			return fmt.Errorf("%w: %s(%s) for %s", ErrDuplicateRule, cr.Name, cr.Field, rc.rule.EntityName)
+		}
+
+		less, ok := lessExpr(rc.typ, cr.Field)
+		if !ok {
+			return fmt.Errorf("%w: %s must be ordered for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		// Sortable(Name) is a bundle of Len, Swap and Less
+		if !rc.hasLen {
+			g.genLen(TemplateData{Entity: e})
+			g.L()
+			rc.hasLen = true
+		}
+
+		g.genSortable(TemplateData{FieldName: cr.Field, Entity: e, Args: less})
+		rc.hasSortable = true
+	case CustomRuleApply:
+		// Apply(fn) is generated once for all functions
+		if !rc.hasApply {
+			g.genApply(TemplateData{Entity: e, Args: applyFuncs(rc.rule.CustomRules)})
+			g.L()
+			rc.hasApply = true
+		}
+
+		g.genApplyFunc(TemplateData{Entity: e, FuncName: cr.Arg[strings.LastIndex(cr.Arg, ".")+1:], Args: cr.Arg})
+		if pkgName, _, ok := strings.Cut(cr.Arg, "."); ok {
+			g.addPackageImport(pkgName)
+		}
+	case CustomRuleFirst, CustomRuleLast:
+		idx := "0"
+		if cr.Name == CustomRuleLast {
+			idx = "len(ll)-1"
+		}
+
+		g.genFirstLast(TemplateData{FuncName: cr.Name, Entity: e, Args: idx})
+		g.needEmptyErr = true
+	case CustomRulePaginate:
+		g.genPaginate(TemplateData{Entity: e})
+		g.needPaginationMeta = true
+	}
+
+	return nil
+}
+
+// sortRules generates Sort rule with stable multi-key comparison to Buffer, e.g. Sort(-CreatedAt,ID).
+func (g *Generator) sortRules(rc *ruleContext) error {
+	cr := rc.cr
+	keys := strings.Split(cr.Arg, ",")
+	exprs, desc := make([]string, 0, len(keys)), make([]string, 0, len(keys))
+
+	var name strings.Builder
+	for _, key := range keys {
+		field, isDesc := strings.CutPrefix(key, "-")
+		f, ok := rc.fields[field]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrMissingField, field)
+		}
+
+		expr, ok := g.compareExpr(f, field, isDesc)
+		if !ok {
+			return fmt.Errorf("%w: %s must be ordered or time.Time for %s", ErrFieldType, field, cr.Name)
+		}
+
+		exprs = append(exprs, expr)
+		name.WriteString(field)
+		if isDesc {
+			name.WriteString("Desc")
+			field += " in descending order"
+		}
+		desc = append(desc, field)
+	}
+
+	if _, ok := rc.sorts[name.String()]; ok {
+		return fmt.Errorf("%w: %s(%s) for %s", ErrDuplicateRule, cr.Name, cr.Arg, rc.rule.EntityName)
+	}
+	rc.sorts[name.String()] = struct{}{}
+
+	g.genSort(TemplateData{Entity: rc.entity, FuncName: name.String(), Args: compareFunc(exprs), Value: strings.Join(desc, ", then by ")})
+	return nil
+}
+
+// sliceRules generates Head, Tail, Rotate, Shuffle, Synced and Merge rules to Buffer.
+func (g *Generator) sliceRules(rc *ruleContext) error {
+	data := TemplateData{Entity: rc.entity}
+	switch rc.cr.Name {
+	case CustomRuleHead:
+		g.genHeadRule(data)
+	case CustomRuleTail:
+		g.genTailRule(data)
+	case CustomRuleRotate:
+		g.genRotate(data)
+	case CustomRuleShuffle:
+		g.genShuffle(data)
+	case CustomRuleSynced:
+		// Get by ID requires comparable ID
+		if rc.hasID && rc.idField.typ != nil && types.Comparable(rc.idField.typ) {
+			g.addImports(rc.idField.Imports)
+			data.IDType = rc.idField.Type
+		}
+
+		g.genSynced(data)
+	case CustomRuleMerge:
+		if !rc.hasID {
+			return fmt.Errorf("%w: %s for %s", ErrMissingField, FieldID, rc.cr.Name)
+		}
+		if err := checkComparable(rc.idField, false, rc.cr.Name); err != nil {
+			return err
+		}
+
+		g.addImports(rc.idField.Imports)
+		data.IDType = rc.idField.Type
+		g.genMerge(data)
+	}
+
+	return nil
+}
+
+// entityRules generates methods of entity: With<Field>, CacheKey and Hash, and JSON rule of collection to Buffer.
+func (g *Generator) entityRules(rc *ruleContext) error {
+	e := rc.entity
+	recv := receiverName(e.Name)
+	switch rc.cr.Name {
+	case CustomRuleJSON:
+		g.genJSON(TemplateData{Entity: e})
+	case CustomRuleWithField:
+		param := "v"
+		if recv == param {
+			param = "val"
+		}
+
+		data := rc.data()
+		data.FuncName, data.Args = recv, param
+		g.genWithField(data)
+	case CustomRuleCacheKey:
+		g.genCacheKey(TemplateData{Entity: e, FuncName: recv, Args: cacheKeyArgs(rc.typ, e.Name, recv)})
+	case CustomRuleHash:
+		g.genHash(TemplateData{Entity: e, FuncName: recv, Args: hashWrites(rc.typ, recv)})
+	}
+
+	return nil
+}
+
+// fieldRules generates field values and Append, Exclude, Partition, TakeWhile, DropWhile and SQLIn rules to Buffer.
+func (g *Generator) fieldRules(rc *ruleContext) error {
+	cr, f, data := rc.cr, rc.f, rc.data()
+	switch cr.Name {
+	case "":
+		g.genField(data)
+	case CustomRuleAppend:
+		g.genAppendField(data)
+	case CustomRuleExclude:
+		if _, ok := rc.excludes[cr.Suffix]; ok {
+			return fmt.Errorf("%w: %s%s for %s", ErrDuplicateRule, cr.Name, cr.Suffix, rc.rule.EntityName)
+		}
+		rc.excludes[cr.Suffix] = struct{}{}
+
+		values, err := literalValues(f, strings.Split(cr.Arg, ","))
+		if err != nil {
+			return err
+		}
+
+		data.FuncName, data.Args = cr.Name+cr.Suffix, strings.Join(values, ", ")
+		g.genExclude(data)
+	case CustomRulePartition:
+		if !f.IsBool {
+			return fmt.Errorf("%w: %s must be bool for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		g.genPartition(TemplateData{FieldName: cr.Field, Entity: rc.entity})
+	case CustomRuleTakeWhile, CustomRuleDropWhile:
+		if f.typ == nil || !types.Comparable(f.typ) {
+			return fmt.Errorf("%w: %s must be comparable for %s", ErrFieldType, cr.Field, cr.Name)
+		}
+
+		if cr.Arg != "" {
+			g.logf("%s: value %s of %s(%s,%s) is ignored, it is passed to %s%s(v) instead", rc.rule.EntityName, cr.Arg, cr.Name, cr.Field, cr.Arg, cr.Name, cr.Field)
+		}
+
+		g.genWhile(data, cr.Name == CustomRuleDropWhile)
+	case CustomRuleSQLIn:
+		g.genSQLIn(TemplateData{FieldName: cr.Field, Entity: rc.entity, Args: cr.Arg})
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package colgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ErrInvalidTests is returned by AppendTests if tests from assistant can't be appended to existing test file.
+var ErrInvalidTests = errors.New("invalid tests")
+
+// AppendTests appends tests returned by assistant in append mode to existing test file and returns it formatted with gofmt.
+// Tests can be a full file or declarations only. Markdown code fence and package clause of tests are removed,
+// imports of tests are merged into import block of existing file. Package of tests must be the same as of existing file.
+func AppendTests(existing, tests []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	ef, err := parser.ParseFile(fset, "", existing, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parse test file: %w", err)
+	}
+
+	// declarations only: package clause of existing file is added for parsing
+	src := []byte(trimCodeFence(string(tests)))
+	if _, err = parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly); err != nil {
+		src = append([]byte("package "+ef.Name.Name+"\n\n"), src...)
+	}
+
+	tf, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTests, err)
+	}
+
+	if tf.Name.Name != ef.Name.Name {
+		return nil, fmt.Errorf("%w: package %s, want %s", ErrInvalidTests, tf.Name.Name, ef.Name.Name)
+	}
+
+	for _, imp := range tf.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			astutil.AddNamedImport(fset, ef, imp.Name.Name, path)
+		} else {
+			astutil.AddImport(fset, ef, path)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = format.Node(&buf, fset, ef); err != nil {
+		return nil, fmt.Errorf("format test file: %w", err)
+	}
+
+	if decls := declsSource(fset, tf, src); len(decls) > 0 {
+		buf.WriteString("\n")
+		buf.Write(decls)
+	}
+
+	r, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTests, err)
+	}
+
+	return r, nil
+}
+
+// declsSource returns source of declarations of f except imports, starting from doc comment of the first declaration.
+func declsSource(fset *token.FileSet, f *ast.File, src []byte) []byte {
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+
+		pos := d.Pos()
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+		}
+
+		return bytes.TrimSpace(src[fset.Position(pos).Offset:])
+	}
+
+	return nil
+}
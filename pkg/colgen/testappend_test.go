@@ -0,0 +1,110 @@
+package colgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendTests(t *testing.T) {
+	const existing = `package news
+
+import (
+	"testing"
+)
+
+// TestNewsList_IDs checks IDs.
+func TestNewsList_IDs(t *testing.T) {
+	t.Log("ids")
+}
+`
+
+	tests := []struct {
+		name    string
+		tests   string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "full file",
+			tests: "```go\npackage news\n\nimport (\n\t\"testing\"\n\n\t\"github.com/stretchr/testify/assert\"\n)\n\n" +
+				"// TestNewsList_Index checks Index.\nfunc TestNewsList_Index(t *testing.T) {\n\tassert.True(t, true)\n}\n```",
+			want: `package news
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestNewsList_IDs checks IDs.
+func TestNewsList_IDs(t *testing.T) {
+	t.Log("ids")
+}
+
+// TestNewsList_Index checks Index.
+func TestNewsList_Index(t *testing.T) {
+	assert.True(t, true)
+}
+`,
+		},
+		{
+			name:  "fragment",
+			tests: "func TestNewsList_Len(t *testing.T) {\n\tt.Log(\"len\")\n}\n",
+			want: `package news
+
+import (
+	"testing"
+)
+
+// TestNewsList_IDs checks IDs.
+func TestNewsList_IDs(t *testing.T) {
+	t.Log("ids")
+}
+
+func TestNewsList_Len(t *testing.T) {
+	t.Log("len")
+}
+`,
+		},
+		{
+			name:  "fragment with imports",
+			tests: "import (\n\tstdsort \"sort\"\n\t\"testing\"\n)\n\nfunc TestNewsList_Sort(t *testing.T) {\n\tstdsort.Ints(nil)\n}\n",
+			want: `package news
+
+import (
+	stdsort "sort"
+	"testing"
+)
+
+// TestNewsList_IDs checks IDs.
+func TestNewsList_IDs(t *testing.T) {
+	t.Log("ids")
+}
+
+func TestNewsList_Sort(t *testing.T) {
+	stdsort.Ints(nil)
+}
+`,
+		},
+		{name: "another package", tests: "package news_test\n\nfunc TestX(t *testing.T) {}\n", wantErr: ErrInvalidTests},
+		{name: "syntax error", tests: "func TestX(t *testing.T) {", wantErr: ErrInvalidTests},
+		{name: "not a go code", tests: "Looks good to me.", wantErr: ErrInvalidTests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendTests([]byte(existing), []byte(tt.tests))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	_, err := AppendTests([]byte("package"), []byte("func TestX(t *testing.T) {}"))
+	assert.Error(t, err)
+}
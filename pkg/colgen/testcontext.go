@@ -0,0 +1,75 @@
+package colgen
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrSkippedTestFile is returned for tests mode if file is generated or excluded by build tags.
+var ErrSkippedTestFile = errors.New("file is skipped for tests")
+
+// TestContextFiles returns go files from dir that can be used as context for tests mode:
+// test files, files excluded by build tags for current platform and generated files are skipped.
+// Generated files are included if withGenerated is set.
+func TestContextFiles(dir string, withGenerated bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") || strings.HasSuffix(e.Name(), "_test.go") {
+			continue
+		}
+
+		filename := filepath.Join(dir, e.Name())
+		ok, err := IsTestContextFile(filename, nil, withGenerated)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			files = append(files, filename)
+		}
+	}
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// IsTestContextFile checks that file matches build tags for current platform and is not generated (unless withGenerated is set).
+// If src is nil, file is read from filename.
+func IsTestContextFile(filename string, src []byte, withGenerated bool) (bool, error) {
+	var s any
+	bc := build.Default
+	if src != nil {
+		s = src
+		bc.OpenFile = func(string) (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(src)), nil }
+	}
+
+	ok, err := bc.MatchFile(filepath.Dir(filename), filepath.Base(filename))
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if withGenerated {
+		return true, nil
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), filename, s, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false, err
+	}
+
+	return !ast.IsGenerated(f), nil
+}
@@ -0,0 +1,38 @@
+package colgen
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestContextFiles(t *testing.T) {
+	dir := filepath.Join("testdata", "testcontext")
+
+	files, err := TestContextFiles(dir, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sum.go")}, files)
+
+	files, err = TestContextFiles(dir, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sum.go"), filepath.Join(dir, "sum_colgen.go")}, files)
+
+	_, err = TestContextFiles(filepath.Join(dir, "not-exists"), false)
+	require.Error(t, err)
+}
+
+func TestIsTestContextFile(t *testing.T) {
+	ok, err := IsTestContextFile("main.go", []byte("// Code generated by colgen; DO NOT EDIT.\n\npackage main\n"), false)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = IsTestContextFile("main.go", []byte("package main\n"), false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = IsTestContextFile("main.go", []byte("//go:build ignore\n\npackage main\n"), true)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,8 @@
+package buildtags
+
+//go:generate colgen
+//colgen:Common
+
+type Common struct {
+	ID int
+}
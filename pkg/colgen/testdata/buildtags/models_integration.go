@@ -0,0 +1,10 @@
+//go:build integration
+
+package buildtags
+
+//go:generate colgen
+//colgen:Fixture
+
+type Fixture struct {
+	ID int
+}
@@ -0,0 +1,8 @@
+package buildtags
+
+//go:generate colgen
+//colgen:Plan9
+
+type Plan9 struct {
+	ID int
+}
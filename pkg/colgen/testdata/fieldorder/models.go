@@ -0,0 +1,37 @@
+package fieldorder
+
+import "time"
+
+type Audit struct {
+	CreatedAt time.Time
+	UpdatedBy string
+}
+
+// User and UserMoved differ only by positions of unexported fields.
+type User struct {
+	ID int
+	Audit
+	Name  string
+	hash  string
+	Email string
+	salt  []byte
+}
+
+type UserMoved struct {
+	hash string
+	ID   int
+	salt []byte
+	Audit
+	Name  string
+	Email string
+}
+
+// UserShuffled has the same fields as User in another order.
+type UserShuffled struct {
+	Email string
+	Audit
+	salt []byte
+	Name string
+	hash string
+	ID   int
+}
@@ -0,0 +1,10 @@
+// Package generated is a fixture for directives targeting types from previously generated files.
+package generated
+
+//colgen:News
+//colgen:NewsList
+
+type News struct {
+	ID    int
+	Title string
+}
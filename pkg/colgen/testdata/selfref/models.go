@@ -0,0 +1,10 @@
+// Package selfref is a fixture for directive-like lines in generated and test files, which are not live directives.
+package selfref
+
+//go:generate colgen
+//colgen:Item
+
+type Item struct {
+	ID   int
+	Name string
+}
@@ -0,0 +1,6 @@
+package selfref
+
+// Example of directive in test generated by assistant:
+//colgen:Item:Index(Title)
+
+// TestItems is not compiled: testdata is skipped by go tooling.
@@ -0,0 +1,5 @@
+//go:build ignore
+
+package testcontext
+
+func ignored() {}
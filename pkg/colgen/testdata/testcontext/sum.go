@@ -0,0 +1,3 @@
+package testcontext
+
+func Sum(a, b int) int { return a + b }
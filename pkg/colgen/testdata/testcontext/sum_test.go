@@ -0,0 +1,5 @@
+package testcontext
+
+import "testing"
+
+func TestSum(t *testing.T) {}
@@ -0,0 +1,18 @@
+// Package typeerrors is a fixture for generation in package with type errors: NewsList is used before generation.
+package typeerrors
+
+type News struct {
+	ID    int
+	Title string
+}
+
+// Broken has field of undefined type, so it can't be generated.
+type Broken struct {
+	ID     int
+	Author *UndefinedAuthor
+}
+
+// newsIDs uses NewsList.IDs, which is declared in generated code.
+func newsIDs(ll NewsList) []int {
+	return ll.IDs()
+}
@@ -1,13 +1,20 @@
 package colgen
 
+import "time"
+
 // test types.
 type (
 	Category struct {
-		ID int
+		ID            int
+		ParentID      *int
+		SubCategories []Category
 	}
 
 	News struct {
-		ID int
+		ID         int
+		CategoryID int
+		Sections   [][]string
+		Views      uint
 	}
 
 	Tag struct {
@@ -15,4 +22,120 @@ type (
 		OrderNumber int64
 		Name        string
 	}
+
+	Item struct {
+		ID        int
+		Price     float64
+		Active    bool
+		CreatedAt time.Time
+		Tags      []string
+		Status    ItemStatus
+	}
+
+	ItemStatus string
+
+	Label struct {
+		ID    string
+		Title string
+	}
+
+	// Account embeds two bases: ID of Base is shadowed, CreatedAt is ambiguous.
+	Account struct {
+		Base
+		Audit
+		ID    int
+		Login string
+	}
+
+	Base struct {
+		ID        int
+		CreatedAt time.Time
+	}
+
+	Audit struct {
+		CreatedAt time.Time
+		UpdatedBy string
+	}
+
+	// Post references categories, tags and labels by IDs.
+	Post struct {
+		ID         int
+		CategoryID int
+		Category   *Category
+		TagIDs     []int
+		Tags       []Tag
+		LabelIDs   []string
+		Labels     []*Label
+		Author     *Author
+	}
+
+	// Author and Company are a pointer chain of Post: Post.Author.Company.Name.
+	Author struct {
+		Name    string
+		Company *Company
+		Profile Profile
+	}
+
+	Company struct {
+		Name      string
+		Rating    float64
+		Employees int
+		Status    ItemStatus
+		Founded   *time.Time
+		revenue   int
+	}
+
+	Profile struct {
+		Bio string
+	}
+
+	Stock struct {
+		Quantity    int
+		Labels      map[string]ItemStatus
+		Checksum    []byte
+		Tax         float64
+		WithTax     bool // field rule, not With rule for Tax
+		FlattenedAt time.Time
+		reserved    int
+		limits      map[string]int
+	}
+
+	// Glossary has fields with names of generated methods: IDs, Names of Name rule and Len.
+	Glossary struct {
+		ID    int
+		Name  string
+		Names []string
+		IDs   []int
+		Len   int
+	}
 )
+
+// MapP is a test converter for MapP rule, Map is not declared intentionally.
+func MapP[T, M any](a []T, f func(*T) *M) []*M {
+	r := make([]*M, 0, len(a))
+	for i := range a {
+		r = append(r, f(&a[i]))
+	}
+
+	return r
+}
+
+// Row is a test interface input of Map and MapP rules, e.g. MapP(Row).
+type Row interface {
+	Table() string
+}
+
+// NewLabel is a test constructor for MapP(Row) rule.
+func NewLabel(r *Row) *Label { return &Label{ID: (*r).Table()} }
+
+// NewAuthor is a test constructor accepting interface instead of pointer to it, it doesn't match MapP(Row).
+func NewAuthor(r Row) *Author { return &Author{Name: r.Table()} }
+
+// Slug is a test method for rules referencing methods, e.g. Index(Slug()).
+func (t Tag) Slug() string { return t.Name }
+
+// Level is a test pointer receiver method for rules referencing methods, e.g. Group(Level()).
+func (t *Tag) Level() int64 { return t.OrderNumber / 10 }
+
+// Rename is a test method with params, it can't be used by rules.
+func (t Tag) Rename(name string) Tag { t.Name = name; return t }
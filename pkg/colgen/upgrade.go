@@ -0,0 +1,91 @@
+package colgen
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// ErrInvalidUpgrade is returned by FormatUpgrade if upgraded file from assistant can't replace the source.
+var ErrInvalidUpgrade = errors.New("invalid upgraded file")
+
+// Upgrade suggests modernization of the provided Go code to current Go idioms.
+// Returns Markdown with unified diff and explanations or an error if the request fails.
+func (a *Assistant) Upgrade(code string) (string, error) {
+	return a.call(Code{SystemPrompt: a.system(systemPromptUpgrade) + upgradeAsDiff, Prompt: a.withMethods(code)})
+}
+
+// UpgradeFile modernizes the provided Go code to current Go idioms and returns full rewritten file.
+// Format of result is kept for custom system prompts. Use FormatUpgrade to validate the result before writing it.
+func (a *Assistant) UpgradeFile(code string) (string, error) {
+	return a.call(Code{SystemPrompt: a.system(systemPromptUpgrade) + upgradeAsFile, Prompt: a.withMethods(code)})
+}
+
+// FormatUpgrade validates upgraded file returned by UpgradeFile and returns it formatted with gofmt.
+// Markdown code fence is removed. Upgraded file must parse and have the same package name as original.
+func FormatUpgrade(original, upgraded []byte) ([]byte, error) {
+	src := []byte(trimCodeFence(string(upgraded)))
+
+	fset := token.NewFileSet()
+	orig, err := parser.ParseFile(fset, "", original, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, fmt.Errorf("parse original file: %w", err)
+	}
+
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUpgrade, err)
+	}
+
+	if f.Name.Name != orig.Name.Name {
+		return nil, fmt.Errorf("%w: package %s, want %s", ErrInvalidUpgrade, f.Name.Name, orig.Name.Name)
+	}
+
+	r, err := format.Source(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidUpgrade, err)
+	}
+
+	return r, nil
+}
+
+// trimCodeFence removes markdown code fence around code, e.g. ```go ... ```.
+func trimCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+
+	// drop opening fence with language and closing fence
+	if _, after, ok := strings.Cut(s, "\n"); ok {
+		s = after
+	}
+
+	return strings.TrimSuffix(strings.TrimSpace(s), "```")
+}
+
+const systemPromptUpgrade = `You are a professional Go developer.
+You write idiomatic go code for the latest Go version.
+` + basicLinks + `
+---
+I will give you one file from go project for modernization.
+Rewrite code to current Go idioms without changing its behaviour:
+- any instead of interface{}
+- slices and maps packages instead of hand-written loops and sort.Slice
+- range over int (for i := range n) and range over functions where it is simpler
+- min and max builtins, clear builtin
+- errors.Join, errors.Is and errors.As, fmt.Errorf with %w
+- strings.Cut, strings.CutPrefix and strings.CutSuffix
+Keep exported API, comments and formatting style. Do not touch generated code.
+`
+
+const upgradeAsDiff = `
+Return result in Markdown: unified diff of the file in a diff code block and a short list of applied changes.
+`
+
+const upgradeAsFile = `
+Return full rewritten file as go code without additional markdown comments.
+`
@@ -0,0 +1,57 @@
+package colgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssistant_Upgrade(t *testing.T) {
+	var last Code
+	r := NewAssistantRegistry()
+	r.Register("fake", func(string) Caller { return fakeCaller{answer: "ok", last: &last} })
+	a, err := r.New("fake", "")
+	require.NoError(t, err)
+	require.NoError(t, a.IsValidMode(ModeUpgrade))
+
+	_, err = a.Generate(ModeUpgrade, "package main")
+	require.NoError(t, err)
+	assert.Equal(t, "package main", last.Prompt)
+	assert.Equal(t, systemPromptUpgrade+upgradeAsDiff, last.SystemPrompt)
+
+	// format of result is kept for custom system prompt
+	a.SetSystemPrompt("custom")
+	_, err = a.UpgradeFile("package main")
+	require.NoError(t, err)
+	assert.Equal(t, "custom"+upgradeAsFile, last.SystemPrompt)
+}
+
+func TestFormatUpgrade(t *testing.T) {
+	original := []byte("package news\n\nfunc f(v interface{}) {}\n")
+	tests := []struct {
+		name     string
+		upgraded string
+		want     string
+		wantErr  error
+	}{
+		{name: "plain", upgraded: "package news\nfunc f(v any) {}", want: "package news\n\nfunc f(v any) {}\n"},
+		{name: "code fence", upgraded: "```go\npackage news\n\nfunc f(v any) {}\n```\n", want: "package news\n\nfunc f(v any) {}\n"},
+		{name: "syntax error", upgraded: "package news\n\nfunc f(v any) {", wantErr: ErrInvalidUpgrade},
+		{name: "another package", upgraded: "package main\n", wantErr: ErrInvalidUpgrade},
+		{name: "not a go code", upgraded: "Looks good to me.", wantErr: ErrInvalidUpgrade},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatUpgrade(original, []byte(tt.upgraded))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
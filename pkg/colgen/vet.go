@@ -0,0 +1,160 @@
+package colgen
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+)
+
+// Vet finding codes. Findings report likely mistakes in rules and don't fail generation.
+const (
+	VetNoID        = "CG001" // entity with base generation has no ID field and custom rules: only collection type is generated
+	VetUniqueID    = "CG002" // Unique rule on ID field, values of ID are unique already
+	VetBoolIndex   = "CG003" // Index on bool field keeps at most two elements
+	VetMissingFunc = "CG004" // Map or MapP constructor New<Entity> is not declared in the package
+
+	// NolintPrefix suppresses findings: `//colgen:nolint:CG001` for all entities of the file or inline
+	// `//colgen:News:UniqueID //colgen:nolint:CG002` for entities of the directive.
+	NolintPrefix = "nolint:"
+)
+
+// VetCodes are descriptions of vet findings by code.
+var VetCodes = map[string]string{
+	VetNoID:        "entity has no ID field and custom rules, only collection type is generated",
+	VetUniqueID:    "values of ID field are unique already",
+	VetBoolIndex:   "index by bool field keeps at most two elements",
+	VetMissingFunc: "constructor is not declared in the package",
+}
+
+// Finding is a likely mistake in rules of entity reported by Vet.
+type Finding struct {
+	Code    string
+	Entity  string
+	Message string
+}
+
+func (f Finding) String() string {
+	return f.Code + ": " + f.Entity + ": " + f.Message
+}
+
+// Nolint is a set of suppressed finding codes by entity name. Codes suppressed for all entities have empty name.
+type Nolint map[string][]string
+
+// Suppressed reports whether finding is suppressed for its entity or for all entities.
+func (n Nolint) Suppressed(f Finding) bool {
+	return slices.Contains(n[""], f.Code) || slices.Contains(n[f.Entity], f.Code)
+}
+
+// CutNolint cuts nolint directive of line without `//colgen:` prefix and returns the rest of line and suppressed codes:
+// `News:UniqueID //colgen:nolint:CG002` => `News:UniqueID`, [CG002]. Standalone `nolint:CG001` returns empty line.
+func CutNolint(line string) (string, []string) {
+	rest, codes, ok := strings.Cut(line, ColgenPrefix+NolintPrefix)
+	if !ok {
+		if codes, ok = strings.CutPrefix(strings.TrimSpace(line), NolintPrefix); !ok {
+			return line, nil
+		}
+		rest = ""
+	}
+
+	var r []string
+	for _, c := range strings.Split(codes, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			r = append(r, c)
+		}
+	}
+
+	return strings.TrimSpace(rest), r
+}
+
+// ParseNolint returns suppressed codes of directive lines without `//colgen:` prefix by entities.
+// Unknown codes are returned as ErrInvalidArg.
+func ParseNolint(lines []string) (Nolint, error) {
+	r := make(Nolint)
+	for _, line := range lines {
+		rest, codes := CutNolint(line)
+		if len(codes) == 0 {
+			continue
+		}
+
+		for _, c := range codes {
+			if _, ok := VetCodes[c]; !ok {
+				return nil, fmt.Errorf("%w: unknown nolint code %s", ErrInvalidArg, c)
+			}
+		}
+
+		if rest == "" {
+			r[""] = append(r[""], codes...)
+			continue
+		}
+
+		// entities of `News:UniqueID` or `News,Tag`
+		entities, _, _ := strings.Cut(rest, ":")
+		for _, e := range strings.Split(entities, ",") {
+			e = strings.TrimSpace(e)
+			r[e] = append(r[e], codes...)
+		}
+	}
+
+	return r, nil
+}
+
+// Vet reports likely mistakes in rules which don't fail generation, e.g. base generation for entity without ID.
+// Findings suppressed by nolint are skipped. Package must be loaded by UsePackageDir, missing entities are skipped.
+func (g *Generator) Vet(rules []Rule, nolint Nolint) ([]Finding, error) {
+	if g.pkg == nil {
+		return nil, fmt.Errorf("%w: package is not loaded", ErrLoadPackage)
+	}
+
+	var r []Finding
+	for _, rule := range rules {
+		t := g.lookupType(rule.EntityName)
+		fields := typeMapFromType(t, g.pkg.Types)
+		if t == nil || len(fields) == 0 {
+			continue
+		}
+
+		for _, f := range g.vetRule(rule, fields) {
+			if !nolint.Suppressed(f) {
+				r = append(r, f)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// vetRule returns findings of single rule with entity fields.
+func (g *Generator) vetRule(rule Rule, fields map[string]entityField) []Finding {
+	var r []Finding
+	add := func(code, format string, args ...any) {
+		r = append(r, Finding{Code: code, Entity: rule.EntityName, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if _, ok := fields[FieldID]; rule.BaseGen && !ok && len(rule.CustomRules) == 0 {
+		add(VetNoID, "%s, add custom rules or remove it from base entities", VetCodes[VetNoID])
+	}
+
+	for _, cr := range rule.CustomRules {
+		cr = resolvePrefixRule(cr, fields)
+		switch {
+		case (cr.Name == CustomRuleUnique || cr.Name == CustomRuleUniqueSorted || cr.Name == CustomRuleDistinct) && cr.Field == FieldID:
+			add(VetUniqueID, "%s(%s): %s, use IDs instead", cr.Name, cr.Field, VetCodes[VetUniqueID])
+		case cr.Name == CustomRuleIndex || cr.Name == CustomRuleByField:
+			if f, ok := fields[cr.Field]; ok && f.IsBool {
+				add(VetBoolIndex, "%s(%s): %s, use Group or Partition instead", cr.Name, cr.Field, VetCodes[VetBoolIndex])
+			}
+		case isMapP(cr.Name) && !strings.Contains(rule.EntityName, "."):
+			prefix := "New"
+			if cr.Name == strings.ToLower(cr.Name) {
+				prefix = "new"
+			}
+
+			if _, ok := g.lookupType(prefix + rule.EntityName).(*types.Func); !ok {
+				add(VetMissingFunc, "%s(%s): %s%s %s", cr.Name, cr.Arg, prefix, rule.EntityName, VetCodes[VetMissingFunc])
+			}
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,93 @@
+package colgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCutNolint(t *testing.T) {
+	tests := []struct {
+		line, rest string
+		codes      []string
+	}{
+		{line: "News:UniqueID", rest: "News:UniqueID"},
+		{line: "nolint:CG001", codes: []string{"CG001"}},
+		{line: " nolint:CG001, CG004", codes: []string{"CG001", "CG004"}},
+		{line: "News:UniqueID //colgen:nolint:CG002", rest: "News:UniqueID", codes: []string{"CG002"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			rest, codes := CutNolint(tt.line)
+			assert.Equal(t, tt.rest, rest)
+			assert.Equal(t, tt.codes, codes)
+		})
+	}
+}
+
+func TestParseNolint(t *testing.T) {
+	n, err := ParseNolint([]string{"News,Tag", "nolint:CG001", "Tag,Item //colgen:nolint:CG003", "Item:UniqueID //colgen:nolint:CG002"})
+	require.NoError(t, err)
+	assert.Equal(t, Nolint{"": {"CG001"}, "Tag": {"CG003"}, "Item": {"CG003", "CG002"}}, n)
+
+	assert.True(t, n.Suppressed(Finding{Code: VetNoID, Entity: "News"}))
+	assert.True(t, n.Suppressed(Finding{Code: VetUniqueID, Entity: "Item"}))
+	assert.False(t, n.Suppressed(Finding{Code: VetUniqueID, Entity: "Tag"}))
+
+	_, err = ParseNolint([]string{"nolint:CG999"})
+	assert.ErrorIs(t, err, ErrInvalidArg)
+
+	// nolint directives don't break rules
+	rules, err := ParseRules([]string{"Tag //colgen:nolint:CG003", "nolint:CG001", "Tag:Index(Name)"}, false)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "Tag", rules[0].EntityName)
+}
+
+func TestGenerator_Vet(t *testing.T) {
+	pkg, err := loadPackage(context.Background(), ".", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{name: "no ID", lines: []string{"Stock"}, want: []string{VetNoID}},
+		{name: "no ID with custom rules", lines: []string{"Stock", "Stock:Quantity"}},
+		{name: "unique ID", lines: []string{"Tag", "Tag:UniqueID,Distinct(ID),UniqueTagIDs?"}, want: []string{VetUniqueID, VetUniqueID}},
+		{name: "bool index", lines: []string{"Item", "Item:Index(Active),ByField(Active),Group(Active)"}, want: []string{VetBoolIndex, VetBoolIndex}},
+		{name: "missing constructor", lines: []string{"Tag", "Tag:MapP(db),mapp(db)"}, want: []string{VetMissingFunc, VetMissingFunc}},
+		{name: "existing constructor", lines: []string{"Entity:MapP(db)"}},
+		{name: "suppressed", lines: []string{"Stock", "nolint:CG001"}},
+		{name: "suppressed inline", lines: []string{"Tag", "Tag:UniqueID //colgen:nolint:CG002", "Item,Stock //colgen:nolint:CG001"}},
+		{name: "missing entity", lines: []string{"Missing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator("colgen", "", "", "devel")
+			g.pkg = pkg
+
+			rules, err := ParseRules(tt.lines, false)
+			require.NoError(t, err)
+			nolint, err := ParseNolint(tt.lines)
+			require.NoError(t, err)
+
+			findings, err := g.Vet(rules, nolint)
+			require.NoError(t, err)
+
+			var codes []string
+			for _, f := range findings {
+				codes = append(codes, f.Code)
+			}
+			assert.Equal(t, tt.want, codes)
+		})
+	}
+
+	_, err = NewGenerator("colgen", "", "", "devel").Vet(nil, nil)
+	assert.ErrorIs(t, err, ErrLoadPackage)
+}